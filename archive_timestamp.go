@@ -0,0 +1,99 @@
+package xades4go
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+const (
+	xades141NamespacePrefix = "xades141"
+	xades141NamespaceURI    = "http://uri.etsi.org/01903/v1.4.1#"
+
+	timeStampValidationDataElementTag = "TimeStampValidationData"
+)
+
+// addArchiveTimeStamp time-stamps the input of archive time-stamp of signatureElement (placed in doc) and appends the token as ArchiveTimeStamp element (XAdES 1.4.1 namespace) to UnsignedSignatureProperties element.
+// The new ArchiveTimeStamp covers every unsigned signature property present before it, including the earlier ArchiveTimeStamp elements it renews.
+func addArchiveTimeStamp(doc *etree.Document, signatureElement *etree.Element, signedInfoFactory SignedInfoFactory, uriResolver URIResolver, canonicalizationAlgorithm string, digestAlgorithm string, timeStampProvider TimeStampProvider) error {
+	qualifyingPropertiesElement, err := findQualifyingPropertiesElement(signatureElement)
+	if err != nil {
+		return err
+	}
+	unsignedSignaturePropertiesElement := unsignedSignaturePropertiesElementOf(qualifyingPropertiesElement)
+	xmlBytes, err := doc.WriteToBytes()
+	if err != nil {
+		return fmt.Errorf("cannot serialize document: %w", err)
+	}
+	input, err := archiveTimeStampInputOf(signedInfoFactory, uriResolver, xmlBytes, signatureElement, qualifyingPropertiesElement, nil, canonicalizationAlgorithm)
+	if err != nil {
+		return err
+	}
+	timeStampToken, err := obtainTimeStampToken(timeStampProvider, digestAlgorithm, input)
+	if err != nil {
+		return fmt.Errorf("cannot obtain ArchiveTimeStamp: %w", err)
+	}
+	timeStampID, err := generateID("xades-archivets")
+	if err != nil {
+		return err
+	}
+	archiveTimeStampElement := unsignedSignaturePropertiesElement.CreateElement(xades141NamespacePrefix + ":" + archiveTimeStampElementTag)
+	archiveTimeStampElement.CreateAttr("xmlns:"+xades141NamespacePrefix, xades141NamespaceURI)
+	fillTimeStampElement(archiveTimeStampElement, signatureElement, qualifyingPropertiesElement, timeStampID, canonicalizationAlgorithm, timeStampToken)
+	return nil
+}
+
+// archiveTimeStampInputOf returns the input of archiveTimeStampElement (ETSI EN 319 132-1 clause 5.5.2.2): the transformed data objects of every Reference of SignedInfo element,
+// followed by the canonicalized SignedInfo, SignatureValue and KeyInfo elements, the unsigned signature properties preceding archiveTimeStampElement and the Object elements other than the one containing qualifyingPropertiesElement.
+// If archiveTimeStampElement is nil, every unsigned signature property is taken, which is the input of a new ArchiveTimeStamp to be appended.
+func archiveTimeStampInputOf(signedInfoFactory SignedInfoFactory, uriResolver URIResolver, xmlBytes []byte, signatureElement *etree.Element, qualifyingPropertiesElement *etree.Element, archiveTimeStampElement *etree.Element, canonicalizationAlgorithm string) ([]byte, error) {
+	var input bytes.Buffer
+	signedInfoElement, err := mustFoundOnlyOneChildElement(signatureElement, signedInfoElementTag)
+	if err != nil {
+		return nil, err
+	}
+	referencedDataObjects, err := referencedDataObjectsOf(signedInfoFactory, uriResolver, xmlBytes, signedInfoElement, canonicalizationAlgorithm, true)
+	if err != nil {
+		return nil, err
+	}
+	input.Write(referencedDataObjects)
+
+	signatureValueElement, err := mustFoundOnlyOneChildElement(signatureElement, signatureValueElementTag)
+	if err != nil {
+		return nil, err
+	}
+	elementsToBeCanonicalized := []*etree.Element{signedInfoElement, signatureValueElement}
+	if keyInfoElement := signatureElement.SelectElement(keyInfoElementTag); keyInfoElement != nil {
+		elementsToBeCanonicalized = append(elementsToBeCanonicalized, keyInfoElement)
+	}
+	if unsignedSignaturePropertiesElement := qualifyingPropertiesElement.FindElement("./" + unsignedPropertiesElementTag + "/" + unsignedSignaturePropertiesElementTag); unsignedSignaturePropertiesElement != nil {
+		for _, propertyElement := range unsignedSignaturePropertiesElement.ChildElements() {
+			if propertyElement == archiveTimeStampElement {
+				break
+			}
+			elementsToBeCanonicalized = append(elementsToBeCanonicalized, propertyElement)
+		}
+	}
+	for _, objectElement := range signatureElement.SelectElements(objectElementTag) {
+		if qualifyingPropertiesElement.Parent() != objectElement {
+			elementsToBeCanonicalized = append(elementsToBeCanonicalized, objectElement)
+		}
+	}
+	canonicalizedElements, err := canonicalizeElementsInSignature(signedInfoFactory, xmlBytes, signatureElement, elementsToBeCanonicalized, canonicalizationAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	input.Write(canonicalizedElements)
+	return input.Bytes(), nil
+}
+
+// pathOfElementInSignature returns the path of element, a descendant of signatureElement, for Dereferencer.DereferenceByPath. Each step below signatureElement selects a child element by its position.
+func pathOfElementInSignature(signatureElement *etree.Element, element *etree.Element) string {
+	steps := make([]string, 0)
+	for current := element; current != signatureElement && current.Parent() != nil; current = current.Parent() {
+		steps = append([]string{fmt.Sprintf("*[%d]", positionOfElement(current, ""))}, steps...)
+	}
+	return strings.Join(append([]string{pathOfSignatureElement(signatureElement)}, steps...), "/")
+}
@@ -0,0 +1,230 @@
+package xades4go
+
+import (
+	"crypto/x509"
+	"fmt"
+
+	"github.com/beevik/etree"
+)
+
+const (
+	signatureProductionPlaceElementTag   = "SignatureProductionPlace"
+	signerRoleElementTag                 = "SignerRole"
+	completeCertificateRefsElementTag    = "CompleteCertificateRefs"
+	completeCertificateRefsV2ElementTag  = "CompleteCertificateRefsV2"
+	completeRevocationRefsElementTag     = "CompleteRevocationRefs"
+	attributeCertificateRefsElementTag   = "AttributeCertificateRefs"
+	attributeCertificateRefsV2ElementTag = "AttributeCertificateRefsV2"
+	attributeRevocationRefsElementTag    = "AttributeRevocationRefs"
+	sigAndRefsTimeStampElementTag        = "SigAndRefsTimeStamp"
+	sigAndRefsTimeStampV2ElementTag      = "SigAndRefsTimeStampV2"
+	refsOnlyTimeStampElementTag          = "RefsOnlyTimeStamp"
+	refsOnlyTimeStampV2ElementTag        = "RefsOnlyTimeStampV2"
+	certificateValuesElementTag          = "CertificateValues"
+	revocationValuesElementTag           = "RevocationValues"
+	archiveTimeStampElementTag           = "ArchiveTimeStamp"
+)
+
+// BaselineProfile is a level of XAdES baseline signatures of ETSI EN 319 132-1. Each level includes the requirements of the levels below it.
+type BaselineProfile int
+
+const (
+	// BaselineProfileB is XAdES-B-B level: the signature and its signed qualifying properties.
+	BaselineProfileB BaselineProfile = iota + 1
+	// BaselineProfileT is XAdES-B-T level: B-B with a SignatureTimeStamp as proof of existence.
+	BaselineProfileT
+	// BaselineProfileLT is XAdES-B-LT level: B-T with the certificate and revocation values needed for long-term validation.
+	BaselineProfileLT
+	// BaselineProfileLTA is XAdES-B-LTA level: B-LT with ArchiveTimeStamp.
+	BaselineProfileLTA
+)
+
+func (profile BaselineProfile) String() string {
+	switch profile {
+	case BaselineProfileB:
+		return "B-B"
+	case BaselineProfileT:
+		return "B-T"
+	case BaselineProfileLT:
+		return "B-LT"
+	case BaselineProfileLTA:
+		return "B-LTA"
+	}
+	return fmt.Sprintf("BaselineProfile(%d)", int(profile))
+}
+
+// BaselineProfileValidationResult is the result of checking a signature against the requirements of a baseline profile.
+type BaselineProfileValidationResult struct {
+	Profile BaselineProfile
+	// IsConformant is true if Violations is empty.
+	IsConformant bool
+	// Violations describes every requirement of Profile that the signature does not meet.
+	Violations []string
+}
+
+// baselineForbiddenSignatureAlgorithms and baselineForbiddenDigestAlgorithms are the algorithms that baseline signatures must not be created with (SHA-1 based, ETSI TS 119 312).
+var (
+	baselineForbiddenSignatureAlgorithms = map[string]bool{
+		RSASHA1SignatureAlgorithm:     true,
+		DSASHA1SignatureAlgorithm:     true,
+		ECDSASHA1SignatureAlgorithm:   true,
+		RSASHA1MGF1SignatureAlgorithm: true,
+		HMACSHA1SignatureAlgorithm:    true,
+	}
+	baselineForbiddenDigestAlgorithms = map[string]bool{
+		SHA1MessageDigestAlgorithm: true,
+	}
+)
+
+// baselineForbiddenSignedSignatureProperties and baselineForbiddenUnsignedSignatureProperties are the qualifying properties that shall not be present in baseline signatures at any level.
+var (
+	baselineForbiddenSignedSignatureProperties = []string{
+		signingCertificateElementTag,
+		signatureProductionPlaceElementTag,
+		signerRoleElementTag,
+	}
+	baselineForbiddenUnsignedSignatureProperties = []string{
+		completeCertificateRefsElementTag,
+		completeCertificateRefsV2ElementTag,
+		completeRevocationRefsElementTag,
+		attributeCertificateRefsElementTag,
+		attributeCertificateRefsV2ElementTag,
+		attributeRevocationRefsElementTag,
+		sigAndRefsTimeStampElementTag,
+		sigAndRefsTimeStampV2ElementTag,
+		refsOnlyTimeStampElementTag,
+		refsOnlyTimeStampV2ElementTag,
+	}
+)
+
+// checkBaselineProfileOfGenerator checks whether generator is configured to generate a signature of its baselineProfile.
+func checkBaselineProfileOfGenerator(generator *XAdESSignatureGenerator) error {
+	profile := generator.baselineProfile
+	switch profile {
+	case BaselineProfileB:
+		if generator.timeStampProvider != nil {
+			return fmt.Errorf("%s signature must not be time-stamped, use %s profile instead", profile, BaselineProfileT)
+		}
+	case BaselineProfileT:
+		if generator.timeStampProvider == nil {
+			return fmt.Errorf("%s signature requires TimeStampProvider", profile)
+		}
+	case BaselineProfileLT, BaselineProfileLTA:
+		return fmt.Errorf("%s signature cannot be generated directly, augment %s signature with XAdESSignatureAugmenter instead", profile, BaselineProfileT)
+	default:
+		return fmt.Errorf("%s is not a baseline profile", profile)
+	}
+	if baselineForbiddenSignatureAlgorithms[generator.xmldsigSignatureGenerator.signatureAlgorithm] {
+		return fmt.Errorf("%s must not be used by %s signature", generator.xmldsigSignatureGenerator.signatureAlgorithm, profile)
+	}
+	if baselineForbiddenDigestAlgorithms[generator.digestAlgorithm] {
+		return fmt.Errorf("%s must not be used by %s signature", generator.digestAlgorithm, profile)
+	}
+	return nil
+}
+
+// checkBaselineProfile returns the requirements of profile that signatureElement (whose QualifyingProperties element is qualifyingPropertiesElement) does not meet.
+// signerCertificate is the certificate in KeyInfo element that verified SignatureValue element, if any.
+func checkBaselineProfile(profile BaselineProfile, signatureElement *etree.Element, qualifyingPropertiesElement *etree.Element, signerCertificate *x509.Certificate) []string {
+	violations := make([]string, 0)
+	signedInfoElement := signatureElement.SelectElement(signedInfoElementTag)
+	if signatureMethodElement := signedInfoElement.SelectElement(signatureMethodElementTag); signatureMethodElement != nil {
+		if signatureAlgorithm := signatureMethodElement.SelectAttrValue(algorithmAttributeKey, ""); baselineForbiddenSignatureAlgorithms[signatureAlgorithm] {
+			violations = append(violations, fmt.Sprintf("SignatureMethod %s is not allowed", signatureAlgorithm))
+		}
+	}
+	objectReferences := map[string]bool{}
+	for _, dataObjectFormatElement := range qualifyingPropertiesElement.FindElements("./" + signedPropertiesElementTag + "/" + signedDataObjectPropertiesElementTag + "/" + dataObjectFormatElementTag) {
+		objectReferences[dataObjectFormatElement.SelectAttrValue(objectReferenceAttributeKey, "")] = true
+	}
+	for referenceIndex, referenceElement := range signedInfoElement.SelectElements(referenceElementTag) {
+		if digestMethodElement := referenceElement.SelectElement(digestMethodElementTag); digestMethodElement != nil {
+			if digestAlgorithm := digestMethodElement.SelectAttrValue(algorithmAttributeKey, ""); baselineForbiddenDigestAlgorithms[digestAlgorithm] {
+				violations = append(violations, fmt.Sprintf("DigestMethod %s of Reference#%d is not allowed", digestAlgorithm, referenceIndex))
+			}
+		}
+		if referenceType := referenceElement.SelectAttrValue(typeAttributeKey, ""); referenceType == SignedPropertiesReferenceType || referenceType == CountersignedSignatureReferenceType {
+			continue
+		}
+		referenceID := referenceElement.SelectAttrValue(idAttributeKey, "")
+		if referenceID == "" || !objectReferences["#"+referenceID] {
+			violations = append(violations, fmt.Sprintf("DataObjectFormat of Reference#%d is missing", referenceIndex))
+		}
+	}
+	if signerCertificate == nil {
+		violations = append(violations, "KeyInfo does not contain the signing certificate")
+	}
+
+	signedSignaturePropertiesElement := qualifyingPropertiesElement.FindElement("./" + signedPropertiesElementTag + "/" + signedSignaturePropertiesElementTag)
+	if signedSignaturePropertiesElement == nil {
+		signedSignaturePropertiesElement = etree.NewElement(signedSignaturePropertiesElementTag)
+	}
+	for _, requiredTag := range []string{signingTimeElementTag, signingCertificateV2ElementTag} {
+		if signedSignaturePropertiesElement.SelectElement(requiredTag) == nil {
+			violations = append(violations, requiredTag+" is missing")
+		}
+	}
+	for _, forbiddenTag := range baselineForbiddenSignedSignatureProperties {
+		if signedSignaturePropertiesElement.SelectElement(forbiddenTag) != nil {
+			violations = append(violations, forbiddenTag+" must not be present")
+		}
+	}
+
+	unsignedSignaturePropertiesElement := qualifyingPropertiesElement.FindElement("./" + unsignedPropertiesElementTag + "/" + unsignedSignaturePropertiesElementTag)
+	if unsignedSignaturePropertiesElement == nil {
+		unsignedSignaturePropertiesElement = etree.NewElement(unsignedSignaturePropertiesElementTag)
+	}
+	for _, forbiddenTag := range baselineForbiddenUnsignedSignatureProperties {
+		if unsignedSignaturePropertiesElement.SelectElement(forbiddenTag) != nil {
+			violations = append(violations, forbiddenTag+" must not be present")
+		}
+	}
+	requiredUnsignedSignatureProperties := map[BaselineProfile][]string{
+		BaselineProfileT:   {signatureTimeStampElementTag},
+		BaselineProfileLT:  longTermValidationPropertiesRequiredBy(signatureElement, qualifyingPropertiesElement, unsignedSignaturePropertiesElement),
+		BaselineProfileLTA: {archiveTimeStampElementTag},
+	}
+	for level := BaselineProfileT; level <= profile; level++ {
+		for _, requiredTag := range requiredUnsignedSignatureProperties[level] {
+			if unsignedSignaturePropertiesElement.SelectElement(requiredTag) == nil {
+				violations = append(violations, fmt.Sprintf("%s is missing (required since %s)", requiredTag, level))
+			}
+		}
+	}
+	return violations
+}
+
+// longTermValidationPropertiesRequiredBy returns which of CertificateValues and RevocationValues the signature needs for B-LT level.
+// They are only required if the validation material is not present elsewhere: CertificateValues if the certificates of KeyInfo element,
+// the time-stamp tokens and TimeStampValidationData elements do not chain up to self-signed certificates,
+// and RevocationValues if any of those certificates is not self-signed and no TimeStampValidationData element carries revocation values.
+func longTermValidationPropertiesRequiredBy(signatureElement *etree.Element, qualifyingPropertiesElement *etree.Element, unsignedSignaturePropertiesElement *etree.Element) []string {
+	// The properties are parsed leniently, since a malformed one is reported when the signature is validated.
+	certificates, _ := keyInfoCertificatesOf(signatureElement)
+	timeStampCertificates, _ := certificatesOfTimeStampElements(qualifyingPropertiesElement.FindElements(".//" + encapsulatedTimeStampElementTag))
+	certificates = append(certificates, timeStampCertificates...)
+	hasRevocationValues := false
+	for _, timeStampValidationDataElement := range unsignedSignaturePropertiesElement.SelectElements(timeStampValidationDataElementTag) {
+		for _, certificateValuesElement := range timeStampValidationDataElement.SelectElements(certificateValuesElementTag) {
+			timeStampValidationCertificates, _ := parseEncapsulatedX509Certificates(certificateValuesElement)
+			certificates = append(certificates, timeStampValidationCertificates...)
+		}
+		hasRevocationValues = hasRevocationValues || timeStampValidationDataElement.SelectElement(revocationValuesElementTag) != nil
+	}
+	isChainComplete, isEverySelfSigned := true, true
+	for _, certificate := range certificates {
+		if isSelfSigned(certificate) {
+			continue
+		}
+		isEverySelfSigned = false
+		isChainComplete = isChainComplete && findIssuerCertificate(certificates, certificate) != nil
+	}
+	requiredTags := make([]string, 0)
+	if !isChainComplete {
+		requiredTags = append(requiredTags, certificateValuesElementTag)
+	}
+	if !isEverySelfSigned && !hasRevocationValues {
+		requiredTags = append(requiredTags, revocationValuesElementTag)
+	}
+	return requiredTags
+}
@@ -0,0 +1,199 @@
+package xades4go_test
+
+import (
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mekpavit/xades4go"
+	"github.com/mekpavit/xades4go/etreeimpl"
+)
+
+func Test_NewXAdESSignatureGenerator_BaselineProfile(t *testing.T) {
+	signedInfoFactory := etreeimpl.NewSignedInfoFactory()
+	signerKey, signerCertificate := mustCreateRSAKeyAndSelfSignedCertificate(t)
+	authority, _ := mustCreateTimeStampAuthority(t, time.Now())
+	tests := []struct {
+		name    string
+		options []xades4go.XAdESSignatureGeneratorOption
+		wantErr bool
+	}{
+		{
+			name:    "When B-B profile is given without time-stamp provider, it should create the generator",
+			options: []xades4go.XAdESSignatureGeneratorOption{xades4go.GenerateWithBaselineProfile(xades4go.BaselineProfileB)},
+			wantErr: false,
+		},
+		{
+			name:    "When B-B profile is given with time-stamp provider, it should return error",
+			options: []xades4go.XAdESSignatureGeneratorOption{xades4go.GenerateWithBaselineProfile(xades4go.BaselineProfileB), xades4go.GenerateWithTimeStampProvider(authority)},
+			wantErr: true,
+		},
+		{
+			name:    "When B-T profile is given with time-stamp provider, it should create the generator",
+			options: []xades4go.XAdESSignatureGeneratorOption{xades4go.GenerateWithBaselineProfile(xades4go.BaselineProfileT), xades4go.GenerateWithTimeStampProvider(authority)},
+			wantErr: false,
+		},
+		{
+			name:    "When B-T profile is given without time-stamp provider, it should return error",
+			options: []xades4go.XAdESSignatureGeneratorOption{xades4go.GenerateWithBaselineProfile(xades4go.BaselineProfileT)},
+			wantErr: true,
+		},
+		{
+			name:    "When B-LT profile is given, it should return error",
+			options: []xades4go.XAdESSignatureGeneratorOption{xades4go.GenerateWithBaselineProfile(xades4go.BaselineProfileLT), xades4go.GenerateWithTimeStampProvider(authority)},
+			wantErr: true,
+		},
+		{
+			name:    "When SHA-1 digest algorithm is given with B-B profile, it should return error",
+			options: []xades4go.XAdESSignatureGeneratorOption{xades4go.GenerateWithBaselineProfile(xades4go.BaselineProfileB), xades4go.GenerateWithDigestAlgorithm(xades4go.SHA1MessageDigestAlgorithm)},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := xades4go.NewXAdESSignatureGenerator(signedInfoFactory, signerKey, []*x509.Certificate{signerCertificate}, xades4go.RSASHA256SignatureAlgorithm, tt.options...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewXAdESSignatureGenerator() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_XAdESSignatureValidator_BaselineProfile(t *testing.T) {
+	signedInfoFactory := etreeimpl.NewSignedInfoFactory()
+	signerKey, signerCertificate := mustCreateRSAKeyAndSelfSignedCertificate(t)
+	authority, _ := mustCreateTimeStampAuthority(t, time.Now())
+	rootKey, rootCertificate := mustIssueCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "xades4go test root CA"},
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, nil, nil)
+	issuedSignerKey, issuedSignerCertificate := mustIssueCertificate(t, &x509.Certificate{
+		Subject:  pkix.Name{CommonName: "xades4go test signer"},
+		KeyUsage: x509.KeyUsageDigitalSignature,
+	}, rootCertificate, rootKey)
+	mustSignWithKeyAndProfile := func(key crypto.Signer, certificateChain []*x509.Certificate, signatureAlgorithm string, options ...xades4go.XAdESSignatureGeneratorOption) []byte {
+		generator, err := xades4go.NewXAdESSignatureGenerator(signedInfoFactory, key, certificateChain, signatureAlgorithm, options...)
+		if err != nil {
+			t.Fatalf("NewXAdESSignatureGenerator() error = %v", err)
+		}
+		signedXMLBytes, err := generator.SignXMLBytes([]byte(unsignedInvoice), []xades4go.ReferenceGenerationDetail{
+			{
+				URIOfDataObjectBeingSigned: "",
+				TransformAlgorithms:        []string{xades4go.EnvelopedSignatureTransformAlgorithm},
+				DigestAlgorithm:            xades4go.SHA256MessageDigestAlgorithm,
+			},
+		})
+		if err != nil {
+			t.Fatalf("SignXMLBytes() error = %v", err)
+		}
+		return signedXMLBytes
+	}
+	mustSignWithProfile := func(options ...xades4go.XAdESSignatureGeneratorOption) []byte {
+		return mustSignWithKeyAndProfile(signerKey, []*x509.Certificate{signerCertificate}, xades4go.RSASHA256SignatureAlgorithm, options...)
+	}
+	baselineBSignature := mustSignWithProfile(xades4go.GenerateWithBaselineProfile(xades4go.BaselineProfileB))
+	baselineTSignature := mustSignWithProfile(xades4go.GenerateWithBaselineProfile(xades4go.BaselineProfileT), xades4go.GenerateWithTimeStampProvider(authority))
+	tests := []struct {
+		name     string
+		xmlBytes []byte
+		profile  xades4go.BaselineProfile
+		want     xades4go.BaselineProfileValidationResult
+	}{
+		{
+			name:     "When B-B signature is validated against B-B profile, it should be conformant",
+			xmlBytes: baselineBSignature,
+			profile:  xades4go.BaselineProfileB,
+			want:     xades4go.BaselineProfileValidationResult{Profile: xades4go.BaselineProfileB, IsConformant: true, Violations: []string{}},
+		},
+		{
+			name:     "When B-B signature is validated against B-T profile, SignatureTimeStamp should be reported missing",
+			xmlBytes: baselineBSignature,
+			profile:  xades4go.BaselineProfileT,
+			want: xades4go.BaselineProfileValidationResult{Profile: xades4go.BaselineProfileT, IsConformant: false, Violations: []string{
+				"SignatureTimeStamp is missing (required since B-T)",
+			}},
+		},
+		{
+			name:     "When B-T signature is validated against B-B profile, it should be conformant",
+			xmlBytes: baselineTSignature,
+			profile:  xades4go.BaselineProfileB,
+			want:     xades4go.BaselineProfileValidationResult{Profile: xades4go.BaselineProfileB, IsConformant: true, Violations: []string{}},
+		},
+		{
+			name:     "When B-T signature with only self-signed certificates is validated against B-LT profile, it should be conformant as no other validation material is needed",
+			xmlBytes: baselineTSignature,
+			profile:  xades4go.BaselineProfileLT,
+			want:     xades4go.BaselineProfileValidationResult{Profile: xades4go.BaselineProfileLT, IsConformant: true, Violations: []string{}},
+		},
+		{
+			name:     "When B-T signature with only self-signed certificates is validated against B-LTA profile, only ArchiveTimeStamp should be reported missing",
+			xmlBytes: baselineTSignature,
+			profile:  xades4go.BaselineProfileLTA,
+			want: xades4go.BaselineProfileValidationResult{Profile: xades4go.BaselineProfileLTA, IsConformant: false, Violations: []string{
+				"ArchiveTimeStamp is missing (required since B-LTA)",
+			}},
+		},
+		{
+			name: "When B-T signature whose KeyInfo lacks the issuer of the signer certificate is validated against B-LT profile, CertificateValues and RevocationValues should be reported missing",
+			xmlBytes: mustSignWithKeyAndProfile(issuedSignerKey, []*x509.Certificate{issuedSignerCertificate}, xades4go.ECDSASHA256SignatureAlgorithm,
+				xades4go.GenerateWithBaselineProfile(xades4go.BaselineProfileT), xades4go.GenerateWithTimeStampProvider(authority)),
+			profile: xades4go.BaselineProfileLT,
+			want: xades4go.BaselineProfileValidationResult{Profile: xades4go.BaselineProfileLT, IsConformant: false, Violations: []string{
+				"CertificateValues is missing (required since B-LT)",
+				"RevocationValues is missing (required since B-LT)",
+			}},
+		},
+		{
+			name: "When B-T signature whose KeyInfo carries the whole chain is validated against B-LT profile, only RevocationValues should be reported missing",
+			xmlBytes: mustSignWithKeyAndProfile(issuedSignerKey, []*x509.Certificate{issuedSignerCertificate, rootCertificate}, xades4go.ECDSASHA256SignatureAlgorithm,
+				xades4go.GenerateWithBaselineProfile(xades4go.BaselineProfileT), xades4go.GenerateWithTimeStampProvider(authority)),
+			profile: xades4go.BaselineProfileLT,
+			want: xades4go.BaselineProfileValidationResult{Profile: xades4go.BaselineProfileLT, IsConformant: false, Violations: []string{
+				"RevocationValues is missing (required since B-LT)",
+			}},
+		},
+		{
+			name:     "When a XAdES-BES signature with SigningCertificate is validated against B-B profile, it should not be conformant",
+			xmlBytes: []byte(etdaSignedTaxInvoice),
+			profile:  xades4go.BaselineProfileB,
+			want: xades4go.BaselineProfileValidationResult{Profile: xades4go.BaselineProfileB, IsConformant: false, Violations: []string{
+				"DataObjectFormat of Reference#0 is missing",
+				"SigningCertificateV2 is missing",
+				"SigningCertificate must not be present",
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := xades4go.NewXAdESSignatureValidator(signedInfoFactory, xades4go.ValidateWithBaselineProfile(tt.profile)).Validate(tt.xmlBytes)
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if diff := cmp.Diff(&tt.want, got.QualifyingPropertiesValidationResult.BaselineProfileValidationResult); diff != "" {
+				t.Errorf("Validate() BaselineProfileValidationResult mismatch (-want+got):\n%s", diff)
+			}
+			if !tt.want.IsConformant && got.QualifyingPropertiesValidationResult.IsValid {
+				t.Errorf("Validate() QualifyingPropertiesValidationResult.IsValid = true, want false")
+			}
+		})
+	}
+	t.Run("When B-T signature is validated with default options, it should be valid although the TSA certificate is not validated without TrustStore", func(t *testing.T) {
+		got, err := xades4go.NewXAdESSignatureValidator(signedInfoFactory).Validate(baselineTSignature)
+		if err != nil {
+			t.Fatalf("Validate() error = %v", err)
+		}
+		if !got.IsSignatureValid || !got.QualifyingPropertiesValidationResult.IsValid {
+			t.Errorf("Validate() IsSignatureValid = %v, QualifyingPropertiesValidationResult.IsValid = %v, want both true", got.IsSignatureValid, got.QualifyingPropertiesValidationResult.IsValid)
+		}
+		for _, timeStampValidationResult := range got.QualifyingPropertiesValidationResult.TimeStampValidationResults {
+			if !timeStampValidationResult.IsValid || timeStampValidationResult.IsTSACertificateTrusted {
+				t.Errorf("Validate() TimeStampValidationResult = %+v, want valid with the TSA certificate not trusted", timeStampValidationResult)
+			}
+		}
+	})
+}
@@ -0,0 +1,300 @@
+package xades4go
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+const (
+	certRefsElementTag          = "CertRefs"
+	crlRefsElementTag           = "CRLRefs"
+	crlRefElementTag            = "CRLRef"
+	ocspRefsElementTag          = "OCSPRefs"
+	ocspRefElementTag           = "OCSPRef"
+	digestAlgAndValueElementTag = "DigestAlgAndValue"
+	ocspIdentifierElementTag    = "OCSPIdentifier"
+	responderIDElementTag       = "ResponderID"
+	byNameElementTag            = "ByName"
+	byKeyElementTag             = "ByKey"
+	producedAtElementTag        = "ProducedAt"
+)
+
+// CompleteReferencesValidationResult is the result of validating CompleteCertificateRefs, CompleteCertificateRefsV2 and CompleteRevocationRefs elements (XAdES-C) of a signature.
+type CompleteReferencesValidationResult struct {
+	// IsValid is true if every reference of CertificateReferenceResults and RevocationReferenceResults is matched.
+	IsValid bool
+	// CertificateReferenceResults has one result per Cert element of CompleteCertificateRefs and CompleteCertificateRefsV2 elements, in document order.
+	CertificateReferenceResults []CompleteReferenceValidationResult
+	// RevocationReferenceResults has one result per CRLRef and OCSPRef element of CompleteRevocationRefs elements, CRLRef elements first.
+	RevocationReferenceResults []CompleteReferenceValidationResult
+}
+
+// CompleteReferenceValidationResult is the result of matching one reference to a certificate, CRL or OCSP response against the ones embedded in the signature or fetched to validate it.
+type CompleteReferenceValidationResult struct {
+	// Reference is the local name of the referencing element: Cert, CRLRef or OCSPRef.
+	Reference string
+	// IsMatched is true if a value matches the digest of the reference, and also IssuerSerial (or IssuerSerialV2 if present) for Cert element.
+	// OCSPRef element without DigestAlgAndValue element is matched by the responder and the production time of its OCSPIdentifier element.
+	IsMatched bool
+	// Value is the DER encoding of the matched certificate, CRL or OCSP response. It is nil if IsMatched is false.
+	Value []byte
+}
+
+// referableValidationDataOf returns the values that CompleteCertificateRefs(V2) and CompleteRevocationRefs elements of qualifyingPropertiesElement may refer to:
+// the certificates of KeyInfo element of signatureElement and of the time-stamp tokens, validationData embedded in the signature,
+// and the path and revocation data used to validate the signer certificate in certificateValidationResult (nil if not validated).
+func referableValidationDataOf(signatureElement *etree.Element, qualifyingPropertiesElement *etree.Element, validationData embeddedValidationData, certificateValidationResult *CertificateValidationResult) (embeddedValidationData, error) {
+	keyInfoCertificates, err := keyInfoCertificatesOf(signatureElement)
+	if err != nil {
+		return embeddedValidationData{}, err
+	}
+	timeStampCertificates, err := certificatesOfTimeStampElements(qualifyingPropertiesElement.FindElements(".//" + encapsulatedTimeStampElementTag))
+	if err != nil {
+		return embeddedValidationData{}, err
+	}
+	referable := embeddedValidationData{
+		certificates:  append(append(append([]*x509.Certificate{}, keyInfoCertificates...), timeStampCertificates...), validationData.certificates...),
+		crls:          append([][]byte{}, validationData.crls...),
+		ocspResponses: append([][]byte{}, validationData.ocspResponses...),
+	}
+	if certificateValidationResult != nil {
+		referable.certificates = append(referable.certificates, certificateValidationResult.Chain...)
+		for _, revocationResult := range certificateValidationResult.RevocationResults {
+			referable.crls = append(referable.crls, revocationResult.CRLs...)
+			if revocationResult.OCSPResponse != nil {
+				referable.ocspResponses = append(referable.ocspResponses, revocationResult.OCSPResponse)
+			}
+		}
+	}
+	return referable, nil
+}
+
+// validateCompleteReferences matches the references of CompleteCertificateRefs, CompleteCertificateRefsV2 and CompleteRevocationRefs elements in UnsignedSignatureProperties element of qualifyingPropertiesElement
+// against referable (see referableValidationDataOf). It returns nil if none of the elements is present.
+func validateCompleteReferences(qualifyingPropertiesElement *etree.Element, referable embeddedValidationData) (*CompleteReferencesValidationResult, error) {
+	completeCertificateRefsElements := append(findUnsignedSignaturePropertyElements(qualifyingPropertiesElement, completeCertificateRefsElementTag),
+		findUnsignedSignaturePropertyElements(qualifyingPropertiesElement, completeCertificateRefsV2ElementTag)...)
+	completeRevocationRefsElements := findUnsignedSignaturePropertyElements(qualifyingPropertiesElement, completeRevocationRefsElementTag)
+	if len(completeCertificateRefsElements) == 0 && len(completeRevocationRefsElements) == 0 {
+		return nil, nil
+	}
+	result := &CompleteReferencesValidationResult{}
+	for _, completeCertificateRefsElement := range completeCertificateRefsElements {
+		certRefsElement, err := mustFoundOnlyOneChildElement(completeCertificateRefsElement, certRefsElementTag)
+		if err != nil {
+			return nil, fmt.Errorf("at %s element: %w", completeCertificateRefsElement.Tag, err)
+		}
+		isV2 := completeCertificateRefsElement.Tag == completeCertificateRefsV2ElementTag
+		for certIndex, certElement := range certRefsElement.SelectElements(certElementTag) {
+			referenceResult, err := matchCertificateReference(certElement, isV2, referable.certificates)
+			if err != nil {
+				return nil, fmt.Errorf("at %s element: at Cert#%d element: %w", completeCertificateRefsElement.Tag, certIndex, err)
+			}
+			result.CertificateReferenceResults = append(result.CertificateReferenceResults, referenceResult)
+		}
+	}
+	for _, completeRevocationRefsElement := range completeRevocationRefsElements {
+		for crlRefIndex, crlRefElement := range completeRevocationRefsElement.FindElements("./" + crlRefsElementTag + "/" + crlRefElementTag) {
+			digestAlgAndValueElement, err := mustFoundOnlyOneChildElement(crlRefElement, digestAlgAndValueElementTag)
+			if err != nil {
+				return nil, fmt.Errorf("at CompleteRevocationRefs element: at CRLRef#%d element: %w", crlRefIndex, err)
+			}
+			matched, err := findValueByDigest(digestAlgAndValueElement, referable.crls)
+			if err != nil {
+				return nil, fmt.Errorf("at CompleteRevocationRefs element: at CRLRef#%d element: %w", crlRefIndex, err)
+			}
+			result.RevocationReferenceResults = append(result.RevocationReferenceResults, CompleteReferenceValidationResult{Reference: crlRefElementTag, IsMatched: matched != nil, Value: matched})
+		}
+		for ocspRefIndex, ocspRefElement := range completeRevocationRefsElement.FindElements("./" + ocspRefsElementTag + "/" + ocspRefElementTag) {
+			matched, err := matchOCSPReference(ocspRefElement, referable.ocspResponses)
+			if err != nil {
+				return nil, fmt.Errorf("at CompleteRevocationRefs element: at OCSPRef#%d element: %w", ocspRefIndex, err)
+			}
+			result.RevocationReferenceResults = append(result.RevocationReferenceResults, CompleteReferenceValidationResult{Reference: ocspRefElementTag, IsMatched: matched != nil, Value: matched})
+		}
+	}
+	result.IsValid = true
+	for _, referenceResult := range append(append([]CompleteReferenceValidationResult{}, result.CertificateReferenceResults...), result.RevocationReferenceResults...) {
+		result.IsValid = result.IsValid && referenceResult.IsMatched
+	}
+	return result, nil
+}
+
+// matchCertificateReference finds the certificate of certificates that certElement (of CompleteCertificateRefsV2 element if isV2, of CompleteCertificateRefs element otherwise) refers to.
+func matchCertificateReference(certElement *etree.Element, isV2 bool, certificates []*x509.Certificate) (CompleteReferenceValidationResult, error) {
+	result := CompleteReferenceValidationResult{Reference: certElement.Tag}
+	for _, certificate := range certificates {
+		isMatched, err := isCertDigestMatched(certElement, certificate)
+		if err != nil {
+			return CompleteReferenceValidationResult{}, err
+		}
+		if !isMatched {
+			continue
+		}
+		if (isV2 && isIssuerSerialV2Matched(certElement, certificate)) || (!isV2 && isIssuerSerialMatched(certElement, certificate)) {
+			result.IsMatched = true
+			result.Value = certificate.Raw
+			break
+		}
+	}
+	return result, nil
+}
+
+// matchOCSPReference finds the OCSP response of ocspResponses that ocspRefElement refers to, by its DigestAlgAndValue element if present or by its OCSPIdentifier element otherwise.
+// It returns nil if none matches.
+func matchOCSPReference(ocspRefElement *etree.Element, ocspResponses [][]byte) ([]byte, error) {
+	digestAlgAndValueElement, err := mustFoundOnlyOneIfFound(ocspRefElement, digestAlgAndValueElementTag)
+	if err != nil {
+		return nil, err
+	}
+	if digestAlgAndValueElement != nil {
+		return findValueByDigest(digestAlgAndValueElement, ocspResponses)
+	}
+	ocspIdentifierElement, err := mustFoundOnlyOneChildElement(ocspRefElement, ocspIdentifierElementTag)
+	if err != nil {
+		return nil, err
+	}
+	producedAtElement, err := mustFoundOnlyOneChildElement(ocspIdentifierElement, producedAtElementTag)
+	if err != nil {
+		return nil, err
+	}
+	producedAt, err := parseXSDDateTime(producedAtElement.Text())
+	if err != nil {
+		return nil, fmt.Errorf("ProducedAt is not a valid xsd:dateTime: %w", err)
+	}
+	responderIDElement, err := mustFoundOnlyOneChildElement(ocspIdentifierElement, responderIDElementTag)
+	if err != nil {
+		return nil, err
+	}
+	for _, ocspResponse := range ocspResponses {
+		basicResponse, err := parseBasicOCSPResponse(ocspResponse)
+		if err != nil || !basicResponse.TBSResponseData.ProducedAt.Equal(producedAt) {
+			continue
+		}
+		if isResponderIDElementOf(responderIDElement, basicResponse.TBSResponseData.ResponderID) {
+			return ocspResponse, nil
+		}
+	}
+	return nil, nil
+}
+
+// isResponderIDElementOf reports whether ResponderID element, which carries ByName or ByKey element, identifies the same responder as responderID of an OCSP response.
+func isResponderIDElementOf(responderIDElement *etree.Element, responderID asn1.RawValue) bool {
+	if responderID.Class != asn1.ClassContextSpecific {
+		return false
+	}
+	if byNameElement := responderIDElement.SelectElement(byNameElementTag); byNameElement != nil {
+		return responderID.Tag == ocspResponderIDByNameTag && isSameDistinguishedName(byNameElement.Text(), responderID.Bytes)
+	}
+	if byKeyElement := responderIDElement.SelectElement(byKeyElementTag); byKeyElement != nil {
+		keyHash, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(byKeyElement.Text()), ""))
+		if err != nil || responderID.Tag != ocspResponderIDByKeyTag {
+			return false
+		}
+		var responderKeyHash []byte
+		if _, err := asn1.Unmarshal(responderID.Bytes, &responderKeyHash); err != nil {
+			return false
+		}
+		return bytes.Equal(keyHash, responderKeyHash)
+	}
+	return false
+}
+
+// findValueByDigest returns the value of values whose digest is the one of digestAlgAndValueElement (of XAdES DigestAlgAndValueType), or nil if there is none.
+func findValueByDigest(digestAlgAndValueElement *etree.Element, values [][]byte) ([]byte, error) {
+	digestMethodElement, err := mustFoundOnlyOneChildElement(digestAlgAndValueElement, digestMethodElementTag)
+	if err != nil {
+		return nil, err
+	}
+	algorithmAttribute, err := mustFoundAttribute(digestMethodElement, algorithmAttributeKey)
+	if err != nil {
+		return nil, err
+	}
+	digestValueElement, err := mustFoundOnlyOneChildElement(digestAlgAndValueElement, digestValueElementTag)
+	if err != nil {
+		return nil, err
+	}
+	digester, err := CreateDigester(algorithmAttribute.Value)
+	if err != nil {
+		return nil, err
+	}
+	digestValue := strings.Join(strings.Fields(digestValueElement.Text()), "")
+	for _, value := range values {
+		generatedDigestValue, err := digester.Digest(value)
+		if err != nil {
+			return nil, err
+		}
+		if string(generatedDigestValue) == digestValue {
+			return value, nil
+		}
+	}
+	return nil, nil
+}
+
+// sigAndRefsTimeStampInputOf returns the input of timeStampElement, SigAndRefsTimeStamp or SigAndRefsTimeStampV2 element of qualifyingPropertiesElement (XAdES 1.3.2 clause 7.5.1, explicit mode):
+// the canonicalized SignatureValue element of signatureElement followed by SignatureTimeStamp and the reference properties preceding timeStampElement, in their order.
+func sigAndRefsTimeStampInputOf(signedInfoFactory SignedInfoFactory, xmlBytes []byte, signatureElement *etree.Element, qualifyingPropertiesElement *etree.Element, timeStampElement *etree.Element, canonicalizationAlgorithm string) ([]byte, error) {
+	signatureValueElement, err := mustFoundOnlyOneChildElement(signatureElement, signatureValueElementTag)
+	if err != nil {
+		return nil, err
+	}
+	elementsToBeCanonicalized := append([]*etree.Element{signatureValueElement}, precedingUnsignedSignaturePropertyElements(qualifyingPropertiesElement, timeStampElement, append([]string{signatureTimeStampElementTag}, referencePropertyTags...))...)
+	return canonicalizeElementsInSignature(signedInfoFactory, xmlBytes, signatureElement, elementsToBeCanonicalized, canonicalizationAlgorithm)
+}
+
+// refsOnlyTimeStampInputOf returns the input of timeStampElement, RefsOnlyTimeStamp or RefsOnlyTimeStampV2 element of qualifyingPropertiesElement (XAdES 1.3.2 clause 7.5.2):
+// the canonicalized reference properties preceding timeStampElement, in their order.
+func refsOnlyTimeStampInputOf(signedInfoFactory SignedInfoFactory, xmlBytes []byte, signatureElement *etree.Element, qualifyingPropertiesElement *etree.Element, timeStampElement *etree.Element, canonicalizationAlgorithm string) ([]byte, error) {
+	elementsToBeCanonicalized := precedingUnsignedSignaturePropertyElements(qualifyingPropertiesElement, timeStampElement, referencePropertyTags)
+	return canonicalizeElementsInSignature(signedInfoFactory, xmlBytes, signatureElement, elementsToBeCanonicalized, canonicalizationAlgorithm)
+}
+
+// referencePropertyTags are the local names of the unsigned signature properties covered by SigAndRefsTimeStamp and RefsOnlyTimeStamp.
+var referencePropertyTags = []string{
+	completeCertificateRefsElementTag,
+	completeCertificateRefsV2ElementTag,
+	completeRevocationRefsElementTag,
+	attributeCertificateRefsElementTag,
+	attributeCertificateRefsV2ElementTag,
+	attributeRevocationRefsElementTag,
+}
+
+// precedingUnsignedSignaturePropertyElements returns the elements of UnsignedSignatureProperties element of qualifyingPropertiesElement that precede element and have one of tags as local name.
+func precedingUnsignedSignaturePropertyElements(qualifyingPropertiesElement *etree.Element, element *etree.Element, tags []string) []*etree.Element {
+	elements := make([]*etree.Element, 0)
+	unsignedSignaturePropertiesElement := qualifyingPropertiesElement.FindElement("./" + unsignedPropertiesElementTag + "/" + unsignedSignaturePropertiesElementTag)
+	if unsignedSignaturePropertiesElement == nil {
+		return elements
+	}
+	for _, propertyElement := range unsignedSignaturePropertiesElement.ChildElements() {
+		if propertyElement == element {
+			break
+		}
+		for _, tag := range tags {
+			if propertyElement.Tag == tag {
+				elements = append(elements, propertyElement)
+				break
+			}
+		}
+	}
+	return elements
+}
+
+// canonicalizeElementsInSignature returns the concatenation of elements, descendants of signatureElement (parsed from xmlBytes), canonicalized with canonicalizationAlgorithm.
+func canonicalizeElementsInSignature(signedInfoFactory SignedInfoFactory, xmlBytes []byte, signatureElement *etree.Element, elements []*etree.Element, canonicalizationAlgorithm string) ([]byte, error) {
+	var input bytes.Buffer
+	for _, element := range elements {
+		canonicalizedElement, err := canonicalizeElementByPath(signedInfoFactory, xmlBytes, pathOfElementInSignature(signatureElement, element), canonicalizationAlgorithm)
+		if err != nil {
+			return nil, err
+		}
+		input.Write(canonicalizedElement)
+	}
+	return input.Bytes(), nil
+}
@@ -0,0 +1,325 @@
+package xades4go_test
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/google/go-cmp/cmp"
+	"github.com/mekpavit/xades4go"
+	"github.com/mekpavit/xades4go/etreeimpl"
+)
+
+type testCompleteReferences struct {
+	isV2         bool
+	certificates []*x509.Certificate
+	crls         [][]byte
+	ocspRefs     []testOCSPRef
+}
+
+type testOCSPRef struct {
+	responderName string
+	producedAt    time.Time
+	// ocspResponse is digested in DigestAlgAndValue element. OCSPRef element has only OCSPIdentifier element if it is nil.
+	ocspResponse []byte
+}
+
+func Test_XAdESSignatureValidator_CompleteReferences(t *testing.T) {
+	signedInfoFactory := etreeimpl.NewSignedInfoFactory()
+	now := time.Now().Truncate(time.Second)
+	rootKey, rootCertificate := mustIssueCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "xades4go test root CA"},
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, nil, nil)
+	intermediateKey, intermediateCertificate := mustIssueCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "xades4go test intermediate CA"},
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		CRLDistributionPoints: []string{testRootCRLURL},
+	}, rootCertificate, rootKey)
+	signerKey, signerCertificate := mustIssueCertificate(t, &x509.Certificate{
+		Subject:    pkix.Name{CommonName: "xades4go test signer"},
+		KeyUsage:   x509.KeyUsageDigitalSignature,
+		OCSPServer: []string{testSignerOCSPURL},
+	}, intermediateCertificate, intermediateKey)
+	responder, err := xades4go.NewInProcessOCSPResponder(intermediateKey, intermediateCertificate, intermediateCertificate, func() time.Time { return now })
+	if err != nil {
+		t.Fatalf("NewInProcessOCSPResponder() error = %v", err)
+	}
+	rootCRL := mustCreateCRL(t, rootCertificate, rootKey, 1, now, nil, nil)
+	otherRootCRL := mustCreateCRL(t, rootCertificate, rootKey, 2, now, nil, nil)
+	crlFetcher := xades4go.NewMapCRLFetcher(map[string][]byte{testRootCRLURL: rootCRL})
+	crls, ocspResponses, err := xades4go.NewRevocationDataProvider(responder, crlFetcher).RevocationData([]*x509.Certificate{signerCertificate, intermediateCertificate, rootCertificate})
+	if err != nil {
+		t.Fatalf("RevocationData() error = %v", err)
+	}
+	authority, tsaCertificate := mustCreateTimeStampAuthority(t, now)
+	tsaTrustStore := xades4go.ValidateWithTrustStore(xades4go.NewTrustStore([]*x509.Certificate{tsaCertificate}, nil))
+	generator, err := xades4go.NewXAdESSignatureGenerator(signedInfoFactory, signerKey, []*x509.Certificate{signerCertificate}, xades4go.ECDSASHA256SignatureAlgorithm,
+		xades4go.GenerateWithTimeStampProvider(authority))
+	if err != nil {
+		t.Fatalf("NewXAdESSignatureGenerator() error = %v", err)
+	}
+	signedXMLBytes, err := generator.SignXMLBytes([]byte(unsignedInvoice), []xades4go.ReferenceGenerationDetail{
+		{
+			URIOfDataObjectBeingSigned: "",
+			TransformAlgorithms:        []string{xades4go.EnvelopedSignatureTransformAlgorithm},
+			DigestAlgorithm:            xades4go.SHA256MessageDigestAlgorithm,
+		},
+	})
+	if err != nil {
+		t.Fatalf("SignXMLBytes() error = %v", err)
+	}
+	mustAddValues := func(xmlBytes []byte) []byte {
+		augmenter := xades4go.NewXAdESSignatureAugmenter(signedInfoFactory,
+			xades4go.AugmentWithCertificates(intermediateCertificate, rootCertificate),
+			xades4go.AugmentWithRevocationDataProvider(&fakeRevocationDataProvider{crls: crls, ocspResponses: ocspResponses}),
+		)
+		augmentedXMLBytes, err := augmenter.Augment(xmlBytes, xades4go.BaselineProfileLT)
+		if err != nil {
+			t.Fatalf("Augment() error = %v", err)
+		}
+		return augmentedXMLBytes
+	}
+	xlSignature := mustAddValues(mustAddCompleteReferences(t, signedInfoFactory, signedXMLBytes, testCompleteReferences{
+		certificates: []*x509.Certificate{intermediateCertificate, rootCertificate},
+		crls:         crls,
+		ocspRefs:     []testOCSPRef{{responderName: intermediateCertificate.Subject.String(), producedAt: now, ocspResponse: ocspResponses[0]}},
+	}, authority))
+	xSignature := mustAddCompleteReferences(t, signedInfoFactory, signedXMLBytes, testCompleteReferences{
+		isV2:         true,
+		certificates: []*x509.Certificate{intermediateCertificate, rootCertificate},
+		crls:         crls,
+		ocspRefs:     []testOCSPRef{{responderName: intermediateCertificate.Subject.String(), producedAt: now}},
+	}, authority)
+	cSignatureWithUnknownCRL := mustAddValues(mustAddCompleteReferences(t, signedInfoFactory, signedXMLBytes, testCompleteReferences{
+		certificates: []*x509.Certificate{intermediateCertificate, rootCertificate},
+		crls:         [][]byte{otherRootCRL},
+		ocspRefs:     []testOCSPRef{{responderName: intermediateCertificate.Subject.String(), producedAt: now, ocspResponse: ocspResponses[0]}},
+	}, nil))
+	alteredXLSignature := mustRemoveElement(t, xlSignature, "//CompleteCertificateRefs/CertRefs/Cert[2]")
+
+	tests := []struct {
+		name                         string
+		xmlBytes                     []byte
+		options                      []xades4go.XMLDSigSignatureValidatorOption
+		wantIsValid                  bool
+		wantIsCompleteReferenceValid bool
+		wantReferences               []string
+		wantTimeStamps               []string
+	}{
+		{
+			name:                         "When XAdES-X-L signature references its certificate and revocation values, it should be valid",
+			xmlBytes:                     xlSignature,
+			options:                      []xades4go.XMLDSigSignatureValidatorOption{tsaTrustStore},
+			wantIsValid:                  true,
+			wantIsCompleteReferenceValid: true,
+			wantReferences:               []string{"Cert=true", "Cert=true", "CRLRef=true", "OCSPRef=true"},
+			wantTimeStamps:               []string{"SignatureTimeStamp=true", "SigAndRefsTimeStamp=true", "RefsOnlyTimeStamp=true"},
+		},
+		{
+			name:     "When XAdES-X signature references the OCSP response by OCSPIdentifier, it should match the fetched values",
+			xmlBytes: xSignature,
+			options: []xades4go.XMLDSigSignatureValidatorOption{
+				xades4go.ValidateWithTrustStore(xades4go.NewTrustStore([]*x509.Certificate{rootCertificate, tsaCertificate}, []*x509.Certificate{intermediateCertificate})),
+				xades4go.ValidateWithOCSPFetcher(responder),
+				xades4go.ValidateWithCRLFetcher(crlFetcher),
+			},
+			wantIsValid:                  true,
+			wantIsCompleteReferenceValid: true,
+			wantReferences:               []string{"Cert=true", "Cert=true", "CRLRef=true", "OCSPRef=true"},
+			wantTimeStamps:               []string{"SignatureTimeStamp=true", "SigAndRefsTimeStampV2=true", "RefsOnlyTimeStampV2=true"},
+		},
+		{
+			name:                         "When XAdES-X signature is validated without fetching, it should not match the certificates and revocation data",
+			xmlBytes:                     xSignature,
+			options:                      []xades4go.XMLDSigSignatureValidatorOption{tsaTrustStore},
+			wantIsValid:                  false,
+			wantIsCompleteReferenceValid: false,
+			wantReferences:               []string{"Cert=false", "Cert=false", "CRLRef=false", "OCSPRef=false"},
+			wantTimeStamps:               []string{"SignatureTimeStamp=true", "SigAndRefsTimeStampV2=true", "RefsOnlyTimeStampV2=true"},
+		},
+		{
+			name:                         "When CRLRef does not reference an embedded CRL, it should be invalid",
+			xmlBytes:                     cSignatureWithUnknownCRL,
+			options:                      []xades4go.XMLDSigSignatureValidatorOption{tsaTrustStore},
+			wantIsValid:                  false,
+			wantIsCompleteReferenceValid: false,
+			wantReferences:               []string{"Cert=true", "Cert=true", "CRLRef=false", "OCSPRef=true"},
+			wantTimeStamps:               []string{"SignatureTimeStamp=true"},
+		},
+		{
+			name:                         "When CompleteCertificateRefs is altered after being time-stamped, SigAndRefsTimeStamp and RefsOnlyTimeStamp should be invalid",
+			xmlBytes:                     alteredXLSignature,
+			options:                      []xades4go.XMLDSigSignatureValidatorOption{tsaTrustStore},
+			wantIsValid:                  false,
+			wantIsCompleteReferenceValid: true,
+			wantReferences:               []string{"Cert=true", "CRLRef=true", "OCSPRef=true"},
+			wantTimeStamps:               []string{"SignatureTimeStamp=true", "SigAndRefsTimeStamp=false", "RefsOnlyTimeStamp=false"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator := xades4go.NewXAdESSignatureValidator(signedInfoFactory, xades4go.ValidateWithXMLDSigOptions(tt.options...))
+			got, err := validator.Validate(tt.xmlBytes)
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			qualifyingPropertiesValidationResult := got.QualifyingPropertiesValidationResult
+			if qualifyingPropertiesValidationResult.IsValid != tt.wantIsValid {
+				t.Errorf("Validate() QualifyingPropertiesValidationResult.IsValid = %v, want %v", qualifyingPropertiesValidationResult.IsValid, tt.wantIsValid)
+			}
+			completeReferencesValidationResult := qualifyingPropertiesValidationResult.CompleteReferencesValidationResult
+			if completeReferencesValidationResult == nil {
+				t.Fatalf("Validate() CompleteReferencesValidationResult = nil")
+			}
+			if completeReferencesValidationResult.IsValid != tt.wantIsCompleteReferenceValid {
+				t.Errorf("Validate() CompleteReferencesValidationResult.IsValid = %v, want %v", completeReferencesValidationResult.IsValid, tt.wantIsCompleteReferenceValid)
+			}
+			var gotReferences []string
+			for _, referenceResult := range append(completeReferencesValidationResult.CertificateReferenceResults, completeReferencesValidationResult.RevocationReferenceResults...) {
+				gotReferences = append(gotReferences, fmt.Sprintf("%s=%v", referenceResult.Reference, referenceResult.IsMatched))
+				if referenceResult.IsMatched != (referenceResult.Value != nil) {
+					t.Errorf("Validate() %s reference has IsMatched = %v but Value = %v", referenceResult.Reference, referenceResult.IsMatched, referenceResult.Value)
+				}
+			}
+			if diff := cmp.Diff(tt.wantReferences, gotReferences); diff != "" {
+				t.Errorf("Validate() references mismatch (-want+got):\n%s", diff)
+			}
+			var gotTimeStamps []string
+			for _, timeStampValidationResult := range qualifyingPropertiesValidationResult.TimeStampValidationResults {
+				gotTimeStamps = append(gotTimeStamps, fmt.Sprintf("%s=%v", timeStampValidationResult.Property, timeStampValidationResult.IsValid))
+			}
+			if diff := cmp.Diff(tt.wantTimeStamps, gotTimeStamps); diff != "" {
+				t.Errorf("Validate() time-stamps mismatch (-want+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// mustAddCompleteReferences appends CompleteCertificateRefs(V2) and CompleteRevocationRefs elements of references to the signature of xmlBytes,
+// followed by SigAndRefsTimeStamp(V2) and RefsOnlyTimeStamp(V2) elements time-stamped by authority unless it is nil.
+func mustAddCompleteReferences(t *testing.T, signedInfoFactory xades4go.SignedInfoFactory, xmlBytes []byte, references testCompleteReferences, authority *xades4go.InProcessTimeStampAuthority) []byte {
+	t.Helper()
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(xmlBytes); err != nil {
+		t.Fatalf("cannot parse XML: %v", err)
+	}
+	unsignedSignaturePropertiesElement := doc.FindElement("//UnsignedSignatureProperties")
+	if unsignedSignaturePropertiesElement == nil {
+		t.Fatalf("UnsignedSignatureProperties element is not found")
+	}
+	createPropertyElement := func(tag string) *etree.Element {
+		if !references.isV2 {
+			return unsignedSignaturePropertiesElement.CreateElement("xades:" + tag)
+		}
+		propertyElement := unsignedSignaturePropertiesElement.CreateElement("xades141:" + tag + "V2")
+		propertyElement.CreateAttr("xmlns:xades141", "http://uri.etsi.org/01903/v1.4.1#")
+		return propertyElement
+	}
+	createDigestElements := func(parent *etree.Element, value []byte) {
+		digest := sha256.Sum256(value)
+		parent.CreateElement("ds:DigestMethod").CreateAttr("Algorithm", xades4go.SHA256MessageDigestAlgorithm)
+		parent.CreateElement("ds:DigestValue").SetText(base64.StdEncoding.EncodeToString(digest[:]))
+	}
+
+	certRefsElement := createPropertyElement("CompleteCertificateRefs").CreateElement("xades:CertRefs")
+	for _, certificate := range references.certificates {
+		certElement := certRefsElement.CreateElement("xades:Cert")
+		createDigestElements(certElement.CreateElement("xades:CertDigest"), certificate.Raw)
+		if !references.isV2 {
+			issuerSerialElement := certElement.CreateElement("xades:IssuerSerial")
+			issuerSerialElement.CreateElement("ds:X509IssuerName").SetText(certificate.Issuer.String())
+			issuerSerialElement.CreateElement("ds:X509SerialNumber").SetText(certificate.SerialNumber.String())
+		}
+	}
+	completeRevocationRefsElement := unsignedSignaturePropertiesElement.CreateElement("xades:CompleteRevocationRefs")
+	crlRefsElement := completeRevocationRefsElement.CreateElement("xades:CRLRefs")
+	for _, crl := range references.crls {
+		createDigestElements(crlRefsElement.CreateElement("xades:CRLRef").CreateElement("xades:DigestAlgAndValue"), crl)
+	}
+	ocspRefsElement := completeRevocationRefsElement.CreateElement("xades:OCSPRefs")
+	for _, ocspRef := range references.ocspRefs {
+		ocspRefElement := ocspRefsElement.CreateElement("xades:OCSPRef")
+		ocspIdentifierElement := ocspRefElement.CreateElement("xades:OCSPIdentifier")
+		ocspIdentifierElement.CreateElement("xades:ResponderID").CreateElement("xades:ByName").SetText(ocspRef.responderName)
+		ocspIdentifierElement.CreateElement("xades:ProducedAt").SetText(ocspRef.producedAt.UTC().Format(time.RFC3339))
+		if ocspRef.ocspResponse != nil {
+			createDigestElements(ocspRefElement.CreateElement("xades:DigestAlgAndValue"), ocspRef.ocspResponse)
+		}
+	}
+	if authority == nil {
+		return mustWriteDocument(t, doc)
+	}
+
+	referencePaths := []string{"//CompleteCertificateRefs", "//CompleteRevocationRefs"}
+	if references.isV2 {
+		referencePaths[0] = "//CompleteCertificateRefsV2"
+	}
+	timeStampInputs := []struct {
+		tag   string
+		paths []string
+	}{
+		{tag: "SigAndRefsTimeStamp", paths: append([]string{"//Signature/SignatureValue", "//SignatureTimeStamp"}, referencePaths...)},
+		{tag: "RefsOnlyTimeStamp", paths: referencePaths},
+	}
+	for _, timeStampInput := range timeStampInputs {
+		currentXMLBytes := mustWriteDocument(t, doc)
+		hash := sha256.New()
+		for _, path := range timeStampInput.paths {
+			element, err := signedInfoFactory.CreateDereferencer().DereferenceByPath(currentXMLBytes, path)
+			if err != nil {
+				t.Fatalf("cannot dereference %s: %v", path, err)
+			}
+			canonicalizer, err := signedInfoFactory.CreateCanonicalizer(xades4go.CanonicalXML10Algorithm)
+			if err != nil {
+				t.Fatalf("cannot create canonicalizer: %v", err)
+			}
+			canonicalizedElement, err := canonicalizer.Canonicalize(element)
+			if err != nil {
+				t.Fatalf("cannot canonicalize %s: %v", path, err)
+			}
+			hash.Write(canonicalizedElement)
+		}
+		timeStampToken, err := authority.TimeStamp(crypto.SHA256, hash.Sum(nil))
+		if err != nil {
+			t.Fatalf("TimeStamp() error = %v", err)
+		}
+		timeStampElement := createPropertyElement(timeStampInput.tag)
+		timeStampElement.CreateElement("ds:CanonicalizationMethod").CreateAttr("Algorithm", xades4go.CanonicalXML10Algorithm)
+		timeStampElement.CreateElement("xades:EncapsulatedTimeStamp").SetText(base64.StdEncoding.EncodeToString(timeStampToken))
+	}
+	return mustWriteDocument(t, doc)
+}
+
+func mustRemoveElement(t *testing.T, xmlBytes []byte, path string) []byte {
+	t.Helper()
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(xmlBytes); err != nil {
+		t.Fatalf("cannot parse XML: %v", err)
+	}
+	element := doc.FindElement(path)
+	if element == nil {
+		t.Fatalf("%s is not found", path)
+	}
+	element.Parent().RemoveChild(element)
+	return mustWriteDocument(t, doc)
+}
+
+func mustWriteDocument(t *testing.T, doc *etree.Document) []byte {
+	t.Helper()
+	xmlBytes, err := doc.WriteToBytes()
+	if err != nil {
+		t.Fatalf("cannot serialize XML: %v", err)
+	}
+	return xmlBytes
+}
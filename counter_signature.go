@@ -0,0 +1,160 @@
+package xades4go
+
+import (
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	"github.com/beevik/etree"
+)
+
+const (
+	// CountersignedSignatureReferenceType is the Type attribute of the Reference of a counter signature that points to SignatureValue element of the countersigned signature.
+	CountersignedSignatureReferenceType = "http://uri.etsi.org/01903#CountersignedSignature"
+
+	counterSignatureElementTag = "CounterSignature"
+)
+
+// CounterSignatureGenerator adds counter signatures to already signed documents.
+type CounterSignatureGenerator interface {
+	// CounterSign adds CounterSignature element over SignatureValue element of the signature whose Id is signatureID, or of the only top-level signature if signatureID is empty.
+	// A counter signature can itself be countersigned by giving its Id.
+	CounterSign(xmlBytes []byte, signatureID string) ([]byte, error)
+}
+
+// CounterSignatureValidationResult is the result of validating the Signature element of a CounterSignature element.
+type CounterSignatureValidationResult struct {
+	// IsValid is true if References, SignatureValue and qualifying properties of the counter signature are valid and IsCountersignedSignatureReferenceValid is true.
+	IsValid bool
+	// IsCountersignedSignatureReferenceValid is true if a Reference of type CountersignedSignatureReferenceType points to SignatureValue element of the countersigned signature and its digest is valid.
+	IsCountersignedSignatureReferenceValid bool
+	// ValidationResult is the result of validating the counter signature as XAdES signature, including the counter signatures nested in it.
+	ValidationResult ValidationResult
+}
+
+// NewXAdESCounterSignatureGenerator creates CounterSignatureGenerator whose counter signatures are XAdES signatures generated like NewXAdESSignatureGenerator does.
+func NewXAdESCounterSignatureGenerator(signedInfoFactory SignedInfoFactory, signer crypto.Signer, certificateChain []*x509.Certificate, signatureAlgorithm string, options ...XAdESSignatureGeneratorOption) (CounterSignatureGenerator, error) {
+	generator, err := newXAdESSignatureGenerator(signedInfoFactory, signer, certificateChain, signatureAlgorithm, options...)
+	if err != nil {
+		return nil, err
+	}
+	return generator, nil
+}
+
+// CounterSign adds CounterSignature element to UnsignedSignatureProperties element of the countersigned signature.
+// If SignatureValue element of the countersigned signature has no Id attribute, it is given one, unless a time-stamp of the signature already covers SignatureValue element.
+func (generator *XAdESSignatureGenerator) CounterSign(xmlBytes []byte, signatureID string) ([]byte, error) {
+	doc := etree.NewDocument()
+	err := doc.ReadFromBytes(xmlBytes)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse xmlBytes to etree's element: %w", err)
+	}
+	if doc.Root() == nil {
+		return nil, errors.New("xmlBytes does not contain any root element")
+	}
+	var countersignedSignatureElement *etree.Element
+	if signatureID == "" {
+		countersignedSignatureElement, err = mustFoundOnlyOneSignatureElement(doc.Root())
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		countersignedSignatureElement, err = mustFoundSignatureElementByID(doc.Root(), signatureID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	qualifyingPropertiesElement, err := findQualifyingPropertiesElement(countersignedSignatureElement)
+	if err != nil {
+		return nil, err
+	}
+	signatureValueElement, err := mustFoundOnlyOneChildElement(countersignedSignatureElement, signatureValueElementTag)
+	if err != nil {
+		return nil, err
+	}
+	signatureValueID := signatureValueElement.SelectAttrValue(idAttributeKey, "")
+	if signatureValueID != "" && len(findElementsByID(doc.Root(), signatureValueID)) > 1 {
+		return nil, fmt.Errorf("found more than one element with Id %s, the Id of SignatureValue element is ambiguous", signatureValueID)
+	}
+	if signatureValueID == "" {
+		if len(findUnsignedSignaturePropertyElements(qualifyingPropertiesElement, signatureTimeStampElementTag)) > 0 || len(findUnsignedSignaturePropertyElements(qualifyingPropertiesElement, archiveTimeStampElementTag)) > 0 {
+			return nil, errors.New("SignatureValue element has no Id attribute and cannot be given one without invalidating the time-stamps over it")
+		}
+		signatureValueID, err = signatureValueIDOf(doc.Root(), countersignedSignatureElement)
+		if err != nil {
+			return nil, err
+		}
+		signatureValueElement.CreateAttr(idAttributeKey, signatureValueID)
+	}
+
+	signatureElement, references, err := generator.xmldsigSignatureGenerator.createSignatureElement([]ReferenceGenerationDetail{
+		{
+			URIOfDataObjectBeingSigned: "#" + signatureValueID,
+			DigestAlgorithm:            generator.digestAlgorithm,
+			Type:                       CountersignedSignatureReferenceType,
+		},
+	}, nil, generator)
+	if err != nil {
+		return nil, err
+	}
+	createElementInNamespaceOf(unsignedSignaturePropertiesElementOf(qualifyingPropertiesElement), qualifyingPropertiesElement, counterSignatureElementTag).AddChild(signatureElement)
+	return generator.xmldsigSignatureGenerator.completeSignatureElement(doc, signatureElement, references, generator)
+}
+
+// signatureValueIDOf returns the Id to give SignatureValue element of signatureElement, derived from Id attribute of signatureElement or generated if it has none.
+// The Id must not be taken by any element of root, or the Reference of the counter signature would be ambiguous.
+func signatureValueIDOf(root *etree.Element, signatureElement *etree.Element) (string, error) {
+	if signatureID := signatureElement.SelectAttrValue(idAttributeKey, ""); signatureID != "" {
+		if signatureValueID := signatureID + "-sigvalue"; len(findElementsByID(root, signatureValueID)) == 0 {
+			return signatureValueID, nil
+		}
+	}
+	signatureValueID, err := generateID("xmldsig-sigvalue")
+	if err != nil {
+		return "", err
+	}
+	if len(findElementsByID(root, signatureValueID)) > 0 {
+		return "", fmt.Errorf("cannot give SignatureValue element Id %s because another element already has it", signatureValueID)
+	}
+	return signatureValueID, nil
+}
+
+// validateCounterSignatures validates the Signature element of every CounterSignature element of qualifyingPropertiesElement, which qualifies signatureElement.
+// The counter signatures nested in them are validated recursively.
+func (validator *XAdESSignatureValidator) validateCounterSignatures(xmlBytes []byte, signatureElement *etree.Element, qualifyingPropertiesElement *etree.Element) ([]CounterSignatureValidationResult, error) {
+	counterSignatureElements := findUnsignedSignaturePropertyElements(qualifyingPropertiesElement, counterSignatureElementTag)
+	if len(counterSignatureElements) == 0 {
+		return nil, nil
+	}
+	signatureValueElement, err := mustFoundOnlyOneChildElement(signatureElement, signatureValueElementTag)
+	if err != nil {
+		return nil, err
+	}
+	signatureValueID := signatureValueElement.SelectAttrValue(idAttributeKey, "")
+	results := make([]CounterSignatureValidationResult, 0, len(counterSignatureElements))
+	for counterSignatureIndex, counterSignatureElement := range counterSignatureElements {
+		counterSignatureSignatureElement, err := mustFoundOnlyOneChildElement(counterSignatureElement, signatureElementTag)
+		if err != nil {
+			return nil, fmt.Errorf("at CounterSignature#%d element: %w", counterSignatureIndex, err)
+		}
+		if !isXMLDSigSignatureElement(counterSignatureSignatureElement) {
+			return nil, fmt.Errorf("at CounterSignature#%d element: %s element is not in %s namespace", counterSignatureIndex, signatureElementTag, xmldsigNamespaceURI)
+		}
+		validationResult, err := validator.validateSignatureElement(xmlBytes, counterSignatureSignatureElement, 0)
+		if err != nil {
+			return nil, fmt.Errorf("at CounterSignature#%d element: %w", counterSignatureIndex, err)
+		}
+		result := CounterSignatureValidationResult{ValidationResult: validationResult}
+		isEveryReferenceValid := true
+		for _, referenceValidationResult := range validationResult.ReferenceValidationResults {
+			isEveryReferenceValid = isEveryReferenceValid && referenceValidationResult.IsValid
+			if signatureValueID != "" && referenceValidationResult.Type == CountersignedSignatureReferenceType && referenceValidationResult.URI == "#"+signatureValueID {
+				result.IsCountersignedSignatureReferenceValid = referenceValidationResult.IsValid
+			}
+		}
+		result.IsValid = validationResult.IsSignatureValid && isEveryReferenceValid && validationResult.QualifyingPropertiesValidationResult.IsValid && result.IsCountersignedSignatureReferenceValid
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -0,0 +1,174 @@
+package xades4go_test
+
+import (
+	"crypto/x509"
+	"strings"
+	"testing"
+
+	"github.com/beevik/etree"
+	"github.com/google/go-cmp/cmp"
+	"github.com/mekpavit/xades4go"
+	"github.com/mekpavit/xades4go/etreeimpl"
+)
+
+func Test_XAdESCounterSignatureGenerator(t *testing.T) {
+	signedInfoFactory := etreeimpl.NewSignedInfoFactory()
+	signerKey, signerCertificate := mustCreateRSAKeyAndSelfSignedCertificate(t)
+	officerKey, officerCertificate := mustCreateRSAKeyAndSelfSignedCertificate(t)
+	generator, err := xades4go.NewXAdESSignatureGenerator(signedInfoFactory, signerKey, []*x509.Certificate{signerCertificate}, xades4go.RSASHA256SignatureAlgorithm)
+	if err != nil {
+		t.Fatalf("NewXAdESSignatureGenerator() error = %v", err)
+	}
+	signedXMLBytes, err := generator.SignXMLBytes([]byte(unsignedInvoice), []xades4go.ReferenceGenerationDetail{
+		{
+			URIOfDataObjectBeingSigned: "",
+			TransformAlgorithms:        []string{xades4go.EnvelopedSignatureTransformAlgorithm},
+			DigestAlgorithm:            xades4go.SHA256MessageDigestAlgorithm,
+		},
+	})
+	if err != nil {
+		t.Fatalf("SignXMLBytes() error = %v", err)
+	}
+	counterSignatureGenerator, err := xades4go.NewXAdESCounterSignatureGenerator(signedInfoFactory, officerKey, []*x509.Certificate{officerCertificate}, xades4go.RSASHA256SignatureAlgorithm)
+	if err != nil {
+		t.Fatalf("NewXAdESCounterSignatureGenerator() error = %v", err)
+	}
+	mustCounterSign := func(xmlBytes []byte, signatureID string) []byte {
+		counterSignedXMLBytes, err := counterSignatureGenerator.CounterSign(xmlBytes, signatureID)
+		if err != nil {
+			t.Fatalf("CounterSign() error = %v", err)
+		}
+		return counterSignedXMLBytes
+	}
+	mustFindCounterSignatureID := func(xmlBytes []byte) string {
+		doc := etree.NewDocument()
+		if err := doc.ReadFromBytes(xmlBytes); err != nil {
+			t.Fatalf("cannot parse XML: %v", err)
+		}
+		return doc.FindElement("//CounterSignature/Signature").SelectAttrValue("Id", "")
+	}
+	counterSignedXMLBytes := mustCounterSign(signedXMLBytes, "")
+	nestedCounterSignedXMLBytes := mustCounterSign(counterSignedXMLBytes, mustFindCounterSignatureID(counterSignedXMLBytes))
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(counterSignedXMLBytes); err != nil {
+		t.Fatalf("cannot parse XML: %v", err)
+	}
+	signatureValueElement := doc.FindElement("//Signature/SignatureValue")
+	signatureValueElement.SetText(strings.Replace(signatureValueElement.Text(), signatureValueElement.Text()[:4], "AAAA", 1))
+	tamperedXMLBytes, err := doc.WriteToBytes()
+	if err != nil {
+		t.Fatalf("cannot serialize XML: %v", err)
+	}
+	collidingDoc := etree.NewDocument()
+	if err := collidingDoc.ReadFromBytes(signedXMLBytes); err != nil {
+		t.Fatalf("cannot parse XML: %v", err)
+	}
+	signatureElement := collidingDoc.FindElement("//Signature")
+	collidingID := signatureElement.SelectElement("SignatureValue").SelectAttrValue("Id", "")
+	signatureElement.SelectElement("SignatureValue").RemoveAttr("Id")
+	signatureElement.CreateElement(signatureElement.Space+":Object").CreateElement("Collision").CreateAttr("Id", collidingID)
+	collidingXMLBytes, err := collidingDoc.WriteToBytes()
+	if err != nil {
+		t.Fatalf("cannot serialize XML: %v", err)
+	}
+	counterSignedCollidingXMLBytes := mustCounterSign(collidingXMLBytes, "")
+	if err := collidingDoc.ReadFromBytes(counterSignedCollidingXMLBytes); err != nil {
+		t.Fatalf("cannot parse XML: %v", err)
+	}
+	if got := collidingDoc.FindElement("//Signature/SignatureValue").SelectAttrValue("Id", ""); got == collidingID {
+		t.Errorf("CounterSign() gave SignatureValue element Id %s that another element already has", got)
+	}
+
+	type counterSignatureTree struct {
+		IsValid                                bool
+		IsCountersignedSignatureReferenceValid bool
+		SignerCertificate                      *x509.Certificate
+		Nested                                 []counterSignatureTree
+	}
+	var treeOf func(results []xades4go.CounterSignatureValidationResult) []counterSignatureTree
+	treeOf = func(results []xades4go.CounterSignatureValidationResult) []counterSignatureTree {
+		var trees []counterSignatureTree
+		for _, result := range results {
+			trees = append(trees, counterSignatureTree{
+				IsValid:                                result.IsValid,
+				IsCountersignedSignatureReferenceValid: result.IsCountersignedSignatureReferenceValid,
+				SignerCertificate:                      result.ValidationResult.SignerCertificate,
+				Nested:                                 treeOf(result.ValidationResult.QualifyingPropertiesValidationResult.CounterSignatureValidationResults),
+			})
+		}
+		return trees
+	}
+	tests := []struct {
+		name             string
+		xmlBytes         []byte
+		wantIsValid      bool
+		wantCounterSigns []counterSignatureTree
+	}{
+		{
+			name:        "When a signature is countersigned, the counter signature should be valid",
+			xmlBytes:    counterSignedXMLBytes,
+			wantIsValid: true,
+			wantCounterSigns: []counterSignatureTree{
+				{IsValid: true, IsCountersignedSignatureReferenceValid: true, SignerCertificate: officerCertificate},
+			},
+		},
+		{
+			name:        "When a counter signature is countersigned, the nested counter signature should be validated recursively",
+			xmlBytes:    nestedCounterSignedXMLBytes,
+			wantIsValid: true,
+			wantCounterSigns: []counterSignatureTree{
+				{IsValid: true, IsCountersignedSignatureReferenceValid: true, SignerCertificate: officerCertificate, Nested: []counterSignatureTree{
+					{IsValid: true, IsCountersignedSignatureReferenceValid: true, SignerCertificate: officerCertificate},
+				}},
+			},
+		},
+		{
+			name:        "When the signed tax invoice of ETDA is countersigned, the counter signature should be valid",
+			xmlBytes:    mustCounterSign([]byte(etdaSignedTaxInvoice), ""),
+			wantIsValid: false,
+			wantCounterSigns: []counterSignatureTree{
+				{IsValid: true, IsCountersignedSignatureReferenceValid: true, SignerCertificate: officerCertificate},
+			},
+		},
+		{
+			name:        "When another element already has the Id derived for SignatureValue, the counter signature should reference a generated Id",
+			xmlBytes:    counterSignedCollidingXMLBytes,
+			wantIsValid: true,
+			wantCounterSigns: []counterSignatureTree{
+				{IsValid: true, IsCountersignedSignatureReferenceValid: true, SignerCertificate: officerCertificate},
+			},
+		},
+		{
+			name:        "When SignatureValue of the countersigned signature is tampered, the counter signature should be invalid",
+			xmlBytes:    tamperedXMLBytes,
+			wantIsValid: false,
+			wantCounterSigns: []counterSignatureTree{
+				{IsValid: false, IsCountersignedSignatureReferenceValid: false, SignerCertificate: officerCertificate},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := xades4go.NewXAdESSignatureValidator(signedInfoFactory).Validate(tt.xmlBytes)
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if got.QualifyingPropertiesValidationResult.IsValid != tt.wantIsValid {
+				t.Errorf("Validate() QualifyingPropertiesValidationResult.IsValid = %v, want %v", got.QualifyingPropertiesValidationResult.IsValid, tt.wantIsValid)
+			}
+			if diff := cmp.Diff(tt.wantCounterSigns, treeOf(got.QualifyingPropertiesValidationResult.CounterSignatureValidationResults), certificateComparer); diff != "" {
+				t.Errorf("Validate() counter signatures mismatch (-want+got):\n%s", diff)
+			}
+			if _, err := xades4go.NewXMLDSigSignatureValidator(signedInfoFactory).Validate(tt.xmlBytes); err != nil {
+				t.Errorf("XMLDSigSignatureValidator.Validate() error = %v", err)
+			}
+		})
+	}
+
+	if _, err := counterSignatureGenerator.CounterSign(signedXMLBytes, "unknown"); err == nil {
+		t.Errorf("CounterSign() with unknown signature Id should return error")
+	}
+	if _, err := counterSignatureGenerator.CounterSign([]byte(strings.Replace(string(signedXMLBytes), "<ds:Object>", `<ds:Object><Collision Id="`+collidingID+`"/>`, 1)), ""); err == nil {
+		t.Errorf("CounterSign() should return error when another element has the Id of SignatureValue element")
+	}
+}
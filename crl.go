@@ -0,0 +1,245 @@
+package xades4go
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+const crlReasonRemoveFromCRL = 8
+
+var (
+	oidExtensionAuthorityKeyIdentifier   = asn1.ObjectIdentifier{2, 5, 29, 35}
+	oidExtensionCRLNumber                = asn1.ObjectIdentifier{2, 5, 29, 20}
+	oidExtensionDeltaCRLIndicator        = asn1.ObjectIdentifier{2, 5, 29, 27}
+	oidExtensionIssuingDistributionPoint = asn1.ObjectIdentifier{2, 5, 29, 28}
+	oidExtensionFreshestCRL              = asn1.ObjectIdentifier{2, 5, 29, 46}
+	oidExtensionReasonCode               = asn1.ObjectIdentifier{2, 5, 29, 21}
+)
+
+type certificateList struct {
+	TBSCertList        tbsCertList
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	SignatureValue     asn1.BitString
+}
+
+type tbsCertList struct {
+	Raw                 asn1.RawContent
+	Version             int `asn1:"optional,default:0"`
+	Signature           pkix.AlgorithmIdentifier
+	Issuer              asn1.RawValue
+	ThisUpdate          time.Time
+	NextUpdate          time.Time                 `asn1:"optional"`
+	RevokedCertificates []pkix.RevokedCertificate `asn1:"optional"`
+	Extensions          []pkix.Extension          `asn1:"tag:0,optional,explicit"`
+}
+
+type distributionPoint struct {
+	DistributionPoint distributionPointName `asn1:"optional,tag:0"`
+	Reason            asn1.BitString        `asn1:"optional,tag:1"`
+	CRLIssuer         asn1.RawValue         `asn1:"optional,tag:2"`
+}
+
+type distributionPointName struct {
+	FullName     []asn1.RawValue  `asn1:"optional,tag:0"`
+	RelativeName pkix.RDNSequence `asn1:"optional,tag:1"`
+}
+
+type issuingDistributionPoint struct {
+	DistributionPoint          distributionPointName `asn1:"optional,tag:0"`
+	OnlyContainsUserCerts      bool                  `asn1:"optional,tag:1"`
+	OnlyContainsCACerts        bool                  `asn1:"optional,tag:2"`
+	OnlySomeReasons            asn1.BitString        `asn1:"optional,tag:3"`
+	IndirectCRL                bool                  `asn1:"optional,tag:4"`
+	OnlyContainsAttributeCerts bool                  `asn1:"optional,tag:5"`
+}
+
+// verifiedCRL is a CRL whose signature, issuer and freshness are verified by verifyCRL.
+type verifiedCRL struct {
+	tbsCertList tbsCertList
+	crlNumber   *big.Int
+	// baseCRLNumber is BaseCRLNumber of Delta CRL Indicator extension. It is nil if the CRL is not a delta CRL.
+	baseCRLNumber *big.Int
+}
+
+// verifyCRL parses DER-encoded crlBytes and verifies that it is issued and signed by issuer, which must be allowed to sign CRLs, and tells the status of certificate at validationTime without being issued after currentTime (see checkRevocationDataFreshness).
+// It returns errRevocationDataNotApplicable if the CRL is issued by another issuer, or its Issuing Distribution Point extension does not cover certificate.
+func verifyCRL(crlBytes []byte, certificate *x509.Certificate, issuer *x509.Certificate, validationTime time.Time, currentTime time.Time) (*verifiedCRL, error) {
+	var crl certificateList
+	rest, err := asn1.Unmarshal(crlBytes, &crl)
+	if err != nil || len(rest) > 0 {
+		return nil, errors.New("it is not a DER-encoded CertificateList")
+	}
+	if !bytes.Equal(crl.TBSCertList.Issuer.FullBytes, issuer.RawSubject) {
+		return nil, errRevocationDataNotApplicable
+	}
+	if issuer.KeyUsage != 0 && issuer.KeyUsage&x509.KeyUsageCRLSign == 0 {
+		return nil, errors.New("the issuer has no cRLSign key usage")
+	}
+	signatureAlgorithm, err := x509SignatureAlgorithmOf(crl.SignatureAlgorithm.Algorithm, 0)
+	if err != nil {
+		return nil, err
+	}
+	err = issuer.CheckSignature(signatureAlgorithm, crl.TBSCertList.Raw, crl.SignatureValue.RightAlign())
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature: %w", err)
+	}
+	if crl.TBSCertList.NextUpdate.IsZero() {
+		return nil, errors.New("it has no nextUpdate")
+	}
+	err = checkRevocationDataFreshness(crl.TBSCertList.ThisUpdate, crl.TBSCertList.NextUpdate, certificate, validationTime, currentTime)
+	if err != nil {
+		return nil, err
+	}
+	result := &verifiedCRL{tbsCertList: crl.TBSCertList}
+	for _, extension := range crl.TBSCertList.Extensions {
+		switch {
+		case extension.Id.Equal(oidExtensionCRLNumber):
+			_, err = asn1.Unmarshal(extension.Value, &result.crlNumber)
+		case extension.Id.Equal(oidExtensionDeltaCRLIndicator):
+			_, err = asn1.Unmarshal(extension.Value, &result.baseCRLNumber)
+		case extension.Id.Equal(oidExtensionIssuingDistributionPoint):
+			if scopeErr := checkIssuingDistributionPoint(extension.Value, certificate); scopeErr != nil {
+				return nil, scopeErr
+			}
+		case extension.Id.Equal(oidExtensionAuthorityKeyIdentifier), extension.Id.Equal(oidExtensionFreshestCRL):
+		default:
+			if extension.Critical {
+				return nil, fmt.Errorf("unsupported critical extension %s", extension.Id)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("malformed extension %s", extension.Id)
+		}
+	}
+	return result, nil
+}
+
+// checkIssuingDistributionPoint checks that the scope of a CRL, given by the value of its Issuing Distribution Point extension, covers certificate (RFC 5280 section 6.3.3 (b)(2)).
+// The distribution point must match one of CRLDistributionPoints of certificate by URI. It returns errRevocationDataNotApplicable if the CRL is of another scope,
+// and error for indirect CRLs and CRLs partitioned by reasons, which cannot tell the status of certificate alone.
+func checkIssuingDistributionPoint(extensionValue []byte, certificate *x509.Certificate) error {
+	var scope issuingDistributionPoint
+	rest, err := asn1.Unmarshal(extensionValue, &scope)
+	if err != nil || len(rest) > 0 {
+		return fmt.Errorf("malformed extension %s", oidExtensionIssuingDistributionPoint)
+	}
+	if scope.IndirectCRL {
+		return errors.New("indirect CRL is not supported")
+	}
+	if scope.OnlySomeReasons.BitLength > 0 {
+		return errors.New("CRL covering only some reasons is not supported")
+	}
+	isCA := certificate.BasicConstraintsValid && certificate.IsCA
+	if scope.OnlyContainsAttributeCerts || (scope.OnlyContainsUserCerts && isCA) || (scope.OnlyContainsCACerts && !isCA) {
+		return errRevocationDataNotApplicable
+	}
+	if len(scope.DistributionPoint.RelativeName) > 0 {
+		return errors.New("relative name of the distribution point is not supported")
+	}
+	if len(scope.DistributionPoint.FullName) == 0 {
+		return nil
+	}
+	for _, generalName := range scope.DistributionPoint.FullName {
+		// uniformResourceIdentifier [6] IA5String
+		if generalName.Class != asn1.ClassContextSpecific || generalName.Tag != 6 {
+			continue
+		}
+		for _, url := range certificate.CRLDistributionPoints {
+			if url == string(generalName.Bytes) {
+				return nil
+			}
+		}
+	}
+	return errRevocationDataNotApplicable
+}
+
+// checkDeltaOf checks that crl is a delta CRL that can be applied to baseCRL: its BaseCRLNumber is not newer than baseCRL and its own CRL number is newer.
+func (crl *verifiedCRL) checkDeltaOf(baseCRL *verifiedCRL) error {
+	if crl.baseCRLNumber == nil {
+		return errors.New("it has no Delta CRL Indicator extension")
+	}
+	if baseCRL.crlNumber == nil || crl.crlNumber == nil {
+		return errors.New("the base CRL or the delta CRL has no CRL Number extension")
+	}
+	if crl.baseCRLNumber.Cmp(baseCRL.crlNumber) > 0 {
+		return fmt.Errorf("it requires base CRL number %s but the base CRL is number %s", crl.baseCRLNumber, baseCRL.crlNumber)
+	}
+	if crl.crlNumber.Cmp(baseCRL.crlNumber) <= 0 {
+		return fmt.Errorf("it is number %s, not newer than the base CRL number %s", crl.crlNumber, baseCRL.crlNumber)
+	}
+	return nil
+}
+
+// apply sets the status of certificate according to crl to result. A base CRL decides the status; a delta CRL only changes it for the certificates it lists.
+// Entries with removeFromCRL reason, which are only meaningful in delta CRLs, mark the certificate as no longer revoked.
+func (crl *verifiedCRL) apply(result *CertificateRevocationResult, certificate *x509.Certificate, validationTime time.Time) {
+	isDelta := crl.baseCRLNumber != nil
+	result.ThisUpdate = crl.tbsCertList.ThisUpdate
+	result.NextUpdate = crl.tbsCertList.NextUpdate
+	if !isDelta {
+		result.Status = RevocationStatusGood
+	}
+	for _, revokedCertificate := range crl.tbsCertList.RevokedCertificates {
+		if revokedCertificate.SerialNumber.Cmp(certificate.SerialNumber) != 0 {
+			continue
+		}
+		reason := crlReasonOf(revokedCertificate.Extensions)
+		if reason == crlReasonRemoveFromCRL {
+			if isDelta {
+				result.Status = RevocationStatusGood
+				result.RevocationTime = time.Time{}
+				result.RevocationReason = 0
+			}
+			return
+		}
+		if revokedCertificate.RevocationTime.After(validationTime) {
+			return
+		}
+		result.Status = RevocationStatusRevoked
+		result.RevocationTime = revokedCertificate.RevocationTime
+		result.RevocationReason = reason
+		return
+	}
+}
+
+// crlReasonOf returns the code of Reason Code extension of a CRL entry, or 0 (unspecified) if it is absent.
+func crlReasonOf(extensions []pkix.Extension) int {
+	for _, extension := range extensions {
+		if extension.Id.Equal(oidExtensionReasonCode) {
+			var reason asn1.Enumerated
+			if _, err := asn1.Unmarshal(extension.Value, &reason); err == nil {
+				return int(reason)
+			}
+		}
+	}
+	return 0
+}
+
+// freshestCRLURLsOf returns the URIs of the distribution points of Freshest CRL extension in extensions (of a certificate or a base CRL), where its delta CRLs are published.
+func freshestCRLURLsOf(extensions []pkix.Extension) []string {
+	var urls []string
+	for _, extension := range extensions {
+		if !extension.Id.Equal(oidExtensionFreshestCRL) {
+			continue
+		}
+		var distributionPoints []distributionPoint
+		if _, err := asn1.Unmarshal(extension.Value, &distributionPoints); err != nil {
+			continue
+		}
+		for _, distributionPoint := range distributionPoints {
+			for _, generalName := range distributionPoint.DistributionPoint.FullName {
+				// uniformResourceIdentifier [6] IA5String
+				if generalName.Class == asn1.ClassContextSpecific && generalName.Tag == 6 {
+					urls = append(urls, string(generalName.Bytes))
+				}
+			}
+		}
+	}
+	return urls
+}
@@ -0,0 +1,154 @@
+package xades4go
+
+import (
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"crypto/x509/pkix"
+)
+
+// attributeTypeOIDs maps the attribute type names of RFC 4514 (and the ones commonly used by XMLDSig implementations) to their OIDs.
+var attributeTypeOIDs = map[string]string{
+	"CN":           "2.5.4.3",
+	"SN":           "2.5.4.4",
+	"SURNAME":      "2.5.4.4",
+	"SERIALNUMBER": "2.5.4.5",
+	"C":            "2.5.4.6",
+	"L":            "2.5.4.7",
+	"ST":           "2.5.4.8",
+	"S":            "2.5.4.8",
+	"STREET":       "2.5.4.9",
+	"O":            "2.5.4.10",
+	"OU":           "2.5.4.11",
+	"T":            "2.5.4.12",
+	"TITLE":        "2.5.4.12",
+	"GIVENNAME":    "2.5.4.42",
+	"G":            "2.5.4.42",
+	"DC":           "0.9.2342.19200300.100.1.25",
+	"UID":          "0.9.2342.19200300.100.1.1",
+	"E":            "1.2.840.113549.1.9.1",
+	"EMAILADDRESS": "1.2.840.113549.1.9.1",
+}
+
+// isSameDistinguishedName reports whether the string representation of a distinguished name (as found in X509IssuerName and X509SubjectName elements) names the same entity as the DER-encoded rawName.
+// The attributes are compared regardless of their order, since implementations disagree on whether the string follows RFC 4514 order or the ASN.1 order.
+// Values are compared case-insensitively with insignificant spaces removed.
+func isSameDistinguishedName(distinguishedName string, rawName []byte) bool {
+	attributes, err := parseDistinguishedName(distinguishedName)
+	if err != nil {
+		return false
+	}
+	var rdnSequence pkix.RDNSequence
+	rest, err := asn1.Unmarshal(rawName, &rdnSequence)
+	if err != nil || len(rest) > 0 {
+		return false
+	}
+	rawAttributes := make([]string, 0)
+	for _, relativeDistinguishedName := range rdnSequence {
+		for _, attribute := range relativeDistinguishedName {
+			rawAttributes = append(rawAttributes, attribute.Type.String()+"="+normalizeAttributeValue(fmt.Sprint(attribute.Value)))
+		}
+	}
+	if len(attributes) != len(rawAttributes) {
+		return false
+	}
+	sort.Strings(attributes)
+	sort.Strings(rawAttributes)
+	for index := range attributes {
+		if attributes[index] != rawAttributes[index] {
+			return false
+		}
+	}
+	return true
+}
+
+// parseDistinguishedName parses RFC 4514 string representation of a distinguished name to a list of "OID=normalized value".
+func parseDistinguishedName(distinguishedName string) ([]string, error) {
+	result := make([]string, 0)
+	remaining := strings.TrimSpace(distinguishedName)
+	if remaining == "" {
+		return result, nil
+	}
+	for {
+		equalIndex := strings.Index(remaining, "=")
+		if equalIndex < 0 {
+			return nil, fmt.Errorf("attribute type and value are not separated by '=' in %s", distinguishedName)
+		}
+		attributeType, err := attributeTypeOID(strings.TrimSpace(remaining[:equalIndex]))
+		if err != nil {
+			return nil, err
+		}
+		value, rest, err := parseAttributeValue(remaining[equalIndex+1:])
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, attributeType+"="+normalizeAttributeValue(value))
+		if rest == "" {
+			return result, nil
+		}
+		remaining = strings.TrimSpace(rest[1:])
+	}
+}
+
+func attributeTypeOID(attributeType string) (string, error) {
+	if oid, ok := attributeTypeOIDs[strings.ToUpper(attributeType)]; ok {
+		return oid, nil
+	}
+	attributeType = strings.TrimPrefix(strings.TrimPrefix(attributeType, "OID."), "oid.")
+	for _, arc := range strings.Split(attributeType, ".") {
+		if _, err := strconv.ParseUint(arc, 10, 64); err != nil {
+			return "", fmt.Errorf("unknown attribute type %s", attributeType)
+		}
+	}
+	return attributeType, nil
+}
+
+// parseAttributeValue parses an attribute value until an unescaped separator (',', ';' or '+').
+// It returns the unescaped value and the rest of the string starting at the separator.
+func parseAttributeValue(input string) (string, string, error) {
+	input = strings.TrimLeft(input, " ")
+	var value strings.Builder
+	if strings.HasPrefix(input, "\"") {
+		closingIndex := strings.Index(input[1:], "\"")
+		if closingIndex < 0 {
+			return "", "", errors.New("quoted attribute value is not closed")
+		}
+		value.WriteString(input[1 : closingIndex+1])
+		rest := strings.TrimLeft(input[closingIndex+2:], " ")
+		return value.String(), rest, nil
+	}
+	for index := 0; index < len(input); index++ {
+		switch character := input[index]; character {
+		case ',', ';', '+':
+			return value.String(), input[index:], nil
+		case '\\':
+			if index+1 >= len(input) {
+				return "", "", errors.New("attribute value ends with an escape character")
+			}
+			if index+2 < len(input) && isHexDigit(input[index+1]) && isHexDigit(input[index+2]) {
+				decoded, _ := hex.DecodeString(input[index+1 : index+3])
+				value.Write(decoded)
+				index += 2
+				continue
+			}
+			value.WriteByte(input[index+1])
+			index++
+		default:
+			value.WriteByte(character)
+		}
+	}
+	return value.String(), "", nil
+}
+
+func isHexDigit(character byte) bool {
+	return strings.IndexByte("0123456789abcdefABCDEF", character) >= 0
+}
+
+func normalizeAttributeValue(value string) string {
+	return strings.ToLower(strings.Join(strings.Fields(value), " "))
+}
@@ -128,8 +128,8 @@ func (attributes attributesByLexicographicalOrder) Less(i int, j int) bool {
 			return x.Key < y.Key
 		}
 		if !isUnqualifiedAttribute(x) && !isUnqualifiedAttribute(y) {
-			if x.NamespaceURI() != y.NamespaceURI() {
-				return x.NamespaceURI() < y.NamespaceURI()
+			if namespaceURIOf(x) != namespaceURIOf(y) {
+				return namespaceURIOf(x) < namespaceURIOf(y)
 			}
 			return x.Key < y.Key
 		}
@@ -156,6 +156,17 @@ func isUnqualifiedAttribute(attribute etree.Attr) bool {
 	return !isNamespace(attribute) && attribute.Space == ""
 }
 
+// namespaceURIOf resolves the namespace URI bound to the prefix of a qualified attribute.
+// etree's Attr.NamespaceURI returns the namespace of the owner element instead of the attribute's one, so it cannot be used here.
+func namespaceURIOf(attribute etree.Attr) string {
+	for element := attribute.Element(); element != nil; element = element.Parent() {
+		if namespace := element.SelectAttr("xmlns:" + attribute.Space); namespace != nil {
+			return namespace.Value
+		}
+	}
+	return ""
+}
+
 // completeCanonicalization performs the canonicalization that does not include in the Canonicalization Transformer.
 // If any of Canonicalization is used, this function MUST be called to ensure the correctness of canonicalization.
 //
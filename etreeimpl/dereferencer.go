@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/beevik/etree"
 	"github.com/mekpavit/xades4go"
 )
 
@@ -17,8 +18,21 @@ func (d *dereferencer) DereferenceByURI(xmlContent []byte, uri string) (xades4go
 	if uri == "" {
 		return xades4go.XML{IsOctetStream: false, NodeSet: nodeSet}, nil
 	}
+	if !strings.HasPrefix(uri, "#") {
+		return xades4go.XML{}, fmt.Errorf("only same-document URI can be dereferenced by etreeimpl, use URIResolver for -> %s", uri)
+	}
 	idOfDataObject := strings.TrimPrefix(uri, "#")
-	dereferencedNodeSet := nodeSet.FindElement(fmt.Sprintf("//[@Id='%s']", idOfDataObject))
+	var dereferencedNodeSet *etree.Element
+	// The Id comes from the document, so it is compared with the attribute values instead of being put in a path.
+	for _, element := range nodeSet.FindElements("//*") {
+		if element.SelectAttrValue("Id", "") != idOfDataObject {
+			continue
+		}
+		if dereferencedNodeSet != nil {
+			return xades4go.XML{}, fmt.Errorf("found more than one node set from uri -> %s", uri)
+		}
+		dereferencedNodeSet = element
+	}
 	if dereferencedNodeSet == nil {
 		return xades4go.XML{}, fmt.Errorf("cannot find any node set from uri -> %s", uri)
 	}
@@ -32,7 +32,7 @@ func (factory *signedInfoFactory) CreateTransformer(algorithmName string) (xades
 		return nil, fmt.Errorf("%s was not implemented by etreeimpl", algorithmName)
 
 	case xades4go.Base64Algorithm:
-		return nil, fmt.Errorf("%s was not implemented by etreeimpl", algorithmName)
+		return &base64Transformer{}, nil
 	case xades4go.XPathFilteringAlgorithm:
 		return nil, fmt.Errorf("%s was not implemented by etreeimpl", algorithmName)
 	case xades4go.EnvelopedSignatureTransformAlgorithm:
@@ -1,7 +1,10 @@
 package etreeimpl
 
 import (
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/beevik/etree"
 	"github.com/mekpavit/xades4go"
@@ -10,6 +13,11 @@ import (
 type envelopedSignatureTransformer struct{}
 
 func (transformer *envelopedSignatureTransformer) Transform(input xades4go.XML) (xades4go.XML, error) {
+	return transformer.TransformEnvelopedSignature(input, "//Signature")
+}
+
+// TransformEnvelopedSignature removes the Signature element found by signaturePath from the input. The input is returned unchanged if it does not contain that element.
+func (transformer *envelopedSignatureTransformer) TransformEnvelopedSignature(input xades4go.XML, signaturePath string) (xades4go.XML, error) {
 	var inputNodeSet *etree.Element
 	if input.IsOctetStream {
 		var err error
@@ -24,15 +32,15 @@ func (transformer *envelopedSignatureTransformer) Transform(input xades4go.XML)
 			return xades4go.XML{}, errors.New("input must be []byte or *etree.Element")
 		}
 	}
-	outputNodeSet, err := transformer.transform(inputNodeSet)
+	outputNodeSet, err := transformer.transform(inputNodeSet, signaturePath)
 	if err != nil {
 		return xades4go.XML{}, err
 	}
 	return xades4go.XML{IsOctetStream: false, NodeSet: outputNodeSet}, nil
 }
 
-func (transformer *envelopedSignatureTransformer) transform(nodeSet *etree.Element) (*etree.Element, error) {
-	signatureElement := nodeSet.FindElement("//Signature")
+func (transformer *envelopedSignatureTransformer) transform(nodeSet *etree.Element, signaturePath string) (*etree.Element, error) {
+	signatureElement := nodeSet.FindElement(signaturePath)
 	if signatureElement == nil {
 		return nodeSet, nil
 	}
@@ -43,3 +51,38 @@ func (transformer *envelopedSignatureTransformer) transform(nodeSet *etree.Eleme
 	parentOfSignatureElement.RemoveChild(signatureElement)
 	return nodeSet, nil
 }
+
+// base64Transformer is a Transformer that follows https://www.w3.org/TR/xmldsig-core1/#sec-Base64.
+// When a node set is given, the string-value of the node set (concatenation of every text node) is decoded.
+type base64Transformer struct{}
+
+func (transformer *base64Transformer) Transform(input xades4go.XML) (xades4go.XML, error) {
+	var encoded string
+	if input.IsOctetStream {
+		encoded = string(input.OctetStream)
+	} else {
+		inputNodeSet, ok := input.NodeSet.(*etree.Element)
+		if !ok {
+			return xades4go.XML{}, errors.New("input must be []byte or *etree.Element")
+		}
+		encoded = stringValueOf(inputNodeSet)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(encoded), ""))
+	if err != nil {
+		return xades4go.XML{}, fmt.Errorf("cannot base64-decode the input: %w", err)
+	}
+	return xades4go.XML{IsOctetStream: true, OctetStream: decoded}, nil
+}
+
+func stringValueOf(element *etree.Element) string {
+	var builder strings.Builder
+	for _, child := range element.Child {
+		switch node := child.(type) {
+		case *etree.CharData:
+			builder.WriteString(node.Data)
+		case *etree.Element:
+			builder.WriteString(stringValueOf(node))
+		}
+	}
+	return builder.String()
+}
@@ -54,3 +54,102 @@ func TestEtreeEnvelopedSignatureTransformer_Transform(t *testing.T) {
 		})
 	}
 }
+
+func TestEtreeEnvelopedSignatureTransformer_TransformEnvelopedSignature(t *testing.T) {
+	type args struct {
+		nodeSet       *etree.Element
+		signaturePath string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    []byte
+		wantErr bool
+	}{
+		{
+			name: "when element contains several Signature elements, it should remove only the one found by the path",
+			args: args{
+				nodeSet:       mustCreateElementFromString(`<a><aa></aa><ds:Signature Id="first"></ds:Signature><ds:Signature Id="second"></ds:Signature></a>`),
+				signaturePath: "//Signature[@Id='second']",
+			},
+			want:    []byte(`<a><aa></aa><ds:Signature Id="first"></ds:Signature></a>`),
+			wantErr: false,
+		},
+		{
+			name: "when element does not contain the Signature element found by the path, it should do nothing",
+			args: args{
+				nodeSet:       mustCreateElementFromString(`<a><aa></aa><ds:Signature Id="first"></ds:Signature></a>`),
+				signaturePath: "//Signature[@Id='second']",
+			},
+			want:    []byte(`<a><aa></aa><ds:Signature Id="first"></ds:Signature></a>`),
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transformer := &envelopedSignatureTransformer{}
+			gotElement, err := transformer.TransformEnvelopedSignature(xades4go.XML{IsOctetStream: false, NodeSet: tt.args.nodeSet}, tt.args.signaturePath)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("EnvelopedSignatureTransformer.TransformEnvelopedSignature() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			got, err := completeCanonicalization(gotElement.NodeSet.(*etree.Element))
+			if err != nil {
+				t.Errorf("CompleteCanonicalization returns error: %v", err)
+			}
+			if diff := cmp.Diff(string(tt.want), string(got)); diff != "" {
+				t.Errorf("EnvelopedSignatureTransformer.TransformEnvelopedSignature() result mistmatch (-want+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestEtreeBase64Transformer_Transform(t *testing.T) {
+	type args struct {
+		input xades4go.XML
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    []byte
+		wantErr bool
+	}{
+		{
+			name: "when node set is given, it should decode the text content of the node set",
+			args: args{
+				input: xades4go.XML{IsOctetStream: false, NodeSet: mustCreateElementFromString(`<ds:Object xmlns:ds="http://www.w3.org/2000/09/xmldsig#" Id="object">SGVsbG8s
+IHdvcmxkIQ==</ds:Object>`)},
+			},
+			want:    []byte(`Hello, world!`),
+			wantErr: false,
+		},
+		{
+			name: "when octet stream is given, it should decode the octet stream",
+			args: args{
+				input: xades4go.XML{IsOctetStream: true, OctetStream: []byte(`SGVsbG8sIHdvcmxkIQ==`)},
+			},
+			want:    []byte(`Hello, world!`),
+			wantErr: false,
+		},
+		{
+			name: "when the content is not base64-encoded, it should return error",
+			args: args{
+				input: xades4go.XML{IsOctetStream: true, OctetStream: []byte(`<not-base64>`)},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transformer := &base64Transformer{}
+			got, err := transformer.Transform(tt.args.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Base64Transformer.Transform() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if diff := cmp.Diff(string(tt.want), string(got.OctetStream)); diff != "" {
+				t.Errorf("Base64Transformer.Transform() result mistmatch (-want+got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,363 @@
+package xades4go
+
+// This file exports the test doubles used by the tests of xades4go_test package. They are not a part of the API of this package.
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	ocspResponseContentType          = "application/ocsp-response"
+	ocspResponseStatusMalformed      = 1
+	ocspResponseStatusInternalError  = 2
+	ocspCertStatusUnknownTag         = 2
+	inProcessOCSPResponseValidPeriod = time.Hour
+
+	timeStampReplyContentType = "application/timestamp-reply"
+	pkiStatusRejection        = 2
+	pkiFailureInfoBadAlg      = 0
+	pkiFailureInfoBadRequest  = 2
+	pkiFailureInfoBadDataForm = 5
+)
+
+var oidAnyPolicy = asn1.ObjectIdentifier{2, 5, 29, 32, 0}
+
+// InProcessOCSPResponder is a minimal RFC 6960 OCSP responder that signs responses in-process with the given key.
+// It can be used directly as OCSPFetcher, or served as http.Handler behind NewHTTPOCSPFetcher.
+type InProcessOCSPResponder struct {
+	signer            crypto.Signer
+	certificate       *x509.Certificate
+	issuerCertificate *x509.Certificate
+	clock             func() time.Time
+	mutex             sync.Mutex
+	revocations       map[string]ocspRevokedInfo
+}
+
+// NewInProcessOCSPResponder creates InProcessOCSPResponder that answers for the certificates issued by issuerCertificate.
+// Responses are signed with signer (RSA or ECDSA) whose certificate is certificate, which is either issuerCertificate or a delegated responder certificate issued by it.
+// clock returns thisUpdate and producedAt of the responses, whose nextUpdate is an hour later; time.Now is used if it is nil.
+func NewInProcessOCSPResponder(signer crypto.Signer, certificate *x509.Certificate, issuerCertificate *x509.Certificate, clock func() time.Time) (*InProcessOCSPResponder, error) {
+	switch signer.Public().(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+	default:
+		return nil, fmt.Errorf("this package does not implement OCSP signing with %T public key", signer.Public())
+	}
+	if clock == nil {
+		clock = time.Now
+	}
+	return &InProcessOCSPResponder{
+		signer:            signer,
+		certificate:       certificate,
+		issuerCertificate: issuerCertificate,
+		clock:             clock,
+		revocations:       make(map[string]ocspRevokedInfo),
+	}, nil
+}
+
+// Revoke makes the responder answer that the certificate of serialNumber is revoked since revocationTime for reason (CRLReason code of RFC 5280).
+func (responder *InProcessOCSPResponder) Revoke(serialNumber *big.Int, revocationTime time.Time, reason int) {
+	responder.mutex.Lock()
+	defer responder.mutex.Unlock()
+	responder.revocations[serialNumber.String()] = ocspRevokedInfo{RevocationTime: revocationTime.UTC().Truncate(time.Second), RevocationReason: asn1.Enumerated(reason)}
+}
+
+func (responder *InProcessOCSPResponder) FetchOCSPResponse(url string, request []byte) ([]byte, error) {
+	return responder.respond(request)
+}
+
+// ServeHTTP answers OCSP requests posted with application/ocsp-request content type.
+func (responder *InProcessOCSPResponder) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	requestBytes, err := ioutil.ReadAll(io.LimitReader(request.Body, maxOCSPMessageSize))
+	if err != nil {
+		http.Error(writer, "cannot read request body", http.StatusBadRequest)
+		return
+	}
+	responseBytes, err := responder.respond(requestBytes)
+	if err != nil {
+		http.Error(writer, "cannot encode OCSP response", http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Set("Content-Type", ocspResponseContentType)
+	if _, err := writer.Write(responseBytes); err != nil {
+		log.Printf("cannot write OCSP response: %v", err)
+	}
+}
+
+// respond returns DER-encoded OCSP response to requestBytes, signed with SHA-256. The certificates not issued by issuerCertificate are answered unknown,
+// and the other ones are answered revoked if their serial numbers are given to Revoke, or good otherwise.
+func (responder *InProcessOCSPResponder) respond(requestBytes []byte) ([]byte, error) {
+	var request ocspRequest
+	rest, err := asn1.Unmarshal(requestBytes, &request)
+	if err != nil || len(rest) > 0 || len(request.TBSRequest.RequestList) == 0 {
+		return asn1.Marshal(ocspResponse{ResponseStatus: ocspResponseStatusMalformed})
+	}
+	now := responder.clock().UTC().Truncate(time.Second)
+	responseData := ocspResponseData{
+		ResponderID: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: ocspResponderIDByNameTag, IsCompound: true, Bytes: responder.certificate.RawSubject},
+		ProducedAt:  now,
+	}
+	for _, singleRequest := range request.TBSRequest.RequestList {
+		singleResponse := ocspSingleResponse{
+			CertID:     singleRequest.CertID,
+			CertStatus: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: ocspCertStatusUnknownTag},
+			ThisUpdate: now,
+			NextUpdate: now.Add(inProcessOCSPResponseValidPeriod),
+		}
+		if singleRequest.CertID.SerialNumber != nil && singleRequest.CertID.isIssuedBy(responder.issuerCertificate) {
+			singleResponse.CertStatus = asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: ocspCertStatusGoodTag}
+			responder.mutex.Lock()
+			revokedInfo, isRevoked := responder.revocations[singleRequest.CertID.SerialNumber.String()]
+			responder.mutex.Unlock()
+			if isRevoked {
+				encodedRevokedInfo, err := asn1.MarshalWithParams(revokedInfo, fmt.Sprintf("tag:%d", ocspCertStatusRevokedTag))
+				if err != nil {
+					return asn1.Marshal(ocspResponse{ResponseStatus: ocspResponseStatusInternalError})
+				}
+				singleResponse.CertStatus = asn1.RawValue{FullBytes: encodedRevokedInfo}
+			}
+		}
+		responseData.Responses = append(responseData.Responses, singleResponse)
+	}
+	for _, extension := range request.TBSRequest.RequestExtensions {
+		if extension.Id.Equal(oidOCSPNonce) {
+			responseData.ResponseExtensions = append(responseData.ResponseExtensions, pkix.Extension{Id: oidOCSPNonce, Value: extension.Value})
+		}
+	}
+	encodedResponseData, err := asn1.Marshal(responseData)
+	if err != nil {
+		return asn1.Marshal(ocspResponse{ResponseStatus: ocspResponseStatusInternalError})
+	}
+	signatureAlgorithm := pkix.AlgorithmIdentifier{Algorithm: oidSHA256WithRSA, Parameters: asn1.NullRawValue}
+	if _, ok := responder.signer.Public().(*ecdsa.PublicKey); ok {
+		signatureAlgorithm = pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA256}
+	}
+	signature, err := responder.signer.Sign(rand.Reader, sha256Of(encodedResponseData), crypto.SHA256)
+	if err != nil {
+		return asn1.Marshal(ocspResponse{ResponseStatus: ocspResponseStatusInternalError})
+	}
+	basicResponse := basicOCSPResponse{
+		TBSResponseData:    ocspResponseData{Raw: encodedResponseData},
+		SignatureAlgorithm: signatureAlgorithm,
+		Signature:          asn1.BitString{Bytes: signature, BitLength: 8 * len(signature)},
+	}
+	if responder.certificate != responder.issuerCertificate {
+		basicResponse.Certificates = []asn1.RawValue{{FullBytes: responder.certificate.Raw}}
+	}
+	encodedBasicResponse, err := asn1.Marshal(basicResponse)
+	if err != nil {
+		return asn1.Marshal(ocspResponse{ResponseStatus: ocspResponseStatusInternalError})
+	}
+	return asn1.Marshal(ocspResponse{
+		ResponseStatus: ocspResponseStatusSuccessful,
+		ResponseBytes:  ocspResponseBytes{ResponseType: oidOCSPBasicResponse, Response: encodedBasicResponse},
+	})
+}
+
+// InProcessTimeStampAuthority is a minimal RFC 3161 time-stamping authority that signs time-stamp tokens in-process with the given key.
+// It can be used directly as TimeStampProvider, or served as http.Handler behind NewHTTPTimeStampProvider.
+type InProcessTimeStampAuthority struct {
+	signer       crypto.Signer
+	certificate  *x509.Certificate
+	clock        func() time.Time
+	mutex        sync.Mutex
+	serialNumber int64
+}
+
+// NewInProcessTimeStampAuthority creates InProcessTimeStampAuthority that signs tokens with signer (RSA or ECDSA) whose certificate is certificate.
+// clock returns genTime of the tokens; time.Now is used if it is nil.
+func NewInProcessTimeStampAuthority(signer crypto.Signer, certificate *x509.Certificate, clock func() time.Time) (*InProcessTimeStampAuthority, error) {
+	switch signer.Public().(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+	default:
+		return nil, fmt.Errorf("this package does not implement time-stamping with %T public key", signer.Public())
+	}
+	if clock == nil {
+		clock = time.Now
+	}
+	return &InProcessTimeStampAuthority{signer: signer, certificate: certificate, clock: clock}, nil
+}
+
+func (authority *InProcessTimeStampAuthority) TimeStamp(hashAlgorithm crypto.Hash, hashedMessage []byte) ([]byte, error) {
+	hashAlgorithmIdentifier, err := hashAlgorithmIdentifierOf(hashAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	return authority.createTimeStampToken(messageImprint{HashAlgorithm: hashAlgorithmIdentifier, HashedMessage: hashedMessage}, nil, true)
+}
+
+// ServeHTTP answers TimeStampReq posted with application/timestamp-query content type.
+func (authority *InProcessTimeStampAuthority) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	requestBytes, err := ioutil.ReadAll(io.LimitReader(request.Body, maxTimeStampResponseSize))
+	if err != nil {
+		http.Error(writer, "cannot read request body", http.StatusBadRequest)
+		return
+	}
+	resp := authority.respond(requestBytes)
+	responseBytes, err := asn1.Marshal(resp)
+	if err != nil {
+		http.Error(writer, "cannot encode TimeStampResp", http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Set("Content-Type", timeStampReplyContentType)
+	if _, err := writer.Write(responseBytes); err != nil {
+		log.Printf("cannot write TimeStampResp: %v", err)
+	}
+}
+
+func (authority *InProcessTimeStampAuthority) respond(requestBytes []byte) timeStampResp {
+	var req timeStampReq
+	rest, err := asn1.Unmarshal(requestBytes, &req)
+	if err != nil || len(rest) > 0 || req.Version != 1 {
+		return rejectedTimeStampResp(pkiFailureInfoBadDataForm)
+	}
+	hashAlgorithm, err := hashAlgorithmOf(req.MessageImprint.HashAlgorithm.Algorithm)
+	if err != nil {
+		return rejectedTimeStampResp(pkiFailureInfoBadAlg)
+	}
+	if len(req.MessageImprint.HashedMessage) != hashAlgorithm.Size() {
+		return rejectedTimeStampResp(pkiFailureInfoBadDataForm)
+	}
+	if len(req.ReqPolicy) > 0 && !req.ReqPolicy.Equal(oidAnyPolicy) {
+		return rejectedTimeStampResp(pkiFailureInfoBadRequest)
+	}
+	token, err := authority.createTimeStampToken(req.MessageImprint, req.Nonce, req.CertReq)
+	if err != nil {
+		return rejectedTimeStampResp(pkiFailureInfoBadRequest)
+	}
+	return timeStampResp{Status: pkiStatusInfo{Status: pkiStatusGranted}, TimeStampToken: asn1.RawValue{FullBytes: token}}
+}
+
+func rejectedTimeStampResp(failureInfo int) timeStampResp {
+	failInfo := asn1.BitString{Bytes: make([]byte, failureInfo/8+1), BitLength: failureInfo + 1}
+	failInfo.Bytes[failureInfo/8] |= 0x80 >> uint(failureInfo%8)
+	return timeStampResp{Status: pkiStatusInfo{Status: pkiStatusRejection, FailInfo: failInfo}}
+}
+
+func (authority *InProcessTimeStampAuthority) nextSerialNumber() *big.Int {
+	authority.mutex.Lock()
+	defer authority.mutex.Unlock()
+	authority.serialNumber++
+	return big.NewInt(authority.serialNumber)
+}
+
+// createTimeStampToken creates TimeStampToken signed with SHA-256. Its signed attributes carry SigningCertificateV2 that binds the token to the TSA certificate.
+func (authority *InProcessTimeStampAuthority) createTimeStampToken(imprint messageImprint, nonce *big.Int, includeCertificate bool) ([]byte, error) {
+	encodedTSTInfo, err := asn1.Marshal(tstInfo{
+		Version:        1,
+		Policy:         oidAnyPolicy,
+		MessageImprint: imprint,
+		SerialNumber:   authority.nextSerialNumber(),
+		GenTime:        authority.clock().UTC().Truncate(time.Second),
+		Nonce:          nonce,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode TSTInfo: %w", err)
+	}
+	signedAttributes, err := encodeSignedAttributes([]signedAttribute{
+		{oid: oidAttributeContentType, value: oidTSTInfo},
+		{oid: oidAttributeMessageDigest, value: sha256Of(encodedTSTInfo)},
+		{oid: oidAttributeSigningCertificateV2, value: signingCertificateV2{Certs: []essCertIDv2{{CertHash: sha256Of(authority.certificate.Raw)}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	// signed attributes are signed as SET OF but put in SignerInfo with IMPLICIT [0] tag.
+	signedAttributesToBeSigned, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: signedAttributes})
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode signed attributes: %w", err)
+	}
+	signatureAlgorithm := pkix.AlgorithmIdentifier{Algorithm: oidSHA256WithRSA, Parameters: asn1.NullRawValue}
+	if _, ok := authority.signer.Public().(*ecdsa.PublicKey); ok {
+		signatureAlgorithm = pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA256}
+	}
+	signature, err := authority.signer.Sign(rand.Reader, sha256Of(signedAttributesToBeSigned), crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("cannot sign time-stamp token: %w", err)
+	}
+	sid, err := asn1.Marshal(issuerAndSerialNumber{Issuer: asn1.RawValue{FullBytes: authority.certificate.RawIssuer}, SerialNumber: authority.certificate.SerialNumber})
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode IssuerAndSerialNumber: %w", err)
+	}
+	sha256AlgorithmIdentifier := pkix.AlgorithmIdentifier{Algorithm: oidSHA256}
+	data := signedData{
+		Version:          3,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{sha256AlgorithmIdentifier},
+		EncapContentInfo: encapsulatedContentInfo{EContentType: oidTSTInfo, EContent: encodedTSTInfo},
+		SignerInfos: []signerInfo{
+			{
+				Version:            1,
+				SID:                asn1.RawValue{FullBytes: sid},
+				DigestAlgorithm:    sha256AlgorithmIdentifier,
+				SignedAttrs:        asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signedAttributes},
+				SignatureAlgorithm: signatureAlgorithm,
+				Signature:          signature,
+			},
+		},
+	}
+	if includeCertificate {
+		data.Certificates = asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: authority.certificate.Raw}
+	}
+	encodedSignedData, err := asn1.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode SignedData: %w", err)
+	}
+	return asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: encodedSignedData},
+	})
+}
+
+type signedAttribute struct {
+	oid   asn1.ObjectIdentifier
+	value interface{}
+}
+
+// encodeSignedAttributes returns the content octets of DER-encoded SET OF Attribute, which must be ordered by their encoding.
+func encodeSignedAttributes(signedAttributes []signedAttribute) ([]byte, error) {
+	encodedAttributes := make([][]byte, 0, len(signedAttributes))
+	for _, signedAttribute := range signedAttributes {
+		encodedValue, err := asn1.Marshal(signedAttribute.value)
+		if err != nil {
+			return nil, fmt.Errorf("cannot encode value of attribute %s: %w", signedAttribute.oid, err)
+		}
+		encodedAttribute, err := asn1.Marshal(attribute{Type: signedAttribute.oid, Values: []asn1.RawValue{{FullBytes: encodedValue}}})
+		if err != nil {
+			return nil, fmt.Errorf("cannot encode attribute %s: %w", signedAttribute.oid, err)
+		}
+		encodedAttributes = append(encodedAttributes, encodedAttribute)
+	}
+	sort.Slice(encodedAttributes, func(i, j int) bool {
+		return bytes.Compare(encodedAttributes[i], encodedAttributes[j]) < 0
+	})
+	return bytes.Join(encodedAttributes, nil), nil
+}
+
+func sha256Of(input []byte) []byte {
+	digest := sha256.Sum256(input)
+	return digest[:]
+}
@@ -0,0 +1,133 @@
+package xades4go
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+const (
+	hmacOutputLengthElementTag = "HMACOutputLength"
+
+	// minimumHMACOutputLength is the shortest HMACOutputLength in bits accepted by the validator, regardless of the hash (XMLDSig 1.1 section 6.3.1).
+	minimumHMACOutputLength = 80
+)
+
+// HMACKeySelector is an object that selects the shared secret verifying a signature made with HMAC signature algorithms.
+// keyName is the text of the first KeyName element of KeyInfo element, or empty if the signature has none.
+type HMACKeySelector interface {
+	SelectHMACKey(keyName string, signatureAlgorithm string) ([]byte, error)
+}
+
+type mapHMACKeySelector struct {
+	secrets map[string][]byte
+}
+
+// NewMapHMACKeySelector creates HMACKeySelector that selects the shared secret by exact match of KeyName to the keys of secrets.
+func NewMapHMACKeySelector(secrets map[string][]byte) HMACKeySelector {
+	return &mapHMACKeySelector{secrets: secrets}
+}
+
+func (selector *mapHMACKeySelector) SelectHMACKey(keyName string, signatureAlgorithm string) ([]byte, error) {
+	secret, ok := selector.secrets[keyName]
+	if !ok {
+		return nil, fmt.Errorf("no HMAC key for KeyName %q", keyName)
+	}
+	return secret, nil
+}
+
+// isHMACSignatureAlgorithm returns true if signatureAlgorithm is one of HMACSHA*SignatureAlgorithm.
+func isHMACSignatureAlgorithm(signatureAlgorithm string) bool {
+	switch signatureAlgorithm {
+	case HMACSHA1SignatureAlgorithm, HMACSHA256SignatureAlgorithm, HMACSHA384SignatureAlgorithm, HMACSHA512SignatureAlgorithm:
+		return true
+	}
+	return false
+}
+
+// hmacOutputLengthOf returns HMACOutputLength (in bits) of signatureMethodElement, or the full length of hashAlgorithm if it is absent.
+// A truncated output shorter than minimumHMACOutputLength or half of the hash is rejected, as the truncation attack of CVE-2009-0217 relies on it.
+func hmacOutputLengthOf(signatureMethodElement *etree.Element, hashAlgorithm crypto.Hash) (int, error) {
+	fullLength := hashAlgorithm.Size() * 8
+	hmacOutputLengthElement, err := mustFoundOnlyOneIfFound(signatureMethodElement, hmacOutputLengthElementTag)
+	if err != nil {
+		return 0, err
+	}
+	if hmacOutputLengthElement == nil {
+		return fullLength, nil
+	}
+	outputLength, err := strconv.Atoi(strings.TrimSpace(hmacOutputLengthElement.Text()))
+	if err != nil {
+		return 0, fmt.Errorf("HMACOutputLength must be an integer, got %s", hmacOutputLengthElement.Text())
+	}
+	minimumLength := minimumHMACOutputLength
+	if fullLength/2 > minimumLength {
+		minimumLength = fullLength / 2
+	}
+	if outputLength < minimumLength || outputLength > fullLength {
+		return 0, fmt.Errorf("HMACOutputLength must be between %d and %d bits for %s, got %d", minimumLength, fullLength, hashAlgorithm.String(), outputLength)
+	}
+	if outputLength%8 != 0 {
+		return 0, fmt.Errorf("HMACOutputLength must be a multiple of 8, got %d", outputLength)
+	}
+	return outputLength, nil
+}
+
+// hmacSignatureValueSigner signs with HMAC using a shared secret. The output is never truncated.
+type hmacSignatureValueSigner struct {
+	secret []byte
+}
+
+func (valueSigner *hmacSignatureValueSigner) Sign(signatureAlgorithm string, canonicalizedSignedInfo []byte) ([]byte, error) {
+	if !isHMACSignatureAlgorithm(signatureAlgorithm) {
+		return nil, fmt.Errorf("%s cannot be used with HMAC key", signatureAlgorithm)
+	}
+	hashAlgorithm, err := mapSignatureAlgorithmToCrytoHash(signatureAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	h := hmac.New(hashAlgorithm.New, valueSigner.secret)
+	_, err = h.Write(canonicalizedSignedInfo)
+	if err != nil {
+		return nil, fmt.Errorf("cannot compute HMAC of SignedInfo using %s: %w", hashAlgorithm.String(), err)
+	}
+	return []byte(base64.StdEncoding.EncodeToString(h.Sum(nil))), nil
+}
+
+// hmacSignatureValueVerifier verifies HMAC signature value truncated to outputLength bits.
+type hmacSignatureValueVerifier struct {
+	secret       []byte
+	outputLength int
+}
+
+func (verifier *hmacSignatureValueVerifier) Verify(signatureAlgorithm string, canonicalizedSignedInfo []byte, base64SignatureValue []byte) error {
+	if !isHMACSignatureAlgorithm(signatureAlgorithm) {
+		return fmt.Errorf("%s cannot be used with HMAC key", signatureAlgorithm)
+	}
+	hashAlgorithm, err := mapSignatureAlgorithmToCrytoHash(signatureAlgorithm)
+	if err != nil {
+		return err
+	}
+	h := hmac.New(hashAlgorithm.New, verifier.secret)
+	_, err = h.Write(canonicalizedSignedInfo)
+	if err != nil {
+		return fmt.Errorf("cannot compute HMAC of SignedInfo using %s: %w", hashAlgorithm.String(), err)
+	}
+	signatureValue, err := base64.StdEncoding.DecodeString(string(base64SignatureValue))
+	if err != nil {
+		return fmt.Errorf("SignatureValue is not base64-encoded: %w", err)
+	}
+	if len(signatureValue) != verifier.outputLength/8 {
+		return fmt.Errorf("SignatureValue must be %d octets, got %d", verifier.outputLength/8, len(signatureValue))
+	}
+	if !hmac.Equal(h.Sum(nil)[:verifier.outputLength/8], signatureValue) {
+		return errors.New("HMAC verification error")
+	}
+	return nil
+}
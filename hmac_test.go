@@ -0,0 +1,195 @@
+package xades4go_test
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"encoding/base64"
+	"strconv"
+	"testing"
+
+	"github.com/beevik/etree"
+	"github.com/mekpavit/xades4go"
+	"github.com/mekpavit/xades4go/etreeimpl"
+)
+
+func Test_XMLDSigHMACSignatureGenerator(t *testing.T) {
+	signedInfoFactory := etreeimpl.NewSignedInfoFactory()
+	secret := []byte("shared secret of the message bus")
+	hmacKeySelector := xades4go.NewMapHMACKeySelector(map[string][]byte{"message-bus": secret})
+	tests := []struct {
+		name                     string
+		signatureAlgorithm       string
+		wantSignatureValueOctets int
+		wantErr                  bool
+	}{
+		{
+			name:                     "When signing with HMAC-SHA1, it should pass the validation",
+			signatureAlgorithm:       xades4go.HMACSHA1SignatureAlgorithm,
+			wantSignatureValueOctets: 20,
+		},
+		{
+			name:                     "When signing with HMAC-SHA256, it should pass the validation",
+			signatureAlgorithm:       xades4go.HMACSHA256SignatureAlgorithm,
+			wantSignatureValueOctets: 32,
+		},
+		{
+			name:                     "When signing with HMAC-SHA384, it should pass the validation",
+			signatureAlgorithm:       xades4go.HMACSHA384SignatureAlgorithm,
+			wantSignatureValueOctets: 48,
+		},
+		{
+			name:                     "When signing with HMAC-SHA512, it should pass the validation",
+			signatureAlgorithm:       xades4go.HMACSHA512SignatureAlgorithm,
+			wantSignatureValueOctets: 64,
+		},
+		{
+			name:               "When signing HMAC secret with RSA signature algorithm, it should return error",
+			signatureAlgorithm: xades4go.RSASHA256SignatureAlgorithm,
+			wantErr:            true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator, err := xades4go.NewXMLDSigHMACSignatureGenerator(signedInfoFactory, secret, tt.signatureAlgorithm, xades4go.GenerateWithKeyName("message-bus"))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewXMLDSigHMACSignatureGenerator() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			signedXMLBytes, err := generator.SignXMLBytes([]byte(unsignedInvoice), []xades4go.ReferenceGenerationDetail{
+				{
+					URIOfDataObjectBeingSigned: "",
+					TransformAlgorithms:        []string{xades4go.EnvelopedSignatureTransformAlgorithm},
+					DigestAlgorithm:            xades4go.SHA256MessageDigestAlgorithm,
+				},
+			})
+			if err != nil {
+				t.Fatalf("SignXMLBytes() error = %v", err)
+			}
+			doc := etree.NewDocument()
+			if err := doc.ReadFromBytes(signedXMLBytes); err != nil {
+				t.Fatalf("cannot parse signed XML: %v", err)
+			}
+			if got := doc.FindElement("//KeyInfo/KeyName").Text(); got != "message-bus" {
+				t.Errorf("KeyName = %s, want message-bus", got)
+			}
+			signatureValue, err := base64.StdEncoding.DecodeString(doc.FindElement("//SignatureValue").Text())
+			if err != nil {
+				t.Fatalf("cannot base64-decode SignatureValue: %v", err)
+			}
+			if len(signatureValue) != tt.wantSignatureValueOctets {
+				t.Errorf("SignatureValue has %d octets, want %d", len(signatureValue), tt.wantSignatureValueOctets)
+			}
+			got, err := xades4go.NewXMLDSigSignatureValidator(signedInfoFactory, xades4go.ValidateWithHMACKeySelector(hmacKeySelector)).Validate(signedXMLBytes)
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if !got.IsSignatureValid {
+				t.Errorf("Validate() IsSignatureValid = false, want true")
+			}
+		})
+	}
+}
+
+func Test_XMLDSigSignatureValidator_HMAC(t *testing.T) {
+	signedInfoFactory := etreeimpl.NewSignedInfoFactory()
+	secret := []byte("shared secret of the message bus")
+	generator, err := xades4go.NewXMLDSigHMACSignatureGenerator(signedInfoFactory, secret, xades4go.HMACSHA256SignatureAlgorithm, xades4go.GenerateWithKeyName("message-bus"))
+	if err != nil {
+		t.Fatalf("NewXMLDSigHMACSignatureGenerator() error = %v", err)
+	}
+	signedXMLBytes, err := generator.SignXMLBytes([]byte(unsignedInvoice), []xades4go.ReferenceGenerationDetail{
+		{
+			URIOfDataObjectBeingSigned: "",
+			TransformAlgorithms:        []string{xades4go.EnvelopedSignatureTransformAlgorithm},
+			DigestAlgorithm:            xades4go.SHA256MessageDigestAlgorithm,
+		},
+	})
+	if err != nil {
+		t.Fatalf("SignXMLBytes() error = %v", err)
+	}
+	// mustSignTruncated adds HMACOutputLength element to SignatureMethod element and re-signs SignedInfo with the HMAC truncated to outputLength bits, as an attacker would.
+	mustSignTruncated := func(outputLength int) []byte {
+		doc := etree.NewDocument()
+		if err := doc.ReadFromBytes(signedXMLBytes); err != nil {
+			t.Fatalf("cannot parse signed XML: %v", err)
+		}
+		doc.FindElement("//SignatureMethod").CreateElement("ds:HMACOutputLength").SetText(strconv.Itoa(outputLength))
+		xmlBytes, err := doc.WriteToBytes()
+		if err != nil {
+			t.Fatalf("cannot serialize XML: %v", err)
+		}
+		h := hmac.New(crypto.SHA256.New, secret)
+		h.Write(mustCanonicalizeSignedInfo(t, signedInfoFactory, xmlBytes))
+		doc.FindElement("//SignatureValue").SetText(base64.StdEncoding.EncodeToString(h.Sum(nil)[:outputLength/8]))
+		xmlBytes, err = doc.WriteToBytes()
+		if err != nil {
+			t.Fatalf("cannot serialize XML: %v", err)
+		}
+		return xmlBytes
+	}
+	tests := []struct {
+		name                 string
+		xmlBytes             []byte
+		options              []xades4go.XMLDSigSignatureValidatorOption
+		wantIsSignatureValid bool
+		wantErr              bool
+	}{
+		{
+			name:                 "When the shared secret is selected by KeyName, it should be valid",
+			xmlBytes:             signedXMLBytes,
+			options:              []xades4go.XMLDSigSignatureValidatorOption{xades4go.ValidateWithHMACKeySelector(xades4go.NewMapHMACKeySelector(map[string][]byte{"message-bus": secret}))},
+			wantIsSignatureValid: true,
+		},
+		{
+			name:                 "When the selected secret is different, it should be invalid",
+			xmlBytes:             signedXMLBytes,
+			options:              []xades4go.XMLDSigSignatureValidatorOption{xades4go.ValidateWithHMACKeySelector(xades4go.NewMapHMACKeySelector(map[string][]byte{"message-bus": []byte("another secret")}))},
+			wantIsSignatureValid: false,
+		},
+		{
+			name:     "When no secret is known for KeyName, it should return error",
+			xmlBytes: signedXMLBytes,
+			options:  []xades4go.XMLDSigSignatureValidatorOption{xades4go.ValidateWithHMACKeySelector(xades4go.NewMapHMACKeySelector(map[string][]byte{"another-bus": secret}))},
+			wantErr:  true,
+		},
+		{
+			name:     "When HMACKeySelector is not given, it should return error",
+			xmlBytes: signedXMLBytes,
+			wantErr:  true,
+		},
+		{
+			name:                 "When HMACOutputLength is half of the hash, it should be valid",
+			xmlBytes:             mustSignTruncated(128),
+			options:              []xades4go.XMLDSigSignatureValidatorOption{xades4go.ValidateWithHMACKeySelector(xades4go.NewMapHMACKeySelector(map[string][]byte{"message-bus": secret}))},
+			wantIsSignatureValid: true,
+		},
+		{
+			name:     "When HMACOutputLength is shorter than half of the hash, it should return error",
+			xmlBytes: mustSignTruncated(120),
+			options:  []xades4go.XMLDSigSignatureValidatorOption{xades4go.ValidateWithHMACKeySelector(xades4go.NewMapHMACKeySelector(map[string][]byte{"message-bus": secret}))},
+			wantErr:  true,
+		},
+		{
+			name:     "When HMACOutputLength truncates HMAC to a single octet (CVE-2009-0217), it should return error",
+			xmlBytes: mustSignTruncated(8),
+			options:  []xades4go.XMLDSigSignatureValidatorOption{xades4go.ValidateWithHMACKeySelector(xades4go.NewMapHMACKeySelector(map[string][]byte{"message-bus": secret}))},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := xades4go.NewXMLDSigSignatureValidator(signedInfoFactory, tt.options...).Validate(tt.xmlBytes)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.IsSignatureValid != tt.wantIsSignatureValid {
+				t.Errorf("Validate() IsSignatureValid = %v, want %v", got.IsSignatureValid, tt.wantIsSignatureValid)
+			}
+		})
+	}
+}
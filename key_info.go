@@ -0,0 +1,350 @@
+package xades4go
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+const (
+	rsaKeyValueElementTag        = "RSAKeyValue"
+	modulusElementTag            = "Modulus"
+	exponentElementTag           = "Exponent"
+	derEncodedKeyValueElementTag = "DEREncodedKeyValue"
+	x509IssuerSerialElementTag   = "X509IssuerSerial"
+	x509SKIElementTag            = "X509SKI"
+	x509SubjectNameElementTag    = "X509SubjectName"
+	x509DigestElementTag         = "X509Digest"
+	retrievalMethodElementTag    = "RetrievalMethod"
+)
+
+// namedCurves maps URI of NamedCurve element of ECKeyValue element (RFC 4055 object identifiers) to the ECDSA curves.
+var namedCurves = map[string]elliptic.Curve{
+	"urn:oid:1.3.132.0.33":        elliptic.P224(),
+	"urn:oid:1.2.840.10045.3.1.7": elliptic.P256(),
+	"urn:oid:1.3.132.0.34":        elliptic.P384(),
+	"urn:oid:1.3.132.0.35":        elliptic.P521(),
+}
+
+// CertificateStore is an object that provides the certificates that KeyInfo element only identifies, by X509IssuerSerial, X509SKI, X509SubjectName or X509Digest element.
+type CertificateStore interface {
+	Certificates() ([]*x509.Certificate, error)
+}
+
+type certificateStore struct {
+	certificates []*x509.Certificate
+}
+
+// NewCertificateStore creates CertificateStore of certificates.
+func NewCertificateStore(certificates []*x509.Certificate) CertificateStore {
+	return &certificateStore{certificates: certificates}
+}
+
+func (store *certificateStore) Certificates() ([]*x509.Certificate, error) {
+	return store.certificates, nil
+}
+
+// certificateIdentifier reports whether a certificate is the one identified by an element of X509Data element.
+type certificateIdentifier func(certificate *x509.Certificate) bool
+
+// keyInfoParser collects the content of KeyInfo element into keyInfo.
+type keyInfoParser struct {
+	keyInfo     *KeyInfo
+	identifiers []certificateIdentifier
+}
+
+// parseKeyInfoElement parses keyInfoElement into KeyInfo. It returns nil if keyInfoElement is nil.
+// The certificates identified by X509Data element are looked up among the attached certificates and the ones of certificateStore (which may be nil), and added to Certificates of KeyInfo.
+func parseKeyInfoElement(keyInfoElement *etree.Element, certificateStore CertificateStore) (*KeyInfo, error) {
+	if keyInfoElement == nil {
+		return nil, nil
+	}
+	parser := &keyInfoParser{keyInfo: &KeyInfo{Certificates: make([]*x509.Certificate, 0), PublicKeys: make([]crypto.PublicKey, 0)}}
+	for childIndex, childElement := range keyInfoElement.ChildElements() {
+		var err error
+		switch childElement.Tag {
+		case keyNameElementTag:
+			parser.keyInfo.KeyNames = append(parser.keyInfo.KeyNames, strings.TrimSpace(childElement.Text()))
+		case retrievalMethodElementTag:
+			err = parser.parseRetrievalMethodElement(childElement)
+		default:
+			err = parser.parseKeyElement(childElement)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("at %s#%d element: %w", childElement.Tag, childIndex, err)
+		}
+	}
+	if len(parser.identifiers) == 0 {
+		return parser.keyInfo, nil
+	}
+	candidates := append([]*x509.Certificate{}, parser.keyInfo.Certificates...)
+	if certificateStore != nil {
+		storedCertificates, err := certificateStore.Certificates()
+		if err != nil {
+			return nil, fmt.Errorf("cannot get certificates from CertificateStore: %w", err)
+		}
+		candidates = append(candidates, storedCertificates...)
+	}
+	for _, identifier := range parser.identifiers {
+		for _, candidate := range candidates {
+			if identifier(candidate) && !containsCertificate(parser.keyInfo.Certificates, candidate) {
+				parser.keyInfo.Certificates = append(parser.keyInfo.Certificates, candidate)
+			}
+		}
+	}
+	return parser.keyInfo, nil
+}
+
+// parseKeyElement parses an element that carries or identifies a key, either as a child of KeyInfo element or as the target of RetrievalMethod element.
+// Elements that this package does not understand (PGPData, SPKIData, MgmtData for example) are ignored.
+func (parser *keyInfoParser) parseKeyElement(element *etree.Element) error {
+	switch element.Tag {
+	case x509DataElementTag:
+		return parser.parseX509DataElement(element)
+	case keyValueElementTag:
+		for _, keyValueChildElement := range element.ChildElements() {
+			if err := parser.parseKeyElement(keyValueChildElement); err != nil {
+				return fmt.Errorf("at %s element: %w", keyValueChildElement.Tag, err)
+			}
+		}
+	case rsaKeyValueElementTag, dsaKeyValueElementTag, ecKeyValueElementTag:
+		publicKey, err := parseKeyValueChildElement(element)
+		if err != nil {
+			return err
+		}
+		parser.keyInfo.PublicKeys = append(parser.keyInfo.PublicKeys, publicKey)
+	case derEncodedKeyValueElementTag:
+		der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(element.Text()), ""))
+		if err != nil {
+			return fmt.Errorf("cannot base64-decode DEREncodedKeyValue: %w", err)
+		}
+		publicKey, err := x509.ParsePKIXPublicKey(der)
+		if err != nil {
+			return fmt.Errorf("cannot parse DEREncodedKeyValue: %w", err)
+		}
+		parser.keyInfo.PublicKeys = append(parser.keyInfo.PublicKeys, publicKey)
+	}
+	return nil
+}
+
+// parseRetrievalMethodElement parses the element of the same document referenced by URI attribute (#id) of retrievalMethodElement.
+// RetrievalMethod with Transforms or pointing outside the document is not supported.
+func (parser *keyInfoParser) parseRetrievalMethodElement(retrievalMethodElement *etree.Element) error {
+	uri := retrievalMethodElement.SelectAttrValue(uriAttributeKey, "")
+	if !strings.HasPrefix(uri, "#") || len(uri) == 1 {
+		return fmt.Errorf("this package only implements same-document RetrievalMethod (#id), got URI %q", uri)
+	}
+	if retrievalMethodElement.SelectElement(transformsElementTag) != nil {
+		return errors.New("this package does not implement Transforms of RetrievalMethod")
+	}
+	retrievedElements := findElementsByID(retrievalMethodElement, uri[1:])
+	if len(retrievedElements) != 1 {
+		return fmt.Errorf("found %d elements with Id %s, want exactly one", len(retrievedElements), uri[1:])
+	}
+	retrievedElement := retrievedElements[0]
+	if retrievedElement.Tag == retrievalMethodElementTag || retrievedElement.Tag == keyInfoElementTag {
+		return fmt.Errorf("RetrievalMethod must not retrieve %s element", retrievedElement.Tag)
+	}
+	return parser.parseKeyElement(retrievedElement)
+}
+
+// parseX509DataElement parses X509Certificate elements of x509DataElement and records the certificates identified by the other children to be looked up later.
+func (parser *keyInfoParser) parseX509DataElement(x509DataElement *etree.Element) error {
+	for _, childElement := range x509DataElement.ChildElements() {
+		switch childElement.Tag {
+		case x509CertificateElementTag:
+			certificate, err := parseX509CertificateElement(childElement)
+			if err != nil {
+				return err
+			}
+			parser.keyInfo.Certificates = append(parser.keyInfo.Certificates, certificate)
+		case x509IssuerSerialElementTag:
+			identifier, err := issuerSerialIdentifierOf(childElement)
+			if err != nil {
+				return err
+			}
+			parser.identifiers = append(parser.identifiers, identifier)
+		case x509SKIElementTag:
+			subjectKeyID, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(childElement.Text()), ""))
+			if err != nil {
+				return fmt.Errorf("cannot base64-decode X509SKI: %w", err)
+			}
+			parser.identifiers = append(parser.identifiers, func(certificate *x509.Certificate) bool {
+				return len(certificate.SubjectKeyId) > 0 && bytes.Equal(certificate.SubjectKeyId, subjectKeyID)
+			})
+		case x509SubjectNameElementTag:
+			subjectName := childElement.Text()
+			parser.identifiers = append(parser.identifiers, func(certificate *x509.Certificate) bool {
+				return isSameDistinguishedName(subjectName, certificate.RawSubject)
+			})
+		case x509DigestElementTag:
+			algorithmAttribute, err := mustFoundAttribute(childElement, algorithmAttributeKey)
+			if err != nil {
+				return err
+			}
+			digester, err := CreateDigester(algorithmAttribute.Value)
+			if err != nil {
+				return fmt.Errorf("at X509Digest element: %w", err)
+			}
+			digestValue := strings.Join(strings.Fields(childElement.Text()), "")
+			parser.identifiers = append(parser.identifiers, func(certificate *x509.Certificate) bool {
+				certificateDigestValue, err := digester.Digest(certificate.Raw)
+				return err == nil && string(certificateDigestValue) == digestValue
+			})
+		}
+	}
+	return nil
+}
+
+// issuerSerialIdentifierOf returns the identifier of the certificate named by X509IssuerName and X509SerialNumber elements of x509IssuerSerialElement.
+func issuerSerialIdentifierOf(x509IssuerSerialElement *etree.Element) (certificateIdentifier, error) {
+	issuerNameElement, err := mustFoundOnlyOneChildElement(x509IssuerSerialElement, x509IssuerNameElementTag)
+	if err != nil {
+		return nil, err
+	}
+	serialNumberElement, err := mustFoundOnlyOneChildElement(x509IssuerSerialElement, x509SerialNumberElementTag)
+	if err != nil {
+		return nil, err
+	}
+	serialNumber, ok := new(big.Int).SetString(strings.TrimSpace(serialNumberElement.Text()), 10)
+	if !ok {
+		return nil, fmt.Errorf("X509SerialNumber must be a decimal integer, got %s", serialNumberElement.Text())
+	}
+	issuerName := issuerNameElement.Text()
+	return func(certificate *x509.Certificate) bool {
+		return certificate.SerialNumber.Cmp(serialNumber) == 0 && isSameDistinguishedName(issuerName, certificate.RawIssuer)
+	}, nil
+}
+
+// parseKeyValueChildElement parses RSAKeyValue, DSAKeyValue or ECKeyValue element.
+func parseKeyValueChildElement(element *etree.Element) (crypto.PublicKey, error) {
+	switch element.Tag {
+	case rsaKeyValueElementTag:
+		return parseRSAKeyValueElement(element)
+	case dsaKeyValueElementTag:
+		return parseDSAKeyValueElement(element)
+	case ecKeyValueElementTag:
+		return parseECKeyValueElement(element)
+	}
+	return nil, fmt.Errorf("this package does not implement %s element", element.Tag)
+}
+
+// parseRSAKeyValueElement parses Modulus and Exponent elements of rsaKeyValueElement.
+func parseRSAKeyValueElement(rsaKeyValueElement *etree.Element) (*rsa.PublicKey, error) {
+	modulus, err := parseCryptoBinaryElement(rsaKeyValueElement, modulusElementTag)
+	if err != nil {
+		return nil, err
+	}
+	exponent, err := parseCryptoBinaryElement(rsaKeyValueElement, exponentElementTag)
+	if err != nil {
+		return nil, err
+	}
+	if modulus.Sign() <= 0 || exponent.Sign() <= 0 || !exponent.IsInt64() || exponent.Int64() > int64(^uint32(0)>>1) {
+		return nil, errors.New("RSAKeyValue must have positive Modulus and Exponent that fits in 31 bits")
+	}
+	return &rsa.PublicKey{N: modulus, E: int(exponent.Int64())}, nil
+}
+
+// parseDSAKeyValueElement parses P, Q, G and Y elements of dsaKeyValueElement. The optional J, Seed and PgenCounter elements are ignored.
+func parseDSAKeyValueElement(dsaKeyValueElement *etree.Element) (*dsa.PublicKey, error) {
+	values := make(map[string]*big.Int)
+	for _, tag := range []string{"P", "Q", "G", "Y"} {
+		value, err := parseCryptoBinaryElement(dsaKeyValueElement, tag)
+		if err != nil {
+			return nil, err
+		}
+		values[tag] = value
+	}
+	publicKey := &dsa.PublicKey{Parameters: dsa.Parameters{P: values["P"], Q: values["Q"], G: values["G"]}, Y: values["Y"]}
+	if publicKey.P.Sign() <= 0 || publicKey.Q.Sign() <= 0 || publicKey.G.Sign() <= 0 || publicKey.Y.Sign() <= 0 {
+		return nil, errors.New("DSAKeyValue must have positive P, Q, G and Y")
+	}
+	return publicKey, nil
+}
+
+// parseECKeyValueElement parses ECKeyValue element with NamedCurve element, either one of namedCurves or Ed25519. Explicit ECParameters element is not supported.
+func parseECKeyValueElement(ecKeyValueElement *etree.Element) (crypto.PublicKey, error) {
+	namedCurveElement, err := mustFoundOnlyOneIfFound(ecKeyValueElement, namedCurveElementTag)
+	if err != nil {
+		return nil, err
+	}
+	if namedCurveElement == nil {
+		return nil, errors.New("this package only implements ECKeyValue with NamedCurve element")
+	}
+	publicKeyElement, err := mustFoundOnlyOneChildElement(ecKeyValueElement, publicKeyElementTag)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(publicKeyElement.Text()), ""))
+	if err != nil {
+		return nil, fmt.Errorf("cannot base64-decode PublicKey of ECKeyValue: %w", err)
+	}
+	namedCurveURI := namedCurveElement.SelectAttrValue(uriAttributeKey, "")
+	if namedCurveURI == ed25519NamedCurveURI {
+		if len(publicKey) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("Ed25519 public key must be %d octets, got %d", ed25519.PublicKeySize, len(publicKey))
+		}
+		return ed25519.PublicKey(publicKey), nil
+	}
+	curve, ok := namedCurves[namedCurveURI]
+	if !ok {
+		return nil, fmt.Errorf("this package does not implement %s named curve", namedCurveURI)
+	}
+	x, y := elliptic.Unmarshal(curve, publicKey)
+	if x == nil {
+		return nil, fmt.Errorf("PublicKey of ECKeyValue is not an uncompressed point on %s curve", curve.Params().Name)
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// parseCryptoBinaryElement parses the only childTag element of parent as a base64-encoded big-endian integer (ds:CryptoBinary).
+func parseCryptoBinaryElement(parent *etree.Element, childTag string) (*big.Int, error) {
+	element, err := mustFoundOnlyOneChildElement(parent, childTag)
+	if err != nil {
+		return nil, fmt.Errorf("at %s element: %w", parent.Tag, err)
+	}
+	value, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(element.Text()), ""))
+	if err != nil {
+		return nil, fmt.Errorf("cannot base64-decode %s of %s: %w", childTag, parent.Tag, err)
+	}
+	return new(big.Int).SetBytes(value), nil
+}
+
+// parseX509CertificatesOfKeyInfoElement parses every X509Certificate element of X509Data elements of keyInfoElement.
+func parseX509CertificatesOfKeyInfoElement(keyInfoElement *etree.Element) ([]*x509.Certificate, error) {
+	certificates := make([]*x509.Certificate, 0)
+	for _, x509Element := range keyInfoElement.SelectElements(x509DataElementTag) {
+		for _, x509CertificateElement := range x509Element.SelectElements(x509CertificateElementTag) {
+			certificate, err := parseX509CertificateElement(x509CertificateElement)
+			if err != nil {
+				return nil, err
+			}
+			certificates = append(certificates, certificate)
+		}
+	}
+	return certificates, nil
+}
+
+func parseX509CertificateElement(x509CertificateElement *etree.Element) (*x509.Certificate, error) {
+	asn1Certificate, err := base64.StdEncoding.DecodeString(x509CertificateElement.Text())
+	if err != nil {
+		return nil, errors.New("cannot base64-decode attached certificate: " + err.Error())
+	}
+	certificate, err := x509.ParseCertificate(asn1Certificate)
+	if err != nil {
+		return nil, errors.New("cannot parse attached certificate: " + err.Error())
+	}
+	return certificate, nil
+}
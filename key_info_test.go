@@ -0,0 +1,237 @@
+package xades4go_test
+
+import (
+	"crypto"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/google/go-cmp/cmp"
+	"github.com/mekpavit/xades4go"
+	"github.com/mekpavit/xades4go/etreeimpl"
+)
+
+func Test_XMLDSigSignatureValidator_KeyInfo(t *testing.T) {
+	signedInfoFactory := etreeimpl.NewSignedInfoFactory()
+	rsaKey, rsaCertificate := mustCreateRSAKeyAndCertificateWithSubjectKeyID(t)
+	ecdsaKey, ecdsaCertificate := mustCreateECDSAKeyAndSelfSignedCertificate(t, elliptic.P256())
+	mustSign := func(signer crypto.Signer, certificate *x509.Certificate, signatureAlgorithm string) []byte {
+		generator, err := xades4go.NewXMLDSigSignatureGenerator(signedInfoFactory, signer, []*x509.Certificate{certificate}, signatureAlgorithm)
+		if err != nil {
+			t.Fatalf("NewXMLDSigSignatureGenerator() error = %v", err)
+		}
+		signedXMLBytes, err := generator.SignXMLBytes([]byte(unsignedInvoice), []xades4go.ReferenceGenerationDetail{
+			{
+				URIOfDataObjectBeingSigned: "",
+				TransformAlgorithms:        []string{xades4go.EnvelopedSignatureTransformAlgorithm},
+				DigestAlgorithm:            xades4go.SHA256MessageDigestAlgorithm,
+			},
+		})
+		if err != nil {
+			t.Fatalf("SignXMLBytes() error = %v", err)
+		}
+		return signedXMLBytes
+	}
+	rsaSignedXMLBytes := mustSign(rsaKey, rsaCertificate, xades4go.RSASHA256SignatureAlgorithm)
+	ecdsaSignedXMLBytes := mustSign(ecdsaKey, ecdsaCertificate, xades4go.ECDSASHA256SignatureAlgorithm)
+	// replaceKeyInfo replaces the children of KeyInfo element, which is not signed, by the ones created by createChildren.
+	replaceKeyInfo := func(xmlBytes []byte, createChildren func(keyInfoElement *etree.Element)) []byte {
+		doc := etree.NewDocument()
+		if err := doc.ReadFromBytes(xmlBytes); err != nil {
+			t.Fatalf("cannot parse signed XML: %v", err)
+		}
+		keyInfoElement := doc.FindElement("//KeyInfo")
+		for _, childElement := range keyInfoElement.ChildElements() {
+			keyInfoElement.RemoveChild(childElement)
+		}
+		createChildren(keyInfoElement)
+		replacedXMLBytes, err := doc.WriteToBytes()
+		if err != nil {
+			t.Fatalf("cannot serialize XML: %v", err)
+		}
+		return replacedXMLBytes
+	}
+	createX509DataElement := func(keyInfoElement *etree.Element) *etree.Element {
+		x509DataElement := keyInfoElement.CreateElement("ds:X509Data")
+		x509DataElement.CreateAttr("xmlns:dsig11", "http://www.w3.org/2009/xmldsig11#")
+		return x509DataElement
+	}
+	rsaCertificateDigest := sha256.Sum256(rsaCertificate.Raw)
+	certificateStore := xades4go.NewCertificateStore([]*x509.Certificate{ecdsaCertificate, rsaCertificate})
+
+	tests := []struct {
+		name                  string
+		xmlBytes              []byte
+		options               []xades4go.XMLDSigSignatureValidatorOption
+		wantIsSignatureValid  bool
+		wantSignerCertificate *x509.Certificate
+		wantErr               bool
+	}{
+		{
+			name: "When KeyInfo has RSAKeyValue, it should be valid",
+			xmlBytes: replaceKeyInfo(rsaSignedXMLBytes, func(keyInfoElement *etree.Element) {
+				rsaKeyValueElement := keyInfoElement.CreateElement("ds:KeyValue").CreateElement("ds:RSAKeyValue")
+				rsaKeyValueElement.CreateElement("ds:Modulus").SetText(base64.StdEncoding.EncodeToString(rsaKey.N.Bytes()))
+				rsaKeyValueElement.CreateElement("ds:Exponent").SetText(base64.StdEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()))
+			}),
+			wantIsSignatureValid: true,
+		},
+		{
+			name: "When KeyInfo has ECKeyValue of P-256 curve, it should be valid",
+			xmlBytes: replaceKeyInfo(ecdsaSignedXMLBytes, func(keyInfoElement *etree.Element) {
+				ecKeyValueElement := keyInfoElement.CreateElement("ds:KeyValue").CreateElement("dsig11:ECKeyValue")
+				ecKeyValueElement.CreateAttr("xmlns:dsig11", "http://www.w3.org/2009/xmldsig11#")
+				ecKeyValueElement.CreateElement("dsig11:NamedCurve").CreateAttr("URI", "urn:oid:1.2.840.10045.3.1.7")
+				ecKeyValueElement.CreateElement("dsig11:PublicKey").SetText(base64.StdEncoding.EncodeToString(elliptic.Marshal(elliptic.P256(), ecdsaKey.X, ecdsaKey.Y)))
+			}),
+			wantIsSignatureValid: true,
+		},
+		{
+			name: "When KeyInfo has DEREncodedKeyValue, it should be valid",
+			xmlBytes: replaceKeyInfo(ecdsaSignedXMLBytes, func(keyInfoElement *etree.Element) {
+				derEncodedKeyValueElement := keyInfoElement.CreateElement("dsig11:DEREncodedKeyValue")
+				derEncodedKeyValueElement.CreateAttr("xmlns:dsig11", "http://www.w3.org/2009/xmldsig11#")
+				derEncodedKeyValueElement.SetText(base64.StdEncoding.EncodeToString(ecdsaCertificate.RawSubjectPublicKeyInfo))
+			}),
+			wantIsSignatureValid: true,
+		},
+		{
+			name: "When KeyInfo has X509IssuerSerial of a stored certificate, it should be valid",
+			xmlBytes: replaceKeyInfo(rsaSignedXMLBytes, func(keyInfoElement *etree.Element) {
+				x509IssuerSerialElement := createX509DataElement(keyInfoElement).CreateElement("ds:X509IssuerSerial")
+				x509IssuerSerialElement.CreateElement("ds:X509IssuerName").SetText(rsaCertificate.Issuer.String())
+				x509IssuerSerialElement.CreateElement("ds:X509SerialNumber").SetText(rsaCertificate.SerialNumber.String())
+			}),
+			options:               []xades4go.XMLDSigSignatureValidatorOption{xades4go.ValidateWithCertificateStore(certificateStore)},
+			wantIsSignatureValid:  true,
+			wantSignerCertificate: rsaCertificate,
+		},
+		{
+			name: "When KeyInfo has X509IssuerSerial and no CertificateStore is given, it should be invalid",
+			xmlBytes: replaceKeyInfo(rsaSignedXMLBytes, func(keyInfoElement *etree.Element) {
+				x509IssuerSerialElement := createX509DataElement(keyInfoElement).CreateElement("ds:X509IssuerSerial")
+				x509IssuerSerialElement.CreateElement("ds:X509IssuerName").SetText(rsaCertificate.Issuer.String())
+				x509IssuerSerialElement.CreateElement("ds:X509SerialNumber").SetText(rsaCertificate.SerialNumber.String())
+			}),
+			wantIsSignatureValid: false,
+		},
+		{
+			name: "When KeyInfo has X509SKI of a stored certificate, it should be valid",
+			xmlBytes: replaceKeyInfo(rsaSignedXMLBytes, func(keyInfoElement *etree.Element) {
+				createX509DataElement(keyInfoElement).CreateElement("ds:X509SKI").SetText(base64.StdEncoding.EncodeToString(rsaCertificate.SubjectKeyId))
+			}),
+			options:               []xades4go.XMLDSigSignatureValidatorOption{xades4go.ValidateWithCertificateStore(certificateStore)},
+			wantIsSignatureValid:  true,
+			wantSignerCertificate: rsaCertificate,
+		},
+		{
+			name: "When KeyInfo has X509SubjectName of a stored certificate, it should be valid",
+			xmlBytes: replaceKeyInfo(rsaSignedXMLBytes, func(keyInfoElement *etree.Element) {
+				createX509DataElement(keyInfoElement).CreateElement("ds:X509SubjectName").SetText(rsaCertificate.Subject.String())
+			}),
+			options:               []xades4go.XMLDSigSignatureValidatorOption{xades4go.ValidateWithCertificateStore(certificateStore)},
+			wantIsSignatureValid:  true,
+			wantSignerCertificate: rsaCertificate,
+		},
+		{
+			name: "When KeyInfo has X509Digest of a stored certificate, it should be valid",
+			xmlBytes: replaceKeyInfo(rsaSignedXMLBytes, func(keyInfoElement *etree.Element) {
+				x509DigestElement := createX509DataElement(keyInfoElement).CreateElement("dsig11:X509Digest")
+				x509DigestElement.CreateAttr("Algorithm", xades4go.SHA256MessageDigestAlgorithm)
+				x509DigestElement.SetText(base64.StdEncoding.EncodeToString(rsaCertificateDigest[:]))
+			}),
+			options:               []xades4go.XMLDSigSignatureValidatorOption{xades4go.ValidateWithCertificateStore(certificateStore)},
+			wantIsSignatureValid:  true,
+			wantSignerCertificate: rsaCertificate,
+		},
+		{
+			name: "When KeyInfo has RetrievalMethod to X509Data element in the same document, it should be valid",
+			xmlBytes: replaceKeyInfo(rsaSignedXMLBytes, func(keyInfoElement *etree.Element) {
+				retrievalMethodElement := keyInfoElement.CreateElement("ds:RetrievalMethod")
+				retrievalMethodElement.CreateAttr("URI", "#signer-certificate")
+				retrievalMethodElement.CreateAttr("Type", "http://www.w3.org/2000/09/xmldsig#X509Data")
+				x509DataElement := keyInfoElement.Parent().CreateElement("ds:Object").CreateElement("ds:X509Data")
+				x509DataElement.CreateAttr("Id", "signer-certificate")
+				x509DataElement.CreateElement("ds:X509Certificate").SetText(base64.StdEncoding.EncodeToString(rsaCertificate.Raw))
+			}),
+			wantIsSignatureValid:  true,
+			wantSignerCertificate: rsaCertificate,
+		},
+		{
+			name: "When RetrievalMethod URI has a quote, it should be compared with Id attributes as it is",
+			xmlBytes: replaceKeyInfo(rsaSignedXMLBytes, func(keyInfoElement *etree.Element) {
+				retrievalMethodElement := keyInfoElement.CreateElement("ds:RetrievalMethod")
+				retrievalMethodElement.CreateAttr("URI", "#signer'certificate")
+				x509DataElement := keyInfoElement.Parent().CreateElement("ds:Object").CreateElement("ds:X509Data")
+				x509DataElement.CreateAttr("Id", "signer'certificate")
+				x509DataElement.CreateElement("ds:X509Certificate").SetText(base64.StdEncoding.EncodeToString(rsaCertificate.Raw))
+			}),
+			wantIsSignatureValid:  true,
+			wantSignerCertificate: rsaCertificate,
+		},
+		{
+			name: "When RetrievalMethod URI has a quote but no element has the Id, it should return error",
+			xmlBytes: replaceKeyInfo(rsaSignedXMLBytes, func(keyInfoElement *etree.Element) {
+				keyInfoElement.CreateElement("ds:RetrievalMethod").CreateAttr("URI", "#a'b")
+			}),
+			wantErr: true,
+		},
+		{
+			name: "When KeyInfo has RetrievalMethod outside the document, it should return error",
+			xmlBytes: replaceKeyInfo(rsaSignedXMLBytes, func(keyInfoElement *etree.Element) {
+				keyInfoElement.CreateElement("ds:RetrievalMethod").CreateAttr("URI", "http://example.com/certificate.cer")
+			}),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := xades4go.NewXMLDSigSignatureValidator(signedInfoFactory, tt.options...).Validate(tt.xmlBytes)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.IsSignatureValid != tt.wantIsSignatureValid {
+				t.Errorf("Validate() IsSignatureValid = %v, want %v", got.IsSignatureValid, tt.wantIsSignatureValid)
+			}
+			if diff := cmp.Diff(tt.wantSignerCertificate, got.SignerCertificate, certificateComparer); diff != "" {
+				t.Errorf("Validate() SignerCertificate mismatch (-want+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func mustCreateRSAKeyAndCertificateWithSubjectKeyID(t *testing.T) (*rsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("cannot generate RSA key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "xades4go test signer", Organization: []string{"xades4go"}, Country: []string{"TH"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		SubjectKeyId: []byte{1, 2, 3, 4, 5, 6, 7, 8},
+	}
+	asn1Certificate, err := x509.CreateCertificate(rand.Reader, template, template, privateKey.Public(), privateKey)
+	if err != nil {
+		t.Fatalf("cannot create certificate: %v", err)
+	}
+	certificate, err := x509.ParseCertificate(asn1Certificate)
+	if err != nil {
+		t.Fatalf("cannot parse certificate: %v", err)
+	}
+	return privateKey, certificate
+}
@@ -0,0 +1,88 @@
+package xades4go
+
+import (
+	"crypto"
+	"crypto/x509"
+	"errors"
+)
+
+// KeyInfo is the content of KeyInfo element of a signature, parsed for KeySelector.
+type KeyInfo struct {
+	// KeyNames are the texts of KeyName elements.
+	KeyNames []string
+	// Certificates are the certificates of X509Certificate elements of X509Data elements in document order,
+	// followed by the certificates identified by X509IssuerSerial, X509SKI, X509SubjectName or X509Digest elements (see ValidateWithCertificateStore).
+	// RetrievalMethod elements are followed within the document.
+	Certificates []*x509.Certificate
+	// PublicKeys are the keys of KeyValue and DEREncodedKeyValue elements.
+	PublicKeys []crypto.PublicKey
+}
+
+// SelectedKey is a candidate key to verify SignatureValue element. Certificate is the certificate that contains PublicKey, if any. It is reported as SignerCertificate of ValidationResult.
+type SelectedKey struct {
+	PublicKey   crypto.PublicKey
+	Certificate *x509.Certificate
+}
+
+// KeySelector is an object that selects the candidate keys to verify SignatureValue element of asymmetric signature algorithms.
+// keyInfo is nil if the signature has no KeyInfo element. The validator tries the candidates in order and the signature is valid if any of them verifies it.
+// The shared secret of HMAC signature algorithms is selected by HMACKeySelector instead.
+type KeySelector interface {
+	SelectKeys(keyInfo *KeyInfo, signatureAlgorithm string) ([]SelectedKey, error)
+}
+
+type keyInfoKeySelector struct{}
+
+// NewKeyInfoKeySelector creates KeySelector that selects every certificate and every key value embedded in KeyInfo element. It is the default of XMLDSigSignatureValidator.
+// Anyone can embed a key, so the signer certificate should be validated against trust anchors afterwards.
+func NewKeyInfoKeySelector() KeySelector {
+	return &keyInfoKeySelector{}
+}
+
+func (selector *keyInfoKeySelector) SelectKeys(keyInfo *KeyInfo, signatureAlgorithm string) ([]SelectedKey, error) {
+	if keyInfo == nil {
+		return nil, errors.New("Signature element has no KeyInfo element, the key must be supplied with ValidateWithKeySelector option")
+	}
+	selectedKeys := make([]SelectedKey, 0, len(keyInfo.Certificates)+len(keyInfo.PublicKeys))
+	for _, certificate := range keyInfo.Certificates {
+		selectedKeys = append(selectedKeys, SelectedKey{PublicKey: certificate.PublicKey, Certificate: certificate})
+	}
+	for _, publicKey := range keyInfo.PublicKeys {
+		selectedKeys = append(selectedKeys, SelectedKey{PublicKey: publicKey})
+	}
+	return selectedKeys, nil
+}
+
+type certificateKeySelector struct {
+	certificates []*x509.Certificate
+}
+
+// NewCertificateKeySelector creates KeySelector that always selects the keys of certificates (pinned partner certificates for example), whatever KeyInfo element carries or even if it is absent.
+func NewCertificateKeySelector(certificates []*x509.Certificate) KeySelector {
+	return &certificateKeySelector{certificates: certificates}
+}
+
+func (selector *certificateKeySelector) SelectKeys(keyInfo *KeyInfo, signatureAlgorithm string) ([]SelectedKey, error) {
+	selectedKeys := make([]SelectedKey, 0, len(selector.certificates))
+	for _, certificate := range selector.certificates {
+		selectedKeys = append(selectedKeys, SelectedKey{PublicKey: certificate.PublicKey, Certificate: certificate})
+	}
+	return selectedKeys, nil
+}
+
+type publicKeySelector struct {
+	publicKeys []crypto.PublicKey
+}
+
+// NewPublicKeySelector creates KeySelector that always selects publicKeys, whatever KeyInfo element carries or even if it is absent.
+func NewPublicKeySelector(publicKeys []crypto.PublicKey) KeySelector {
+	return &publicKeySelector{publicKeys: publicKeys}
+}
+
+func (selector *publicKeySelector) SelectKeys(keyInfo *KeyInfo, signatureAlgorithm string) ([]SelectedKey, error) {
+	selectedKeys := make([]SelectedKey, 0, len(selector.publicKeys))
+	for _, publicKey := range selector.publicKeys {
+		selectedKeys = append(selectedKeys, SelectedKey{PublicKey: publicKey})
+	}
+	return selectedKeys, nil
+}
@@ -0,0 +1,127 @@
+package xades4go_test
+
+import (
+	"crypto"
+	"crypto/x509"
+	"testing"
+
+	"github.com/beevik/etree"
+	"github.com/google/go-cmp/cmp"
+	"github.com/mekpavit/xades4go"
+	"github.com/mekpavit/xades4go/etreeimpl"
+)
+
+// recordingKeySelector selects the keys of KeyInfo element like NewKeyInfoKeySelector and records the KeyInfo it receives.
+type recordingKeySelector struct {
+	keyInfo *xades4go.KeyInfo
+}
+
+func (selector *recordingKeySelector) SelectKeys(keyInfo *xades4go.KeyInfo, signatureAlgorithm string) ([]xades4go.SelectedKey, error) {
+	selector.keyInfo = keyInfo
+	return xades4go.NewKeyInfoKeySelector().SelectKeys(keyInfo, signatureAlgorithm)
+}
+
+func Test_XMLDSigSignatureValidator_KeySelector(t *testing.T) {
+	signedInfoFactory := etreeimpl.NewSignedInfoFactory()
+	partnerKey, partnerCertificate := mustCreateRSAKeyAndSelfSignedCertificate(t)
+	attackerKey, attackerCertificate := mustCreateRSAKeyAndSelfSignedCertificate(t)
+	mustSign := func(signer crypto.Signer, certificate *x509.Certificate) []byte {
+		generator, err := xades4go.NewXMLDSigSignatureGenerator(signedInfoFactory, signer, []*x509.Certificate{certificate}, xades4go.RSASHA256SignatureAlgorithm, xades4go.GenerateWithKeyName("partner"))
+		if err != nil {
+			t.Fatalf("NewXMLDSigSignatureGenerator() error = %v", err)
+		}
+		signedXMLBytes, err := generator.SignXMLBytes([]byte(unsignedInvoice), []xades4go.ReferenceGenerationDetail{
+			{
+				URIOfDataObjectBeingSigned: "",
+				TransformAlgorithms:        []string{xades4go.EnvelopedSignatureTransformAlgorithm},
+				DigestAlgorithm:            xades4go.SHA256MessageDigestAlgorithm,
+			},
+		})
+		if err != nil {
+			t.Fatalf("SignXMLBytes() error = %v", err)
+		}
+		return signedXMLBytes
+	}
+	partnerSignedXMLBytes := mustSign(partnerKey, partnerCertificate)
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(partnerSignedXMLBytes); err != nil {
+		t.Fatalf("cannot parse signed XML: %v", err)
+	}
+	signatureElement := doc.FindElement("//Signature")
+	signatureElement.RemoveChild(signatureElement.SelectElement("KeyInfo"))
+	keyInfoLessXMLBytes, err := doc.WriteToBytes()
+	if err != nil {
+		t.Fatalf("cannot serialize XML: %v", err)
+	}
+
+	tests := []struct {
+		name                  string
+		xmlBytes              []byte
+		keySelector           xades4go.KeySelector
+		wantIsSignatureValid  bool
+		wantSignerCertificate *x509.Certificate
+		wantErr               bool
+	}{
+		{
+			name:     "When Signature has no KeyInfo and no KeySelector is given, it should return error",
+			xmlBytes: keyInfoLessXMLBytes,
+			wantErr:  true,
+		},
+		{
+			name:                  "When Signature has no KeyInfo and the certificate is pinned, it should be valid",
+			xmlBytes:              keyInfoLessXMLBytes,
+			keySelector:           xades4go.NewCertificateKeySelector([]*x509.Certificate{partnerCertificate}),
+			wantIsSignatureValid:  true,
+			wantSignerCertificate: partnerCertificate,
+		},
+		{
+			name:                 "When Signature has no KeyInfo and the public key is pinned, it should be valid without signer certificate",
+			xmlBytes:             keyInfoLessXMLBytes,
+			keySelector:          xades4go.NewPublicKeySelector([]crypto.PublicKey{partnerKey.Public()}),
+			wantIsSignatureValid: true,
+		},
+		{
+			name:        "When Signature is made by an embedded attacker certificate and the partner certificate is pinned, it should be invalid",
+			xmlBytes:    mustSign(attackerKey, attackerCertificate),
+			keySelector: xades4go.NewCertificateKeySelector([]*x509.Certificate{partnerCertificate}),
+		},
+		{
+			name:                  "When Signature is made by an embedded attacker certificate and no KeySelector is given, the embedded certificate should verify it",
+			xmlBytes:              mustSign(attackerKey, attackerCertificate),
+			wantIsSignatureValid:  true,
+			wantSignerCertificate: attackerCertificate,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var options []xades4go.XMLDSigSignatureValidatorOption
+			if tt.keySelector != nil {
+				options = append(options, xades4go.ValidateWithKeySelector(tt.keySelector))
+			}
+			got, err := xades4go.NewXMLDSigSignatureValidator(signedInfoFactory, options...).Validate(tt.xmlBytes)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.IsSignatureValid != tt.wantIsSignatureValid {
+				t.Errorf("Validate() IsSignatureValid = %v, want %v", got.IsSignatureValid, tt.wantIsSignatureValid)
+			}
+			if diff := cmp.Diff(tt.wantSignerCertificate, got.SignerCertificate, certificateComparer); diff != "" {
+				t.Errorf("Validate() SignerCertificate mismatch (-want+got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("When KeySelector is given, it should receive the parsed KeyInfo", func(t *testing.T) {
+		keySelector := &recordingKeySelector{}
+		if _, err := xades4go.NewXMLDSigSignatureValidator(signedInfoFactory, xades4go.ValidateWithKeySelector(keySelector)).Validate(partnerSignedXMLBytes); err != nil {
+			t.Fatalf("Validate() error = %v", err)
+		}
+		want := &xades4go.KeyInfo{KeyNames: []string{"partner"}, Certificates: []*x509.Certificate{partnerCertificate}, PublicKeys: []crypto.PublicKey{}}
+		if diff := cmp.Diff(want, keySelector.keyInfo, certificateComparer); diff != "" {
+			t.Errorf("SelectKeys() keyInfo mismatch (-want+got):\n%s", diff)
+		}
+	})
+}
@@ -0,0 +1,320 @@
+package xades4go
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+const (
+	ocspRequestContentType = "application/ocsp-request"
+	maxOCSPMessageSize     = 1 << 20
+
+	ocspResponseStatusSuccessful = 0
+	ocspResponderIDByNameTag     = 1
+	ocspResponderIDByKeyTag      = 2
+	ocspCertStatusGoodTag        = 0
+	ocspCertStatusRevokedTag     = 1
+)
+
+var (
+	oidOCSPBasicResponse = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+	oidOCSPNonce         = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 2}
+)
+
+type ocspCertID struct {
+	HashAlgorithm  pkix.AlgorithmIdentifier
+	IssuerNameHash []byte
+	IssuerKeyHash  []byte
+	SerialNumber   *big.Int
+}
+
+type ocspSingleRequest struct {
+	CertID ocspCertID
+}
+
+type ocspTBSRequest struct {
+	Version           int `asn1:"explicit,tag:0,default:0,optional"`
+	RequestList       []ocspSingleRequest
+	RequestExtensions []pkix.Extension `asn1:"explicit,tag:2,optional"`
+}
+
+type ocspRequest struct {
+	TBSRequest ocspTBSRequest
+}
+
+type ocspResponseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type ocspResponse struct {
+	ResponseStatus asn1.Enumerated
+	ResponseBytes  ocspResponseBytes `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspRevokedInfo struct {
+	RevocationTime   time.Time       `asn1:"generalized"`
+	RevocationReason asn1.Enumerated `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspSingleResponse struct {
+	CertID     ocspCertID
+	CertStatus asn1.RawValue
+	ThisUpdate time.Time        `asn1:"generalized"`
+	NextUpdate time.Time        `asn1:"generalized,explicit,tag:0,optional"`
+	Extensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type ocspResponseData struct {
+	Raw                asn1.RawContent
+	Version            int `asn1:"explicit,tag:0,default:0,optional"`
+	ResponderID        asn1.RawValue
+	ProducedAt         time.Time `asn1:"generalized"`
+	Responses          []ocspSingleResponse
+	ResponseExtensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type basicOCSPResponse struct {
+	TBSResponseData    ocspResponseData
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+	Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type subjectPublicKeyInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	PublicKey asn1.BitString
+}
+
+// createOCSPCertID creates CertID of the certificate of serialNumber issued by issuer, hashing the issuer name and key with hashAlgorithm.
+func createOCSPCertID(serialNumber *big.Int, issuer *x509.Certificate, hashAlgorithm crypto.Hash) (ocspCertID, error) {
+	hashAlgorithmIdentifier, err := hashAlgorithmIdentifierOf(hashAlgorithm)
+	if err != nil {
+		return ocspCertID{}, err
+	}
+	hashAlgorithmIdentifier.Parameters = asn1.NullRawValue
+	var publicKeyInfo subjectPublicKeyInfo
+	if _, err := asn1.Unmarshal(issuer.RawSubjectPublicKeyInfo, &publicKeyInfo); err != nil {
+		return ocspCertID{}, fmt.Errorf("cannot parse public key of issuer certificate: %w", err)
+	}
+	issuerNameHash := hashAlgorithm.New()
+	if _, err := issuerNameHash.Write(issuer.RawSubject); err != nil {
+		return ocspCertID{}, fmt.Errorf("cannot hash issuer name: %w", err)
+	}
+	issuerKeyHash := hashAlgorithm.New()
+	if _, err := issuerKeyHash.Write(publicKeyInfo.PublicKey.RightAlign()); err != nil {
+		return ocspCertID{}, fmt.Errorf("cannot hash issuer key: %w", err)
+	}
+	return ocspCertID{
+		HashAlgorithm:  hashAlgorithmIdentifier,
+		IssuerNameHash: issuerNameHash.Sum(nil),
+		IssuerKeyHash:  issuerKeyHash.Sum(nil),
+		SerialNumber:   serialNumber,
+	}, nil
+}
+
+// matches returns true if certID identifies the certificate of serialNumber issued by issuer, whatever hash algorithm certID is created with.
+func (certID ocspCertID) matches(serialNumber *big.Int, issuer *x509.Certificate) bool {
+	return certID.SerialNumber != nil && serialNumber != nil && certID.SerialNumber.Cmp(serialNumber) == 0 && certID.isIssuedBy(issuer)
+}
+
+// isIssuedBy returns true if IssuerNameHash and IssuerKeyHash of certID are the hashes of the name and the key of issuer.
+func (certID ocspCertID) isIssuedBy(issuer *x509.Certificate) bool {
+	hashAlgorithm, err := hashAlgorithmOf(certID.HashAlgorithm.Algorithm)
+	if err != nil {
+		return false
+	}
+	expectedCertID, err := createOCSPCertID(certID.SerialNumber, issuer, hashAlgorithm)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(certID.IssuerNameHash, expectedCertID.IssuerNameHash) && bytes.Equal(certID.IssuerKeyHash, expectedCertID.IssuerKeyHash)
+}
+
+// createOCSPRequest creates DER-encoded OCSP request for certificate with a random nonce extension. It returns the request and the DER-encoded value of the nonce extension.
+func createOCSPRequest(certificate *x509.Certificate, issuer *x509.Certificate) ([]byte, []byte, error) {
+	certID, err := createOCSPCertID(certificate.SerialNumber, issuer, crypto.SHA1)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, fmt.Errorf("cannot generate nonce: %w", err)
+	}
+	nonceValue, err := asn1.Marshal(nonce)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot encode nonce: %w", err)
+	}
+	request, err := asn1.Marshal(ocspRequest{TBSRequest: ocspTBSRequest{
+		RequestList:       []ocspSingleRequest{{CertID: certID}},
+		RequestExtensions: []pkix.Extension{{Id: oidOCSPNonce, Value: nonceValue}},
+	}})
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot encode OCSP request: %w", err)
+	}
+	return request, nonceValue, nil
+}
+
+// parseBasicOCSPResponse parses DER-encoded OCSP response, which must be successful and of the basic response type.
+func parseBasicOCSPResponse(responseBytes []byte) (*basicOCSPResponse, error) {
+	var response ocspResponse
+	rest, err := asn1.Unmarshal(responseBytes, &response)
+	if err != nil || len(rest) > 0 {
+		return nil, errors.New("it is not a DER-encoded OCSPResponse")
+	}
+	if response.ResponseStatus != ocspResponseStatusSuccessful {
+		return nil, fmt.Errorf("responder returned status %d", response.ResponseStatus)
+	}
+	if !response.ResponseBytes.ResponseType.Equal(oidOCSPBasicResponse) {
+		return nil, fmt.Errorf("response type %s is not the basic response type", response.ResponseBytes.ResponseType)
+	}
+	var basicResponse basicOCSPResponse
+	rest, err = asn1.Unmarshal(response.ResponseBytes.Response, &basicResponse)
+	if err != nil || len(rest) > 0 {
+		return nil, errors.New("it does not contain a valid BasicOCSPResponse")
+	}
+	return &basicResponse, nil
+}
+
+// verifyOCSPResponse verifies DER-encoded OCSP response for certificate issued by issuer, and returns the status of certificate at validationTime.
+// The response must be signed by issuer or by a responder certificate that issuer delegated with id-kp-OCSPSigning extended key usage,
+// echo nonce if both nonce (nil for responses not requested by this package) and its nonce extension exist, and tell the status at validationTime without being issued after currentTime (see checkRevocationDataFreshness).
+// It returns errRevocationDataNotApplicable if the response does not cover certificate.
+func verifyOCSPResponse(responseBytes []byte, certificate *x509.Certificate, issuer *x509.Certificate, nonce []byte, validationTime time.Time, currentTime time.Time) (CertificateRevocationResult, error) {
+	basicResponse, err := parseBasicOCSPResponse(responseBytes)
+	if err != nil {
+		return CertificateRevocationResult{}, err
+	}
+	responseData := basicResponse.TBSResponseData
+	var singleResponse *ocspSingleResponse
+	for index := range responseData.Responses {
+		if responseData.Responses[index].CertID.matches(certificate.SerialNumber, issuer) {
+			singleResponse = &responseData.Responses[index]
+			break
+		}
+	}
+	if singleResponse == nil {
+		return CertificateRevocationResult{}, errRevocationDataNotApplicable
+	}
+	responder, err := findOCSPResponderCertificate(basicResponse, issuer)
+	if err != nil {
+		return CertificateRevocationResult{}, err
+	}
+	signatureAlgorithm, err := x509SignatureAlgorithmOf(basicResponse.SignatureAlgorithm.Algorithm, 0)
+	if err != nil {
+		return CertificateRevocationResult{}, err
+	}
+	err = responder.CheckSignature(signatureAlgorithm, responseData.Raw, basicResponse.Signature.RightAlign())
+	if err != nil {
+		return CertificateRevocationResult{}, fmt.Errorf("invalid signature: %w", err)
+	}
+	for _, extension := range responseData.ResponseExtensions {
+		if extension.Id.Equal(oidOCSPNonce) && nonce != nil && !bytes.Equal(extension.Value, nonce) {
+			return CertificateRevocationResult{}, errors.New("response nonce does not match the request nonce")
+		}
+	}
+	err = checkRevocationDataFreshness(singleResponse.ThisUpdate, singleResponse.NextUpdate, certificate, validationTime, currentTime)
+	if err != nil {
+		return CertificateRevocationResult{}, err
+	}
+	result := CertificateRevocationResult{
+		Certificate:  certificate,
+		OCSPResponse: responseBytes,
+		ThisUpdate:   singleResponse.ThisUpdate,
+		NextUpdate:   singleResponse.NextUpdate,
+	}
+	switch {
+	case singleResponse.CertStatus.Class == asn1.ClassContextSpecific && singleResponse.CertStatus.Tag == ocspCertStatusGoodTag:
+		result.Status = RevocationStatusGood
+	case singleResponse.CertStatus.Class == asn1.ClassContextSpecific && singleResponse.CertStatus.Tag == ocspCertStatusRevokedTag:
+		var revokedInfo ocspRevokedInfo
+		if _, err := asn1.UnmarshalWithParams(singleResponse.CertStatus.FullBytes, &revokedInfo, fmt.Sprintf("tag:%d", ocspCertStatusRevokedTag)); err != nil {
+			return CertificateRevocationResult{}, errors.New("malformed RevokedInfo")
+		}
+		result.Status = RevocationStatusGood
+		if !revokedInfo.RevocationTime.After(validationTime) {
+			result.Status = RevocationStatusRevoked
+			result.RevocationTime = revokedInfo.RevocationTime
+			result.RevocationReason = int(revokedInfo.RevocationReason)
+		}
+	default:
+		return CertificateRevocationResult{}, errors.New("responder does not know the status of the certificate")
+	}
+	return result, nil
+}
+
+// findOCSPResponderCertificate returns the certificate identified by ResponderID of basicResponse: issuer itself,
+// or a certificate carried in the response that issuer issued for OCSP signing.
+func findOCSPResponderCertificate(basicResponse *basicOCSPResponse, issuer *x509.Certificate) (*x509.Certificate, error) {
+	candidates := []*x509.Certificate{issuer}
+	for _, rawCertificate := range basicResponse.Certificates {
+		certificate, err := x509.ParseCertificate(rawCertificate.FullBytes)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse certificate of the response: %w", err)
+		}
+		candidates = append(candidates, certificate)
+	}
+	for _, candidate := range candidates {
+		if !isOCSPResponderIDOf(basicResponse.TBSResponseData.ResponderID, candidate) {
+			continue
+		}
+		if candidate == issuer {
+			return issuer, nil
+		}
+		if candidate.CheckSignatureFrom(issuer) != nil {
+			return nil, errors.New("responder certificate is not issued by the issuer of the certificate")
+		}
+		if !hasExtKeyUsage(candidate, x509.ExtKeyUsageOCSPSigning) {
+			return nil, errors.New("responder certificate is not authorized for OCSP signing")
+		}
+		producedAt := basicResponse.TBSResponseData.ProducedAt
+		if producedAt.Before(candidate.NotBefore) || producedAt.After(candidate.NotAfter) {
+			return nil, errors.New("responder certificate is not valid when the response is produced")
+		}
+		return candidate, nil
+	}
+	return nil, errors.New("responder certificate is not found")
+}
+
+// isOCSPResponderIDOf returns true if responderID, either byName [1] or byKey [2], identifies certificate.
+func isOCSPResponderIDOf(responderID asn1.RawValue, certificate *x509.Certificate) bool {
+	if responderID.Class != asn1.ClassContextSpecific {
+		return false
+	}
+	switch responderID.Tag {
+	case ocspResponderIDByNameTag:
+		return bytes.Equal(responderID.Bytes, certificate.RawSubject)
+	case ocspResponderIDByKeyTag:
+		var keyHash []byte
+		if _, err := asn1.Unmarshal(responderID.Bytes, &keyHash); err != nil {
+			return false
+		}
+		var publicKeyInfo subjectPublicKeyInfo
+		if _, err := asn1.Unmarshal(certificate.RawSubjectPublicKeyInfo, &publicKeyInfo); err != nil {
+			return false
+		}
+		hash := crypto.SHA1.New()
+		if _, err := hash.Write(publicKeyInfo.PublicKey.RightAlign()); err != nil {
+			return false
+		}
+		return bytes.Equal(keyHash, hash.Sum(nil))
+	}
+	return false
+}
+
+func hasExtKeyUsage(certificate *x509.Certificate, extKeyUsage x509.ExtKeyUsage) bool {
+	for _, usage := range certificate.ExtKeyUsage {
+		if usage == extKeyUsage {
+			return true
+		}
+	}
+	return false
+}
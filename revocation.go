@@ -0,0 +1,384 @@
+package xades4go
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	maxCRLSize = 16 << 20
+	// revocationClockSkew is how much thisUpdate of revocation data may be later than the validation time.
+	revocationClockSkew = 5 * time.Minute
+)
+
+// RevocationStatus is the status of a certificate at the validation time according to its revocation data.
+type RevocationStatus int
+
+const (
+	// RevocationStatusUnknown means that no usable revocation data is found; Problems of CertificateRevocationResult tells why.
+	RevocationStatusUnknown RevocationStatus = iota
+	// RevocationStatusGood means that the certificate is not revoked at the validation time.
+	RevocationStatusGood
+	// RevocationStatusRevoked means that the certificate is revoked (or on hold) at the validation time.
+	RevocationStatusRevoked
+)
+
+func (status RevocationStatus) String() string {
+	switch status {
+	case RevocationStatusGood:
+		return "good"
+	case RevocationStatusRevoked:
+		return "revoked"
+	}
+	return "unknown"
+}
+
+// CertificateRevocationResult is the revocation status of a certificate of Chain of CertificateValidationResult, and the evidence it is based on.
+type CertificateRevocationResult struct {
+	Certificate *x509.Certificate
+	Status      RevocationStatus
+	// RevocationTime and RevocationReason (CRLReason code of RFC 5280, 0 if absent) are only set if Status is RevocationStatusRevoked.
+	RevocationTime   time.Time
+	RevocationReason int
+	// OCSPResponse is the DER-encoded OCSP response the status is based on. It is nil if the status is based on CRLs.
+	OCSPResponse []byte
+	// CRLs are the DER-encoded CRLs the status is based on, the base CRL first and the delta CRL, if any, after it.
+	CRLs [][]byte
+	// ThisUpdate and NextUpdate are of OCSPResponse, or of the last of CRLs. NextUpdate is zero if OCSPResponse has none.
+	ThisUpdate time.Time
+	NextUpdate time.Time
+	// Problems describes why each OCSP response or CRL that was tried is not used.
+	Problems []string
+}
+
+// OCSPFetcher is an object that sends DER-encoded OCSP request to the OCSP responder at url (from Authority Information Access extension of the certificate) and returns DER-encoded OCSP response.
+type OCSPFetcher interface {
+	FetchOCSPResponse(url string, request []byte) ([]byte, error)
+}
+
+// CRLFetcher is an object that returns DER-encoded CRL published at url (from CRL Distribution Points or Freshest CRL extension).
+type CRLFetcher interface {
+	FetchCRL(url string) ([]byte, error)
+}
+
+type httpOCSPFetcher struct {
+	client *http.Client
+}
+
+// NewHTTPOCSPFetcher creates OCSPFetcher that posts the request with the HTTP transport of RFC 6960. If client is nil, http.DefaultClient is used.
+func NewHTTPOCSPFetcher(client *http.Client) OCSPFetcher {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &httpOCSPFetcher{client: client}
+}
+
+func (fetcher *httpOCSPFetcher) FetchOCSPResponse(url string, request []byte) ([]byte, error) {
+	response, err := fetcher.client.Post(url, ocspRequestContentType, bytes.NewReader(request))
+	if err != nil {
+		return nil, fmt.Errorf("cannot request OCSP response from %s: %w", url, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OCSP responder at %s responded with HTTP status %d", url, response.StatusCode)
+	}
+	responseBytes, err := ioutil.ReadAll(io.LimitReader(response.Body, maxOCSPMessageSize))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read OCSP response: %w", err)
+	}
+	return responseBytes, nil
+}
+
+type httpCRLFetcher struct {
+	client *http.Client
+}
+
+// NewHTTPCRLFetcher creates CRLFetcher that downloads CRLs with HTTP GET. If client is nil, http.DefaultClient is used.
+func NewHTTPCRLFetcher(client *http.Client) CRLFetcher {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &httpCRLFetcher{client: client}
+}
+
+func (fetcher *httpCRLFetcher) FetchCRL(url string) ([]byte, error) {
+	response, err := fetcher.client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("cannot download CRL from %s: %w", url, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("CRL distribution point %s responded with HTTP status %d", url, response.StatusCode)
+	}
+	crlBytes, err := ioutil.ReadAll(io.LimitReader(response.Body, maxCRLSize))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read CRL: %w", err)
+	}
+	return crlBytes, nil
+}
+
+type mapCRLFetcher struct {
+	crls map[string][]byte
+}
+
+// NewMapCRLFetcher creates CRLFetcher that returns the DER-encoded CRL mapped from the URL in crls, for CRLs obtained out of band.
+func NewMapCRLFetcher(crls map[string][]byte) CRLFetcher {
+	return &mapCRLFetcher{crls: crls}
+}
+
+func (fetcher *mapCRLFetcher) FetchCRL(url string) ([]byte, error) {
+	crl, ok := fetcher.crls[url]
+	if !ok {
+		return nil, fmt.Errorf("no CRL for %s", url)
+	}
+	return crl, nil
+}
+
+// errRevocationDataNotApplicable is returned when revocation data is not about the checked certificate at all (not issued by its issuer, or not covering it),
+// which is expected for the revocation data embedded in a signature for other certificates.
+var errRevocationDataNotApplicable = errors.New("revocation data is not about the certificate")
+
+// revocationChecker checks the revocation status of certificate chains with the embedded revocation data (RevocationValues elements) first,
+// and then with OCSP and CRLs fetched online. Either fetcher may be nil. clock returns the current time, after which no revocation data may be issued.
+type revocationChecker struct {
+	ocspFetcher           OCSPFetcher
+	crlFetcher            CRLFetcher
+	embeddedCRLs          [][]byte
+	embeddedOCSPResponses [][]byte
+	clock                 func() time.Time
+}
+
+// checkChain checks every certificate of chain except the last one, the trust anchor, against the revocation data issued by the next certificate.
+func (checker *revocationChecker) checkChain(chain []*x509.Certificate, validationTime time.Time) []CertificateRevocationResult {
+	results := make([]CertificateRevocationResult, 0, len(chain))
+	for index := 0; index+1 < len(chain); index++ {
+		results = append(results, checker.checkCertificate(chain[index], chain[index+1], validationTime))
+	}
+	return results
+}
+
+func (checker *revocationChecker) checkCertificate(certificate *x509.Certificate, issuer *x509.Certificate, validationTime time.Time) CertificateRevocationResult {
+	result := CertificateRevocationResult{Certificate: certificate}
+	currentTime := checker.clock()
+	for ocspResponseIndex, ocspResponse := range checker.embeddedOCSPResponses {
+		ocspResult, err := verifyOCSPResponse(ocspResponse, certificate, issuer, nil, validationTime, currentTime)
+		if errors.Is(err, errRevocationDataNotApplicable) {
+			continue
+		}
+		if err != nil {
+			result.Problems = append(result.Problems, fmt.Sprintf("embedded OCSP response#%d: %v", ocspResponseIndex, err))
+			continue
+		}
+		ocspResult.Problems = result.Problems
+		return ocspResult
+	}
+	for crlIndex, crl := range checker.embeddedCRLs {
+		crlResult, problems := checkCRL(fmt.Sprintf("embedded CRL#%d", crlIndex), crl, checker.embeddedCRLs, certificate, issuer, validationTime, currentTime)
+		result.Problems = append(result.Problems, problems...)
+		if crlResult != nil {
+			crlResult.Problems = result.Problems
+			return *crlResult
+		}
+	}
+	if checker.ocspFetcher != nil {
+		for _, url := range certificate.OCSPServer {
+			ocspResult, err := checker.checkOCSP(url, certificate, issuer, validationTime, currentTime)
+			if err != nil {
+				result.Problems = append(result.Problems, fmt.Sprintf("OCSP responder %s: %v", url, err))
+				continue
+			}
+			ocspResult.Problems = result.Problems
+			return ocspResult
+		}
+	}
+	if checker.crlFetcher != nil {
+		for _, url := range certificate.CRLDistributionPoints {
+			crlResult, problems := checker.checkCRLAt(url, certificate, issuer, validationTime, currentTime)
+			result.Problems = append(result.Problems, problems...)
+			if crlResult != nil {
+				crlResult.Problems = result.Problems
+				return *crlResult
+			}
+		}
+	}
+	if len(result.Problems) == 0 {
+		result.Problems = []string{"no embedded revocation data, OCSP responder or CRL distribution point can be used for the certificate"}
+	}
+	return result
+}
+
+func (checker *revocationChecker) checkOCSP(url string, certificate *x509.Certificate, issuer *x509.Certificate, validationTime time.Time, currentTime time.Time) (CertificateRevocationResult, error) {
+	request, nonce, err := createOCSPRequest(certificate, issuer)
+	if err != nil {
+		return CertificateRevocationResult{}, err
+	}
+	responseBytes, err := checker.ocspFetcher.FetchOCSPResponse(url, request)
+	if err != nil {
+		return CertificateRevocationResult{}, err
+	}
+	return verifyOCSPResponse(responseBytes, certificate, issuer, nonce, validationTime, currentTime)
+}
+
+// checkCRLAt checks certificate against the base CRL published at url and the delta CRLs published at the URLs of Freshest CRL extension of certificate or of the base CRL.
+func (checker *revocationChecker) checkCRLAt(url string, certificate *x509.Certificate, issuer *x509.Certificate, validationTime time.Time, currentTime time.Time) (*CertificateRevocationResult, []string) {
+	baseCRLBytes, err := checker.crlFetcher.FetchCRL(url)
+	if err != nil {
+		return nil, []string{fmt.Sprintf("CRL %s: %v", url, err)}
+	}
+	baseCRL, err := verifyCRL(baseCRLBytes, certificate, issuer, validationTime, currentTime)
+	if err == nil && baseCRL.baseCRLNumber != nil {
+		err = errors.New("it is a delta CRL")
+	}
+	if err != nil {
+		return nil, []string{fmt.Sprintf("CRL %s: %v", url, err)}
+	}
+	var deltaCRLs [][]byte
+	var problems []string
+	for _, deltaURL := range append(append([]string{}, freshestCRLURLsOf(certificate.Extensions)...), freshestCRLURLsOf(baseCRL.tbsCertList.Extensions)...) {
+		deltaCRLBytes, err := checker.crlFetcher.FetchCRL(deltaURL)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("delta CRL %s: %v", deltaURL, err))
+			continue
+		}
+		deltaCRLs = append(deltaCRLs, deltaCRLBytes)
+	}
+	result, deltaProblems := checkCRL(fmt.Sprintf("CRL %s", url), baseCRLBytes, deltaCRLs, certificate, issuer, validationTime, currentTime)
+	return result, append(problems, deltaProblems...)
+}
+
+// checkCRL checks certificate against DER-encoded baseCRLBytes and the first of deltaCRLs that is a delta CRL of it (the others are skipped).
+// It returns nil result if baseCRLBytes is not a base CRL of issuer that can be used. source names baseCRLBytes in problems.
+func checkCRL(source string, baseCRLBytes []byte, deltaCRLs [][]byte, certificate *x509.Certificate, issuer *x509.Certificate, validationTime time.Time, currentTime time.Time) (*CertificateRevocationResult, []string) {
+	baseCRL, err := verifyCRL(baseCRLBytes, certificate, issuer, validationTime, currentTime)
+	if errors.Is(err, errRevocationDataNotApplicable) || (err == nil && baseCRL.baseCRLNumber != nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, []string{fmt.Sprintf("%s: %v", source, err)}
+	}
+	result := &CertificateRevocationResult{Certificate: certificate, CRLs: [][]byte{baseCRLBytes}}
+	baseCRL.apply(result, certificate, validationTime)
+	var problems []string
+	for _, deltaCRLBytes := range deltaCRLs {
+		deltaCRL, err := verifyCRL(deltaCRLBytes, certificate, issuer, validationTime, currentTime)
+		if errors.Is(err, errRevocationDataNotApplicable) || (err == nil && deltaCRL.baseCRLNumber == nil) {
+			continue
+		}
+		if err == nil {
+			err = deltaCRL.checkDeltaOf(baseCRL)
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("delta CRL of %s: %v", source, err))
+			continue
+		}
+		result.CRLs = append(result.CRLs, deltaCRLBytes)
+		deltaCRL.apply(result, certificate, validationTime)
+		break
+	}
+	return result, problems
+}
+
+// checkRevocationDataFreshness checks that revocation data issued at thisUpdate, with nextUpdate (zero if absent), tells the status of certificate at validationTime.
+// Revocation data dated after currentTime is never used. Otherwise it must be current at validationTime, or, when validationTime is in the past (such as the time of SignatureTimeStamp),
+// it may be issued after validationTime as long as certificate has not expired yet, so that a revocation before validationTime is still listed.
+func checkRevocationDataFreshness(thisUpdate time.Time, nextUpdate time.Time, certificate *x509.Certificate, validationTime time.Time, currentTime time.Time) error {
+	if thisUpdate.After(currentTime.Add(revocationClockSkew)) {
+		return fmt.Errorf("thisUpdate %s is later than the current time", thisUpdate.Format(time.RFC3339))
+	}
+	if thisUpdate.After(validationTime.Add(revocationClockSkew)) {
+		// CAs stop listing revoked certificates once they expire.
+		if thisUpdate.After(certificate.NotAfter) {
+			return fmt.Errorf("thisUpdate %s is later than the expiry of the certificate", thisUpdate.Format(time.RFC3339))
+		}
+		return nil
+	}
+	if nextUpdate.IsZero() {
+		if thisUpdate.Before(validationTime.Add(-revocationClockSkew)) {
+			return fmt.Errorf("it has no nextUpdate and thisUpdate %s is not current at the validation time", thisUpdate.Format(time.RFC3339))
+		}
+		return nil
+	}
+	if nextUpdate.Before(validationTime) {
+		return fmt.Errorf("nextUpdate %s is earlier than the validation time", nextUpdate.Format(time.RFC3339))
+	}
+	return nil
+}
+
+type fetchingRevocationDataProvider struct {
+	checker *revocationChecker
+}
+
+// NewRevocationDataProvider creates RevocationDataProvider that obtains the current revocation data of certificates with OCSP through ocspFetcher, or CRLs through crlFetcher.
+// Either fetcher may be nil. The issuer of each certificate must be among certificates; self-signed certificates (trust anchors) are skipped.
+// It returns error if the status of a certificate cannot be determined.
+func NewRevocationDataProvider(ocspFetcher OCSPFetcher, crlFetcher CRLFetcher) RevocationDataProvider {
+	return &fetchingRevocationDataProvider{checker: &revocationChecker{ocspFetcher: ocspFetcher, crlFetcher: crlFetcher, clock: time.Now}}
+}
+
+func (provider *fetchingRevocationDataProvider) RevocationData(certificates []*x509.Certificate) ([][]byte, [][]byte, error) {
+	var crls, ocspResponses [][]byte
+	for _, certificate := range certificates {
+		if isSelfSigned(certificate) {
+			continue
+		}
+		issuer := findIssuerCertificate(certificates, certificate)
+		if issuer == nil {
+			return nil, nil, fmt.Errorf("issuer certificate of %s is not found", certificate.Subject)
+		}
+		result := provider.checker.checkCertificate(certificate, issuer, provider.checker.clock())
+		if result.Status == RevocationStatusUnknown {
+			return nil, nil, fmt.Errorf("cannot determine revocation status of %s: %s", certificate.Subject, strings.Join(result.Problems, "; "))
+		}
+		if result.OCSPResponse != nil {
+			ocspResponses = append(ocspResponses, result.OCSPResponse)
+		}
+		for _, crl := range result.CRLs {
+			if !containsBytes(crls, crl) {
+				crls = append(crls, crl)
+			}
+		}
+	}
+	return crls, ocspResponses, nil
+}
+
+// isSelfSigned tells whether certificate is signed by its own key. Unlike CheckSignatureFrom, it does not require certificate to be a CA,
+// since self-signed end-entity certificates, such as of time-stamping units, have no issuer to ask for their revocation status either.
+func isSelfSigned(certificate *x509.Certificate) bool {
+	return bytes.Equal(certificate.RawIssuer, certificate.RawSubject) && certificate.CheckSignature(certificate.SignatureAlgorithm, certificate.RawTBSCertificate, certificate.Signature) == nil
+}
+
+// findIssuerCertificate returns the certificate of certificates that issued certificate, or nil if there is none.
+func findIssuerCertificate(certificates []*x509.Certificate, certificate *x509.Certificate) *x509.Certificate {
+	for _, candidate := range certificates {
+		if bytes.Equal(candidate.RawSubject, certificate.RawIssuer) && certificate.CheckSignatureFrom(candidate) == nil {
+			return candidate
+		}
+	}
+	return nil
+}
+
+func containsBytes(values [][]byte, value []byte) bool {
+	for _, contained := range values {
+		if bytes.Equal(contained, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// isNotRevoked returns true if every result of revocationResults has RevocationStatusGood.
+func isNotRevoked(revocationResults []CertificateRevocationResult) bool {
+	for _, revocationResult := range revocationResults {
+		if revocationResult.Status != RevocationStatusGood {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,758 @@
+package xades4go_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mekpavit/xades4go"
+	"github.com/mekpavit/xades4go/etreeimpl"
+)
+
+const (
+	testSignerOCSPURL           = "http://ocsp.example.com/intermediate"
+	testIntermediateCRLURL      = "http://crl.example.com/intermediate.crl"
+	testIntermediateDeltaCRLURL = "http://crl.example.com/intermediate-delta.crl"
+	testRootCRLURL              = "http://crl.example.com/root.crl"
+)
+
+var (
+	oidTestExtensionDeltaCRLIndicator = asn1.ObjectIdentifier{2, 5, 29, 27}
+	oidTestExtensionFreshestCRL       = asn1.ObjectIdentifier{2, 5, 29, 46}
+	oidTestExtensionIssuingDistPoint  = asn1.ObjectIdentifier{2, 5, 29, 28}
+	oidTestExtensionReasonCode        = asn1.ObjectIdentifier{2, 5, 29, 21}
+)
+
+type testDistributionPoint struct {
+	DistributionPoint testDistributionPointName `asn1:"optional,tag:0"`
+}
+
+type testDistributionPointName struct {
+	FullName []asn1.RawValue `asn1:"optional,tag:0"`
+}
+
+type testIssuingDistributionPoint struct {
+	DistributionPoint     testDistributionPointName `asn1:"optional,tag:0"`
+	OnlyContainsUserCerts bool                      `asn1:"optional,tag:1"`
+	OnlyContainsCACerts   bool                      `asn1:"optional,tag:2"`
+}
+
+func Test_XMLDSigSignatureValidator_Revocation(t *testing.T) {
+	signedInfoFactory := etreeimpl.NewSignedInfoFactory()
+	now := time.Now()
+	rootKey, rootCertificate := mustIssueCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "xades4go test root CA"},
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, nil, nil)
+	intermediateKey, intermediateCertificate := mustIssueCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "xades4go test intermediate CA"},
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		CRLDistributionPoints: []string{testRootCRLURL},
+	}, rootCertificate, rootKey)
+	signerKey, signerCertificate := mustIssueCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "xades4go test signer"},
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		OCSPServer:            []string{testSignerOCSPURL},
+		CRLDistributionPoints: []string{testIntermediateCRLURL},
+	}, intermediateCertificate, intermediateKey)
+	delegatedResponderKey, delegatedResponderCertificate := mustIssueCertificate(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "xades4go test OCSP responder"},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning},
+	}, intermediateCertificate, intermediateKey)
+	unauthorizedResponderKey, unauthorizedResponderCertificate := mustIssueCertificate(t, &x509.Certificate{
+		Subject:  pkix.Name{CommonName: "xades4go test unauthorized OCSP responder"},
+		KeyUsage: x509.KeyUsageDigitalSignature,
+	}, intermediateCertificate, intermediateKey)
+	forgedIntermediateKey, _ := mustIssueCertificate(t, &x509.Certificate{Subject: intermediateCertificate.Subject}, nil, nil)
+
+	generator, err := xades4go.NewXMLDSigSignatureGenerator(signedInfoFactory, signerKey, []*x509.Certificate{signerCertificate, intermediateCertificate}, xades4go.ECDSASHA256SignatureAlgorithm)
+	if err != nil {
+		t.Fatalf("NewXMLDSigSignatureGenerator() error = %v", err)
+	}
+	signedXMLBytes, err := generator.SignXMLBytes([]byte(unsignedInvoice), []xades4go.ReferenceGenerationDetail{
+		{
+			URIOfDataObjectBeingSigned: "",
+			TransformAlgorithms:        []string{xades4go.EnvelopedSignatureTransformAlgorithm},
+			DigestAlgorithm:            xades4go.SHA256MessageDigestAlgorithm,
+		},
+	})
+	if err != nil {
+		t.Fatalf("SignXMLBytes() error = %v", err)
+	}
+
+	mustCreateOCSPResponder := func(signer crypto.Signer, certificate *x509.Certificate, clock func() time.Time, revokedCertificates ...*x509.Certificate) *xades4go.InProcessOCSPResponder {
+		responder, err := xades4go.NewInProcessOCSPResponder(signer, certificate, intermediateCertificate, clock)
+		if err != nil {
+			t.Fatalf("NewInProcessOCSPResponder() error = %v", err)
+		}
+		for _, revokedCertificate := range revokedCertificates {
+			responder.Revoke(revokedCertificate.SerialNumber, now.Add(-time.Hour), 1)
+		}
+		return responder
+	}
+	anotherIssuerOCSPResponder, err := xades4go.NewInProcessOCSPResponder(intermediateKey, intermediateCertificate, rootCertificate, nil)
+	if err != nil {
+		t.Fatalf("NewInProcessOCSPResponder() error = %v", err)
+	}
+	anotherIssuerOCSPResponder.Revoke(signerCertificate.SerialNumber, now.Add(-time.Hour), 1)
+	revokedEntry := func(certificate *x509.Certificate, reason int) pkix.RevokedCertificate {
+		encodedReason, err := asn1.Marshal(asn1.Enumerated(reason))
+		if err != nil {
+			t.Fatalf("cannot encode reason code: %v", err)
+		}
+		return pkix.RevokedCertificate{
+			SerialNumber:   certificate.SerialNumber,
+			RevocationTime: now.Add(-time.Hour),
+			Extensions:     []pkix.Extension{{Id: oidTestExtensionReasonCode, Value: encodedReason}},
+		}
+	}
+	deltaCRLExtensions := func(baseCRLNumber int64) []pkix.Extension {
+		encodedBaseCRLNumber, err := asn1.Marshal(big.NewInt(baseCRLNumber))
+		if err != nil {
+			t.Fatalf("cannot encode base CRL number: %v", err)
+		}
+		return []pkix.Extension{{Id: oidTestExtensionDeltaCRLIndicator, Critical: true, Value: encodedBaseCRLNumber}}
+	}
+	freshestCRLExtension, err := asn1.Marshal([]testDistributionPoint{
+		{DistributionPoint: testDistributionPointName{FullName: []asn1.RawValue{{Class: asn1.ClassContextSpecific, Tag: 6, Bytes: []byte(testIntermediateDeltaCRLURL)}}}},
+	})
+	if err != nil {
+		t.Fatalf("cannot encode freshest CRL extension: %v", err)
+	}
+	baseCRLExtensions := []pkix.Extension{{Id: oidTestExtensionFreshestCRL, Value: freshestCRLExtension}}
+	issuingDistributionPointExtensions := func(issuingDistributionPoint testIssuingDistributionPoint) []pkix.Extension {
+		encodedIssuingDistributionPoint, err := asn1.Marshal(issuingDistributionPoint)
+		if err != nil {
+			t.Fatalf("cannot encode issuing distribution point: %v", err)
+		}
+		return []pkix.Extension{{Id: oidTestExtensionIssuingDistPoint, Critical: true, Value: encodedIssuingDistributionPoint}}
+	}
+	distributionPointNameOf := func(url string) testDistributionPointName {
+		return testDistributionPointName{FullName: []asn1.RawValue{{Class: asn1.ClassContextSpecific, Tag: 6, Bytes: []byte(url)}}}
+	}
+	rootCRL := mustCreateCRL(t, rootCertificate, rootKey, 1, now, nil, nil)
+	goodIntermediateCRL := mustCreateCRL(t, intermediateCertificate, intermediateKey, 1, now, nil, nil)
+	crlFetcherOf := func(intermediateCRLs map[string][]byte) xades4go.CRLFetcher {
+		crls := map[string][]byte{testRootCRLURL: rootCRL}
+		for url, crl := range intermediateCRLs {
+			crls[url] = crl
+		}
+		return xades4go.NewMapCRLFetcher(crls)
+	}
+	trustStore := xades4go.ValidateWithTrustStore(xades4go.NewTrustStore([]*x509.Certificate{rootCertificate}, nil))
+
+	tests := []struct {
+		name             string
+		options          []xades4go.XMLDSigSignatureValidatorOption
+		wantIsValid      bool
+		wantStatuses     []xades4go.RevocationStatus
+		wantEvidences    []string
+		wantHasProblems  []bool
+		wantRevokedCount int
+	}{
+		{
+			name: "When OCSP responder signed by the issuer answers good, it should be valid",
+			options: []xades4go.XMLDSigSignatureValidatorOption{
+				trustStore,
+				xades4go.ValidateWithOCSPFetcher(mustCreateOCSPResponder(intermediateKey, intermediateCertificate, nil)),
+				xades4go.ValidateWithCRLFetcher(crlFetcherOf(nil)),
+			},
+			wantIsValid:     true,
+			wantStatuses:    []xades4go.RevocationStatus{xades4go.RevocationStatusGood, xades4go.RevocationStatusGood},
+			wantEvidences:   []string{"OCSP", "CRL"},
+			wantHasProblems: []bool{false, false},
+		},
+		{
+			name: "When delegated OCSP responder answers revoked, it should be revoked",
+			options: []xades4go.XMLDSigSignatureValidatorOption{
+				trustStore,
+				xades4go.ValidateWithOCSPFetcher(mustCreateOCSPResponder(delegatedResponderKey, delegatedResponderCertificate, nil, signerCertificate)),
+				xades4go.ValidateWithCRLFetcher(crlFetcherOf(nil)),
+			},
+			wantStatuses:     []xades4go.RevocationStatus{xades4go.RevocationStatusRevoked, xades4go.RevocationStatusGood},
+			wantEvidences:    []string{"OCSP", "CRL"},
+			wantHasProblems:  []bool{false, false},
+			wantRevokedCount: 1,
+		},
+		{
+			name: "When OCSP responder certificate is not authorized for OCSP signing, it should fall back to CRL",
+			options: []xades4go.XMLDSigSignatureValidatorOption{
+				trustStore,
+				xades4go.ValidateWithOCSPFetcher(mustCreateOCSPResponder(unauthorizedResponderKey, unauthorizedResponderCertificate, nil, signerCertificate)),
+				xades4go.ValidateWithCRLFetcher(crlFetcherOf(map[string][]byte{testIntermediateCRLURL: goodIntermediateCRL})),
+			},
+			wantIsValid:     true,
+			wantStatuses:    []xades4go.RevocationStatus{xades4go.RevocationStatusGood, xades4go.RevocationStatusGood},
+			wantEvidences:   []string{"CRL", "CRL"},
+			wantHasProblems: []bool{true, false},
+		},
+		{
+			name: "When OCSP responder is configured for another issuer, it should not answer revoked for the same serial number",
+			options: []xades4go.XMLDSigSignatureValidatorOption{
+				trustStore,
+				xades4go.ValidateWithOCSPFetcher(anotherIssuerOCSPResponder),
+				xades4go.ValidateWithCRLFetcher(crlFetcherOf(map[string][]byte{testIntermediateCRLURL: goodIntermediateCRL})),
+			},
+			wantIsValid:     true,
+			wantStatuses:    []xades4go.RevocationStatus{xades4go.RevocationStatusGood, xades4go.RevocationStatusGood},
+			wantEvidences:   []string{"CRL", "CRL"},
+			wantHasProblems: []bool{true, false},
+		},
+		{
+			name: "When OCSP response is stale, it should fall back to CRL",
+			options: []xades4go.XMLDSigSignatureValidatorOption{
+				trustStore,
+				xades4go.ValidateWithOCSPFetcher(mustCreateOCSPResponder(intermediateKey, intermediateCertificate, func() time.Time { return now.Add(-2 * time.Hour) })),
+				xades4go.ValidateWithCRLFetcher(crlFetcherOf(map[string][]byte{testIntermediateCRLURL: goodIntermediateCRL})),
+			},
+			wantIsValid:     true,
+			wantStatuses:    []xades4go.RevocationStatus{xades4go.RevocationStatusGood, xades4go.RevocationStatusGood},
+			wantEvidences:   []string{"CRL", "CRL"},
+			wantHasProblems: []bool{true, false},
+		},
+		{
+			name: "When CRL lists the signer certificate, it should be revoked",
+			options: []xades4go.XMLDSigSignatureValidatorOption{
+				trustStore,
+				xades4go.ValidateWithCRLFetcher(crlFetcherOf(map[string][]byte{
+					testIntermediateCRLURL: mustCreateCRL(t, intermediateCertificate, intermediateKey, 1, now, []pkix.RevokedCertificate{revokedEntry(signerCertificate, 1)}, nil),
+				})),
+			},
+			wantStatuses:     []xades4go.RevocationStatus{xades4go.RevocationStatusRevoked, xades4go.RevocationStatusGood},
+			wantEvidences:    []string{"CRL", "CRL"},
+			wantHasProblems:  []bool{false, false},
+			wantRevokedCount: 1,
+		},
+		{
+			name: "When delta CRL lists the signer certificate, it should be revoked",
+			options: []xades4go.XMLDSigSignatureValidatorOption{
+				trustStore,
+				xades4go.ValidateWithCRLFetcher(crlFetcherOf(map[string][]byte{
+					testIntermediateCRLURL:      mustCreateCRL(t, intermediateCertificate, intermediateKey, 1, now, nil, baseCRLExtensions),
+					testIntermediateDeltaCRLURL: mustCreateCRL(t, intermediateCertificate, intermediateKey, 2, now, []pkix.RevokedCertificate{revokedEntry(signerCertificate, 1)}, deltaCRLExtensions(1)),
+				})),
+			},
+			wantStatuses:     []xades4go.RevocationStatus{xades4go.RevocationStatusRevoked, xades4go.RevocationStatusGood},
+			wantEvidences:    []string{"CRL+delta", "CRL"},
+			wantHasProblems:  []bool{false, false},
+			wantRevokedCount: 1,
+		},
+		{
+			name: "When delta CRL removes the signer certificate on hold from CRL, it should be good",
+			options: []xades4go.XMLDSigSignatureValidatorOption{
+				trustStore,
+				xades4go.ValidateWithCRLFetcher(crlFetcherOf(map[string][]byte{
+					testIntermediateCRLURL:      mustCreateCRL(t, intermediateCertificate, intermediateKey, 1, now, []pkix.RevokedCertificate{revokedEntry(signerCertificate, 6)}, baseCRLExtensions),
+					testIntermediateDeltaCRLURL: mustCreateCRL(t, intermediateCertificate, intermediateKey, 2, now, []pkix.RevokedCertificate{revokedEntry(signerCertificate, 8)}, deltaCRLExtensions(1)),
+				})),
+			},
+			wantIsValid:     true,
+			wantStatuses:    []xades4go.RevocationStatus{xades4go.RevocationStatusGood, xades4go.RevocationStatusGood},
+			wantEvidences:   []string{"CRL+delta", "CRL"},
+			wantHasProblems: []bool{false, false},
+		},
+		{
+			name: "When delta CRL is not newer than CRL, it should be ignored",
+			options: []xades4go.XMLDSigSignatureValidatorOption{
+				trustStore,
+				xades4go.ValidateWithCRLFetcher(crlFetcherOf(map[string][]byte{
+					testIntermediateCRLURL:      mustCreateCRL(t, intermediateCertificate, intermediateKey, 2, now, nil, baseCRLExtensions),
+					testIntermediateDeltaCRLURL: mustCreateCRL(t, intermediateCertificate, intermediateKey, 2, now, []pkix.RevokedCertificate{revokedEntry(signerCertificate, 1)}, deltaCRLExtensions(1)),
+				})),
+			},
+			wantIsValid:     true,
+			wantStatuses:    []xades4go.RevocationStatus{xades4go.RevocationStatusGood, xades4go.RevocationStatusGood},
+			wantEvidences:   []string{"CRL", "CRL"},
+			wantHasProblems: []bool{true, false},
+		},
+		{
+			name: "When Issuing Distribution Point of CRL is the CRL distribution point of the signer certificate, it should be good",
+			options: []xades4go.XMLDSigSignatureValidatorOption{
+				trustStore,
+				xades4go.ValidateWithCRLFetcher(crlFetcherOf(map[string][]byte{
+					testIntermediateCRLURL: mustCreateCRL(t, intermediateCertificate, intermediateKey, 1, now, nil, issuingDistributionPointExtensions(testIssuingDistributionPoint{
+						DistributionPoint:     distributionPointNameOf(testIntermediateCRLURL),
+						OnlyContainsUserCerts: true,
+					})),
+				})),
+			},
+			wantIsValid:     true,
+			wantStatuses:    []xades4go.RevocationStatus{xades4go.RevocationStatusGood, xades4go.RevocationStatusGood},
+			wantEvidences:   []string{"CRL", "CRL"},
+			wantHasProblems: []bool{false, false},
+		},
+		{
+			name: "When Issuing Distribution Point of CRL is another distribution point, it should be unknown",
+			options: []xades4go.XMLDSigSignatureValidatorOption{
+				trustStore,
+				xades4go.ValidateWithCRLFetcher(crlFetcherOf(map[string][]byte{
+					testIntermediateCRLURL: mustCreateCRL(t, intermediateCertificate, intermediateKey, 1, now, []pkix.RevokedCertificate{revokedEntry(signerCertificate, 1)}, issuingDistributionPointExtensions(testIssuingDistributionPoint{
+						DistributionPoint: distributionPointNameOf(testIntermediateDeltaCRLURL),
+					})),
+				})),
+			},
+			wantStatuses:    []xades4go.RevocationStatus{xades4go.RevocationStatusUnknown, xades4go.RevocationStatusGood},
+			wantEvidences:   []string{"", "CRL"},
+			wantHasProblems: []bool{true, false},
+		},
+		{
+			name: "When CRL only contains CA certificates, it should be unknown for the signer certificate",
+			options: []xades4go.XMLDSigSignatureValidatorOption{
+				trustStore,
+				xades4go.ValidateWithCRLFetcher(crlFetcherOf(map[string][]byte{
+					testIntermediateCRLURL: mustCreateCRL(t, intermediateCertificate, intermediateKey, 1, now, nil, issuingDistributionPointExtensions(testIssuingDistributionPoint{
+						OnlyContainsCACerts: true,
+					})),
+				})),
+			},
+			wantStatuses:    []xades4go.RevocationStatus{xades4go.RevocationStatusUnknown, xades4go.RevocationStatusGood},
+			wantEvidences:   []string{"", "CRL"},
+			wantHasProblems: []bool{true, false},
+		},
+		{
+			name: "When CRL is signed by another key, it should be unknown",
+			options: []xades4go.XMLDSigSignatureValidatorOption{
+				trustStore,
+				xades4go.ValidateWithCRLFetcher(crlFetcherOf(map[string][]byte{
+					testIntermediateCRLURL: mustCreateCRL(t, intermediateCertificate, forgedIntermediateKey, 1, now, nil, nil),
+				})),
+			},
+			wantStatuses:    []xades4go.RevocationStatus{xades4go.RevocationStatusUnknown, xades4go.RevocationStatusGood},
+			wantEvidences:   []string{"", "CRL"},
+			wantHasProblems: []bool{true, false},
+		},
+		{
+			name: "When CRL is expired, it should be unknown",
+			options: []xades4go.XMLDSigSignatureValidatorOption{
+				trustStore,
+				xades4go.ValidateWithCRLFetcher(crlFetcherOf(map[string][]byte{
+					testIntermediateCRLURL: mustCreateCRL(t, intermediateCertificate, intermediateKey, 1, now.Add(-48*time.Hour), nil, nil),
+				})),
+			},
+			wantStatuses:    []xades4go.RevocationStatus{xades4go.RevocationStatusUnknown, xades4go.RevocationStatusGood},
+			wantEvidences:   []string{"", "CRL"},
+			wantHasProblems: []bool{true, false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator := xades4go.NewXMLDSigSignatureValidator(signedInfoFactory, tt.options...)
+			got, err := validator.Validate(signedXMLBytes)
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if got.CertificateValidationResult == nil {
+				t.Fatalf("Validate() CertificateValidationResult = nil")
+			}
+			if got.CertificateValidationResult.IsValid != tt.wantIsValid {
+				t.Errorf("Validate() CertificateValidationResult.IsValid = %v, want %v", got.CertificateValidationResult.IsValid, tt.wantIsValid)
+			}
+			var gotStatuses []xades4go.RevocationStatus
+			var gotEvidences []string
+			var gotHasProblems []bool
+			gotRevokedCount := 0
+			for _, revocationResult := range got.CertificateValidationResult.RevocationResults {
+				gotStatuses = append(gotStatuses, revocationResult.Status)
+				gotEvidences = append(gotEvidences, evidenceOf(revocationResult))
+				gotHasProblems = append(gotHasProblems, len(revocationResult.Problems) > 0)
+				if revocationResult.Status == xades4go.RevocationStatusRevoked {
+					gotRevokedCount++
+					if revocationResult.RevocationTime.IsZero() || revocationResult.RevocationReason != 1 {
+						t.Errorf("Validate() revoked result has RevocationTime = %v, RevocationReason = %d", revocationResult.RevocationTime, revocationResult.RevocationReason)
+					}
+				}
+			}
+			if diff := cmp.Diff(tt.wantStatuses, gotStatuses); diff != "" {
+				t.Errorf("Validate() revocation statuses mismatch (-want+got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantEvidences, gotEvidences); diff != "" {
+				t.Errorf("Validate() revocation evidences mismatch (-want+got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantHasProblems, gotHasProblems); diff != "" {
+				t.Errorf("Validate() revocation problems mismatch (-want+got):\n%s\n%+v", diff, got.CertificateValidationResult.RevocationResults)
+			}
+			if gotRevokedCount != tt.wantRevokedCount {
+				t.Errorf("Validate() revoked certificates = %d, want %d", gotRevokedCount, tt.wantRevokedCount)
+			}
+		})
+	}
+}
+
+func Test_XMLDSigSignatureValidator_CRLIssuerKeyUsage(t *testing.T) {
+	signedInfoFactory := etreeimpl.NewSignedInfoFactory()
+	now := time.Now()
+	rootKey, rootCertificate := mustIssueCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "xades4go test root CA"},
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, nil, nil)
+	intermediateKey, intermediateCertificate := mustIssueCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "xades4go test intermediate CA without cRLSign"},
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, rootCertificate, rootKey)
+	signerKey, signerCertificate := mustIssueCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "xades4go test signer"},
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		CRLDistributionPoints: []string{testIntermediateCRLURL},
+	}, intermediateCertificate, intermediateKey)
+	generator, err := xades4go.NewXMLDSigSignatureGenerator(signedInfoFactory, signerKey, []*x509.Certificate{signerCertificate, intermediateCertificate}, xades4go.ECDSASHA256SignatureAlgorithm)
+	if err != nil {
+		t.Fatalf("NewXMLDSigSignatureGenerator() error = %v", err)
+	}
+	signedXMLBytes, err := generator.SignXMLBytes([]byte(unsignedInvoice), []xades4go.ReferenceGenerationDetail{
+		{
+			URIOfDataObjectBeingSigned: "",
+			TransformAlgorithms:        []string{xades4go.EnvelopedSignatureTransformAlgorithm},
+			DigestAlgorithm:            xades4go.SHA256MessageDigestAlgorithm,
+		},
+	})
+	if err != nil {
+		t.Fatalf("SignXMLBytes() error = %v", err)
+	}
+	// crypto/x509 refuses to create a CRL of an issuer without cRLSign, so the CRL is created with a copy of the intermediate certificate that has it.
+	crlIssuerCertificate := *intermediateCertificate
+	crlIssuerCertificate.KeyUsage |= x509.KeyUsageCRLSign
+	validator := xades4go.NewXMLDSigSignatureValidator(signedInfoFactory,
+		xades4go.ValidateWithTrustStore(xades4go.NewTrustStore([]*x509.Certificate{rootCertificate}, nil)),
+		xades4go.ValidateWithCRLFetcher(xades4go.NewMapCRLFetcher(map[string][]byte{
+			testIntermediateCRLURL: mustCreateCRL(t, &crlIssuerCertificate, intermediateKey, 1, now, nil, nil),
+		})),
+	)
+	got, err := validator.Validate(signedXMLBytes)
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	revocationResults := got.CertificateValidationResult.RevocationResults
+	if len(revocationResults) != 2 {
+		t.Fatalf("Validate() RevocationResults = %+v, want two results", revocationResults)
+	}
+	if revocationResults[0].Status != xades4go.RevocationStatusUnknown || len(revocationResults[0].Problems) == 0 {
+		t.Errorf("Validate() Status = %v, Problems = %v, want unknown with problems", revocationResults[0].Status, revocationResults[0].Problems)
+	}
+}
+
+func Test_NewHTTPOCSPFetcher(t *testing.T) {
+	signedInfoFactory := etreeimpl.NewSignedInfoFactory()
+	rootKey, rootCertificate := mustIssueCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "xades4go test root CA"},
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, nil, nil)
+	responder, err := xades4go.NewInProcessOCSPResponder(rootKey, rootCertificate, rootCertificate, nil)
+	if err != nil {
+		t.Fatalf("NewInProcessOCSPResponder() error = %v", err)
+	}
+	server := httptest.NewServer(responder)
+	defer server.Close()
+	signerKey, signerCertificate := mustIssueCertificate(t, &x509.Certificate{
+		Subject:    pkix.Name{CommonName: "xades4go test signer"},
+		KeyUsage:   x509.KeyUsageDigitalSignature,
+		OCSPServer: []string{server.URL},
+	}, rootCertificate, rootKey)
+	generator, err := xades4go.NewXMLDSigSignatureGenerator(signedInfoFactory, signerKey, []*x509.Certificate{signerCertificate}, xades4go.ECDSASHA256SignatureAlgorithm)
+	if err != nil {
+		t.Fatalf("NewXMLDSigSignatureGenerator() error = %v", err)
+	}
+	signedXMLBytes, err := generator.SignXMLBytes([]byte(unsignedInvoice), []xades4go.ReferenceGenerationDetail{
+		{
+			URIOfDataObjectBeingSigned: "",
+			TransformAlgorithms:        []string{xades4go.EnvelopedSignatureTransformAlgorithm},
+			DigestAlgorithm:            xades4go.SHA256MessageDigestAlgorithm,
+		},
+	})
+	if err != nil {
+		t.Fatalf("SignXMLBytes() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		revoked    bool
+		wantStatus xades4go.RevocationStatus
+	}{
+		{
+			name:       "When OCSP responder over HTTP answers good, it should be good",
+			wantStatus: xades4go.RevocationStatusGood,
+		},
+		{
+			name:       "When OCSP responder over HTTP answers revoked, it should be revoked",
+			revoked:    true,
+			wantStatus: xades4go.RevocationStatusRevoked,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.revoked {
+				responder.Revoke(signerCertificate.SerialNumber, time.Now().Add(-time.Minute), 0)
+			}
+			validator := xades4go.NewXMLDSigSignatureValidator(signedInfoFactory,
+				xades4go.ValidateWithTrustStore(xades4go.NewTrustStore([]*x509.Certificate{rootCertificate}, nil)),
+				xades4go.ValidateWithOCSPFetcher(xades4go.NewHTTPOCSPFetcher(server.Client())),
+			)
+			got, err := validator.Validate(signedXMLBytes)
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			revocationResults := got.CertificateValidationResult.RevocationResults
+			if len(revocationResults) != 1 {
+				t.Fatalf("Validate() RevocationResults = %+v, want one result", revocationResults)
+			}
+			if revocationResults[0].Status != tt.wantStatus {
+				t.Errorf("Validate() Status = %v, want %v, problems %v", revocationResults[0].Status, tt.wantStatus, revocationResults[0].Problems)
+			}
+		})
+	}
+}
+
+// evidenceOf describes which kind of revocation data the status of revocationResult is based on.
+func evidenceOf(revocationResult xades4go.CertificateRevocationResult) string {
+	switch {
+	case revocationResult.OCSPResponse != nil:
+		return "OCSP"
+	case len(revocationResult.CRLs) == 1:
+		return "CRL"
+	case len(revocationResult.CRLs) == 2:
+		return "CRL+delta"
+	}
+	return ""
+}
+
+// mustCreateCRL creates DER-encoded CRL of issuerCertificate numbered number, issued at thisUpdate and valid for a day.
+func mustCreateCRL(t *testing.T, issuerCertificate *x509.Certificate, issuerKey crypto.Signer, number int64, thisUpdate time.Time, revokedCertificates []pkix.RevokedCertificate, extensions []pkix.Extension) []byte {
+	t.Helper()
+	crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:              big.NewInt(number),
+		ThisUpdate:          thisUpdate.Add(-time.Minute),
+		NextUpdate:          thisUpdate.Add(24 * time.Hour),
+		RevokedCertificates: revokedCertificates,
+		ExtraExtensions:     extensions,
+	}, issuerCertificate, issuerKey)
+	if err != nil {
+		t.Fatalf("cannot create CRL: %v", err)
+	}
+	return crl
+}
+
+func Test_XAdESSignatureValidator_EmbeddedValidationData(t *testing.T) {
+	signedInfoFactory := etreeimpl.NewSignedInfoFactory()
+	now := time.Now()
+	rootKey, rootCertificate := mustIssueCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "xades4go test root CA"},
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, nil, nil)
+	intermediateKey, intermediateCertificate := mustIssueCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "xades4go test intermediate CA"},
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		CRLDistributionPoints: []string{testRootCRLURL},
+	}, rootCertificate, rootKey)
+	signerKey, signerCertificate := mustIssueCertificate(t, &x509.Certificate{
+		Subject:    pkix.Name{CommonName: "xades4go test signer"},
+		KeyUsage:   x509.KeyUsageDigitalSignature,
+		OCSPServer: []string{testSignerOCSPURL},
+	}, intermediateCertificate, intermediateKey)
+	authority, tsaCertificate := mustCreateTimeStampAuthority(t, now)
+	generator, err := xades4go.NewXAdESSignatureGenerator(signedInfoFactory, signerKey, []*x509.Certificate{signerCertificate}, xades4go.ECDSASHA256SignatureAlgorithm,
+		xades4go.GenerateWithBaselineProfile(xades4go.BaselineProfileT), xades4go.GenerateWithTimeStampProvider(authority))
+	if err != nil {
+		t.Fatalf("NewXAdESSignatureGenerator() error = %v", err)
+	}
+	baselineTSignature, err := generator.SignXMLBytes([]byte(unsignedInvoice), []xades4go.ReferenceGenerationDetail{
+		{
+			URIOfDataObjectBeingSigned: "",
+			TransformAlgorithms:        []string{xades4go.EnvelopedSignatureTransformAlgorithm},
+			DigestAlgorithm:            xades4go.SHA256MessageDigestAlgorithm,
+		},
+	})
+	if err != nil {
+		t.Fatalf("SignXMLBytes() error = %v", err)
+	}
+	crlFetcher := xades4go.NewMapCRLFetcher(map[string][]byte{testRootCRLURL: mustCreateCRL(t, rootCertificate, rootKey, 1, now, nil, nil)})
+	mustAugmentToLT := func(revokedCertificates ...*x509.Certificate) []byte {
+		responder, err := xades4go.NewInProcessOCSPResponder(intermediateKey, intermediateCertificate, intermediateCertificate, nil)
+		if err != nil {
+			t.Fatalf("NewInProcessOCSPResponder() error = %v", err)
+		}
+		for _, revokedCertificate := range revokedCertificates {
+			responder.Revoke(revokedCertificate.SerialNumber, now.Add(-time.Minute), 1)
+		}
+		augmenter := xades4go.NewXAdESSignatureAugmenter(signedInfoFactory,
+			xades4go.AugmentWithCertificates(intermediateCertificate, rootCertificate),
+			xades4go.AugmentWithRevocationDataProvider(xades4go.NewRevocationDataProvider(responder, crlFetcher)),
+		)
+		augmentedXMLBytes, err := augmenter.Augment(baselineTSignature, xades4go.BaselineProfileLT)
+		if err != nil {
+			t.Fatalf("Augment() error = %v", err)
+		}
+		return augmentedXMLBytes
+	}
+
+	tests := []struct {
+		name               string
+		xmlBytes           []byte
+		validationTime     time.Time
+		trustsTSA          bool
+		wantIsChainTrusted bool
+		wantIsValid        bool
+		wantStatuses       []xades4go.RevocationStatus
+		wantEvidences      []string
+	}{
+		{
+			name:               "When B-LT signature carries the intermediate certificate and the revocation data, it should be valid offline",
+			xmlBytes:           mustAugmentToLT(),
+			validationTime:     now,
+			wantIsChainTrusted: true,
+			wantIsValid:        true,
+			wantStatuses:       []xades4go.RevocationStatus{xades4go.RevocationStatusGood, xades4go.RevocationStatusGood},
+			wantEvidences:      []string{"OCSP", "CRL"},
+		},
+		{
+			name:               "When B-LT signature is validated at a time before its revocation data was issued, the revocation data should not be used",
+			xmlBytes:           mustAugmentToLT(),
+			validationTime:     now.Add(-30 * time.Minute),
+			wantIsChainTrusted: true,
+			wantStatuses:       []xades4go.RevocationStatus{xades4go.RevocationStatusUnknown, xades4go.RevocationStatusUnknown},
+			wantEvidences:      []string{"", ""},
+		},
+		{
+			name:               "When the signer certificate has expired but SignatureTimeStamp is trusted, it should be validated at the time-stamp with the old revocation data",
+			xmlBytes:           mustAugmentToLT(),
+			validationTime:     now.Add(48 * time.Hour),
+			trustsTSA:          true,
+			wantIsChainTrusted: true,
+			wantIsValid:        true,
+			wantStatuses:       []xades4go.RevocationStatus{xades4go.RevocationStatusGood, xades4go.RevocationStatusGood},
+			wantEvidences:      []string{"OCSP", "CRL"},
+		},
+		{
+			name:           "When the signer certificate has expired and SignatureTimeStamp is not trusted, it should not be trusted",
+			xmlBytes:       mustAugmentToLT(),
+			validationTime: now.Add(48 * time.Hour),
+		},
+		{
+			name:               "When B-LT signature carries OCSP response that the signer certificate is revoked, it should be revoked offline",
+			xmlBytes:           mustAugmentToLT(signerCertificate),
+			validationTime:     now,
+			wantIsChainTrusted: true,
+			wantStatuses:       []xades4go.RevocationStatus{xades4go.RevocationStatusRevoked, xades4go.RevocationStatusGood},
+			wantEvidences:      []string{"OCSP", "CRL"},
+		},
+		{
+			name:           "When B-T signature does not carry the intermediate certificate, it should not be trusted",
+			xmlBytes:       baselineTSignature,
+			validationTime: now,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trustAnchors := []*x509.Certificate{rootCertificate}
+			if tt.trustsTSA {
+				trustAnchors = append(trustAnchors, tsaCertificate)
+			}
+			validator := xades4go.NewXAdESSignatureValidator(signedInfoFactory, xades4go.ValidateWithXMLDSigOptions(
+				xades4go.ValidateWithTrustStore(xades4go.NewTrustStore(trustAnchors, nil)),
+				xades4go.ValidateWithValidationTime(func() time.Time { return tt.validationTime }),
+			))
+			got, err := validator.Validate(tt.xmlBytes)
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			certificateValidationResult := got.CertificateValidationResult
+			if certificateValidationResult == nil {
+				t.Fatalf("Validate() CertificateValidationResult = nil")
+			}
+			if certificateValidationResult.IsChainTrusted != tt.wantIsChainTrusted {
+				t.Errorf("Validate() IsChainTrusted = %v, want %v (%s)", certificateValidationResult.IsChainTrusted, tt.wantIsChainTrusted, certificateValidationResult.ChainStatus)
+			}
+			if certificateValidationResult.IsValid != tt.wantIsValid {
+				t.Errorf("Validate() IsValid = %v, want %v", certificateValidationResult.IsValid, tt.wantIsValid)
+			}
+			var gotStatuses []xades4go.RevocationStatus
+			var gotEvidences []string
+			for _, revocationResult := range certificateValidationResult.RevocationResults {
+				gotStatuses = append(gotStatuses, revocationResult.Status)
+				gotEvidences = append(gotEvidences, evidenceOf(revocationResult))
+			}
+			if diff := cmp.Diff(tt.wantStatuses, gotStatuses); diff != "" {
+				t.Errorf("Validate() revocation statuses mismatch (-want+got):\n%s\n%+v", diff, certificateValidationResult.RevocationResults)
+			}
+			if diff := cmp.Diff(tt.wantEvidences, gotEvidences); diff != "" {
+				t.Errorf("Validate() revocation evidences mismatch (-want+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_NewRevocationDataProvider(t *testing.T) {
+	rootKey, rootCertificate := mustIssueCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "xades4go test root CA"},
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, nil, nil)
+	_, signerCertificate := mustIssueCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "xades4go test signer"},
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		CRLDistributionPoints: []string{testRootCRLURL},
+	}, rootCertificate, rootKey)
+	rootCRL := mustCreateCRL(t, rootCertificate, rootKey, 1, time.Now(), nil, nil)
+
+	tests := []struct {
+		name         string
+		certificates []*x509.Certificate
+		crlFetcher   xades4go.CRLFetcher
+		wantCRLs     [][]byte
+		wantErr      bool
+	}{
+		{
+			name:         "When CRL of the issuer is available, it should return it once for the certificate and skip the self-signed certificate",
+			certificates: []*x509.Certificate{signerCertificate, rootCertificate},
+			crlFetcher:   xades4go.NewMapCRLFetcher(map[string][]byte{testRootCRLURL: rootCRL}),
+			wantCRLs:     [][]byte{rootCRL},
+		},
+		{
+			name:         "When the issuer certificate is missing, it should return error",
+			certificates: []*x509.Certificate{signerCertificate},
+			crlFetcher:   xades4go.NewMapCRLFetcher(map[string][]byte{testRootCRLURL: rootCRL}),
+			wantErr:      true,
+		},
+		{
+			name:         "When no revocation data can be obtained, it should return error",
+			certificates: []*x509.Certificate{signerCertificate, rootCertificate},
+			crlFetcher:   xades4go.NewMapCRLFetcher(map[string][]byte{}),
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotCRLs, gotOCSPResponses, err := xades4go.NewRevocationDataProvider(nil, tt.crlFetcher).RevocationData(tt.certificates)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RevocationData() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.wantCRLs, gotCRLs); diff != "" {
+				t.Errorf("RevocationData() CRLs mismatch (-want+got):\n%s", diff)
+			}
+			if len(gotOCSPResponses) != 0 {
+				t.Errorf("RevocationData() returns %d OCSP responses, want none", len(gotOCSPResponses))
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package xades4go
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+const (
+	pssNamespacePrefix = "pss"
+	pssNamespaceURI    = "http://www.w3.org/2007/05/xmldsig-more#"
+
+	rsaPSSParamsElementTag           = "RSAPSSParams"
+	maskGenerationFunctionElementTag = "MaskGenerationFunction"
+	saltLengthElementTag             = "SaltLength"
+	trailerFieldElementTag           = "TrailerField"
+
+	// pssTrailerField is the only trailer field (0xBC) defined by RFC 8017 and supported by crypto/rsa.
+	pssTrailerField = 1
+)
+
+// PSSParameters are the parameters of RSASSAPSSSignatureAlgorithm, written in RSAPSSParams element of SignatureMethod element (RFC 6931 section 2.3.10).
+// DigestAlgorithm hashes SignedInfo and is also used by MGF1, since crypto/rsa does not support a different digest for the mask generation function.
+// SaltLength is the length of the salt in octets. Zero-length salt can be verified but not used for signing, since crypto/rsa reads zero as the maximum length.
+type PSSParameters struct {
+	DigestAlgorithm string
+	SaltLength      int
+	// maskGenerationAlgorithm and maskGenerationDigestAlgorithm are read from MaskGenerationFunction element. Empty means MGF1 with DigestAlgorithm.
+	maskGenerationAlgorithm       string
+	maskGenerationDigestAlgorithm string
+}
+
+// GenerateWithPSSParameters sets the parameters of RSASSAPSSSignatureAlgorithm. Without it, SHA-256 and 32-octet salt are used.
+func GenerateWithPSSParameters(parameters PSSParameters) XMLDSigSignatureGeneratorOption {
+	return func(generator *XMLDSigSignatureGenerator) {
+		generator.pssParameters = &parameters
+	}
+}
+
+// mgf1SignatureAlgorithmDigestAlgorithms maps RSASHA*MGF1SignatureAlgorithm to the digest algorithm used both for SignedInfo and MGF1.
+var mgf1SignatureAlgorithmDigestAlgorithms = map[string]string{
+	RSASHA1MGF1SignatureAlgorithm:   SHA1MessageDigestAlgorithm,
+	RSASHA224MGF1SignatureAlgorithm: SHA224MessageDigestAlgorithm,
+	RSASHA256MGF1SignatureAlgorithm: SHA256MessageDigestAlgorithm,
+	RSASHA384MGF1SignatureAlgorithm: SHA384MessageDigestAlgorithm,
+	RSASHA512MGF1SignatureAlgorithm: SHA512MessageDigestAlgotithm,
+}
+
+// isRSAPSSSignatureAlgorithm returns true if signatureAlgorithm is RSASSAPSSSignatureAlgorithm or one of RSASHA*MGF1SignatureAlgorithm.
+func isRSAPSSSignatureAlgorithm(signatureAlgorithm string) bool {
+	_, ok := mgf1SignatureAlgorithmDigestAlgorithms[signatureAlgorithm]
+	return ok || signatureAlgorithm == RSASSAPSSSignatureAlgorithm
+}
+
+// pssParametersOf returns the PSS parameters of signatureAlgorithm, or nil if it is not a RSASSA-PSS algorithm.
+// The parameters of RSASHA*MGF1SignatureAlgorithm are implied by the URI (salt as long as the digest), while the ones of RSASSAPSSSignatureAlgorithm are read from RSAPSSParams element of signatureMethodElement, which may be nil or omit any of them to take their defaults.
+// A mask generation function that cannot be used is not reported here but by rsaPSSOptionsOf, so that the signature is invalid rather than the validation failing.
+func pssParametersOf(signatureAlgorithm string, signatureMethodElement *etree.Element) (*PSSParameters, error) {
+	if !isRSAPSSSignatureAlgorithm(signatureAlgorithm) {
+		return nil, nil
+	}
+	if digestAlgorithm, ok := mgf1SignatureAlgorithmDigestAlgorithms[signatureAlgorithm]; ok {
+		hashAlgorithm, err := mapDigestAlgorithmToCryptoHash(digestAlgorithm)
+		if err != nil {
+			return nil, err
+		}
+		return &PSSParameters{DigestAlgorithm: digestAlgorithm, SaltLength: hashAlgorithm.Size()}, nil
+	}
+	parameters := &PSSParameters{DigestAlgorithm: SHA256MessageDigestAlgorithm}
+	maskGenerationDigestAlgorithm := SHA256MessageDigestAlgorithm
+	isSaltLengthGiven := false
+	var rsaPSSParamsElement *etree.Element
+	if signatureMethodElement != nil {
+		var err error
+		rsaPSSParamsElement, err = mustFoundOnlyOneIfFound(signatureMethodElement, rsaPSSParamsElementTag)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if rsaPSSParamsElement != nil {
+		if digestMethodElement := rsaPSSParamsElement.SelectElement(digestMethodElementTag); digestMethodElement != nil {
+			algorithmAttribute, err := mustFoundAttribute(digestMethodElement, algorithmAttributeKey)
+			if err != nil {
+				return nil, err
+			}
+			parameters.DigestAlgorithm = algorithmAttribute.Value
+		}
+		if maskGenerationFunctionElement := rsaPSSParamsElement.SelectElement(maskGenerationFunctionElementTag); maskGenerationFunctionElement != nil {
+			if maskGenerationFunctionAlgorithm := maskGenerationFunctionElement.SelectAttrValue(algorithmAttributeKey, MGF1Algorithm); maskGenerationFunctionAlgorithm != MGF1Algorithm {
+				parameters.maskGenerationAlgorithm = maskGenerationFunctionAlgorithm
+			}
+			if digestMethodElement := maskGenerationFunctionElement.SelectElement(digestMethodElementTag); digestMethodElement != nil {
+				maskGenerationDigestAlgorithm = digestMethodElement.SelectAttrValue(algorithmAttributeKey, "")
+			}
+		}
+		if saltLengthElement := rsaPSSParamsElement.SelectElement(saltLengthElementTag); saltLengthElement != nil {
+			saltLength, err := strconv.Atoi(strings.TrimSpace(saltLengthElement.Text()))
+			if err != nil || saltLength < 0 {
+				return nil, fmt.Errorf("SaltLength must be a non-negative integer, got %s", saltLengthElement.Text())
+			}
+			parameters.SaltLength = saltLength
+			isSaltLengthGiven = true
+		}
+		if trailerFieldElement := rsaPSSParamsElement.SelectElement(trailerFieldElementTag); trailerFieldElement != nil {
+			if strings.TrimSpace(trailerFieldElement.Text()) != strconv.Itoa(pssTrailerField) {
+				return nil, fmt.Errorf("TrailerField must be %d, got %s", pssTrailerField, trailerFieldElement.Text())
+			}
+		}
+	}
+	if maskGenerationDigestAlgorithm != parameters.DigestAlgorithm {
+		parameters.maskGenerationDigestAlgorithm = maskGenerationDigestAlgorithm
+	}
+	if !isSaltLengthGiven {
+		hashAlgorithm, err := mapDigestAlgorithmToCryptoHash(parameters.DigestAlgorithm)
+		if err != nil {
+			return nil, err
+		}
+		parameters.SaltLength = hashAlgorithm.Size()
+	}
+	return parameters, nil
+}
+
+// createRSAPSSParamsElement appends RSAPSSParams element describing parameters to signatureMethodElement. Every parameter is written even if it is the default.
+func createRSAPSSParamsElement(signatureMethodElement *etree.Element, parameters PSSParameters) {
+	rsaPSSParamsElement := signatureMethodElement.CreateElement(pssNamespacePrefix + ":" + rsaPSSParamsElementTag)
+	rsaPSSParamsElement.CreateAttr("xmlns:"+pssNamespacePrefix, pssNamespaceURI)
+	createXMLDSigElement(rsaPSSParamsElement, digestMethodElementTag).CreateAttr(algorithmAttributeKey, parameters.DigestAlgorithm)
+	maskGenerationFunctionElement := rsaPSSParamsElement.CreateElement(pssNamespacePrefix + ":" + maskGenerationFunctionElementTag)
+	maskGenerationFunctionElement.CreateAttr(algorithmAttributeKey, MGF1Algorithm)
+	createXMLDSigElement(maskGenerationFunctionElement, digestMethodElementTag).CreateAttr(algorithmAttributeKey, parameters.DigestAlgorithm)
+	rsaPSSParamsElement.CreateElement(pssNamespacePrefix + ":" + saltLengthElementTag).SetText(strconv.Itoa(parameters.SaltLength))
+	rsaPSSParamsElement.CreateElement(pssNamespacePrefix + ":" + trailerFieldElementTag).SetText(strconv.Itoa(pssTrailerField))
+}
+
+// rsaPSSOptionsOf returns the hash and the options for crypto/rsa to sign or verify with parameters.
+// SaltLength 0 is passed as is, which crypto/rsa reads as "any length" when verifying (see verifyPSS) and "maximum length" when signing.
+func rsaPSSOptionsOf(parameters PSSParameters) (crypto.Hash, *rsa.PSSOptions, error) {
+	if parameters.maskGenerationAlgorithm != "" && parameters.maskGenerationAlgorithm != MGF1Algorithm {
+		return 0, nil, fmt.Errorf("this package does not implement %s mask generation function", parameters.maskGenerationAlgorithm)
+	}
+	// MGF1 defaults to SHA-256 even when DigestMethod of RSAPSSParams is another one, so omitting MaskGenerationFunction is not the same as following DigestMethod.
+	if parameters.maskGenerationDigestAlgorithm != "" && parameters.maskGenerationDigestAlgorithm != parameters.DigestAlgorithm {
+		return 0, nil, fmt.Errorf("digest of MGF1 (%s) must be the same as DigestMethod of RSAPSSParams (%s)", parameters.maskGenerationDigestAlgorithm, parameters.DigestAlgorithm)
+	}
+	hashAlgorithm, err := mapDigestAlgorithmToCryptoHash(parameters.DigestAlgorithm)
+	if err != nil {
+		return 0, nil, err
+	}
+	if parameters.SaltLength < 0 {
+		return 0, nil, fmt.Errorf("SaltLength must be a non-negative integer, got %d", parameters.SaltLength)
+	}
+	return hashAlgorithm, &rsa.PSSOptions{SaltLength: parameters.SaltLength, Hash: hashAlgorithm}, nil
+}
+
+// verifyPSS verifies RSASSA-PSS signatureValue of hashed with options. A PSS signature verifies with only one salt length,
+// so a signature declared without salt, which crypto/rsa verifies with any salt length, must not verify with any positive one.
+func verifyPSS(publicKey *rsa.PublicKey, hashAlgorithm crypto.Hash, hashed []byte, signatureValue []byte, options *rsa.PSSOptions) error {
+	err := rsa.VerifyPSS(publicKey, hashAlgorithm, hashed, signatureValue, options)
+	if err != nil || options.SaltLength != 0 {
+		return err
+	}
+	maxSaltLength := (publicKey.N.BitLen()+6)/8 - hashAlgorithm.Size() - 2
+	for saltLength := 1; saltLength <= maxSaltLength; saltLength++ {
+		if rsa.VerifyPSS(publicKey, hashAlgorithm, hashed, signatureValue, &rsa.PSSOptions{SaltLength: saltLength}) == nil {
+			return fmt.Errorf("signature has %d-octet salt but SaltLength is 0", saltLength)
+		}
+	}
+	return nil
+}
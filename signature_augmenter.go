@@ -0,0 +1,20 @@
+package xades4go
+
+import (
+	"crypto/x509"
+)
+
+// SignatureAugmenter extends an existing signature to a higher level by adding unsigned qualifying properties. It does not need the signing key and never changes the signed content.
+type SignatureAugmenter interface {
+	// Augment adds to the signature of xmlBytes the unsigned properties that baselineProfile requires and the signature does not carry yet.
+	Augment(xmlBytes []byte, baselineProfile BaselineProfile) ([]byte, error)
+	// RenewArchiveTimeStamp appends a new ArchiveTimeStamp over the signature of xmlBytes and all its unsigned properties, including the earlier ArchiveTimeStamp elements.
+	// It renews the protection before the algorithms or the TSA certificates of the earlier time-stamps become unreliable.
+	RenewArchiveTimeStamp(xmlBytes []byte) ([]byte, error)
+}
+
+// RevocationDataProvider is an object that provides the revocation data (to be put in RevocationValues element) proving the status of certificates.
+type RevocationDataProvider interface {
+	// RevocationData returns DER-encoded CRLs and DER-encoded OCSP responses covering certificates.
+	RevocationData(certificates []*x509.Certificate) (crls [][]byte, ocspResponses [][]byte, err error)
+}
@@ -1,11 +1,183 @@
 package xades4go
 
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+const (
+	xmldsigNamespacePrefix = "ds"
+	xmldsigNamespaceURI    = "http://www.w3.org/2000/09/xmldsig#"
+
+	objectElementTag = "Object"
+
+	idAttributeKey       = "Id"
+	typeAttributeKey     = "Type"
+	mimeTypeAttributeKey = "MimeType"
+	encodingAttributeKey = "Encoding"
+)
+
 type SignatureGenerator interface {
+	// SignXMLBytes creates an enveloped signature over data objects of xmlBytes.
 	SignXMLBytes(xmlBytes []byte, dataObjectReferences []ReferenceGenerationDetail) ([]byte, error)
 }
 
+// EnvelopingSigner is an object that creates enveloping signatures. The SignatureGenerator created by NewXMLDSigSignatureGenerator or NewXAdESSignatureGenerator implements it.
+type EnvelopingSigner interface {
+	// SignEnveloping creates an enveloping signature. Each data object is wrapped in an Object element of the generated Signature element and referenced by its Id.
+	SignEnveloping(dataObjects []DataObjectGenerationDetail) ([]byte, error)
+}
+
+// DetachedSigner is an object that creates detached signatures. The SignatureGenerator created by NewXMLDSigSignatureGenerator or NewXAdESSignatureGenerator implements it.
+type DetachedSigner interface {
+	// SignDetached creates a detached signature over data objects outside the generated Signature element.
+	SignDetached(dataObjectReferences []ReferenceGenerationDetail) ([]byte, error)
+}
+
 type ReferenceGenerationDetail struct {
 	URIOfDataObjectBeingSigned string
 	TransformAlgorithms        []string
 	DigestAlgorithm            string
+	// Type is the optional Type attribute of Reference element.
+	Type string
+	// MimeType is the MIME type of the data object. It is only used by XAdESSignatureGenerator to describe the data object in DataObjectFormat element.
+	MimeType string
+}
+
+// DataObjectGenerationDetail is a data object to be wrapped in an Object element of an enveloping signature.
+// If IsOctetStream is true, Content is base64-encoded into the Object element and Base64 transform is prepended to TransformAlgorithms, so the digest is computed over Content itself.
+// Otherwise, Content must be a well-formed XML that is appended as children of the Object element.
+type DataObjectGenerationDetail struct {
+	ID                  string
+	MimeType            string
+	Content             []byte
+	IsOctetStream       bool
+	TransformAlgorithms []string
+	DigestAlgorithm     string
+}
+
+// SignatureValueSigner is an object that sign canonicalized SignedInfo element using the given signature algorithm and return Base64-encoded signature value (to be put in SignatureValue element).
+// It is the counterpart of SignatureValueVerifier.
+type SignatureValueSigner interface {
+	Sign(signatureAlgorithm string, canonicalizedSignedInfo []byte) ([]byte, error)
+}
+
+// createSignatureValueSigner creates SignatureValueSigner for the key of signer. pssParameters are only used by RSA key with RSASSA-PSS signature algorithms.
+func createSignatureValueSigner(signer crypto.Signer, pssParameters *PSSParameters) (SignatureValueSigner, error) {
+	switch signer.Public().(type) {
+	case *rsa.PublicKey:
+		return &rsaSignatureValueSigner{signer: signer, pssParameters: pssParameters}, nil
+	case *ecdsa.PublicKey:
+		return &ecdsaSignatureValueSigner{signer: signer}, nil
+	case ed25519.PublicKey:
+		return &ed25519SignatureValueSigner{signer: signer}, nil
+	}
+	return nil, fmt.Errorf("this package does not implement signing with %T public key", signer.Public())
+}
+
+// rsaSignatureValueSigner signs with RSA key using RSASSA-PKCS1-v1_5, or RSASSA-PSS with pssParameters for RSASSA-PSS signature algorithms.
+type rsaSignatureValueSigner struct {
+	signer        crypto.Signer
+	pssParameters *PSSParameters
+}
+
+func (valueSigner *rsaSignatureValueSigner) Sign(signatureAlgorithm string, canonicalizedSignedInfo []byte) ([]byte, error) {
+	var hashAlgorithm crypto.Hash
+	var signerOpts crypto.SignerOpts
+	switch {
+	case signatureAlgorithm == RSASHA224SignatureAlgorithm || signatureAlgorithm == RSASHA256SignatureAlgorithm || signatureAlgorithm == RSASHA384SignatureAlgorithm || signatureAlgorithm == RSASHA512SignatureAlgorithm:
+		var err error
+		hashAlgorithm, err = mapSignatureAlgorithmToCrytoHash(signatureAlgorithm)
+		if err != nil {
+			return nil, err
+		}
+		signerOpts = hashAlgorithm
+	case isRSAPSSSignatureAlgorithm(signatureAlgorithm) && valueSigner.pssParameters != nil:
+		var err error
+		hashAlgorithm, signerOpts, err = rsaPSSOptionsOf(*valueSigner.pssParameters)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%s cannot be used with RSA key", signatureAlgorithm)
+	}
+	h := hashAlgorithm.New()
+	_, err := h.Write(canonicalizedSignedInfo)
+	if err != nil {
+		return nil, fmt.Errorf("cannot hash SignedInfo using %s: %w", hashAlgorithm.String(), err)
+	}
+	signatureValue, err := valueSigner.signer.Sign(rand.Reader, h.Sum(nil), signerOpts)
+	if err != nil {
+		return nil, fmt.Errorf("cannot sign SignedInfo: %w", err)
+	}
+	return []byte(base64.StdEncoding.EncodeToString(signatureValue)), nil
+}
+
+// ecdsaSignatureValueSigner signs with ECDSA key. crypto.Signer of ECDSA key (including the ones backed by HSM) returns ASN.1 DER signature,
+// which is converted to the r||s concatenation required by XMLDSig.
+type ecdsaSignatureValueSigner struct {
+	signer crypto.Signer
+}
+
+func (valueSigner *ecdsaSignatureValueSigner) Sign(signatureAlgorithm string, canonicalizedSignedInfo []byte) ([]byte, error) {
+	switch signatureAlgorithm {
+	case ECDSASHA1SignatureAlgorithm, ECDSASHA224SignatureAlgorithm, ECDSASHA256SignatureAlgorithm, ECDSASHA384SignatureAlgorithm, ECDSASHA512SignatureAlgorithm:
+	default:
+		return nil, fmt.Errorf("%s cannot be used with ECDSA key", signatureAlgorithm)
+	}
+	hashAlgorithm, err := mapSignatureAlgorithmToCrytoHash(signatureAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	h := hashAlgorithm.New()
+	_, err = h.Write(canonicalizedSignedInfo)
+	if err != nil {
+		return nil, fmt.Errorf("cannot hash SignedInfo using %s: %w", hashAlgorithm.String(), err)
+	}
+	derSignatureValue, err := valueSigner.signer.Sign(rand.Reader, h.Sum(nil), hashAlgorithm)
+	if err != nil {
+		return nil, fmt.Errorf("cannot sign SignedInfo: %w", err)
+	}
+	var ecdsaSignature struct {
+		R, S *big.Int
+	}
+	rest, err := asn1.Unmarshal(derSignatureValue, &ecdsaSignature)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse ASN.1 ECDSA signature: %w", err)
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("ASN.1 ECDSA signature has %d trailing bytes", len(rest))
+	}
+	octetLength := ecdsaOctetLength(valueSigner.signer.Public().(*ecdsa.PublicKey))
+	if ecdsaSignature.R.Sign() <= 0 || ecdsaSignature.S.Sign() <= 0 || len(ecdsaSignature.R.Bytes()) > octetLength || len(ecdsaSignature.S.Bytes()) > octetLength {
+		return nil, errors.New("ECDSA signature is out of range of the curve")
+	}
+	signatureValue := make([]byte, 2*octetLength)
+	ecdsaSignature.R.FillBytes(signatureValue[:octetLength])
+	ecdsaSignature.S.FillBytes(signatureValue[octetLength:])
+	return []byte(base64.StdEncoding.EncodeToString(signatureValue)), nil
+}
+
+// ed25519SignatureValueSigner signs with Ed25519 key. Ed25519 hashes the message internally, so canonicalized SignedInfo is given to the signer as is.
+type ed25519SignatureValueSigner struct {
+	signer crypto.Signer
+}
+
+func (valueSigner *ed25519SignatureValueSigner) Sign(signatureAlgorithm string, canonicalizedSignedInfo []byte) ([]byte, error) {
+	if signatureAlgorithm != EdDSAEd25519SignatureAlgorithm {
+		return nil, fmt.Errorf("%s cannot be used with Ed25519 key", signatureAlgorithm)
+	}
+	signatureValue, err := valueSigner.signer.Sign(rand.Reader, canonicalizedSignedInfo, crypto.Hash(0))
+	if err != nil {
+		return nil, fmt.Errorf("cannot sign SignedInfo: %w", err)
+	}
+	return []byte(base64.StdEncoding.EncodeToString(signatureValue)), nil
 }
@@ -0,0 +1,249 @@
+package xades4go
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+const (
+	signaturePolicyIdentifierElementTag = "SignaturePolicyIdentifier"
+	signaturePolicyIDElementTag         = "SignaturePolicyId"
+	signaturePolicyImpliedElementTag    = "SignaturePolicyImplied"
+	sigPolicyIDElementTag               = "SigPolicyId"
+	identifierElementTag                = "Identifier"
+	descriptionElementTag               = "Description"
+	sigPolicyHashElementTag             = "SigPolicyHash"
+	sigPolicyQualifiersElementTag       = "SigPolicyQualifiers"
+	sigPolicyQualifierElementTag        = "SigPolicyQualifier"
+	spURIElementTag                     = "SPURI"
+	spUserNoticeElementTag              = "SPUserNotice"
+	noticeRefElementTag                 = "NoticeRef"
+	organizationElementTag              = "Organization"
+	noticeNumbersElementTag             = "NoticeNumbers"
+	intElementTag                       = "int"
+	explicitTextElementTag              = "ExplicitText"
+
+	qualifierAttributeKey = "Qualifier"
+)
+
+// SignaturePolicyGenerationDetail is the detail of an explicit signature policy that makes the generated signature XAdES-EPES.
+type SignaturePolicyGenerationDetail struct {
+	// Identifier identifies the signature policy, for example urn:oid:2.16.764.1.4.100.
+	Identifier string
+	// IdentifierQualifier is Qualifier attribute of Identifier element (OIDAsURI or OIDAsURN). It is omitted if empty.
+	IdentifierQualifier string
+	Description         string
+	// Document is the signature policy document. SigPolicyHash is the digest of Document after TransformAlgorithms are applied.
+	Document            []byte
+	TransformAlgorithms []string
+	DigestAlgorithm     string
+	// SPURI is the URI where the signature policy document can be retrieved. It is omitted if empty.
+	SPURI string
+	// SPUserNotice is the notice to be displayed whenever the signature is validated. It is omitted if nil.
+	SPUserNotice *SignaturePolicyUserNotice
+}
+
+// SignaturePolicyUserNotice is the content of SPUserNotice signature policy qualifier.
+// Organization and NoticeNumbers make up NoticeRef element, so they are either both given or both empty.
+type SignaturePolicyUserNotice struct {
+	Organization  string
+	NoticeNumbers []int
+	ExplicitText  string
+}
+
+// SignaturePolicyValidationResult is the result of validating SignaturePolicyIdentifier element.
+type SignaturePolicyValidationResult struct {
+	// IsImplied is true if the signature policy is implied by the semantics of the signed data (SignaturePolicyImplied element). Other fields are empty in this case.
+	IsImplied  bool
+	Identifier string
+	SPURI      string
+	// SPUserNotice is nil if SPUserNotice signature policy qualifier is absent.
+	SPUserNotice *SignaturePolicyUserNotice
+	// IsPolicyHashChecked is false if SigPolicyHash is not checked as the validator has no signature policy resolver (see ValidateWithSignaturePolicyResolver).
+	// An unchecked SigPolicyHash does not make the qualifying properties invalid. It is always true for implied policy.
+	IsPolicyHashChecked bool
+	// IsPolicyHashValid is true if SigPolicyHash matches the signature policy document. It is always true for implied policy and always false if IsPolicyHashChecked is false.
+	IsPolicyHashValid    bool
+	GeneratedDigestValue string
+	DigestValue          string
+}
+
+// createSignaturePolicyIdentifierElement appends SignaturePolicyIdentifier element of the explicit policy to parent.
+func createSignaturePolicyIdentifierElement(parent *etree.Element, signedInfoFactory SignedInfoFactory, defaultCanonicalizationAlgorithm string, policy SignaturePolicyGenerationDetail) error {
+	if policy.Identifier == "" {
+		return errors.New("Identifier of signature policy must not be empty")
+	}
+	if policy.SPUserNotice != nil && (policy.SPUserNotice.Organization == "") != (len(policy.SPUserNotice.NoticeNumbers) == 0) {
+		return errors.New("Organization and NoticeNumbers of SPUserNotice must be given together")
+	}
+	policyDigestValue, err := digestSignaturePolicyDocument(signedInfoFactory, defaultCanonicalizationAlgorithm, policy.Document, policy.TransformAlgorithms, policy.DigestAlgorithm)
+	if err != nil {
+		return err
+	}
+	signaturePolicyIDElement := createXAdESElement(createXAdESElement(parent, signaturePolicyIdentifierElementTag), signaturePolicyIDElementTag)
+	sigPolicyIDElement := createXAdESElement(signaturePolicyIDElement, sigPolicyIDElementTag)
+	identifierElement := createXAdESElement(sigPolicyIDElement, identifierElementTag)
+	if policy.IdentifierQualifier != "" {
+		identifierElement.CreateAttr(qualifierAttributeKey, policy.IdentifierQualifier)
+	}
+	identifierElement.SetText(policy.Identifier)
+	if policy.Description != "" {
+		createXAdESElement(sigPolicyIDElement, descriptionElementTag).SetText(policy.Description)
+	}
+	if len(policy.TransformAlgorithms) > 0 {
+		transformsElement := createXMLDSigElement(signaturePolicyIDElement, transformsElementTag)
+		for _, transformAlgorithm := range policy.TransformAlgorithms {
+			createXMLDSigElement(transformsElement, transformElementTag).CreateAttr(algorithmAttributeKey, transformAlgorithm)
+		}
+	}
+	sigPolicyHashElement := createXAdESElement(signaturePolicyIDElement, sigPolicyHashElementTag)
+	createXMLDSigElement(sigPolicyHashElement, digestMethodElementTag).CreateAttr(algorithmAttributeKey, policy.DigestAlgorithm)
+	createXMLDSigElement(sigPolicyHashElement, digestValueElementTag).SetText(string(policyDigestValue))
+	if policy.SPURI == "" && policy.SPUserNotice == nil {
+		return nil
+	}
+	sigPolicyQualifiersElement := createXAdESElement(signaturePolicyIDElement, sigPolicyQualifiersElementTag)
+	if policy.SPURI != "" {
+		createXAdESElement(createXAdESElement(sigPolicyQualifiersElement, sigPolicyQualifierElementTag), spURIElementTag).SetText(policy.SPURI)
+	}
+	if policy.SPUserNotice != nil {
+		spUserNoticeElement := createXAdESElement(createXAdESElement(sigPolicyQualifiersElement, sigPolicyQualifierElementTag), spUserNoticeElementTag)
+		if policy.SPUserNotice.Organization != "" {
+			noticeRefElement := createXAdESElement(spUserNoticeElement, noticeRefElementTag)
+			createXAdESElement(noticeRefElement, organizationElementTag).SetText(policy.SPUserNotice.Organization)
+			noticeNumbersElement := createXAdESElement(noticeRefElement, noticeNumbersElementTag)
+			for _, noticeNumber := range policy.SPUserNotice.NoticeNumbers {
+				createXAdESElement(noticeNumbersElement, intElementTag).SetText(strconv.Itoa(noticeNumber))
+			}
+		}
+		if policy.SPUserNotice.ExplicitText != "" {
+			createXAdESElement(spUserNoticeElement, explicitTextElementTag).SetText(policy.SPUserNotice.ExplicitText)
+		}
+	}
+	return nil
+}
+
+// validateSignaturePolicyIdentifier parses SignaturePolicyIdentifier element and recomputes SigPolicyHash from the policy document resolved by policyResolver using the policy identifier.
+// SigPolicyHash is left unchecked if policyResolver is nil.
+func validateSignaturePolicyIdentifier(signaturePolicyIdentifierElement *etree.Element, signedInfoFactory SignedInfoFactory, defaultCanonicalizationAlgorithm string, policyResolver URIResolver) (SignaturePolicyValidationResult, error) {
+	if signaturePolicyIdentifierElement.SelectElement(signaturePolicyImpliedElementTag) != nil {
+		return SignaturePolicyValidationResult{IsImplied: true, IsPolicyHashChecked: true, IsPolicyHashValid: true}, nil
+	}
+	signaturePolicyIDElement, err := mustFoundOnlyOneChildElement(signaturePolicyIdentifierElement, signaturePolicyIDElementTag)
+	if err != nil {
+		return SignaturePolicyValidationResult{}, err
+	}
+	sigPolicyIDElement, err := mustFoundOnlyOneChildElement(signaturePolicyIDElement, sigPolicyIDElementTag)
+	if err != nil {
+		return SignaturePolicyValidationResult{}, err
+	}
+	identifierElement, err := mustFoundOnlyOneChildElement(sigPolicyIDElement, identifierElementTag)
+	if err != nil {
+		return SignaturePolicyValidationResult{}, err
+	}
+	result := SignaturePolicyValidationResult{Identifier: strings.TrimSpace(identifierElement.Text())}
+	transformAlgorithms := make([]string, 0)
+	transformsElement, err := mustFoundOnlyOneIfFound(signaturePolicyIDElement, transformsElementTag)
+	if err != nil {
+		return SignaturePolicyValidationResult{}, err
+	}
+	if transformsElement != nil {
+		transformElements, err := mustFoundAtLeastOneChildElement(transformsElement, transformElementTag)
+		if err != nil {
+			return SignaturePolicyValidationResult{}, err
+		}
+		for transformIndex, transformElement := range transformElements {
+			algorithmAttribute, err := mustFoundAttribute(transformElement, algorithmAttributeKey)
+			if err != nil {
+				return SignaturePolicyValidationResult{}, fmt.Errorf("at Transform#%d element of SignaturePolicyId element: %w", transformIndex, err)
+			}
+			transformAlgorithms = append(transformAlgorithms, algorithmAttribute.Value)
+		}
+	}
+	sigPolicyHashElement, err := mustFoundOnlyOneChildElement(signaturePolicyIDElement, sigPolicyHashElementTag)
+	if err != nil {
+		return SignaturePolicyValidationResult{}, err
+	}
+	digestMethodElement, err := mustFoundOnlyOneChildElement(sigPolicyHashElement, digestMethodElementTag)
+	if err != nil {
+		return SignaturePolicyValidationResult{}, err
+	}
+	algorithmAttribute, err := mustFoundAttribute(digestMethodElement, algorithmAttributeKey)
+	if err != nil {
+		return SignaturePolicyValidationResult{}, err
+	}
+	digestValueElement, err := mustFoundOnlyOneChildElement(sigPolicyHashElement, digestValueElementTag)
+	if err != nil {
+		return SignaturePolicyValidationResult{}, err
+	}
+	result.DigestValue = strings.Join(strings.Fields(digestValueElement.Text()), "")
+
+	sigPolicyQualifiersElement, err := mustFoundOnlyOneIfFound(signaturePolicyIDElement, sigPolicyQualifiersElementTag)
+	if err != nil {
+		return SignaturePolicyValidationResult{}, err
+	}
+	if sigPolicyQualifiersElement != nil {
+		for _, sigPolicyQualifierElement := range sigPolicyQualifiersElement.SelectElements(sigPolicyQualifierElementTag) {
+			if spURIElement := sigPolicyQualifierElement.SelectElement(spURIElementTag); spURIElement != nil {
+				result.SPURI = strings.TrimSpace(spURIElement.Text())
+			}
+			if spUserNoticeElement := sigPolicyQualifierElement.SelectElement(spUserNoticeElementTag); spUserNoticeElement != nil {
+				result.SPUserNotice, err = parseSignaturePolicyUserNotice(spUserNoticeElement)
+				if err != nil {
+					return SignaturePolicyValidationResult{}, err
+				}
+			}
+		}
+	}
+
+	if policyResolver == nil {
+		return result, nil
+	}
+	policyDocument, err := policyResolver.Resolve(result.Identifier)
+	if err != nil {
+		return SignaturePolicyValidationResult{}, fmt.Errorf("cannot resolve signature policy document: %w", err)
+	}
+	generatedDigestValue, err := digestSignaturePolicyDocument(signedInfoFactory, defaultCanonicalizationAlgorithm, policyDocument, transformAlgorithms, algorithmAttribute.Value)
+	if err != nil {
+		return SignaturePolicyValidationResult{}, err
+	}
+	result.GeneratedDigestValue = string(generatedDigestValue)
+	result.IsPolicyHashChecked = true
+	result.IsPolicyHashValid = result.GeneratedDigestValue == result.DigestValue
+	return result, nil
+}
+
+func parseSignaturePolicyUserNotice(spUserNoticeElement *etree.Element) (*SignaturePolicyUserNotice, error) {
+	userNotice := &SignaturePolicyUserNotice{}
+	if noticeRefElement := spUserNoticeElement.SelectElement(noticeRefElementTag); noticeRefElement != nil {
+		if organizationElement := noticeRefElement.SelectElement(organizationElementTag); organizationElement != nil {
+			userNotice.Organization = organizationElement.Text()
+		}
+		if noticeNumbersElement := noticeRefElement.SelectElement(noticeNumbersElementTag); noticeNumbersElement != nil {
+			for _, intElement := range noticeNumbersElement.SelectElements(intElementTag) {
+				noticeNumber, err := strconv.Atoi(strings.TrimSpace(intElement.Text()))
+				if err != nil {
+					return nil, fmt.Errorf("NoticeNumbers element contains an invalid integer: %w", err)
+				}
+				userNotice.NoticeNumbers = append(userNotice.NoticeNumbers, noticeNumber)
+			}
+		}
+	}
+	if explicitTextElement := spUserNoticeElement.SelectElement(explicitTextElementTag); explicitTextElement != nil {
+		userNotice.ExplicitText = explicitTextElement.Text()
+	}
+	return userNotice, nil
+}
+
+// digestSignaturePolicyDocument computes SigPolicyHash of policyDocument which is treated as octet-stream input of the transforms.
+func digestSignaturePolicyDocument(signedInfoFactory SignedInfoFactory, defaultCanonicalizationAlgorithm string, policyDocument []byte, transformAlgorithms []string, digestAlgorithm string) ([]byte, error) {
+	digestValue, err := digestTransformedDataObject(signedInfoFactory, XML{IsOctetStream: true, OctetStream: policyDocument}, "", defaultCanonicalizationAlgorithm, transformAlgorithms, digestAlgorithm)
+	if err != nil {
+		return nil, fmt.Errorf("cannot compute hash of signature policy document: %w", err)
+	}
+	return digestValue, nil
+}
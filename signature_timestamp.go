@@ -0,0 +1,251 @@
+package xades4go
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+)
+
+const (
+	unsignedPropertiesElementTag          = "UnsignedProperties"
+	unsignedSignaturePropertiesElementTag = "UnsignedSignatureProperties"
+	signatureTimeStampElementTag          = "SignatureTimeStamp"
+	encapsulatedTimeStampElementTag       = "EncapsulatedTimeStamp"
+	xmlTimeStampElementTag                = "XMLTimeStamp"
+	allDataObjectsTimeStampElementTag     = "AllDataObjectsTimeStamp"
+)
+
+// TimeStampValidationResult is the result of validating one time-stamp token of a XAdES time-stamp property.
+type TimeStampValidationResult struct {
+	// Property is the local name of the time-stamp property element, SignatureTimeStamp for example.
+	Property string
+	// ID is Id attribute of the time-stamp property element.
+	ID string
+	// IsValid is true if IsMessageImprintValid, IsSignatureValid and IsTSACertificateTrusted are all true.
+	// Without TrustStore, the trust on TSACertificate is indeterminate and IsValid only requires IsMessageImprintValid and IsSignatureValid.
+	IsValid bool
+	// IsMessageImprintValid is true if the message imprint of the token is the digest of the recomputed time-stamped data.
+	IsMessageImprintValid bool
+	// IsSignatureValid is true if the token is signed by TSACertificate.
+	IsSignatureValid bool
+	// IsTSACertificateTrusted is true if a path is built from TSACertificate to a trust anchor of TrustStore (see ValidateWithTrustStore) at GenTime,
+	// and TSACertificate has id-kp-timeStamping extended key usage. It is always false if the validator has no TrustStore, which is reported by TSACertificateStatus.
+	IsTSACertificateTrusted bool
+	// TSACertificateStatus describes why TSACertificate is not trusted. It is empty if IsTSACertificateTrusted is true.
+	TSACertificateStatus string
+	GenTime              time.Time
+	// TSACertificate is the certificate carried in the token that identifies the TSA. It is nil if the token does not carry it.
+	TSACertificate *x509.Certificate
+}
+
+// addSignatureTimeStamp time-stamps the canonicalized SignatureValue element of signatureElement (placed in doc) and appends the token as SignatureTimeStamp element to UnsignedSignatureProperties element.
+// The digest sent to timeStampProvider is computed with digestAlgorithm.
+func addSignatureTimeStamp(doc *etree.Document, signatureElement *etree.Element, signedInfoFactory SignedInfoFactory, canonicalizationAlgorithm string, digestAlgorithm string, timeStampProvider TimeStampProvider) error {
+	qualifyingPropertiesElement, err := findQualifyingPropertiesElement(signatureElement)
+	if err != nil {
+		return err
+	}
+	xmlBytes, err := doc.WriteToBytes()
+	if err != nil {
+		return fmt.Errorf("cannot serialize document: %w", err)
+	}
+	canonicalizedSignatureValue, err := signatureTimeStampInputOf(signedInfoFactory, xmlBytes, signatureElement, canonicalizationAlgorithm)
+	if err != nil {
+		return err
+	}
+	timeStampToken, err := obtainTimeStampToken(timeStampProvider, digestAlgorithm, canonicalizedSignatureValue)
+	if err != nil {
+		return fmt.Errorf("cannot obtain SignatureTimeStamp: %w", err)
+	}
+	timeStampID, err := generateID("xades-sigts")
+	if err != nil {
+		return err
+	}
+	signatureTimeStampElement := createElementInNamespaceOf(unsignedSignaturePropertiesElementOf(qualifyingPropertiesElement), qualifyingPropertiesElement, signatureTimeStampElementTag)
+	fillTimeStampElement(signatureTimeStampElement, signatureElement, qualifyingPropertiesElement, timeStampID, canonicalizationAlgorithm, timeStampToken)
+	return nil
+}
+
+// obtainTimeStampToken digests timeStampedData with digestAlgorithm and requests a time-stamp token of the digest from timeStampProvider.
+func obtainTimeStampToken(timeStampProvider TimeStampProvider, digestAlgorithm string, timeStampedData []byte) ([]byte, error) {
+	hashAlgorithm, err := mapDigestAlgorithmToCryptoHash(digestAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	hash := hashAlgorithm.New()
+	if _, err := hash.Write(timeStampedData); err != nil {
+		return nil, fmt.Errorf("cannot digest time-stamped data: %w", err)
+	}
+	return timeStampProvider.TimeStamp(hashAlgorithm, hash.Sum(nil))
+}
+
+// fillTimeStampElement sets Id attribute, CanonicalizationMethod and EncapsulatedTimeStamp elements of timeStampElement, an empty element of XAdESTimeStampType.
+// The child elements take the namespace prefixes of signatureElement and qualifyingPropertiesElement.
+func fillTimeStampElement(timeStampElement *etree.Element, signatureElement *etree.Element, qualifyingPropertiesElement *etree.Element, timeStampID string, canonicalizationAlgorithm string, timeStampToken []byte) {
+	timeStampElement.CreateAttr(idAttributeKey, timeStampID)
+	createElementInNamespaceOf(timeStampElement, signatureElement, canonicalizationMethodElementTag).CreateAttr(algorithmAttributeKey, canonicalizationAlgorithm)
+	createElementInNamespaceOf(timeStampElement, qualifyingPropertiesElement, encapsulatedTimeStampElementTag).SetText(base64.StdEncoding.EncodeToString(timeStampToken))
+}
+
+// unsignedSignaturePropertiesElementOf returns UnsignedSignatureProperties element of qualifyingPropertiesElement, creating it (and UnsignedProperties element) if absent.
+func unsignedSignaturePropertiesElementOf(qualifyingPropertiesElement *etree.Element) *etree.Element {
+	unsignedPropertiesElement := qualifyingPropertiesElement.SelectElement(unsignedPropertiesElementTag)
+	if unsignedPropertiesElement == nil {
+		unsignedPropertiesElement = createElementInNamespaceOf(qualifyingPropertiesElement, qualifyingPropertiesElement, unsignedPropertiesElementTag)
+	}
+	unsignedSignaturePropertiesElement := unsignedPropertiesElement.SelectElement(unsignedSignaturePropertiesElementTag)
+	if unsignedSignaturePropertiesElement == nil {
+		unsignedSignaturePropertiesElement = createElementInNamespaceOf(unsignedPropertiesElement, qualifyingPropertiesElement, unsignedSignaturePropertiesElementTag)
+	}
+	return unsignedSignaturePropertiesElement
+}
+
+// findUnsignedSignaturePropertyElements returns the elements of tag in UnsignedSignatureProperties element of qualifyingPropertiesElement.
+func findUnsignedSignaturePropertyElements(qualifyingPropertiesElement *etree.Element, tag string) []*etree.Element {
+	return qualifyingPropertiesElement.FindElements("./" + unsignedPropertiesElementTag + "/" + unsignedSignaturePropertiesElementTag + "/" + tag)
+}
+
+// signatureTimeStampInputOf returns the input of SignatureTimeStamp, the canonicalized SignatureValue element of signatureElement (parsed from xmlBytes).
+func signatureTimeStampInputOf(signedInfoFactory SignedInfoFactory, xmlBytes []byte, signatureElement *etree.Element, canonicalizationAlgorithm string) ([]byte, error) {
+	return canonicalizeElementByPath(signedInfoFactory, xmlBytes, pathOfSignatureElement(signatureElement)+"/"+signatureValueElementTag, canonicalizationAlgorithm)
+}
+
+// canonicalizeElementByPath dereferences the element of xmlBytes at path and canonicalizes it with canonicalizationAlgorithm.
+func canonicalizeElementByPath(signedInfoFactory SignedInfoFactory, xmlBytes []byte, path string, canonicalizationAlgorithm string) ([]byte, error) {
+	elementInput, err := signedInfoFactory.CreateDereferencer().DereferenceByPath(xmlBytes, path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot dereference %s: %w", path, err)
+	}
+	canonicalizer, err := signedInfoFactory.CreateCanonicalizer(canonicalizationAlgorithm)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create canonicalizer for %s: %w", path, err)
+	}
+	canonicalizedElement, err := canonicalizer.Canonicalize(elementInput)
+	if err != nil {
+		return nil, fmt.Errorf("error while canonicalizing %s: %w", path, err)
+	}
+	return canonicalizedElement, nil
+}
+
+// allDataObjectsTimeStampInputOf returns the input of AllDataObjectsTimeStamp, the concatenation of the transformed data objects of every Reference of signedInfoElement but the one to SignedProperties.
+// A data object that is still a node set after its transforms is canonicalized with canonicalizationAlgorithm.
+func allDataObjectsTimeStampInputOf(signedInfoFactory SignedInfoFactory, uriResolver URIResolver, xmlBytes []byte, signedInfoElement *etree.Element, canonicalizationAlgorithm string) ([]byte, error) {
+	return referencedDataObjectsOf(signedInfoFactory, uriResolver, xmlBytes, signedInfoElement, canonicalizationAlgorithm, false)
+}
+
+// referencedDataObjectsOf returns the concatenation of the transformed data objects of References of signedInfoElement in their order,
+// including the one to SignedProperties only if includesSignedProperties is true.
+func referencedDataObjectsOf(signedInfoFactory SignedInfoFactory, uriResolver URIResolver, xmlBytes []byte, signedInfoElement *etree.Element, canonicalizationAlgorithm string, includesSignedProperties bool) ([]byte, error) {
+	var input bytes.Buffer
+	for referenceIndex, referenceElement := range signedInfoElement.SelectElements(referenceElementTag) {
+		referenceDetail, err := parseReferenceElement(referenceElement)
+		if err != nil {
+			return nil, fmt.Errorf("at Reference#%d element: %w", referenceIndex, err)
+		}
+		if referenceDetail.Type == SignedPropertiesReferenceType && !includesSignedProperties {
+			continue
+		}
+		xmlInput, err := dereferenceDataObject(signedInfoFactory, uriResolver, xmlBytes, referenceDetail.URIOfDataObjectBeingSigned)
+		if err != nil {
+			return nil, fmt.Errorf("at Reference#%d element: %w", referenceIndex, err)
+		}
+		transformedDataObject, err := transformDataObject(signedInfoFactory, xmlInput, pathOfSignatureElement(signedInfoElement.Parent()), canonicalizationAlgorithm, referenceDetail.TransformAlgorithms)
+		if err != nil {
+			return nil, fmt.Errorf("at Reference#%d element: %w", referenceIndex, err)
+		}
+		input.Write(transformedDataObject)
+	}
+	return input.Bytes(), nil
+}
+
+// validateTimeStampElement validates every EncapsulatedTimeStamp of timeStampElement against the time-stamped data returned by timeStampedDataOf,
+// which is given the canonicalization algorithm of timeStampElement (Canonical XML 1.0 if CanonicalizationMethod element is absent).
+// The TSA certificate is validated against trustStore through the certificates of the token and attachedCertificates. If trustStore is nil, the trust is left indeterminate.
+func validateTimeStampElement(timeStampElement *etree.Element, timeStampedDataOf func(canonicalizationAlgorithm string) ([]byte, error), trustStore TrustStore, attachedCertificates []*x509.Certificate) ([]TimeStampValidationResult, error) {
+	if timeStampElement.SelectElement(xmlTimeStampElementTag) != nil {
+		return nil, fmt.Errorf("%s element with XMLTimeStamp is not supported", timeStampElement.Tag)
+	}
+	canonicalizationAlgorithm := CanonicalXML10Algorithm
+	canonicalizationMethodElement, err := mustFoundOnlyOneIfFound(timeStampElement, canonicalizationMethodElementTag)
+	if err != nil {
+		return nil, err
+	}
+	if canonicalizationMethodElement != nil {
+		algorithmAttribute, err := mustFoundAttribute(canonicalizationMethodElement, algorithmAttributeKey)
+		if err != nil {
+			return nil, err
+		}
+		canonicalizationAlgorithm = algorithmAttribute.Value
+	}
+	encapsulatedTimeStampElements, err := mustFoundAtLeastOneChildElement(timeStampElement, encapsulatedTimeStampElementTag)
+	if err != nil {
+		return nil, err
+	}
+	timeStampedData, err := timeStampedDataOf(canonicalizationAlgorithm)
+	if err != nil {
+		return nil, fmt.Errorf("cannot compute the input of %s element: %w", timeStampElement.Tag, err)
+	}
+	results := make([]TimeStampValidationResult, 0, len(encapsulatedTimeStampElements))
+	for encapsulatedTimeStampIndex, encapsulatedTimeStampElement := range encapsulatedTimeStampElements {
+		tokenBytes, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(encapsulatedTimeStampElement.Text()), ""))
+		if err != nil {
+			return nil, fmt.Errorf("at EncapsulatedTimeStamp#%d element: cannot base64-decode time-stamp token: %w", encapsulatedTimeStampIndex, err)
+		}
+		token, err := verifyTimeStampToken(tokenBytes, timeStampedData)
+		if err != nil {
+			return nil, fmt.Errorf("at EncapsulatedTimeStamp#%d element: %w", encapsulatedTimeStampIndex, err)
+		}
+		tsaCertificateStatus := "TSA certificate is not carried in the time-stamp token"
+		if token.tsaCertificate != nil {
+			tsaCertificateStatus, err = validateTSACertificate(token.tsaCertificate, append(append([]*x509.Certificate{}, token.certificates...), attachedCertificates...), trustStore, token.genTime)
+			if err != nil {
+				return nil, fmt.Errorf("at EncapsulatedTimeStamp#%d element: cannot validate TSA certificate: %w", encapsulatedTimeStampIndex, err)
+			}
+		}
+		isTSACertificateTrusted := tsaCertificateStatus == ""
+		results = append(results, TimeStampValidationResult{
+			Property:                timeStampElement.Tag,
+			ID:                      timeStampElement.SelectAttrValue(idAttributeKey, ""),
+			IsValid:                 token.isMessageImprintValid && token.isSignatureValid && (isTSACertificateTrusted || trustStore == nil),
+			IsMessageImprintValid:   token.isMessageImprintValid,
+			IsSignatureValid:        token.isSignatureValid,
+			IsTSACertificateTrusted: isTSACertificateTrusted,
+			TSACertificateStatus:    tsaCertificateStatus,
+			GenTime:                 token.genTime,
+			TSACertificate:          token.tsaCertificate,
+		})
+	}
+	return results, nil
+}
+
+// pathOfSignatureElement returns the absolute path of signatureElement for Dereferencer.DereferenceByPath. Each step selects an element by its position,
+// so the path selects signatureElement even if it has no Id attribute. The last step also requires the selected element to be a Signature element.
+func pathOfSignatureElement(signatureElement *etree.Element) string {
+	steps := []string{fmt.Sprintf("%s[%d]", signatureElementTag, positionOfElement(signatureElement, signatureElementTag))}
+	for current := signatureElement.Parent(); current != nil && current.Parent() != nil; current = current.Parent() {
+		steps = append([]string{fmt.Sprintf("*[%d]", positionOfElement(current, ""))}, steps...)
+	}
+	return "/" + strings.Join(steps, "/")
+}
+
+// positionOfElement returns the 1-based position of element among the child elements of its parent whose local name is tag, or among every child element if tag is empty.
+func positionOfElement(element *etree.Element, tag string) int {
+	if element.Parent() == nil {
+		return 1
+	}
+	position := 1
+	for _, sibling := range element.Parent().ChildElements() {
+		if sibling == element {
+			break
+		}
+		if tag == "" || sibling.Tag == tag {
+			position++
+		}
+	}
+	return position
+}
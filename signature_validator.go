@@ -1,9 +1,16 @@
 package xades4go
 
 import (
+	"crypto"
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
+	"crypto/x509"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"math/big"
 )
 
 const (
@@ -22,21 +29,56 @@ const (
 	x509DataElementTag        = "X509Data"
 	x509CertificateElementTag = "X509Certificate"
 
+	keyNameElementTag     = "KeyName"
+	keyValueElementTag    = "KeyValue"
+	ecKeyValueElementTag  = "ECKeyValue"
+	namedCurveElementTag  = "NamedCurve"
+	publicKeyElementTag   = "PublicKey"
+	dsaKeyValueElementTag = "DSAKeyValue"
+
+	// ed25519NamedCurveURI identifies Ed25519 in NamedCurve element of ECKeyValue element by its object identifier (RFC 8410).
+	ed25519NamedCurveURI = "urn:oid:1.3.101.112"
+
 	uriAttributeKey       = "URI"
 	algorithmAttributeKey = "Algorithm"
 )
 
 type SignatureValidator interface {
+	// Validate validates the only signature of xmlBytes. It returns error if xmlBytes carries more than one signature.
 	Validate(xmlBytes []byte) (ValidationResult, error)
 }
 
+// MultipleSignatureValidator is SignatureValidator that also validates documents carrying more than one signature.
+type MultipleSignatureValidator interface {
+	SignatureValidator
+	// ValidateAll validates every signature of xmlBytes (parallel signatures) in document order. Counter signatures are not listed here but in the result of the signature they countersign.
+	ValidateAll(xmlBytes []byte) ([]ValidationResult, error)
+	// ValidateByID validates the signature of xmlBytes whose Id attribute is signatureID.
+	ValidateByID(xmlBytes []byte, signatureID string) (ValidationResult, error)
+}
+
 type ValidationResult struct {
+	// SignatureID is Id attribute of the validated Signature element. It is empty if the element has none.
+	SignatureID                string
 	ReferenceValidationResults []ReferenceValidationResult
 	IsSignatureValid           bool
+	// SignerCertificate is the certificate whose key verified SignatureValue element. It is nil if the signature is invalid or verified by a key of KeyValue element.
+	SignerCertificate *x509.Certificate
+	// SignerPublicKey is the key that verified SignatureValue element. It is nil if the signature is invalid or made with HMAC signature algorithms.
+	SignerPublicKey crypto.PublicKey
+	// CertificateValidationResult is only set if the validator has TrustStore (see ValidateWithTrustStore) and SignerPublicKey is not nil.
+	// If SignerCertificate is nil, it is invalid with ChainStatus "signer key is not bound to a certificate".
+	CertificateValidationResult *CertificateValidationResult
+	// QualifyingPropertiesValidationResult is only set by XAdESSignatureValidator.
+	QualifyingPropertiesValidationResult *QualifyingPropertiesValidationResult
 }
 
 type ReferenceValidationResult struct {
-	IsValid              bool
+	IsValid bool
+	URI     string
+	Type    string
+	// TransformAlgorithms are the algorithms of Transform elements of the Reference element, in order. It is nil if the Reference element has no Transforms element.
+	TransformAlgorithms  []string
 	GeneratedDigestValue string
 	DigestValue          string
 }
@@ -46,11 +88,58 @@ type SignatureValueVerifier interface {
 	Verify(signatureAlgorithm string, canonicalizedSignedInfo []byte, base64SignatureValue []byte) error
 }
 
+// rsaSignatureValueVerifier verifies RSASSA-PKCS1-v1_5 signature value, or RSASSA-PSS signature value with pssParameters for RSASSA-PSS signature algorithms.
 type rsaSignatureValueVerifier struct {
-	rsaPublicKey *rsa.PublicKey
+	rsaPublicKey  *rsa.PublicKey
+	pssParameters *PSSParameters
 }
 
 func (verifier *rsaSignatureValueVerifier) Verify(signatureAlgorithm string, canonicalizedSignedInfo []byte, base64SignatureValue []byte) error {
+	var hashAlgorithm crypto.Hash
+	var pssOptions *rsa.PSSOptions
+	switch {
+	case signatureAlgorithm == RSASHA224SignatureAlgorithm || signatureAlgorithm == RSASHA256SignatureAlgorithm || signatureAlgorithm == RSASHA384SignatureAlgorithm || signatureAlgorithm == RSASHA512SignatureAlgorithm:
+		var err error
+		hashAlgorithm, err = mapSignatureAlgorithmToCrytoHash(signatureAlgorithm)
+		if err != nil {
+			return err
+		}
+	case isRSAPSSSignatureAlgorithm(signatureAlgorithm) && verifier.pssParameters != nil:
+		var err error
+		hashAlgorithm, pssOptions, err = rsaPSSOptionsOf(*verifier.pssParameters)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%s cannot be used with RSA key", signatureAlgorithm)
+	}
+	h := hashAlgorithm.New()
+	_, err := h.Write(canonicalizedSignedInfo)
+	if err != nil {
+		return fmt.Errorf("cannot hash SignedInfo using %s: %w", hashAlgorithm.String(), err)
+	}
+	signatureValue, err := base64.StdEncoding.DecodeString(string(base64SignatureValue))
+	if err != nil {
+		return fmt.Errorf("SignatureValue is not base64-encoded: %w", err)
+	}
+	if pssOptions != nil {
+		return verifyPSS(verifier.rsaPublicKey, hashAlgorithm, h.Sum(nil), signatureValue, pssOptions)
+	}
+	return rsa.VerifyPKCS1v15(verifier.rsaPublicKey, hashAlgorithm, h.Sum(nil), []byte(signatureValue))
+}
+
+// ecdsaSignatureValueVerifier verifies ECDSA signature value encoded as XMLDSig requires (https://www.w3.org/TR/xmldsig-core1/#sec-ECDSA):
+// the concatenation of r and s, each as a big-endian octet string as long as the order of the curve, instead of ASN.1 DER.
+type ecdsaSignatureValueVerifier struct {
+	ecdsaPublicKey *ecdsa.PublicKey
+}
+
+func (verifier *ecdsaSignatureValueVerifier) Verify(signatureAlgorithm string, canonicalizedSignedInfo []byte, base64SignatureValue []byte) error {
+	switch signatureAlgorithm {
+	case ECDSASHA1SignatureAlgorithm, ECDSASHA224SignatureAlgorithm, ECDSASHA256SignatureAlgorithm, ECDSASHA384SignatureAlgorithm, ECDSASHA512SignatureAlgorithm:
+	default:
+		return fmt.Errorf("%s cannot be used with ECDSA key", signatureAlgorithm)
+	}
 	hashAlgorithm, err := mapSignatureAlgorithmToCrytoHash(signatureAlgorithm)
 	if err != nil {
 		return err
@@ -64,5 +153,86 @@ func (verifier *rsaSignatureValueVerifier) Verify(signatureAlgorithm string, can
 	if err != nil {
 		return fmt.Errorf("SignatureValue is not base64-encoded: %w", err)
 	}
-	return rsa.VerifyPKCS1v15(verifier.rsaPublicKey, hashAlgorithm, h.Sum(nil), []byte(signatureValue))
+	if len(signatureValue) != 2*ecdsaOctetLength(verifier.ecdsaPublicKey) {
+		return fmt.Errorf("SignatureValue must be %d octets (r||s) for %s curve, got %d", 2*ecdsaOctetLength(verifier.ecdsaPublicKey), verifier.ecdsaPublicKey.Curve.Params().Name, len(signatureValue))
+	}
+	r := new(big.Int).SetBytes(signatureValue[:len(signatureValue)/2])
+	s := new(big.Int).SetBytes(signatureValue[len(signatureValue)/2:])
+	if !ecdsa.Verify(verifier.ecdsaPublicKey, h.Sum(nil), r, s) {
+		return errors.New("ECDSA verification error")
+	}
+	return nil
+}
+
+// ecdsaOctetLength returns the length in octets of each of r and s in XMLDSig ECDSA signature value made by publicKey.
+func ecdsaOctetLength(publicKey *ecdsa.PublicKey) int {
+	return (publicKey.Curve.Params().N.BitLen() + 7) / 8
+}
+
+// ed25519SignatureValueVerifier verifies Ed25519 signature value (RFC 9231), which is computed over canonicalized SignedInfo itself.
+type ed25519SignatureValueVerifier struct {
+	ed25519PublicKey ed25519.PublicKey
+}
+
+func (verifier *ed25519SignatureValueVerifier) Verify(signatureAlgorithm string, canonicalizedSignedInfo []byte, base64SignatureValue []byte) error {
+	if signatureAlgorithm != EdDSAEd25519SignatureAlgorithm {
+		return fmt.Errorf("%s cannot be used with Ed25519 key", signatureAlgorithm)
+	}
+	signatureValue, err := base64.StdEncoding.DecodeString(string(base64SignatureValue))
+	if err != nil {
+		return fmt.Errorf("SignatureValue is not base64-encoded: %w", err)
+	}
+	if len(signatureValue) != ed25519.SignatureSize {
+		return fmt.Errorf("SignatureValue must be %d octets for Ed25519, got %d", ed25519.SignatureSize, len(signatureValue))
+	}
+	if !ed25519.Verify(verifier.ed25519PublicKey, canonicalizedSignedInfo, signatureValue) {
+		return errors.New("Ed25519 verification error")
+	}
+	return nil
+}
+
+// legacySignatureAlgorithms are the signature algorithms that are only verified if the validator is created with ValidateWithLegacyAlgorithms.
+var legacySignatureAlgorithms = map[string]bool{
+	DSASHA1SignatureAlgorithm:   true,
+	DSASHA256SignatureAlgorithm: true,
+}
+
+// dsaSignatureValueVerifier verifies DSA signature value encoded as XMLDSig requires (https://www.w3.org/TR/xmldsig-core1/#sec-DSA):
+// the concatenation of r and s, each as a big-endian octet string as long as the subgroup order q.
+type dsaSignatureValueVerifier struct {
+	dsaPublicKey *dsa.PublicKey
+}
+
+func (verifier *dsaSignatureValueVerifier) Verify(signatureAlgorithm string, canonicalizedSignedInfo []byte, base64SignatureValue []byte) error {
+	if signatureAlgorithm != DSASHA1SignatureAlgorithm && signatureAlgorithm != DSASHA256SignatureAlgorithm {
+		return fmt.Errorf("%s cannot be used with DSA key", signatureAlgorithm)
+	}
+	hashAlgorithm, err := mapSignatureAlgorithmToCrytoHash(signatureAlgorithm)
+	if err != nil {
+		return err
+	}
+	h := hashAlgorithm.New()
+	_, err = h.Write(canonicalizedSignedInfo)
+	if err != nil {
+		return fmt.Errorf("cannot hash SignedInfo using %s: %w", hashAlgorithm.String(), err)
+	}
+	signatureValue, err := base64.StdEncoding.DecodeString(string(base64SignatureValue))
+	if err != nil {
+		return fmt.Errorf("SignatureValue is not base64-encoded: %w", err)
+	}
+	octetLength := (verifier.dsaPublicKey.Q.BitLen() + 7) / 8
+	if len(signatureValue) != 2*octetLength {
+		return fmt.Errorf("SignatureValue must be %d octets (r||s) for %d-bit q, got %d", 2*octetLength, verifier.dsaPublicKey.Q.BitLen(), len(signatureValue))
+	}
+	// crypto/dsa leaves truncating the digest to the length of q (FIPS 186-4 section 4.6) to the caller.
+	digest := h.Sum(nil)
+	if len(digest) > octetLength {
+		digest = digest[:octetLength]
+	}
+	r := new(big.Int).SetBytes(signatureValue[:octetLength])
+	s := new(big.Int).SetBytes(signatureValue[octetLength:])
+	if !dsa.Verify(verifier.dsaPublicKey, digest, r, s) {
+		return errors.New("DSA verification error")
+	}
+	return nil
 }
@@ -42,6 +42,27 @@ const (
 	ECDSASHA256SignatureAlgorithm = "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha256"
 	ECDSASHA384SignatureAlgorithm = "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha384"
 	ECDSASHA512SignatureAlgorithm = "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha512"
+
+	// RSASSA-PSS Signature Algorithm (RFC 6931). RSASHA*MGF1SignatureAlgorithm use MGF1 with the same digest and salt as long as the digest.
+	// RSASSAPSSSignatureAlgorithm takes its parameters from RSAPSSParams element of SignatureMethod element (see PSSParameters).
+	RSASHA1MGF1SignatureAlgorithm   = "http://www.w3.org/2007/05/xmldsig-more#sha1-rsa-MGF1"
+	RSASHA224MGF1SignatureAlgorithm = "http://www.w3.org/2007/05/xmldsig-more#sha224-rsa-MGF1"
+	RSASHA256MGF1SignatureAlgorithm = "http://www.w3.org/2007/05/xmldsig-more#sha256-rsa-MGF1"
+	RSASHA384MGF1SignatureAlgorithm = "http://www.w3.org/2007/05/xmldsig-more#sha384-rsa-MGF1"
+	RSASHA512MGF1SignatureAlgorithm = "http://www.w3.org/2007/05/xmldsig-more#sha512-rsa-MGF1"
+	RSASSAPSSSignatureAlgorithm     = "http://www.w3.org/2007/05/xmldsig-more#rsa-pss"
+
+	// EdDSA Signature Algorithm (RFC 9231). It signs canonicalized SignedInfo itself, without a separate prehash.
+	EdDSAEd25519SignatureAlgorithm = "http://www.w3.org/2021/04/xmldsig-more#eddsa-ed25519"
+
+	// HMAC Signature Algorithm. The shared secret is supplied by HMACKeySelector.
+	HMACSHA1SignatureAlgorithm   = "http://www.w3.org/2000/09/xmldsig#hmac-sha1"
+	HMACSHA256SignatureAlgorithm = "http://www.w3.org/2001/04/xmldsig-more#hmac-sha256"
+	HMACSHA384SignatureAlgorithm = "http://www.w3.org/2001/04/xmldsig-more#hmac-sha384"
+	HMACSHA512SignatureAlgorithm = "http://www.w3.org/2001/04/xmldsig-more#hmac-sha512"
+
+	// Mask Generation Function of RSASSAPSSSignatureAlgorithm
+	MGF1Algorithm = "http://www.w3.org/2007/05/xmldsig-more#MGF1"
 )
 
 // Transformer is an interface that perform Transform algorithm which follows https://www.w3.org/TR/xmldsig-core1/#sec-TransformAlg.
@@ -50,6 +71,13 @@ type Transformer interface {
 	Transform(input XML) (XML, error)
 }
 
+// EnvelopedSignatureTransformer is a Transformer of EnvelopedSignatureTransformAlgorithm that is told which Signature element contains the Transform element.
+// signaturePath is the path of that Signature element in the form accepted by Dereferencer.DereferenceByPath. Without it, a document carrying several signatures cannot be transformed correctly.
+type EnvelopedSignatureTransformer interface {
+	Transformer
+	TransformEnvelopedSignature(input XML, signaturePath string) (XML, error)
+}
+
 // Canonicalizer is an object that canonicalize XML to octet-stream.
 type Canonicalizer interface {
 	Canonicalize(input XML) ([]byte, error)
@@ -97,24 +125,34 @@ func (digester *cryptoDigester) Digest(input []byte) ([]byte, error) {
 }
 
 func CreateDigester(algorithmName string) (Digester, error) {
-	if algorithmName == "" {
-		return nil, errors.New("Algorithm must not be empty")
+	h, err := mapDigestAlgorithmToCryptoHash(algorithmName)
+	if err != nil {
+		return nil, err
+	}
+	return &cryptoDigester{h: h}, nil
+}
+
+func mapDigestAlgorithmToCryptoHash(digestAlgorithm string) (crypto.Hash, error) {
+	if digestAlgorithm == "" {
+		return 0, errors.New("Algorithm must not be empty")
 	}
-	switch algorithmName {
+	switch digestAlgorithm {
 	case SHA1MessageDigestAlgorithm:
-		return &cryptoDigester{h: crypto.SHA1}, nil
+		return crypto.SHA1, nil
 	case SHA224MessageDigestAlgorithm:
-		return &cryptoDigester{h: crypto.SHA224}, nil
+		return crypto.SHA224, nil
 	case SHA256MessageDigestAlgorithm:
-		return &cryptoDigester{h: crypto.SHA256}, nil
+		return crypto.SHA256, nil
 	case SHA384MessageDigestAlgorithm:
-		return &cryptoDigester{h: crypto.SHA384}, nil
+		return crypto.SHA384, nil
 	case SHA512MessageDigestAlgotithm:
-		return &cryptoDigester{h: crypto.SHA512}, nil
+		return crypto.SHA512, nil
 	}
-	return nil, fmt.Errorf("this package does not implement %s digest algorithm", algorithmName)
+	return 0, fmt.Errorf("this package does not implement %s digest algorithm", digestAlgorithm)
 }
 
+// CreateDigesterForSignatureAlgorithm creates Digester with the hash that signatureAlgorithm applies to canonicalized SignedInfo.
+// It returns error for the algorithms without prehash, such as EdDSAEd25519SignatureAlgorithm.
 func CreateDigesterForSignatureAlgorithm(signatureAlgorithm string) (Digester, error) {
 	h, err := mapSignatureAlgorithmToCrytoHash(signatureAlgorithm)
 	if err != nil {
@@ -123,10 +161,29 @@ func CreateDigesterForSignatureAlgorithm(signatureAlgorithm string) (Digester, e
 	return &cryptoDigester{h: h}, nil
 }
 
+// noPrehashSignatureAlgorithms are the signature algorithms that sign canonicalized SignedInfo itself instead of its hash.
+var noPrehashSignatureAlgorithms = map[string]bool{
+	EdDSAEd25519SignatureAlgorithm: true,
+}
+
+// checkSignatureAlgorithm returns error if this package does not implement signatureAlgorithm.
+func checkSignatureAlgorithm(signatureAlgorithm string) error {
+	if noPrehashSignatureAlgorithms[signatureAlgorithm] {
+		return nil
+	}
+	_, err := mapSignatureAlgorithmToCrytoHash(signatureAlgorithm)
+	return err
+}
+
+// mapSignatureAlgorithmToCrytoHash returns the hash that signatureAlgorithm applies to canonicalized SignedInfo before signing.
+// It returns error for noPrehashSignatureAlgorithms, so that no caller hashes with crypto.Hash(0).
 func mapSignatureAlgorithmToCrytoHash(signatureAlgorithm string) (crypto.Hash, error) {
 	if signatureAlgorithm == "" {
 		return 0, errors.New("Algorithm must not be empty")
 	}
+	if noPrehashSignatureAlgorithms[signatureAlgorithm] {
+		return 0, fmt.Errorf("%s signs SignedInfo without prehash", signatureAlgorithm)
+	}
 	switch signatureAlgorithm {
 	case DSASHA1SignatureAlgorithm:
 		return crypto.SHA1, nil
@@ -150,6 +207,27 @@ func mapSignatureAlgorithmToCrytoHash(signatureAlgorithm string) (crypto.Hash, e
 		return crypto.SHA384, nil
 	case ECDSASHA512SignatureAlgorithm:
 		return crypto.SHA512, nil
+	case RSASHA1MGF1SignatureAlgorithm:
+		return crypto.SHA1, nil
+	case RSASHA224MGF1SignatureAlgorithm:
+		return crypto.SHA224, nil
+	case RSASHA256MGF1SignatureAlgorithm:
+		return crypto.SHA256, nil
+	case RSASHA384MGF1SignatureAlgorithm:
+		return crypto.SHA384, nil
+	case RSASHA512MGF1SignatureAlgorithm:
+		return crypto.SHA512, nil
+	case RSASSAPSSSignatureAlgorithm:
+		// The actual digest is given by RSAPSSParams element, SHA-256 is its default.
+		return crypto.SHA256, nil
+	case HMACSHA1SignatureAlgorithm:
+		return crypto.SHA1, nil
+	case HMACSHA256SignatureAlgorithm:
+		return crypto.SHA256, nil
+	case HMACSHA384SignatureAlgorithm:
+		return crypto.SHA384, nil
+	case HMACSHA512SignatureAlgorithm:
+		return crypto.SHA512, nil
 	}
 	return 0, fmt.Errorf("this package does not implement %s signature algorithm", signatureAlgorithm)
 }
@@ -0,0 +1,531 @@
+package xades4go
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"time"
+)
+
+const (
+	timeStampQueryContentType = "application/timestamp-query"
+	maxTimeStampResponseSize  = 1 << 20
+
+	pkiStatusGranted         = 0
+	pkiStatusGrantedWithMods = 1
+)
+
+var (
+	oidSignedData                    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidTSTInfo                       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+	oidAttributeContentType          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidAttributeMessageDigest        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidAttributeSigningCertificate   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 12}
+	oidAttributeSigningCertificateV2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 47}
+
+	oidSHA1   = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA224 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 4}
+	oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+
+	oidRSAEncryption   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidSHA1WithRSA     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 5}
+	oidSHA256WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	oidSHA384WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 12}
+	oidSHA512WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 13}
+	oidECPublicKey     = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidECDSAWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 1}
+	oidECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidECDSAWithSHA384 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 3}
+	oidECDSAWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 4}
+)
+
+// TimeStampProvider is an object that obtains an RFC 3161 time-stamp token over hashedMessage, the digest of the time-stamped data computed with hashAlgorithm.
+// It returns DER-encoded TimeStampToken, a CMS ContentInfo of SignedData that encapsulates TSTInfo.
+type TimeStampProvider interface {
+	TimeStamp(hashAlgorithm crypto.Hash, hashedMessage []byte) ([]byte, error)
+}
+
+type messageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+type timeStampReq struct {
+	Version        int
+	MessageImprint messageImprint
+	ReqPolicy      asn1.ObjectIdentifier `asn1:"optional"`
+	Nonce          *big.Int              `asn1:"optional"`
+	CertReq        bool                  `asn1:"optional"`
+	Extensions     []pkix.Extension      `asn1:"optional,tag:0"`
+}
+
+type pkiStatusInfo struct {
+	Status       int
+	StatusString []string       `asn1:"optional,utf8"`
+	FailInfo     asn1.BitString `asn1:"optional"`
+}
+
+type timeStampResp struct {
+	Status         pkiStatusInfo
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+type accuracy struct {
+	Seconds int `asn1:"optional"`
+	Millis  int `asn1:"optional,tag:0"`
+	Micros  int `asn1:"optional,tag:1"`
+}
+
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint messageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time        `asn1:"generalized"`
+	Accuracy       accuracy         `asn1:"optional"`
+	Ordering       bool             `asn1:"optional"`
+	Nonce          *big.Int         `asn1:"optional"`
+	TSA            asn1.RawValue    `asn1:"optional,explicit,tag:0"`
+	Extensions     []pkix.Extension `asn1:"optional,tag:1"`
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type encapsulatedContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     []byte `asn1:"explicit,optional,tag:0"`
+}
+
+// signedData is CMS SignedData (RFC 5652). Certificates and SignedAttrs of signerInfo are kept raw, so the signature over signed attributes can be verified against their original encoding.
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo encapsulatedContentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type issuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type signerInfo struct {
+	Version            int
+	SID                asn1.RawValue
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values []asn1.RawValue `asn1:"set"`
+}
+
+type essCertIDv2 struct {
+	HashAlgorithm pkix.AlgorithmIdentifier `asn1:"optional"`
+	CertHash      []byte
+	IssuerSerial  asn1.RawValue `asn1:"optional"`
+}
+
+type signingCertificateV2 struct {
+	Certs    []essCertIDv2
+	Policies asn1.RawValue `asn1:"optional"`
+}
+
+type essCertID struct {
+	CertHash     []byte
+	IssuerSerial asn1.RawValue `asn1:"optional"`
+}
+
+type signingCertificate struct {
+	Certs    []essCertID
+	Policies asn1.RawValue `asn1:"optional"`
+}
+
+// timeStampToken is a parsed TimeStampToken.
+type timeStampToken struct {
+	signedData signedData
+	tstInfo    tstInfo
+}
+
+type httpTimeStampProvider struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPTimeStampProvider creates TimeStampProvider that requests time-stamp tokens from the TSA at url using the HTTP transport of RFC 3161.
+// If client is nil, http.DefaultClient is used.
+// The returned token is checked to have the requested message imprint and nonce; its signature is not verified.
+func NewHTTPTimeStampProvider(url string, client *http.Client) TimeStampProvider {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &httpTimeStampProvider{url: url, client: client}
+}
+
+func (provider *httpTimeStampProvider) TimeStamp(hashAlgorithm crypto.Hash, hashedMessage []byte) ([]byte, error) {
+	hashAlgorithmIdentifier, err := hashAlgorithmIdentifierOf(hashAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, fmt.Errorf("cannot generate nonce: %w", err)
+	}
+	request, err := asn1.Marshal(timeStampReq{
+		Version:        1,
+		MessageImprint: messageImprint{HashAlgorithm: hashAlgorithmIdentifier, HashedMessage: hashedMessage},
+		Nonce:          nonce,
+		CertReq:        true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode TimeStampReq: %w", err)
+	}
+	response, err := provider.client.Post(provider.url, timeStampQueryContentType, bytes.NewReader(request))
+	if err != nil {
+		return nil, fmt.Errorf("cannot request time-stamp token from %s: %w", provider.url, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("TSA at %s responded with HTTP status %d", provider.url, response.StatusCode)
+	}
+	responseBytes, err := ioutil.ReadAll(io.LimitReader(response.Body, maxTimeStampResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read TimeStampResp: %w", err)
+	}
+	var resp timeStampResp
+	rest, err := asn1.Unmarshal(responseBytes, &resp)
+	if err != nil || len(rest) > 0 {
+		return nil, fmt.Errorf("TSA at %s responded with malformed TimeStampResp", provider.url)
+	}
+	if resp.Status.Status != pkiStatusGranted && resp.Status.Status != pkiStatusGrantedWithMods {
+		return nil, fmt.Errorf("TSA at %s rejected the request with status %d %v", provider.url, resp.Status.Status, resp.Status.StatusString)
+	}
+	token, err := parseTimeStampToken(resp.TimeStampToken.FullBytes)
+	if err != nil {
+		return nil, err
+	}
+	if !token.hasMessageImprint(hashAlgorithm, hashedMessage) {
+		return nil, errors.New("time-stamp token does not contain the requested message imprint")
+	}
+	if token.tstInfo.Nonce == nil || token.tstInfo.Nonce.Cmp(nonce) != 0 {
+		return nil, errors.New("time-stamp token does not contain the requested nonce")
+	}
+	return resp.TimeStampToken.FullBytes, nil
+}
+
+func parseTimeStampToken(tokenBytes []byte) (*timeStampToken, error) {
+	var info contentInfo
+	rest, err := asn1.Unmarshal(tokenBytes, &info)
+	if err != nil || len(rest) > 0 {
+		return nil, errors.New("time-stamp token is not a DER-encoded ContentInfo")
+	}
+	if !info.ContentType.Equal(oidSignedData) {
+		return nil, fmt.Errorf("time-stamp token has content type %s instead of SignedData", info.ContentType)
+	}
+	token := &timeStampToken{}
+	rest, err = asn1.Unmarshal(info.Content.Bytes, &token.signedData)
+	if err != nil || len(rest) > 0 {
+		return nil, errors.New("time-stamp token does not contain a valid SignedData")
+	}
+	if !token.signedData.EncapContentInfo.EContentType.Equal(oidTSTInfo) {
+		return nil, fmt.Errorf("time-stamp token encapsulates %s instead of TSTInfo", token.signedData.EncapContentInfo.EContentType)
+	}
+	rest, err = asn1.Unmarshal(token.signedData.EncapContentInfo.EContent, &token.tstInfo)
+	if err != nil || len(rest) > 0 {
+		return nil, errors.New("time-stamp token does not contain a valid TSTInfo")
+	}
+	return token, nil
+}
+
+// verifiedTimeStampToken is the outcome of verifyTimeStampToken.
+type verifiedTimeStampToken struct {
+	isMessageImprintValid bool
+	isSignatureValid      bool
+	genTime               time.Time
+	tsaCertificate        *x509.Certificate
+	// certificates are the certificates carried in the token, including tsaCertificate.
+	certificates []*x509.Certificate
+}
+
+// verifyTimeStampToken verifies the TSA signature of DER-encoded tokenBytes and whether its message imprint is the digest of timeStampedData.
+// The TSA certificate must be carried in the token. A token that cannot be parsed is reported as error.
+func verifyTimeStampToken(tokenBytes []byte, timeStampedData []byte) (verifiedTimeStampToken, error) {
+	token, err := parseTimeStampToken(tokenBytes)
+	if err != nil {
+		return verifiedTimeStampToken{}, err
+	}
+	result := verifiedTimeStampToken{genTime: token.tstInfo.GenTime}
+	hashAlgorithm, err := hashAlgorithmOf(token.tstInfo.MessageImprint.HashAlgorithm.Algorithm)
+	if err == nil {
+		hash := hashAlgorithm.New()
+		if _, err := hash.Write(timeStampedData); err != nil {
+			return verifiedTimeStampToken{}, fmt.Errorf("cannot digest time-stamped data: %w", err)
+		}
+		result.isMessageImprintValid = token.hasMessageImprint(hashAlgorithm, hash.Sum(nil))
+	}
+	if len(token.signedData.SignerInfos) != 1 {
+		return verifiedTimeStampToken{}, fmt.Errorf("time-stamp token must have exactly one SignerInfo but found %d", len(token.signedData.SignerInfos))
+	}
+	certificates, err := x509.ParseCertificates(token.signedData.Certificates.Bytes)
+	if err != nil {
+		return verifiedTimeStampToken{}, fmt.Errorf("cannot parse certificates of time-stamp token: %w", err)
+	}
+	signer := token.signedData.SignerInfos[0]
+	result.certificates = certificates
+	result.tsaCertificate = findSignerCertificate(signer.SID, certificates)
+	if result.tsaCertificate != nil {
+		result.isSignatureValid = token.isSignedBy(signer, result.tsaCertificate)
+	}
+	return result, nil
+}
+
+// validateTSACertificate builds the path from tsaCertificate to a trust anchor of trustStore through intermediateCertificates and the intermediate certificates of trustStore, at genTime.
+// tsaCertificate must have id-kp-timeStamping extended key usage (RFC 3161 section 2.3). It returns the reason why tsaCertificate is not trusted, or empty string if it is trusted.
+func validateTSACertificate(tsaCertificate *x509.Certificate, intermediateCertificates []*x509.Certificate, trustStore TrustStore, genTime time.Time) (string, error) {
+	if trustStore == nil {
+		return "TSA certificate is not validated without TrustStore (see ValidateWithTrustStore)", nil
+	}
+	if !hasExtKeyUsage(tsaCertificate, x509.ExtKeyUsageTimeStamping) {
+		return "TSA certificate does not have id-kp-timeStamping extended key usage", nil
+	}
+	trustAnchors, err := trustStore.TrustAnchors()
+	if err != nil {
+		return "", fmt.Errorf("cannot get trust anchors: %w", err)
+	}
+	trustStoreIntermediateCertificates, err := trustStore.IntermediateCertificates()
+	if err != nil {
+		return "", fmt.Errorf("cannot get intermediate certificates: %w", err)
+	}
+	roots := x509.NewCertPool()
+	for _, trustAnchor := range trustAnchors {
+		roots.AddCert(trustAnchor)
+	}
+	intermediates := x509.NewCertPool()
+	for _, certificate := range append(append([]*x509.Certificate{}, intermediateCertificates...), trustStoreIntermediateCertificates...) {
+		if certificate != tsaCertificate {
+			intermediates.AddCert(certificate)
+		}
+	}
+	_, err = tsaCertificate.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   genTime,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	})
+	if err != nil {
+		return err.Error(), nil
+	}
+	return "", nil
+}
+
+// findSignerCertificate finds the certificate identified by SignerIdentifier, either IssuerAndSerialNumber or [0] SubjectKeyIdentifier.
+func findSignerCertificate(sid asn1.RawValue, certificates []*x509.Certificate) *x509.Certificate {
+	if sid.Class == asn1.ClassContextSpecific && sid.Tag == 0 {
+		for _, certificate := range certificates {
+			if len(certificate.SubjectKeyId) > 0 && bytes.Equal(certificate.SubjectKeyId, sid.Bytes) {
+				return certificate
+			}
+		}
+		return nil
+	}
+	var issuerAndSerial issuerAndSerialNumber
+	if _, err := asn1.Unmarshal(sid.FullBytes, &issuerAndSerial); err != nil {
+		return nil
+	}
+	for _, certificate := range certificates {
+		if bytes.Equal(certificate.RawIssuer, issuerAndSerial.Issuer.FullBytes) && certificate.SerialNumber.Cmp(issuerAndSerial.SerialNumber) == 0 {
+			return certificate
+		}
+	}
+	return nil
+}
+
+// isSignedBy verifies signer, which must carry signed attributes binding TSTInfo (content type and message digest) and certificate (ESS signing certificate), against certificate.
+func (token *timeStampToken) isSignedBy(signer signerInfo, certificate *x509.Certificate) bool {
+	digestAlgorithm, err := hashAlgorithmOf(signer.DigestAlgorithm.Algorithm)
+	if err != nil || len(signer.SignedAttrs.Bytes) == 0 {
+		return false
+	}
+	var attributes []attribute
+	// signed attributes are signed as SET OF, so the IMPLICIT [0] tag is replaced by SET tag before verifying.
+	signedAttributes, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: signer.SignedAttrs.Bytes})
+	if err != nil {
+		return false
+	}
+	if _, err := asn1.UnmarshalWithParams(signedAttributes, &attributes, "set"); err != nil {
+		return false
+	}
+	hash := digestAlgorithm.New()
+	if _, err := hash.Write(token.signedData.EncapContentInfo.EContent); err != nil {
+		return false
+	}
+	isContentTypeValid, isMessageDigestValid, isSigningCertificateValid := false, false, false
+	for _, signedAttribute := range attributes {
+		if len(signedAttribute.Values) != 1 {
+			return false
+		}
+		value := signedAttribute.Values[0].FullBytes
+		switch {
+		case signedAttribute.Type.Equal(oidAttributeContentType):
+			var contentType asn1.ObjectIdentifier
+			_, err := asn1.Unmarshal(value, &contentType)
+			isContentTypeValid = err == nil && contentType.Equal(oidTSTInfo)
+		case signedAttribute.Type.Equal(oidAttributeMessageDigest):
+			var messageDigest []byte
+			_, err := asn1.Unmarshal(value, &messageDigest)
+			isMessageDigestValid = err == nil && bytes.Equal(messageDigest, hash.Sum(nil))
+		case signedAttribute.Type.Equal(oidAttributeSigningCertificateV2):
+			isSigningCertificateValid = isSigningCertificateV2Of(value, certificate)
+		case signedAttribute.Type.Equal(oidAttributeSigningCertificate):
+			isSigningCertificateValid = isSigningCertificateOf(value, certificate)
+		}
+	}
+	if !isContentTypeValid || !isMessageDigestValid || !isSigningCertificateValid {
+		return false
+	}
+	signatureAlgorithm, err := x509SignatureAlgorithmOf(signer.SignatureAlgorithm.Algorithm, digestAlgorithm)
+	if err != nil {
+		return false
+	}
+	return certificate.CheckSignature(signatureAlgorithm, signedAttributes, signer.Signature) == nil
+}
+
+// isSigningCertificateV2Of reports whether the first ESSCertIDv2 of encoded SigningCertificateV2 identifies certificate.
+func isSigningCertificateV2Of(encoded []byte, certificate *x509.Certificate) bool {
+	var value signingCertificateV2
+	if _, err := asn1.Unmarshal(encoded, &value); err != nil || len(value.Certs) == 0 {
+		return false
+	}
+	hashAlgorithm := crypto.SHA256
+	if len(value.Certs[0].HashAlgorithm.Algorithm) > 0 {
+		var err error
+		hashAlgorithm, err = hashAlgorithmOf(value.Certs[0].HashAlgorithm.Algorithm)
+		if err != nil {
+			return false
+		}
+	}
+	hash := hashAlgorithm.New()
+	if _, err := hash.Write(certificate.Raw); err != nil {
+		return false
+	}
+	return bytes.Equal(value.Certs[0].CertHash, hash.Sum(nil))
+}
+
+// isSigningCertificateOf reports whether the first ESSCertID (SHA-1) of encoded SigningCertificate identifies certificate.
+func isSigningCertificateOf(encoded []byte, certificate *x509.Certificate) bool {
+	var value signingCertificate
+	if _, err := asn1.Unmarshal(encoded, &value); err != nil || len(value.Certs) == 0 {
+		return false
+	}
+	hash := crypto.SHA1.New()
+	if _, err := hash.Write(certificate.Raw); err != nil {
+		return false
+	}
+	return bytes.Equal(value.Certs[0].CertHash, hash.Sum(nil))
+}
+
+// x509SignatureAlgorithmOf maps the signature algorithm of SignerInfo (or of a CRL or an OCSP response, with zero digestAlgorithm) to x509.SignatureAlgorithm.
+// Bare rsaEncryption and id-ecPublicKey, which some TSAs put there, are combined with the digest algorithm of SignerInfo.
+func x509SignatureAlgorithmOf(signatureAlgorithm asn1.ObjectIdentifier, digestAlgorithm crypto.Hash) (x509.SignatureAlgorithm, error) {
+	switch {
+	case signatureAlgorithm.Equal(oidSHA1WithRSA):
+		return x509.SHA1WithRSA, nil
+	case signatureAlgorithm.Equal(oidSHA256WithRSA):
+		return x509.SHA256WithRSA, nil
+	case signatureAlgorithm.Equal(oidSHA384WithRSA):
+		return x509.SHA384WithRSA, nil
+	case signatureAlgorithm.Equal(oidSHA512WithRSA):
+		return x509.SHA512WithRSA, nil
+	case signatureAlgorithm.Equal(oidECDSAWithSHA1):
+		return x509.ECDSAWithSHA1, nil
+	case signatureAlgorithm.Equal(oidECDSAWithSHA256):
+		return x509.ECDSAWithSHA256, nil
+	case signatureAlgorithm.Equal(oidECDSAWithSHA384):
+		return x509.ECDSAWithSHA384, nil
+	case signatureAlgorithm.Equal(oidECDSAWithSHA512):
+		return x509.ECDSAWithSHA512, nil
+	case signatureAlgorithm.Equal(oidRSAEncryption):
+		switch digestAlgorithm {
+		case crypto.SHA1:
+			return x509.SHA1WithRSA, nil
+		case crypto.SHA256:
+			return x509.SHA256WithRSA, nil
+		case crypto.SHA384:
+			return x509.SHA384WithRSA, nil
+		case crypto.SHA512:
+			return x509.SHA512WithRSA, nil
+		}
+	case signatureAlgorithm.Equal(oidECPublicKey):
+		switch digestAlgorithm {
+		case crypto.SHA1:
+			return x509.ECDSAWithSHA1, nil
+		case crypto.SHA256:
+			return x509.ECDSAWithSHA256, nil
+		case crypto.SHA384:
+			return x509.ECDSAWithSHA384, nil
+		case crypto.SHA512:
+			return x509.ECDSAWithSHA512, nil
+		}
+	}
+	return x509.UnknownSignatureAlgorithm, fmt.Errorf("this package does not implement %s signature algorithm with %s", signatureAlgorithm, digestAlgorithm.String())
+}
+
+func (token *timeStampToken) hasMessageImprint(hashAlgorithm crypto.Hash, hashedMessage []byte) bool {
+	tokenHashAlgorithm, err := hashAlgorithmOf(token.tstInfo.MessageImprint.HashAlgorithm.Algorithm)
+	if err != nil {
+		return false
+	}
+	return tokenHashAlgorithm == hashAlgorithm && bytes.Equal(token.tstInfo.MessageImprint.HashedMessage, hashedMessage)
+}
+
+func hashAlgorithmIdentifierOf(hashAlgorithm crypto.Hash) (pkix.AlgorithmIdentifier, error) {
+	switch hashAlgorithm {
+	case crypto.SHA1:
+		return pkix.AlgorithmIdentifier{Algorithm: oidSHA1}, nil
+	case crypto.SHA224:
+		return pkix.AlgorithmIdentifier{Algorithm: oidSHA224}, nil
+	case crypto.SHA256:
+		return pkix.AlgorithmIdentifier{Algorithm: oidSHA256}, nil
+	case crypto.SHA384:
+		return pkix.AlgorithmIdentifier{Algorithm: oidSHA384}, nil
+	case crypto.SHA512:
+		return pkix.AlgorithmIdentifier{Algorithm: oidSHA512}, nil
+	}
+	return pkix.AlgorithmIdentifier{}, fmt.Errorf("this package does not implement time-stamping with %s", hashAlgorithm.String())
+}
+
+func hashAlgorithmOf(oid asn1.ObjectIdentifier) (crypto.Hash, error) {
+	switch {
+	case oid.Equal(oidSHA1):
+		return crypto.SHA1, nil
+	case oid.Equal(oidSHA224):
+		return crypto.SHA224, nil
+	case oid.Equal(oidSHA256):
+		return crypto.SHA256, nil
+	case oid.Equal(oidSHA384):
+		return crypto.SHA384, nil
+	case oid.Equal(oidSHA512):
+		return crypto.SHA512, nil
+	}
+	return 0, fmt.Errorf("this package does not implement %s hash algorithm", oid)
+}
@@ -0,0 +1,164 @@
+package xades4go_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mekpavit/xades4go"
+)
+
+type testTSTInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint struct {
+		HashAlgorithm pkix.AlgorithmIdentifier
+		HashedMessage []byte
+	}
+	SerialNumber *big.Int
+	GenTime      time.Time `asn1:"generalized"`
+}
+
+// mustParseTSTInfo extracts TSTInfo from DER-encoded TimeStampToken without verifying it.
+func mustParseTSTInfo(t *testing.T, timeStampToken []byte) testTSTInfo {
+	t.Helper()
+	var contentInfo struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue `asn1:"explicit,tag:0"`
+	}
+	if _, err := asn1.Unmarshal(timeStampToken, &contentInfo); err != nil {
+		t.Fatalf("cannot parse ContentInfo: %v", err)
+	}
+	var signedData struct {
+		Version          int
+		DigestAlgorithms asn1.RawValue
+		EncapContentInfo struct {
+			EContentType asn1.ObjectIdentifier
+			EContent     []byte `asn1:"explicit,tag:0"`
+		}
+	}
+	if _, err := asn1.Unmarshal(contentInfo.Content.Bytes, &signedData); err != nil {
+		t.Fatalf("cannot parse SignedData: %v", err)
+	}
+	var tstInfo testTSTInfo
+	if _, err := asn1.Unmarshal(signedData.EncapContentInfo.EContent, &tstInfo); err != nil {
+		t.Fatalf("cannot parse TSTInfo: %v", err)
+	}
+	return tstInfo
+}
+
+// mustCreateTimeStampAuthority creates InProcessTimeStampAuthority whose tokens have genTime, with a self-signed TSA certificate valid at genTime that is returned for tests to trust it.
+func mustCreateTimeStampAuthority(t *testing.T, genTime time.Time) (*xades4go.InProcessTimeStampAuthority, *x509.Certificate) {
+	t.Helper()
+	return mustCreateTimeStampAuthorityWithExtKeyUsage(t, genTime, []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping})
+}
+
+func mustCreateTimeStampAuthorityWithExtKeyUsage(t *testing.T, genTime time.Time, extKeyUsage []x509.ExtKeyUsage) (*xades4go.InProcessTimeStampAuthority, *x509.Certificate) {
+	t.Helper()
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("cannot generate ECDSA key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "xades4go test TSA", Country: []string{"TH"}},
+		NotBefore:    genTime.Add(-time.Hour),
+		NotAfter:     genTime.Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  extKeyUsage,
+		// A self-signed TSA certificate is its own trust anchor in tests.
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	asn1Certificate, err := x509.CreateCertificate(rand.Reader, template, template, privateKey.Public(), privateKey)
+	if err != nil {
+		t.Fatalf("cannot create TSA certificate: %v", err)
+	}
+	certificate, err := x509.ParseCertificate(asn1Certificate)
+	if err != nil {
+		t.Fatalf("cannot parse TSA certificate: %v", err)
+	}
+	authority, err := xades4go.NewInProcessTimeStampAuthority(privateKey, certificate, func() time.Time { return genTime })
+	if err != nil {
+		t.Fatalf("NewInProcessTimeStampAuthority() error = %v", err)
+	}
+	return authority, certificate
+}
+
+func Test_HTTPTimeStampProvider(t *testing.T) {
+	genTime := time.Date(2021, 2, 5, 3, 30, 0, 0, time.UTC)
+	authority, _ := mustCreateTimeStampAuthority(t, genTime)
+	server := httptest.NewServer(authority)
+	defer server.Close()
+	failingServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		http.Error(writer, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer failingServer.Close()
+	hashedMessage := sha256.Sum256([]byte("SignatureValue"))
+	tests := []struct {
+		name          string
+		url           string
+		hashAlgorithm crypto.Hash
+		hashedMessage []byte
+		wantErr       bool
+	}{
+		{
+			name:          "When TSA grants the request, it should return the token over the hashed message",
+			url:           server.URL,
+			hashAlgorithm: crypto.SHA256,
+			hashedMessage: hashedMessage[:],
+			wantErr:       false,
+		},
+		{
+			name:          "When TSA rejects the request, it should return error",
+			url:           server.URL,
+			hashAlgorithm: crypto.SHA256,
+			hashedMessage: hashedMessage[:16],
+			wantErr:       true,
+		},
+		{
+			name:          "When TSA responds with HTTP error, it should return error",
+			url:           failingServer.URL,
+			hashAlgorithm: crypto.SHA256,
+			hashedMessage: hashedMessage[:],
+			wantErr:       true,
+		},
+		{
+			name:          "When the hash algorithm is not supported, it should return error",
+			url:           server.URL,
+			hashAlgorithm: crypto.MD5,
+			hashedMessage: hashedMessage[:16],
+			wantErr:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := xades4go.NewHTTPTimeStampProvider(tt.url, nil).TimeStamp(tt.hashAlgorithm, tt.hashedMessage)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("TimeStamp() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			tstInfo := mustParseTSTInfo(t, got)
+			if !bytes.Equal(tstInfo.MessageImprint.HashedMessage, tt.hashedMessage) {
+				t.Errorf("TimeStamp() HashedMessage = %x, want %x", tstInfo.MessageImprint.HashedMessage, tt.hashedMessage)
+			}
+			if !tstInfo.GenTime.Equal(genTime) {
+				t.Errorf("TimeStamp() GenTime = %v, want %v", tstInfo.GenTime, genTime)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package xades4go
+
+import (
+	"crypto/x509"
+	"fmt"
+	"time"
+)
+
+// TrustStore is an object that provides the trust anchors that signer certificates are validated against,
+// and the intermediate certificates that may complete the path to them when X509Data element does not carry every one.
+type TrustStore interface {
+	TrustAnchors() ([]*x509.Certificate, error)
+	IntermediateCertificates() ([]*x509.Certificate, error)
+}
+
+type trustStore struct {
+	trustAnchors             []*x509.Certificate
+	intermediateCertificates []*x509.Certificate
+}
+
+// NewTrustStore creates TrustStore of trustAnchors and intermediateCertificates. intermediateCertificates may be nil.
+func NewTrustStore(trustAnchors []*x509.Certificate, intermediateCertificates []*x509.Certificate) TrustStore {
+	return &trustStore{trustAnchors: trustAnchors, intermediateCertificates: intermediateCertificates}
+}
+
+func (store *trustStore) TrustAnchors() ([]*x509.Certificate, error) {
+	return store.trustAnchors, nil
+}
+
+func (store *trustStore) IntermediateCertificates() ([]*x509.Certificate, error) {
+	return store.intermediateCertificates, nil
+}
+
+// CertificateValidationResult is the result of validating SignerCertificate of ValidationResult against TrustStore.
+type CertificateValidationResult struct {
+	// IsValid is true if IsChainTrusted, IsKeyUsageValid and IsWithinValidityPeriod are all true, and every RevocationResults has RevocationStatusGood.
+	IsValid bool
+	// IsChainTrusted is true if a path is built from the signer certificate to a trust anchor, and every certificate of it is valid at ValidationTime.
+	// The extended key usages of the certificates are not checked.
+	IsChainTrusted bool
+	// Chain is the built path, the signer certificate first and the trust anchor last. It is nil if IsChainTrusted is false.
+	Chain []*x509.Certificate
+	// ChainStatus describes why no path is built. It is empty if IsChainTrusted is true.
+	ChainStatus string
+	// IsKeyUsageValid is true if the signer certificate has digitalSignature or nonRepudiation key usage, or has no key usage extension.
+	IsKeyUsageValid bool
+	// IsWithinValidityPeriod is true if ValidationTime is between NotBefore and NotAfter of the signer certificate.
+	IsWithinValidityPeriod bool
+	// RevocationResults are the revocation statuses of the certificates of Chain except the trust anchor, in the same order.
+	// It is nil if IsChainTrusted is false, or if the validator has neither OCSPFetcher nor CRLFetcher (see ValidateWithOCSPFetcher and ValidateWithCRLFetcher)
+	// and the signature carries no revocation data (XAdES RevocationValues elements, used before fetching).
+	RevocationResults []CertificateRevocationResult
+	// ValidationTime is the time the signer certificate is validated at, which is the time of SignatureTimeStamp for XAdES signatures carrying a valid one.
+	ValidationTime time.Time
+}
+
+// unboundSignerKeyChainStatus is ChainStatus of CertificateValidationResult when the signature is verified by a key without certificate.
+const unboundSignerKeyChainStatus = "signer key is not bound to a certificate"
+
+// unboundSignerKeyValidationResult returns the invalid CertificateValidationResult of a signer key without certificate, which cannot be validated against TrustStore.
+func unboundSignerKeyValidationResult(validationTime time.Time) *CertificateValidationResult {
+	return &CertificateValidationResult{
+		ChainStatus:    unboundSignerKeyChainStatus,
+		ValidationTime: validationTime,
+	}
+}
+
+// validateSignerCertificate builds the path from signerCertificate to a trust anchor of trustStore through attachedCertificates (the certificates of KeyInfo element)
+// and the intermediate certificates of trustStore. The certificates of the path are checked with checker unless it is nil.
+func validateSignerCertificate(signerCertificate *x509.Certificate, attachedCertificates []*x509.Certificate, trustStore TrustStore, checker *revocationChecker, validationTime time.Time) (CertificateValidationResult, error) {
+	trustAnchors, err := trustStore.TrustAnchors()
+	if err != nil {
+		return CertificateValidationResult{}, fmt.Errorf("cannot get trust anchors: %w", err)
+	}
+	intermediateCertificates, err := trustStore.IntermediateCertificates()
+	if err != nil {
+		return CertificateValidationResult{}, fmt.Errorf("cannot get intermediate certificates: %w", err)
+	}
+	roots := x509.NewCertPool()
+	for _, trustAnchor := range trustAnchors {
+		roots.AddCert(trustAnchor)
+	}
+	intermediates := x509.NewCertPool()
+	for _, certificate := range append(append([]*x509.Certificate{}, attachedCertificates...), intermediateCertificates...) {
+		if certificate != signerCertificate {
+			intermediates.AddCert(certificate)
+		}
+	}
+	result := CertificateValidationResult{
+		IsKeyUsageValid:        signerCertificate.KeyUsage == 0 || signerCertificate.KeyUsage&(x509.KeyUsageDigitalSignature|x509.KeyUsageContentCommitment) != 0,
+		IsWithinValidityPeriod: !validationTime.Before(signerCertificate.NotBefore) && !validationTime.After(signerCertificate.NotAfter),
+		ValidationTime:         validationTime,
+	}
+	// Signing certificates have no extended key usage in common: they may have emailProtection, documentSigning, a scheme-specific one or none at all,
+	// while x509.Certificate.Verify requires serverAuth by default. So any extended key usage is accepted, and only KeyUsage is checked (IsKeyUsageValid).
+	chains, err := signerCertificate.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   validationTime,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		result.ChainStatus = err.Error()
+	} else {
+		result.IsChainTrusted = true
+		result.Chain = chains[0]
+		if checker != nil {
+			result.RevocationResults = checker.checkChain(result.Chain, validationTime)
+		}
+	}
+	result.IsValid = result.IsChainTrusted && result.IsKeyUsageValid && result.IsWithinValidityPeriod && isNotRevoked(result.RevocationResults)
+	return result, nil
+}
@@ -0,0 +1,236 @@
+package xades4go_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/google/go-cmp/cmp"
+	"github.com/mekpavit/xades4go"
+	"github.com/mekpavit/xades4go/etreeimpl"
+)
+
+func Test_XMLDSigSignatureValidator_TrustStore(t *testing.T) {
+	signedInfoFactory := etreeimpl.NewSignedInfoFactory()
+	now := time.Now()
+	rootKey, rootCertificate := mustIssueCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "xades4go test root CA"},
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, nil, nil)
+	intermediateKey, intermediateCertificate := mustIssueCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "xades4go test intermediate CA"},
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, rootCertificate, rootKey)
+	signerKey, signerCertificate := mustIssueCertificate(t, &x509.Certificate{
+		Subject:  pkix.Name{CommonName: "xades4go test signer"},
+		KeyUsage: x509.KeyUsageContentCommitment,
+	}, intermediateCertificate, intermediateKey)
+	encipheringSignerKey, encipheringSignerCertificate := mustIssueCertificate(t, &x509.Certificate{
+		Subject:  pkix.Name{CommonName: "xades4go test enciphering signer"},
+		KeyUsage: x509.KeyUsageKeyEncipherment,
+	}, intermediateCertificate, intermediateKey)
+	serverAuthSignerKey, serverAuthSignerCertificate := mustIssueCertificate(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "xades4go test serverAuth signer"},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, intermediateCertificate, intermediateKey)
+	selfSignedKey, selfSignedCertificate := mustCreateECDSAKeyAndSelfSignedCertificate(t, elliptic.P256())
+	mustSign := func(signer crypto.Signer, certificateChain []*x509.Certificate) []byte {
+		generator, err := xades4go.NewXMLDSigSignatureGenerator(signedInfoFactory, signer, certificateChain, xades4go.ECDSASHA256SignatureAlgorithm)
+		if err != nil {
+			t.Fatalf("NewXMLDSigSignatureGenerator() error = %v", err)
+		}
+		signedXMLBytes, err := generator.SignXMLBytes([]byte(unsignedInvoice), []xades4go.ReferenceGenerationDetail{
+			{
+				URIOfDataObjectBeingSigned: "",
+				TransformAlgorithms:        []string{xades4go.EnvelopedSignatureTransformAlgorithm},
+				DigestAlgorithm:            xades4go.SHA256MessageDigestAlgorithm,
+			},
+		})
+		if err != nil {
+			t.Fatalf("SignXMLBytes() error = %v", err)
+		}
+		return signedXMLBytes
+	}
+	trustStore := xades4go.NewTrustStore([]*x509.Certificate{rootCertificate}, nil)
+	// replaceKeyInfoWithDEREncodedKeyValue leaves only the public key of the signer certificate in KeyInfo element, which is not signed.
+	replaceKeyInfoWithDEREncodedKeyValue := func(xmlBytes []byte, certificate *x509.Certificate) []byte {
+		doc := etree.NewDocument()
+		if err := doc.ReadFromBytes(xmlBytes); err != nil {
+			t.Fatalf("cannot parse signed XML: %v", err)
+		}
+		keyInfoElement := doc.FindElement("//KeyInfo")
+		for _, childElement := range keyInfoElement.ChildElements() {
+			keyInfoElement.RemoveChild(childElement)
+		}
+		derEncodedKeyValueElement := keyInfoElement.CreateElement("dsig11:DEREncodedKeyValue")
+		derEncodedKeyValueElement.CreateAttr("xmlns:dsig11", "http://www.w3.org/2009/xmldsig11#")
+		derEncodedKeyValueElement.SetText(base64.StdEncoding.EncodeToString(certificate.RawSubjectPublicKeyInfo))
+		replacedXMLBytes, err := doc.WriteToBytes()
+		if err != nil {
+			t.Fatalf("cannot serialize XML: %v", err)
+		}
+		return replacedXMLBytes
+	}
+
+	tests := []struct {
+		name                            string
+		xmlBytes                        []byte
+		options                         []xades4go.XMLDSigSignatureValidatorOption
+		wantCertificateValidationResult *xades4go.CertificateValidationResult
+		wantChainStatus                 string
+	}{
+		{
+			name:     "When X509Data has the signer certificate and the intermediate certificate, it should build the path to the trust anchor",
+			xmlBytes: mustSign(signerKey, []*x509.Certificate{signerCertificate, intermediateCertificate}),
+			options:  []xades4go.XMLDSigSignatureValidatorOption{xades4go.ValidateWithTrustStore(trustStore)},
+			wantCertificateValidationResult: &xades4go.CertificateValidationResult{
+				IsValid:                true,
+				IsChainTrusted:         true,
+				Chain:                  []*x509.Certificate{signerCertificate, intermediateCertificate, rootCertificate},
+				IsKeyUsageValid:        true,
+				IsWithinValidityPeriod: true,
+			},
+		},
+		{
+			name:     "When the intermediate certificate is only in TrustStore, it should build the path to the trust anchor",
+			xmlBytes: mustSign(signerKey, []*x509.Certificate{signerCertificate}),
+			options: []xades4go.XMLDSigSignatureValidatorOption{
+				xades4go.ValidateWithTrustStore(xades4go.NewTrustStore([]*x509.Certificate{rootCertificate}, []*x509.Certificate{intermediateCertificate})),
+			},
+			wantCertificateValidationResult: &xades4go.CertificateValidationResult{
+				IsValid:                true,
+				IsChainTrusted:         true,
+				Chain:                  []*x509.Certificate{signerCertificate, intermediateCertificate, rootCertificate},
+				IsKeyUsageValid:        true,
+				IsWithinValidityPeriod: true,
+			},
+		},
+		{
+			name:     "When the intermediate certificate is missing, it should not be trusted",
+			xmlBytes: mustSign(signerKey, []*x509.Certificate{signerCertificate}),
+			options:  []xades4go.XMLDSigSignatureValidatorOption{xades4go.ValidateWithTrustStore(trustStore)},
+			wantCertificateValidationResult: &xades4go.CertificateValidationResult{
+				IsKeyUsageValid:        true,
+				IsWithinValidityPeriod: true,
+			},
+		},
+		{
+			name:     "When the signer certificate is self-signed, it should not be trusted",
+			xmlBytes: mustSign(selfSignedKey, []*x509.Certificate{selfSignedCertificate}),
+			options:  []xades4go.XMLDSigSignatureValidatorOption{xades4go.ValidateWithTrustStore(trustStore)},
+			wantCertificateValidationResult: &xades4go.CertificateValidationResult{
+				IsKeyUsageValid:        true,
+				IsWithinValidityPeriod: true,
+			},
+		},
+		{
+			name:     "When the signer certificate has neither digitalSignature nor nonRepudiation key usage, it should be invalid",
+			xmlBytes: mustSign(encipheringSignerKey, []*x509.Certificate{encipheringSignerCertificate, intermediateCertificate}),
+			options:  []xades4go.XMLDSigSignatureValidatorOption{xades4go.ValidateWithTrustStore(trustStore)},
+			wantCertificateValidationResult: &xades4go.CertificateValidationResult{
+				IsChainTrusted:         true,
+				Chain:                  []*x509.Certificate{encipheringSignerCertificate, intermediateCertificate, rootCertificate},
+				IsWithinValidityPeriod: true,
+			},
+		},
+		{
+			name:     "When the signer certificate has an extended key usage other than for signing, it should still be valid as extended key usages are not checked",
+			xmlBytes: mustSign(serverAuthSignerKey, []*x509.Certificate{serverAuthSignerCertificate, intermediateCertificate}),
+			options:  []xades4go.XMLDSigSignatureValidatorOption{xades4go.ValidateWithTrustStore(trustStore)},
+			wantCertificateValidationResult: &xades4go.CertificateValidationResult{
+				IsValid:                true,
+				IsChainTrusted:         true,
+				Chain:                  []*x509.Certificate{serverAuthSignerCertificate, intermediateCertificate, rootCertificate},
+				IsKeyUsageValid:        true,
+				IsWithinValidityPeriod: true,
+			},
+		},
+		{
+			name:     "When the validation time is after the signer certificate expires, it should be invalid",
+			xmlBytes: mustSign(signerKey, []*x509.Certificate{signerCertificate, intermediateCertificate}),
+			options: []xades4go.XMLDSigSignatureValidatorOption{
+				xades4go.ValidateWithTrustStore(trustStore),
+				xades4go.ValidateWithValidationTime(func() time.Time { return now.Add(48 * time.Hour) }),
+			},
+			wantCertificateValidationResult: &xades4go.CertificateValidationResult{
+				IsKeyUsageValid: true,
+			},
+		},
+		{
+			name:                            "When the signature is verified by a key without certificate, it should be invalid",
+			xmlBytes:                        replaceKeyInfoWithDEREncodedKeyValue(mustSign(signerKey, []*x509.Certificate{signerCertificate, intermediateCertificate}), signerCertificate),
+			options:                         []xades4go.XMLDSigSignatureValidatorOption{xades4go.ValidateWithTrustStore(trustStore)},
+			wantCertificateValidationResult: &xades4go.CertificateValidationResult{},
+			wantChainStatus:                 "signer key is not bound to a certificate",
+		},
+		{
+			name:                            "When the validator has no TrustStore, it should not validate the signer certificate",
+			xmlBytes:                        mustSign(selfSignedKey, []*x509.Certificate{selfSignedCertificate}),
+			wantCertificateValidationResult: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator := xades4go.NewXMLDSigSignatureValidator(signedInfoFactory, tt.options...)
+			got, err := validator.Validate(tt.xmlBytes)
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if !got.IsSignatureValid {
+				t.Errorf("Validate() IsSignatureValid = false, want true")
+			}
+			gotCertificateValidationResult := got.CertificateValidationResult
+			if gotCertificateValidationResult != nil {
+				if gotCertificateValidationResult.IsChainTrusted == (gotCertificateValidationResult.ChainStatus != "") {
+					t.Errorf("Validate() ChainStatus = %q, IsChainTrusted = %v", gotCertificateValidationResult.ChainStatus, gotCertificateValidationResult.IsChainTrusted)
+				}
+				if tt.wantChainStatus != "" && gotCertificateValidationResult.ChainStatus != tt.wantChainStatus {
+					t.Errorf("Validate() ChainStatus = %q, want %q", gotCertificateValidationResult.ChainStatus, tt.wantChainStatus)
+				}
+				gotCertificateValidationResult.ChainStatus = ""
+				gotCertificateValidationResult.ValidationTime = time.Time{}
+			}
+			if diff := cmp.Diff(tt.wantCertificateValidationResult, gotCertificateValidationResult, certificateComparer); diff != "" {
+				t.Errorf("Validate() CertificateValidationResult mismatch (-want+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// mustIssueCertificate creates an ECDSA key and the certificate of template for it, valid from an hour ago for a day, issued by issuerKey.
+// The certificate is self-signed if issuerCertificate is nil.
+func mustIssueCertificate(t *testing.T, template *x509.Certificate, issuerCertificate *x509.Certificate, issuerKey crypto.Signer) (*ecdsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("cannot generate ECDSA key: %v", err)
+	}
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(24 * time.Hour)
+	if issuerCertificate == nil {
+		issuerCertificate, issuerKey = template, privateKey
+	}
+	asn1Certificate, err := x509.CreateCertificate(rand.Reader, template, issuerCertificate, privateKey.Public(), issuerKey)
+	if err != nil {
+		t.Fatalf("cannot create certificate: %v", err)
+	}
+	certificate, err := x509.ParseCertificate(asn1Certificate)
+	if err != nil {
+		t.Fatalf("cannot parse certificate: %v", err)
+	}
+	return privateKey, certificate
+}
@@ -0,0 +1,481 @@
+// Package trustedlist parses Trusted Lists of ETSI TS 119 612, such as the national Trusted Lists of EU member states and the List of Trusted Lists,
+// to use the digital identities of the trust services they list as trust anchors of xades4go.
+package trustedlist
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/mekpavit/xades4go"
+)
+
+// Service types of ServiceTypeIdentifier element (ETSI TS 119 612 clause 5.5.1).
+const (
+	ServiceTypeCAQC               = "http://uri.etsi.org/TrstSvc/Svctype/CA/QC"
+	ServiceTypeCAPKC              = "http://uri.etsi.org/TrstSvc/Svctype/CA/PKC"
+	ServiceTypeCertstatusOCSPQC   = "http://uri.etsi.org/TrstSvc/Svctype/Certstatus/OCSP/QC"
+	ServiceTypeCertstatusCRLQC    = "http://uri.etsi.org/TrstSvc/Svctype/Certstatus/CRL/QC"
+	ServiceTypeTSAQTST            = "http://uri.etsi.org/TrstSvc/Svctype/TSA/QTST"
+	ServiceTypeTSA                = "http://uri.etsi.org/TrstSvc/Svctype/TSA"
+	ServiceTypeNationalRootCAQC   = "http://uri.etsi.org/TrstSvc/Svctype/NationalRootCA-QC"
+	ServiceTypeUnspecifiedService = "http://uri.etsi.org/TrstSvc/Svctype/unspecified"
+)
+
+// Service statuses of ServiceStatus element (ETSI TS 119 612 clause 5.5.4). The statuses after ServiceStatusDeprecatedAtNationalLevel
+// are the ones used before Regulation (EU) No 910/2014 (eIDAS), which still appear in the history of the services.
+const (
+	ServiceStatusGranted                   = "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/granted"
+	ServiceStatusWithdrawn                 = "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/withdrawn"
+	ServiceStatusRecognisedAtNationalLevel = "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/recognisedatnationallevel"
+	ServiceStatusDeprecatedAtNationalLevel = "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/deprecatedatnationallevel"
+	ServiceStatusUnderSupervision          = "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/undersupervision"
+	ServiceStatusSupervisionInCessation    = "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/supervisionincessation"
+	ServiceStatusSupervisionCeased         = "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/supervisionceased"
+	ServiceStatusSupervisionRevoked        = "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/supervisionrevoked"
+	ServiceStatusAccredited                = "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/accredited"
+	ServiceStatusAccreditationCeased       = "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/accreditationceased"
+	ServiceStatusAccreditationRevoked      = "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/accreditationrevoked"
+	ServiceStatusSetByNationalLaw          = "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/setbynationallaw"
+	ServiceStatusDeprecatedByNationalLaw   = "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/deprecatedbynationallaw"
+)
+
+// tslNamespaceURI is the namespace of the elements of Trusted Lists (ETSI TS 119 612 annex C).
+const tslNamespaceURI = "http://uri.etsi.org/02231/v2#"
+
+const (
+	trustServiceStatusListElementTag   = "TrustServiceStatusList"
+	schemeInformationElementTag        = "SchemeInformation"
+	tslSequenceNumberElementTag        = "TSLSequenceNumber"
+	tslTypeElementTag                  = "TSLType"
+	schemeOperatorNameElementTag       = "SchemeOperatorName"
+	schemeTerritoryElementTag          = "SchemeTerritory"
+	listIssueDateTimeElementTag        = "ListIssueDateTime"
+	nextUpdateElementTag               = "NextUpdate"
+	dateTimeElementTag                 = "dateTime"
+	pointersToOtherTSLElementTag       = "PointersToOtherTSL"
+	otherTSLPointerElementTag          = "OtherTSLPointer"
+	serviceDigitalIdentitiesElementTag = "ServiceDigitalIdentities"
+	tslLocationElementTag              = "TSLLocation"
+	additionalInformationElementTag    = "AdditionalInformation"
+	mimeTypeElementTag                 = "MimeType"
+	trustServiceProviderListElementTag = "TrustServiceProviderList"
+	trustServiceProviderElementTag     = "TrustServiceProvider"
+	tspInformationElementTag           = "TSPInformation"
+	tspNameElementTag                  = "TSPName"
+	tspServicesElementTag              = "TSPServices"
+	tspServiceElementTag               = "TSPService"
+	serviceInformationElementTag       = "ServiceInformation"
+	serviceTypeIdentifierElementTag    = "ServiceTypeIdentifier"
+	serviceNameElementTag              = "ServiceName"
+	serviceDigitalIdentityElementTag   = "ServiceDigitalIdentity"
+	digitalIDElementTag                = "DigitalId"
+	x509CertificateElementTag          = "X509Certificate"
+	serviceStatusElementTag            = "ServiceStatus"
+	statusStartingTimeElementTag       = "StatusStartingTime"
+	serviceHistoryElementTag           = "ServiceHistory"
+	serviceHistoryInstanceElementTag   = "ServiceHistoryInstance"
+	nameElementTag                     = "Name"
+
+	idAttributeKey      = "Id"
+	xmlLangAttributeKey = "xml:lang"
+)
+
+// TrustedList is a Trusted List whose signature has been verified by Parse.
+type TrustedList struct {
+	// SequenceNumber is TSLSequenceNumber, which is incremented by each issue of the list.
+	SequenceNumber int
+	// Type is TSLType, telling a national Trusted List from the List of Trusted Lists for example.
+	Type string
+	// SchemeOperatorName is the English name of the scheme operator, or its first name if it has no English name.
+	SchemeOperatorName string
+	// SchemeTerritory is the country code of the scheme, EU for the List of Trusted Lists.
+	SchemeTerritory string
+	IssueDate       time.Time
+	// NextUpdate is the time by which the next list will be issued. It is zero if the scheme has ceased operation.
+	NextUpdate time.Time
+	// Pointers are the pointers to other Trusted Lists. The List of Trusted Lists points to the national Trusted Lists with the certificates that sign them.
+	Pointers []Pointer
+	// TrustServiceProviders are the listed trust service providers, in document order.
+	TrustServiceProviders []TrustServiceProvider
+	// SignerCertificate is the certificate among the signer certificates given to Parse that signed the list.
+	SignerCertificate *x509.Certificate
+}
+
+// Pointer is OtherTSLPointer element of a Trusted List.
+type Pointer struct {
+	// Location is TSLLocation, the URL of the pointed list.
+	Location string
+	// SchemeTerritory and MimeType are taken from AdditionalInformation element. They are empty if it does not tell.
+	SchemeTerritory string
+	MimeType        string
+	// Certificates are the certificates announced to sign the pointed list, to be given to Parse when parsing it.
+	Certificates []*x509.Certificate
+}
+
+// TrustServiceProvider is TrustServiceProvider element of a Trusted List.
+type TrustServiceProvider struct {
+	// Name is the English name of the provider, or its first name if it has no English name.
+	Name     string
+	Services []TrustService
+}
+
+// TrustService is TSPService element of a Trusted List: the current information of a service followed by its history.
+type TrustService struct {
+	ServiceStatusInstance
+	// Certificates are the X509Certificate elements of ServiceDigitalIdentity element. The digital identity of a service does not change over its history.
+	Certificates []*x509.Certificate
+	// History is the earlier information of the service, from ServiceHistoryInstance elements in document order (usually the newest first).
+	History []ServiceStatusInstance
+}
+
+// ServiceStatusInstance is the information of a service that is in effect from StatusStartingTime until the next instance starts.
+type ServiceStatusInstance struct {
+	ServiceType string
+	// Name is the English name of the service, or its first name if it has no English name.
+	Name               string
+	Status             string
+	StatusStartingTime time.Time
+}
+
+// IsGrantedStatus reports whether status makes the service trusted: granted or recognised at national level,
+// or, for the time before eIDAS, under supervision, in cessation of supervision, accredited or set by national law.
+func IsGrantedStatus(status string) bool {
+	switch status {
+	case ServiceStatusGranted, ServiceStatusRecognisedAtNationalLevel,
+		ServiceStatusUnderSupervision, ServiceStatusSupervisionInCessation, ServiceStatusAccredited, ServiceStatusSetByNationalLaw:
+		return true
+	}
+	return false
+}
+
+// StatusAt returns the information of service in effect at the given time, the instance with the latest StatusStartingTime not after it.
+// It returns false if the service did not exist yet.
+func (service TrustService) StatusAt(at time.Time) (ServiceStatusInstance, bool) {
+	var found ServiceStatusInstance
+	isFound := false
+	for _, instance := range append([]ServiceStatusInstance{service.ServiceStatusInstance}, service.History...) {
+		if instance.StatusStartingTime.After(at) {
+			continue
+		}
+		if !isFound || instance.StatusStartingTime.After(found.StatusStartingTime) {
+			found = instance
+			isFound = true
+		}
+	}
+	return found, isFound
+}
+
+// TrustAnchors returns the certificates of the services whose status is granted (see IsGrantedStatus) at the given time.
+// Only the services of serviceTypes are taken if any is given, for example ServiceTypeCAQC for the CAs issuing qualified certificates.
+// The freshness of list itself (NextUpdate) is not checked.
+func (list *TrustedList) TrustAnchors(at time.Time, serviceTypes ...string) []*x509.Certificate {
+	trustAnchors := make([]*x509.Certificate, 0)
+	for _, provider := range list.TrustServiceProviders {
+		for _, service := range provider.Services {
+			instance, isFound := service.StatusAt(at)
+			if !isFound || !IsGrantedStatus(instance.Status) || !isServiceTypeSelected(serviceTypes, instance.ServiceType) {
+				continue
+			}
+			for _, certificate := range service.Certificates {
+				if !containsCertificate(trustAnchors, certificate) {
+					trustAnchors = append(trustAnchors, certificate)
+				}
+			}
+		}
+	}
+	return trustAnchors
+}
+
+// TrustStore creates xades4go.TrustStore of the trust anchors of list at the given time (see TrustAnchors), to validate signer certificates with xades4go.ValidateWithTrustStore.
+func (list *TrustedList) TrustStore(at time.Time, serviceTypes ...string) xades4go.TrustStore {
+	return xades4go.NewTrustStore(list.TrustAnchors(at, serviceTypes...), nil)
+}
+
+// Parse verifies the enveloped signature of xmlBytes, a Trusted List (TrustServiceStatusList element), with validator and parses the list.
+// The signature must be valid, cover the whole list, and be verified by the key of one of signerCertificates,
+// the certificates that the scheme operator announced to sign the list (see Pointer.Certificates of the List of Trusted Lists).
+func Parse(xmlBytes []byte, validator xades4go.SignatureValidator, signerCertificates []*x509.Certificate) (*TrustedList, error) {
+	doc := etree.NewDocument()
+	err := doc.ReadFromBytes(xmlBytes)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse XML: %w", err)
+	}
+	rootElement := doc.Root()
+	if rootElement == nil || rootElement.Tag != trustServiceStatusListElementTag {
+		return nil, errors.New("root element is not TrustServiceStatusList")
+	}
+	if namespaceURI := rootElement.NamespaceURI(); namespaceURI != tslNamespaceURI {
+		return nil, fmt.Errorf("TrustServiceStatusList element must be in %s namespace, got %q", tslNamespaceURI, namespaceURI)
+	}
+	signerCertificate, err := verifySignature(xmlBytes, rootElement, validator, signerCertificates)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature of Trusted List: %w", err)
+	}
+	list := &TrustedList{SignerCertificate: signerCertificate}
+	err = parseSchemeInformation(rootElement, list)
+	if err != nil {
+		return nil, fmt.Errorf("at SchemeInformation element: %w", err)
+	}
+	for providerIndex, providerElement := range rootElement.FindElements("./" + trustServiceProviderListElementTag + "/" + trustServiceProviderElementTag) {
+		provider, err := parseTrustServiceProvider(providerElement)
+		if err != nil {
+			return nil, fmt.Errorf("at TrustServiceProvider#%d element: %w", providerIndex, err)
+		}
+		list.TrustServiceProviders = append(list.TrustServiceProviders, provider)
+	}
+	return list, nil
+}
+
+// canonicalizationAlgorithms are the transforms that may follow the enveloped signature transform in the Reference covering TrustServiceStatusList element.
+var canonicalizationAlgorithms = map[string]bool{
+	xades4go.CanonicalXML10Algorithm:                            true,
+	xades4go.CanonicalXML10WithCommentAlgorithm:                 true,
+	xades4go.CanonicalXML11Algorithm:                            true,
+	xades4go.CanonicalXML11WithCommentAlgorithm:                 true,
+	xades4go.ExclusiveXMLCanonicalization10Algorithm:            true,
+	xades4go.ExclusiveXMLCanonicalization10WithCommentAlgorithm: true,
+}
+
+// verifySignature validates the only signature of xmlBytes (whose root element is rootElement) with validator and returns the certificate of signerCertificates that verified it.
+// The Reference covering TrustServiceStatusList element must only apply the enveloped signature transform and a canonicalization (ETSI TS 119 612 clause 5.7.1),
+// so that no other transform, such as XPath or XSLT, can leave part of the list unsigned.
+func verifySignature(xmlBytes []byte, rootElement *etree.Element, validator xades4go.SignatureValidator, signerCertificates []*x509.Certificate) (*x509.Certificate, error) {
+	result, err := validator.Validate(xmlBytes)
+	if err != nil {
+		return nil, err
+	}
+	if !result.IsSignatureValid {
+		return nil, errors.New("SignatureValue is not valid")
+	}
+	if len(result.ReferenceValidationResults) == 0 {
+		return nil, errors.New("signature has no Reference")
+	}
+	isListReferenced := false
+	rootID := rootElement.SelectAttrValue(idAttributeKey, "")
+	for referenceIndex, referenceValidationResult := range result.ReferenceValidationResults {
+		if !referenceValidationResult.IsValid {
+			return nil, fmt.Errorf("digest of Reference#%d is not valid", referenceIndex)
+		}
+		if referenceValidationResult.URI == "" || (rootID != "" && referenceValidationResult.URI == "#"+rootID) {
+			transformAlgorithms := referenceValidationResult.TransformAlgorithms
+			if len(transformAlgorithms) != 2 || transformAlgorithms[0] != xades4go.EnvelopedSignatureTransformAlgorithm || !canonicalizationAlgorithms[transformAlgorithms[1]] {
+				return nil, fmt.Errorf("Reference#%d covering TrustServiceStatusList element must have the enveloped signature transform and a canonicalization transform, got %v", referenceIndex, transformAlgorithms)
+			}
+			isListReferenced = true
+		}
+	}
+	if !isListReferenced {
+		return nil, errors.New("no Reference covers TrustServiceStatusList element")
+	}
+	if result.QualifyingPropertiesValidationResult != nil && !result.QualifyingPropertiesValidationResult.IsValid {
+		return nil, errors.New("qualifying properties are not valid")
+	}
+	if result.SignerCertificate == nil {
+		return nil, errors.New("signer certificate is not found in KeyInfo element")
+	}
+	for _, signerCertificate := range signerCertificates {
+		if bytes.Equal(signerCertificate.Raw, result.SignerCertificate.Raw) {
+			return signerCertificate, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not an announced signer of the list", result.SignerCertificate.Subject)
+}
+
+func parseSchemeInformation(rootElement *etree.Element, list *TrustedList) error {
+	schemeInformationElement, err := mustFoundOnlyOneChildElement(rootElement, schemeInformationElementTag)
+	if err != nil {
+		return err
+	}
+	sequenceNumberElement, err := mustFoundOnlyOneChildElement(schemeInformationElement, tslSequenceNumberElementTag)
+	if err != nil {
+		return err
+	}
+	list.SequenceNumber, err = strconv.Atoi(strings.TrimSpace(sequenceNumberElement.Text()))
+	if err != nil {
+		return fmt.Errorf("TSLSequenceNumber is not an integer: %w", err)
+	}
+	list.Type = childText(schemeInformationElement, tslTypeElementTag)
+	list.SchemeOperatorName = multilingualNameOf(schemeInformationElement.SelectElement(schemeOperatorNameElementTag))
+	list.SchemeTerritory = childText(schemeInformationElement, schemeTerritoryElementTag)
+	issueDateElement, err := mustFoundOnlyOneChildElement(schemeInformationElement, listIssueDateTimeElementTag)
+	if err != nil {
+		return err
+	}
+	list.IssueDate, err = parseDateTime(issueDateElement.Text())
+	if err != nil {
+		return fmt.Errorf("ListIssueDateTime is not a valid xsd:dateTime: %w", err)
+	}
+	if nextUpdateDateTimeElement := schemeInformationElement.FindElement("./" + nextUpdateElementTag + "/" + dateTimeElementTag); nextUpdateDateTimeElement != nil {
+		list.NextUpdate, err = parseDateTime(nextUpdateDateTimeElement.Text())
+		if err != nil {
+			return fmt.Errorf("NextUpdate is not a valid xsd:dateTime: %w", err)
+		}
+	}
+	for pointerIndex, pointerElement := range schemeInformationElement.FindElements("./" + pointersToOtherTSLElementTag + "/" + otherTSLPointerElementTag) {
+		pointer := Pointer{
+			Location: childText(pointerElement, tslLocationElementTag),
+		}
+		if additionalInformationElement := pointerElement.SelectElement(additionalInformationElementTag); additionalInformationElement != nil {
+			if schemeTerritoryElement := additionalInformationElement.FindElement(".//" + schemeTerritoryElementTag); schemeTerritoryElement != nil {
+				pointer.SchemeTerritory = strings.TrimSpace(schemeTerritoryElement.Text())
+			}
+			if mimeTypeElement := additionalInformationElement.FindElement(".//" + mimeTypeElementTag); mimeTypeElement != nil {
+				pointer.MimeType = strings.TrimSpace(mimeTypeElement.Text())
+			}
+		}
+		pointer.Certificates, err = parseCertificates(pointerElement.FindElements("./" + serviceDigitalIdentitiesElementTag + "/" + serviceDigitalIdentityElementTag + "/" + digitalIDElementTag + "/" + x509CertificateElementTag))
+		if err != nil {
+			return fmt.Errorf("at OtherTSLPointer#%d element: %w", pointerIndex, err)
+		}
+		list.Pointers = append(list.Pointers, pointer)
+	}
+	return nil
+}
+
+func parseTrustServiceProvider(providerElement *etree.Element) (TrustServiceProvider, error) {
+	provider := TrustServiceProvider{
+		Name: multilingualNameOf(providerElement.FindElement("./" + tspInformationElementTag + "/" + tspNameElementTag)),
+	}
+	for serviceIndex, serviceElement := range providerElement.FindElements("./" + tspServicesElementTag + "/" + tspServiceElementTag) {
+		serviceInformationElement, err := mustFoundOnlyOneChildElement(serviceElement, serviceInformationElementTag)
+		if err != nil {
+			return TrustServiceProvider{}, fmt.Errorf("at TSPService#%d element: %w", serviceIndex, err)
+		}
+		service := TrustService{}
+		service.ServiceStatusInstance, err = parseServiceStatusInstance(serviceInformationElement)
+		if err != nil {
+			return TrustServiceProvider{}, fmt.Errorf("at TSPService#%d element: %w", serviceIndex, err)
+		}
+		service.Certificates, err = parseCertificates(serviceInformationElement.FindElements("./" + serviceDigitalIdentityElementTag + "/" + digitalIDElementTag + "/" + x509CertificateElementTag))
+		if err != nil {
+			return TrustServiceProvider{}, fmt.Errorf("at TSPService#%d element: %w", serviceIndex, err)
+		}
+		for historyIndex, historyInstanceElement := range serviceElement.FindElements("./" + serviceHistoryElementTag + "/" + serviceHistoryInstanceElementTag) {
+			instance, err := parseServiceStatusInstance(historyInstanceElement)
+			if err != nil {
+				return TrustServiceProvider{}, fmt.Errorf("at TSPService#%d element: at ServiceHistoryInstance#%d element: %w", serviceIndex, historyIndex, err)
+			}
+			service.History = append(service.History, instance)
+		}
+		provider.Services = append(provider.Services, service)
+	}
+	return provider, nil
+}
+
+// parseServiceStatusInstance parses ServiceInformation or ServiceHistoryInstance element.
+func parseServiceStatusInstance(element *etree.Element) (ServiceStatusInstance, error) {
+	serviceTypeElement, err := mustFoundOnlyOneChildElement(element, serviceTypeIdentifierElementTag)
+	if err != nil {
+		return ServiceStatusInstance{}, err
+	}
+	statusElement, err := mustFoundOnlyOneChildElement(element, serviceStatusElementTag)
+	if err != nil {
+		return ServiceStatusInstance{}, err
+	}
+	statusStartingTimeElement, err := mustFoundOnlyOneChildElement(element, statusStartingTimeElementTag)
+	if err != nil {
+		return ServiceStatusInstance{}, err
+	}
+	statusStartingTime, err := parseDateTime(statusStartingTimeElement.Text())
+	if err != nil {
+		return ServiceStatusInstance{}, fmt.Errorf("StatusStartingTime is not a valid xsd:dateTime: %w", err)
+	}
+	return ServiceStatusInstance{
+		ServiceType:        strings.TrimSpace(serviceTypeElement.Text()),
+		Name:               multilingualNameOf(element.SelectElement(serviceNameElementTag)),
+		Status:             strings.TrimSpace(statusElement.Text()),
+		StatusStartingTime: statusStartingTime,
+	}, nil
+}
+
+func parseCertificates(x509CertificateElements []*etree.Element) ([]*x509.Certificate, error) {
+	certificates := make([]*x509.Certificate, 0, len(x509CertificateElements))
+	for certificateIndex, x509CertificateElement := range x509CertificateElements {
+		certificateBytes, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(x509CertificateElement.Text()), ""))
+		if err != nil {
+			return nil, fmt.Errorf("at X509Certificate#%d element: cannot base64-decode certificate: %w", certificateIndex, err)
+		}
+		certificate, err := x509.ParseCertificate(certificateBytes)
+		if err != nil {
+			return nil, fmt.Errorf("at X509Certificate#%d element: cannot parse certificate: %w", certificateIndex, err)
+		}
+		certificates = append(certificates, certificate)
+	}
+	return certificates, nil
+}
+
+// multilingualNameOf returns the text of Name element of element (of InternationalNamesType) whose xml:lang is en, or of its first Name element if none is.
+// It returns empty string if element is nil or has no Name element.
+func multilingualNameOf(element *etree.Element) string {
+	if element == nil {
+		return ""
+	}
+	nameElements := element.SelectElements(nameElementTag)
+	if len(nameElements) == 0 {
+		return ""
+	}
+	for _, nameElement := range nameElements {
+		if strings.EqualFold(nameElement.SelectAttrValue(xmlLangAttributeKey, ""), "en") {
+			return strings.TrimSpace(nameElement.Text())
+		}
+	}
+	return strings.TrimSpace(nameElements[0].Text())
+}
+
+func childText(element *etree.Element, tag string) string {
+	childElement := element.SelectElement(tag)
+	if childElement == nil {
+		return ""
+	}
+	return strings.TrimSpace(childElement.Text())
+}
+
+func mustFoundOnlyOneChildElement(parent *etree.Element, childTag string) (*etree.Element, error) {
+	foundElements := parent.SelectElements(childTag)
+	if len(foundElements) == 0 {
+		return nil, fmt.Errorf("%s element was not found on %s element", childTag, parent.FullTag())
+	}
+	if len(foundElements) > 1 {
+		return nil, fmt.Errorf("found more than one %s element on %s element", childTag, parent.FullTag())
+	}
+	return foundElements[0], nil
+}
+
+// parseDateTime parses xsd:dateTime. A value without time zone is interpreted as UTC.
+func parseDateTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	parsed, err := time.Parse(time.RFC3339Nano, value)
+	if err == nil {
+		return parsed, nil
+	}
+	return time.Parse("2006-01-02T15:04:05.999999999", value)
+}
+
+// isServiceTypeSelected reports whether serviceType is one of serviceTypes. Every service type is selected if serviceTypes is empty.
+func isServiceTypeSelected(serviceTypes []string, serviceType string) bool {
+	if len(serviceTypes) == 0 {
+		return true
+	}
+	for _, selected := range serviceTypes {
+		if selected == serviceType {
+			return true
+		}
+	}
+	return false
+}
+
+func containsCertificate(certificates []*x509.Certificate, certificate *x509.Certificate) bool {
+	for _, contained := range certificates {
+		if contained.Equal(certificate) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,344 @@
+package trustedlist_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mekpavit/xades4go"
+	"github.com/mekpavit/xades4go/etreeimpl"
+	"github.com/mekpavit/xades4go/trustedlist"
+)
+
+const testTrustedList = `<?xml version="1.0" encoding="UTF-8"?>
+<TrustServiceStatusList xmlns="http://uri.etsi.org/02231/v2#" xmlns:ns3="http://uri.etsi.org/02231/v2/additionaltypes#" Id="tsl" TSLTag="http://uri.etsi.org/19612/TSLTag">
+    <SchemeInformation>
+        <TSLVersionIdentifier>5</TSLVersionIdentifier>
+        <TSLSequenceNumber>42</TSLSequenceNumber>
+        <TSLType>http://uri.etsi.org/TrstSvc/TrustedList/TSLType/EUgeneric</TSLType>
+        <SchemeOperatorName>
+            <Name xml:lang="th">ผู้ดูแลรายการ</Name>
+            <Name xml:lang="en">xades4go test scheme operator</Name>
+        </SchemeOperatorName>
+        <SchemeTerritory>TH</SchemeTerritory>
+        <ListIssueDateTime>2021-01-15T00:00:00Z</ListIssueDateTime>
+        <NextUpdate>
+            <dateTime>2021-07-15T00:00:00Z</dateTime>
+        </NextUpdate>
+        <PointersToOtherTSL>
+            <OtherTSLPointer>
+                <ServiceDigitalIdentities>
+                    <ServiceDigitalIdentity>
+                        <DigitalId>
+                            <X509Certificate>%[1]s</X509Certificate>
+                        </DigitalId>
+                    </ServiceDigitalIdentity>
+                </ServiceDigitalIdentities>
+                <TSLLocation>https://tl.example.com/eu-lotl.xml</TSLLocation>
+                <AdditionalInformation>
+                    <OtherInformation>
+                        <SchemeTerritory>EU</SchemeTerritory>
+                    </OtherInformation>
+                    <OtherInformation>
+                        <ns3:MimeType>application/vnd.etsi.tsl+xml</ns3:MimeType>
+                    </OtherInformation>
+                </AdditionalInformation>
+            </OtherTSLPointer>
+        </PointersToOtherTSL>
+    </SchemeInformation>
+    <TrustServiceProviderList>
+        <TrustServiceProvider>
+            <TSPInformation>
+                <TSPName>
+                    <Name xml:lang="en">xades4go test provider</Name>
+                </TSPName>
+            </TSPInformation>
+            <TSPServices>
+                <TSPService>
+                    <ServiceInformation>
+                        <ServiceTypeIdentifier>http://uri.etsi.org/TrstSvc/Svctype/CA/QC</ServiceTypeIdentifier>
+                        <ServiceName>
+                            <Name xml:lang="en">xades4go test qualified CA</Name>
+                        </ServiceName>
+                        <ServiceDigitalIdentity>
+                            <DigitalId>
+                                <X509Certificate>%[2]s</X509Certificate>
+                            </DigitalId>
+                        </ServiceDigitalIdentity>
+                        <ServiceStatus>http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/withdrawn</ServiceStatus>
+                        <StatusStartingTime>2020-01-01T00:00:00Z</StatusStartingTime>
+                    </ServiceInformation>
+                    <ServiceHistory>
+                        <ServiceHistoryInstance>
+                            <ServiceTypeIdentifier>http://uri.etsi.org/TrstSvc/Svctype/CA/QC</ServiceTypeIdentifier>
+                            <ServiceName>
+                                <Name xml:lang="en">xades4go test qualified CA</Name>
+                            </ServiceName>
+                            <ServiceStatus>http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/granted</ServiceStatus>
+                            <StatusStartingTime>2016-07-01T00:00:00Z</StatusStartingTime>
+                        </ServiceHistoryInstance>
+                        <ServiceHistoryInstance>
+                            <ServiceTypeIdentifier>http://uri.etsi.org/TrstSvc/Svctype/CA/QC</ServiceTypeIdentifier>
+                            <ServiceName>
+                                <Name xml:lang="en">xades4go test qualified CA</Name>
+                            </ServiceName>
+                            <ServiceStatus>http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/undersupervision</ServiceStatus>
+                            <StatusStartingTime>2012-01-01T00:00:00Z</StatusStartingTime>
+                        </ServiceHistoryInstance>
+                    </ServiceHistory>
+                </TSPService>
+                <TSPService>
+                    <ServiceInformation>
+                        <ServiceTypeIdentifier>http://uri.etsi.org/TrstSvc/Svctype/TSA/QTST</ServiceTypeIdentifier>
+                        <ServiceName>
+                            <Name xml:lang="en">xades4go test qualified TSA</Name>
+                        </ServiceName>
+                        <ServiceDigitalIdentity>
+                            <DigitalId>
+                                <X509Certificate>%[3]s</X509Certificate>
+                            </DigitalId>
+                        </ServiceDigitalIdentity>
+                        <ServiceStatus>http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/granted</ServiceStatus>
+                        <StatusStartingTime>2018-01-01T00:00:00Z</StatusStartingTime>
+                    </ServiceInformation>
+                </TSPService>
+            </TSPServices>
+        </TrustServiceProvider>
+    </TrustServiceProviderList>
+</TrustServiceStatusList>`
+
+func Test_Parse(t *testing.T) {
+	signedInfoFactory := etreeimpl.NewSignedInfoFactory()
+	signerKey, signerCertificate := mustCreateKeyAndCertificate(t, "xades4go test scheme operator")
+	_, otherSignerCertificate := mustCreateKeyAndCertificate(t, "xades4go test other scheme operator")
+	_, lotlSignerCertificate := mustCreateKeyAndCertificate(t, "xades4go test LOTL signer")
+	_, caCertificate := mustCreateKeyAndCertificate(t, "xades4go test qualified CA")
+	_, tsaCertificate := mustCreateKeyAndCertificate(t, "xades4go test qualified TSA")
+	unsignedList := fmt.Sprintf(testTrustedList, base64.StdEncoding.EncodeToString(lotlSignerCertificate.Raw), base64.StdEncoding.EncodeToString(caCertificate.Raw), base64.StdEncoding.EncodeToString(tsaCertificate.Raw))
+	generator, err := xades4go.NewXMLDSigSignatureGenerator(signedInfoFactory, signerKey, []*x509.Certificate{signerCertificate}, xades4go.ECDSASHA256SignatureAlgorithm)
+	if err != nil {
+		t.Fatalf("NewXMLDSigSignatureGenerator() error = %v", err)
+	}
+	mustSignList := func(list string, transformAlgorithms ...string) []byte {
+		signedList, err := generator.SignXMLBytes([]byte(list), []xades4go.ReferenceGenerationDetail{
+			{
+				URIOfDataObjectBeingSigned: "",
+				TransformAlgorithms:        transformAlgorithms,
+				DigestAlgorithm:            xades4go.SHA256MessageDigestAlgorithm,
+			},
+		})
+		if err != nil {
+			t.Fatalf("SignXMLBytes() error = %v", err)
+		}
+		return signedList
+	}
+	mustSign := func(transformAlgorithms ...string) []byte {
+		return mustSignList(unsignedList, transformAlgorithms...)
+	}
+	signedList := mustSign(xades4go.EnvelopedSignatureTransformAlgorithm, xades4go.CanonicalXML10Algorithm)
+	validator := xades4go.NewXMLDSigSignatureValidator(signedInfoFactory)
+
+	tests := []struct {
+		name               string
+		xmlBytes           []byte
+		signerCertificates []*x509.Certificate
+		wantErr            bool
+	}{
+		{
+			name:               "When Trusted List is signed by an announced signer, it should be parsed",
+			xmlBytes:           signedList,
+			signerCertificates: []*x509.Certificate{otherSignerCertificate, signerCertificate},
+		},
+		{
+			name:               "When Trusted List is signed by a certificate that is not announced, it should return error",
+			xmlBytes:           signedList,
+			signerCertificates: []*x509.Certificate{otherSignerCertificate},
+			wantErr:            true,
+		},
+		{
+			name:               "When Trusted List is altered after being signed, it should return error",
+			xmlBytes:           []byte(strings.Replace(string(signedList), "Svcstatus/withdrawn", "Svcstatus/granted", 1)),
+			signerCertificates: []*x509.Certificate{signerCertificate},
+			wantErr:            true,
+		},
+		{
+			name:               "When the Reference covering Trusted List has no canonicalization transform, it should return error",
+			xmlBytes:           mustSign(xades4go.EnvelopedSignatureTransformAlgorithm),
+			signerCertificates: []*x509.Certificate{signerCertificate},
+			wantErr:            true,
+		},
+		{
+			name:               "When the Reference covering Trusted List has more transforms than enveloped signature and canonicalization, it should return error",
+			xmlBytes:           mustSign(xades4go.EnvelopedSignatureTransformAlgorithm, xades4go.CanonicalXML10Algorithm, xades4go.CanonicalXML10Algorithm),
+			signerCertificates: []*x509.Certificate{signerCertificate},
+			wantErr:            true,
+		},
+		{
+			name:               "When TrustServiceStatusList element is not in the namespace of ETSI TS 119 612, it should return error",
+			xmlBytes:           mustSignList(strings.Replace(unsignedList, `xmlns="http://uri.etsi.org/02231/v2#"`, `xmlns="http://example.com/not-a-trusted-list#"`, 1), xades4go.EnvelopedSignatureTransformAlgorithm, xades4go.CanonicalXML10Algorithm),
+			signerCertificates: []*x509.Certificate{signerCertificate},
+			wantErr:            true,
+		},
+		{
+			name:               "When Trusted List is not signed, it should return error",
+			xmlBytes:           []byte(unsignedList),
+			signerCertificates: []*x509.Certificate{signerCertificate},
+			wantErr:            true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := trustedlist.Parse(tt.xmlBytes, validator, tt.signerCertificates)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !got.SignerCertificate.Equal(signerCertificate) {
+				t.Errorf("Parse() SignerCertificate = %s, want %s", got.SignerCertificate.Subject, signerCertificate.Subject)
+			}
+			gotSummary := fmt.Sprintf("%d %s %s %s %s-%s", got.SequenceNumber, got.Type, got.SchemeOperatorName, got.SchemeTerritory, got.IssueDate.Format("2006-01-02"), got.NextUpdate.Format("2006-01-02"))
+			wantSummary := "42 http://uri.etsi.org/TrstSvc/TrustedList/TSLType/EUgeneric xades4go test scheme operator TH 2021-01-15-2021-07-15"
+			if gotSummary != wantSummary {
+				t.Errorf("Parse() scheme information = %q, want %q", gotSummary, wantSummary)
+			}
+			if len(got.Pointers) != 1 || got.Pointers[0].Location != "https://tl.example.com/eu-lotl.xml" || got.Pointers[0].SchemeTerritory != "EU" ||
+				got.Pointers[0].MimeType != "application/vnd.etsi.tsl+xml" || len(got.Pointers[0].Certificates) != 1 || !got.Pointers[0].Certificates[0].Equal(lotlSignerCertificate) {
+				t.Errorf("Parse() Pointers = %+v", got.Pointers)
+			}
+			if len(got.TrustServiceProviders) != 1 || len(got.TrustServiceProviders[0].Services) != 2 {
+				t.Fatalf("Parse() TrustServiceProviders = %+v", got.TrustServiceProviders)
+			}
+			if got.TrustServiceProviders[0].Name != "xades4go test provider" {
+				t.Errorf("Parse() TrustServiceProvider.Name = %q", got.TrustServiceProviders[0].Name)
+			}
+			caService := got.TrustServiceProviders[0].Services[0]
+			if len(caService.Certificates) != 1 || !caService.Certificates[0].Equal(caCertificate) || len(caService.History) != 2 {
+				t.Errorf("Parse() TrustService = %+v", caService)
+			}
+		})
+	}
+}
+
+func Test_TrustedList_TrustAnchors(t *testing.T) {
+	signedInfoFactory := etreeimpl.NewSignedInfoFactory()
+	signerKey, signerCertificate := mustCreateKeyAndCertificate(t, "xades4go test scheme operator")
+	_, caCertificate := mustCreateKeyAndCertificate(t, "xades4go test qualified CA")
+	_, tsaCertificate := mustCreateKeyAndCertificate(t, "xades4go test qualified TSA")
+	unsignedList := fmt.Sprintf(testTrustedList, base64.StdEncoding.EncodeToString(signerCertificate.Raw), base64.StdEncoding.EncodeToString(caCertificate.Raw), base64.StdEncoding.EncodeToString(tsaCertificate.Raw))
+	generator, err := xades4go.NewXMLDSigSignatureGenerator(signedInfoFactory, signerKey, []*x509.Certificate{signerCertificate}, xades4go.ECDSASHA256SignatureAlgorithm)
+	if err != nil {
+		t.Fatalf("NewXMLDSigSignatureGenerator() error = %v", err)
+	}
+	signedList, err := generator.SignXMLBytes([]byte(unsignedList), []xades4go.ReferenceGenerationDetail{
+		{
+			URIOfDataObjectBeingSigned: "#tsl",
+			TransformAlgorithms:        []string{xades4go.EnvelopedSignatureTransformAlgorithm, xades4go.CanonicalXML10Algorithm},
+			DigestAlgorithm:            xades4go.SHA256MessageDigestAlgorithm,
+		},
+	})
+	if err != nil {
+		t.Fatalf("SignXMLBytes() error = %v", err)
+	}
+	list, err := trustedlist.Parse(signedList, xades4go.NewXMLDSigSignatureValidator(signedInfoFactory), []*x509.Certificate{signerCertificate})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	tests := []struct {
+		name            string
+		at              time.Time
+		serviceTypes    []string
+		wantCAStatus    string
+		wantAnchorNames []string
+	}{
+		{
+			name:            "When the CA was under supervision before eIDAS, it should be a trust anchor",
+			at:              time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC),
+			wantCAStatus:    trustedlist.ServiceStatusUnderSupervision,
+			wantAnchorNames: []string{"xades4go test qualified CA"},
+		},
+		{
+			name:            "When the CA is granted and the TSA is granted, both should be trust anchors",
+			at:              time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+			wantCAStatus:    trustedlist.ServiceStatusGranted,
+			wantAnchorNames: []string{"xades4go test qualified CA", "xades4go test qualified TSA"},
+		},
+		{
+			name:            "When only CA/QC services are selected, the TSA should not be a trust anchor",
+			at:              time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+			serviceTypes:    []string{trustedlist.ServiceTypeCAQC},
+			wantCAStatus:    trustedlist.ServiceStatusGranted,
+			wantAnchorNames: []string{"xades4go test qualified CA"},
+		},
+		{
+			name:            "When the CA is withdrawn, it should not be a trust anchor",
+			at:              time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+			wantCAStatus:    trustedlist.ServiceStatusWithdrawn,
+			wantAnchorNames: []string{"xades4go test qualified TSA"},
+		},
+		{
+			name:         "When no service existed yet, there should be no trust anchor",
+			at:           time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC),
+			wantCAStatus: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotCAStatus := ""
+			if instance, isFound := list.TrustServiceProviders[0].Services[0].StatusAt(tt.at); isFound {
+				gotCAStatus = instance.Status
+			}
+			if gotCAStatus != tt.wantCAStatus {
+				t.Errorf("StatusAt() Status = %q, want %q", gotCAStatus, tt.wantCAStatus)
+			}
+			trustAnchors, err := list.TrustStore(tt.at, tt.serviceTypes...).TrustAnchors()
+			if err != nil {
+				t.Fatalf("TrustAnchors() error = %v", err)
+			}
+			var gotAnchorNames []string
+			for _, trustAnchor := range trustAnchors {
+				gotAnchorNames = append(gotAnchorNames, trustAnchor.Subject.CommonName)
+			}
+			if diff := cmp.Diff(tt.wantAnchorNames, gotAnchorNames); diff != "" {
+				t.Errorf("TrustAnchors() mismatch (-want+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func mustCreateKeyAndCertificate(t *testing.T, commonName string) (*ecdsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("cannot generate ECDSA key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	asn1Certificate, err := x509.CreateCertificate(rand.Reader, template, template, privateKey.Public(), privateKey)
+	if err != nil {
+		t.Fatalf("cannot create certificate: %v", err)
+	}
+	certificate, err := x509.ParseCertificate(asn1Certificate)
+	if err != nil {
+		t.Fatalf("cannot parse certificate: %v", err)
+	}
+	return privateKey, certificate
+}
@@ -0,0 +1,87 @@
+package xades4go
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// URIResolver is an object that resolves URI of a data object which is not a part of the XML being signed or validated (a file name, cid: or http: URI for example) to octet-stream.
+// It is consulted by SignatureGenerator and SignatureValidator for every Reference whose URI is neither empty nor a same-document fragment (#id).
+type URIResolver interface {
+	Resolve(uri string) ([]byte, error)
+}
+
+type fileSystemURIResolver struct {
+	baseDirectory string
+}
+
+// NewFileSystemURIResolver creates URIResolver that reads relative paths and relative file: URIs (file:attachments/invoice.pdf) from baseDirectory.
+// Absolute URIs and any URI that points outside baseDirectory, also through a symbolic link, are rejected.
+func NewFileSystemURIResolver(baseDirectory string) URIResolver {
+	return &fileSystemURIResolver{baseDirectory: baseDirectory}
+}
+
+func (resolver *fileSystemURIResolver) Resolve(uri string) ([]byte, error) {
+	parsedURI, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse URI %s: %w", uri, err)
+	}
+	if parsedURI.Scheme != "" && parsedURI.Scheme != "file" {
+		return nil, fmt.Errorf("%s scheme cannot be resolved from file system", parsedURI.Scheme)
+	}
+	uriPath := parsedURI.Path
+	if parsedURI.Opaque != "" {
+		uriPath, err = url.PathUnescape(parsedURI.Opaque)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse URI %s: %w", uri, err)
+		}
+	}
+	if parsedURI.Host != "" || path.IsAbs(uriPath) || filepath.IsAbs(filepath.FromSlash(uriPath)) {
+		return nil, fmt.Errorf("URI %s is absolute, only a path relative to the base directory can be resolved", uri)
+	}
+	baseDirectory, err := filepath.Abs(resolver.baseDirectory)
+	if err == nil {
+		baseDirectory, err = filepath.EvalSymlinks(baseDirectory)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve the base directory: %w", err)
+	}
+	filePath, err := filepath.EvalSymlinks(filepath.Join(baseDirectory, filepath.FromSlash(uriPath)))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read data object of URI %s: %w", uri, err)
+	}
+	relativePath, err := filepath.Rel(baseDirectory, filePath)
+	if err != nil || relativePath == ".." || strings.HasPrefix(relativePath, ".."+string(filepath.Separator)) {
+		return nil, fmt.Errorf("URI %s points outside the base directory", uri)
+	}
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read data object of URI %s: %w", uri, err)
+	}
+	return content, nil
+}
+
+type mapURIResolver struct {
+	contents map[string][]byte
+}
+
+// NewMapURIResolver creates URIResolver that resolves URI by exact match to the keys of contents.
+func NewMapURIResolver(contents map[string][]byte) URIResolver {
+	return &mapURIResolver{contents: contents}
+}
+
+func (resolver *mapURIResolver) Resolve(uri string) ([]byte, error) {
+	content, ok := resolver.contents[uri]
+	if !ok {
+		return nil, fmt.Errorf("no data object for URI %s", uri)
+	}
+	return content, nil
+}
+
+func isSameDocumentURI(uri string) bool {
+	return uri == "" || strings.HasPrefix(uri, "#")
+}
@@ -0,0 +1,101 @@
+package xades4go_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mekpavit/xades4go"
+)
+
+func Test_FileSystemURIResolver(t *testing.T) {
+	baseDirectory, err := ioutil.TempDir("", "xades4go")
+	if err != nil {
+		t.Fatalf("cannot create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(baseDirectory)
+	err = os.MkdirAll(filepath.Join(baseDirectory, "attachments"), 0700)
+	if err != nil {
+		t.Fatalf("cannot create directory: %v", err)
+	}
+	err = ioutil.WriteFile(filepath.Join(baseDirectory, "attachments", "invoice 01.pdf"), []byte("%PDF-1.4"), 0600)
+	if err != nil {
+		t.Fatalf("cannot write file: %v", err)
+	}
+	outsideDirectory, err := ioutil.TempDir("", "xades4go")
+	if err != nil {
+		t.Fatalf("cannot create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(outsideDirectory)
+	err = ioutil.WriteFile(filepath.Join(outsideDirectory, "secret.pdf"), []byte("secret"), 0600)
+	if err != nil {
+		t.Fatalf("cannot write file: %v", err)
+	}
+	err = os.Symlink(filepath.Join(outsideDirectory, "secret.pdf"), filepath.Join(baseDirectory, "attachments", "link.pdf"))
+	if err != nil {
+		t.Fatalf("cannot create symbolic link: %v", err)
+	}
+	tests := []struct {
+		name    string
+		uri     string
+		want    []byte
+		wantErr bool
+	}{
+		{
+			name:    "When relative path is given, it should read the file from base directory",
+			uri:     "attachments/invoice%2001.pdf",
+			want:    []byte("%PDF-1.4"),
+			wantErr: false,
+		},
+		{
+			name:    "When relative file URI is given, it should read the file from base directory",
+			uri:     "file:attachments/invoice%2001.pdf",
+			want:    []byte("%PDF-1.4"),
+			wantErr: false,
+		},
+		{
+			name:    "When absolute file URI is given, it should return error",
+			uri:     "file:///attachments/invoice%2001.pdf",
+			wantErr: true,
+		},
+		{
+			name:    "When absolute path is given, it should return error",
+			uri:     filepath.ToSlash(filepath.Join(outsideDirectory, "secret.pdf")),
+			wantErr: true,
+		},
+		{
+			name:    "When the path is a symbolic link to a file outside base directory, it should return error",
+			uri:     "attachments/link.pdf",
+			wantErr: true,
+		},
+		{
+			name:    "When the path points outside base directory, it should return error",
+			uri:     "../attachments/invoice%2001.pdf",
+			wantErr: true,
+		},
+		{
+			name:    "When non-file scheme is given, it should return error",
+			uri:     "http://example.com/invoice.pdf",
+			wantErr: true,
+		},
+		{
+			name:    "When the file does not exist, it should return error",
+			uri:     "attachments/not-exist.pdf",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := xades4go.NewFileSystemURIResolver(baseDirectory).Resolve(tt.uri)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Resolve() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Resolve() result mismatch (-want+got):\n%s", diff)
+			}
+		})
+	}
+}
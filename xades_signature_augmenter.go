@@ -0,0 +1,437 @@
+package xades4go
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+const (
+	encapsulatedX509CertificateElementTag = "EncapsulatedX509Certificate"
+	crlValuesElementTag                   = "CRLValues"
+	encapsulatedCRLValueElementTag        = "EncapsulatedCRLValue"
+	ocspValuesElementTag                  = "OCSPValues"
+	encapsulatedOCSPValueElementTag       = "EncapsulatedOCSPValue"
+)
+
+// XAdESSignatureAugmenter augments XAdES signatures to baseline profile B-T (SignatureTimeStamp), B-LT (CertificateValues and RevocationValues) and B-LTA (ArchiveTimeStamp) of ETSI EN 319 132-1.
+// The signature is validated like XMLDSigSignatureValidator does before it is augmented, and an invalid signature is never augmented.
+type XAdESSignatureAugmenter struct {
+	xmldsigSignatureValidator *XMLDSigSignatureValidator
+	digestAlgorithm           string
+	timeStampProvider         TimeStampProvider
+	revocationDataProvider    RevocationDataProvider
+	certificates              []*x509.Certificate
+}
+
+// XAdESSignatureAugmenterOption configures optional behavior of XAdESSignatureAugmenter.
+type XAdESSignatureAugmenterOption func(augmenter *XAdESSignatureAugmenter)
+
+// AugmentWithXMLDSigOptions applies XMLDSigSignatureValidatorOption to the validation of the signature before it is augmented.
+func AugmentWithXMLDSigOptions(options ...XMLDSigSignatureValidatorOption) XAdESSignatureAugmenterOption {
+	return func(augmenter *XAdESSignatureAugmenter) {
+		for _, option := range options {
+			option(augmenter.xmldsigSignatureValidator)
+		}
+	}
+}
+
+// AugmentWithDigestAlgorithm sets the digest algorithm of the data sent to TimeStampProvider. The default is SHA-256.
+func AugmentWithDigestAlgorithm(digestAlgorithm string) XAdESSignatureAugmenterOption {
+	return func(augmenter *XAdESSignatureAugmenter) {
+		augmenter.digestAlgorithm = digestAlgorithm
+	}
+}
+
+// AugmentWithTimeStampProvider sets the provider of SignatureTimeStamp and ArchiveTimeStamp. It is required to add either of them.
+func AugmentWithTimeStampProvider(timeStampProvider TimeStampProvider) XAdESSignatureAugmenterOption {
+	return func(augmenter *XAdESSignatureAugmenter) {
+		augmenter.timeStampProvider = timeStampProvider
+	}
+}
+
+// AugmentWithRevocationDataProvider sets the provider of the revocation data put in RevocationValues element. It is required to add RevocationValues element.
+func AugmentWithRevocationDataProvider(revocationDataProvider RevocationDataProvider) XAdESSignatureAugmenterOption {
+	return func(augmenter *XAdESSignatureAugmenter) {
+		augmenter.revocationDataProvider = revocationDataProvider
+	}
+}
+
+// AugmentWithCertificates adds certificates, such as intermediate and root CA certificates, that the signature does not carry to CertificateValues element.
+// The certificates already in KeyInfo element are not added again.
+func AugmentWithCertificates(certificates ...*x509.Certificate) XAdESSignatureAugmenterOption {
+	return func(augmenter *XAdESSignatureAugmenter) {
+		augmenter.certificates = append(augmenter.certificates, certificates...)
+	}
+}
+
+func NewXAdESSignatureAugmenter(signedInfoFactory SignedInfoFactory, options ...XAdESSignatureAugmenterOption) SignatureAugmenter {
+	augmenter := &XAdESSignatureAugmenter{
+		xmldsigSignatureValidator: newXMLDSigSignatureValidator(signedInfoFactory),
+		digestAlgorithm:           SHA256MessageDigestAlgorithm,
+	}
+	for _, option := range options {
+		option(augmenter)
+	}
+	return augmenter
+}
+
+// Augment adds SignatureTimeStamp element if baselineProfile is BaselineProfileT or above, CertificateValues and RevocationValues elements if it is BaselineProfileLT or above
+// and ArchiveTimeStamp element if it is BaselineProfileLTA. A property that the signature already carries is not added again.
+// CertificateValues element carries the certificates that KeyInfo element does not, if any, and RevocationValues element must not be empty.
+func (augmenter *XAdESSignatureAugmenter) Augment(xmlBytes []byte, baselineProfile BaselineProfile) ([]byte, error) {
+	if baselineProfile < BaselineProfileB || baselineProfile > BaselineProfileLTA {
+		return nil, fmt.Errorf("%s is not a baseline profile", baselineProfile)
+	}
+	doc, signatureElement, err := augmenter.parseValidSignature(xmlBytes)
+	if err != nil {
+		return nil, err
+	}
+	err = augmenter.augmentSignatureElement(doc, signatureElement, baselineProfile)
+	if err != nil {
+		return nil, err
+	}
+	return doc.WriteToBytes()
+}
+
+// RenewArchiveTimeStamp augments the signature of xmlBytes to BaselineProfileLTA if needed and appends a new ArchiveTimeStamp element over it,
+// preceded by TimeStampValidationData element with the certificates and revocation data needed to validate the earlier time-stamps.
+func (augmenter *XAdESSignatureAugmenter) RenewArchiveTimeStamp(xmlBytes []byte) ([]byte, error) {
+	doc, signatureElement, err := augmenter.parseValidSignature(xmlBytes)
+	if err != nil {
+		return nil, err
+	}
+	qualifyingPropertiesElement, err := findQualifyingPropertiesElement(signatureElement)
+	if err != nil {
+		return nil, err
+	}
+	if len(findUnsignedSignaturePropertyElements(qualifyingPropertiesElement, archiveTimeStampElementTag)) == 0 {
+		err = augmenter.augmentSignatureElement(doc, signatureElement, BaselineProfileLTA)
+		if err != nil {
+			return nil, err
+		}
+		return doc.WriteToBytes()
+	}
+	err = augmenter.addTimeStampValidationData(signatureElement, qualifyingPropertiesElement)
+	if err != nil {
+		return nil, err
+	}
+	err = augmenter.addArchiveTimeStamp(doc, signatureElement)
+	if err != nil {
+		return nil, err
+	}
+	return doc.WriteToBytes()
+}
+
+// parseValidSignature parses xmlBytes and returns it with its only Signature element, which must have valid References and SignatureValue.
+func (augmenter *XAdESSignatureAugmenter) parseValidSignature(xmlBytes []byte) (*etree.Document, *etree.Element, error) {
+	doc := etree.NewDocument()
+	err := doc.ReadFromBytes(xmlBytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot parse xmlBytes to etree's element: %w", err)
+	}
+	signatureElement, err := mustFoundOnlyOneSignatureElement(doc.Root())
+	if err != nil {
+		return nil, nil, err
+	}
+	xmldsigResult, err := augmenter.xmldsigSignatureValidator.validateSignatureElement(xmlBytes, signatureElement)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !xmldsigResult.IsSignatureValid {
+		return nil, nil, errors.New("cannot augment signature whose SignatureValue is invalid")
+	}
+	for referenceIndex, referenceValidationResult := range xmldsigResult.ReferenceValidationResults {
+		if !referenceValidationResult.IsValid {
+			return nil, nil, fmt.Errorf("cannot augment signature whose Reference#%d is invalid", referenceIndex)
+		}
+	}
+	return doc, signatureElement, nil
+}
+
+func (augmenter *XAdESSignatureAugmenter) augmentSignatureElement(doc *etree.Document, signatureElement *etree.Element, baselineProfile BaselineProfile) error {
+	qualifyingPropertiesElement, err := findQualifyingPropertiesElement(signatureElement)
+	if err != nil {
+		return err
+	}
+	hasUnsignedSignatureProperty := func(tag string) bool {
+		return len(findUnsignedSignaturePropertyElements(qualifyingPropertiesElement, tag)) > 0
+	}
+
+	if baselineProfile >= BaselineProfileT && !hasUnsignedSignatureProperty(signatureTimeStampElementTag) {
+		if augmenter.timeStampProvider == nil {
+			return fmt.Errorf("cannot augment signature to %s without TimeStampProvider", baselineProfile)
+		}
+		canonicalizationAlgorithm, err := canonicalizationAlgorithmOfSignedInfo(signatureElement)
+		if err != nil {
+			return err
+		}
+		err = addSignatureTimeStamp(doc, signatureElement, augmenter.xmldsigSignatureValidator.signedInfoFactory, canonicalizationAlgorithm, augmenter.digestAlgorithm, augmenter.timeStampProvider)
+		if err != nil {
+			return err
+		}
+	}
+
+	if baselineProfile >= BaselineProfileLT {
+		keyInfoCertificates, err := keyInfoCertificatesOf(signatureElement)
+		if err != nil {
+			return err
+		}
+		certificates, err := augmenter.collectCertificates(keyInfoCertificates, qualifyingPropertiesElement)
+		if err != nil {
+			return err
+		}
+		var crls, ocspResponses [][]byte
+		if !hasUnsignedSignatureProperty(revocationValuesElementTag) {
+			if augmenter.revocationDataProvider == nil {
+				return fmt.Errorf("cannot augment signature to %s without RevocationDataProvider", baselineProfile)
+			}
+			crls, ocspResponses, err = augmenter.revocationDataOf(certificates)
+			if err != nil {
+				return err
+			}
+			if len(crls) == 0 && len(ocspResponses) == 0 {
+				return fmt.Errorf("cannot augment signature to %s because RevocationDataProvider returned no revocation data", baselineProfile)
+			}
+		}
+		if !hasUnsignedSignatureProperty(certificateValuesElementTag) {
+			missingCertificates := make([]*x509.Certificate, 0)
+			for _, certificate := range certificates {
+				if !containsCertificate(keyInfoCertificates, certificate) {
+					missingCertificates = append(missingCertificates, certificate)
+				}
+			}
+			// CertificateValues element is only needed if KeyInfo element does not carry every certificate.
+			if len(missingCertificates) > 0 {
+				createCertificateValuesElement(unsignedSignaturePropertiesElementOf(qualifyingPropertiesElement), qualifyingPropertiesElement, missingCertificates)
+			}
+		}
+		if !hasUnsignedSignatureProperty(revocationValuesElementTag) {
+			createRevocationValuesElement(unsignedSignaturePropertiesElementOf(qualifyingPropertiesElement), qualifyingPropertiesElement, crls, ocspResponses)
+		}
+	}
+
+	if baselineProfile >= BaselineProfileLTA && !hasUnsignedSignatureProperty(archiveTimeStampElementTag) {
+		return augmenter.addArchiveTimeStamp(doc, signatureElement)
+	}
+	return nil
+}
+
+func (augmenter *XAdESSignatureAugmenter) addArchiveTimeStamp(doc *etree.Document, signatureElement *etree.Element) error {
+	if augmenter.timeStampProvider == nil {
+		return errors.New("cannot add ArchiveTimeStamp without TimeStampProvider")
+	}
+	canonicalizationAlgorithm, err := canonicalizationAlgorithmOfSignedInfo(signatureElement)
+	if err != nil {
+		return err
+	}
+	return addArchiveTimeStamp(doc, signatureElement, augmenter.xmldsigSignatureValidator.signedInfoFactory, augmenter.xmldsigSignatureValidator.uriResolver, canonicalizationAlgorithm, augmenter.digestAlgorithm, augmenter.timeStampProvider)
+}
+
+// addTimeStampValidationData appends TimeStampValidationData element with the certificates of the time-stamp tokens that the signature does not carry yet
+// and the revocation data of the certificates of ArchiveTimeStamp tokens. Nothing is appended if there is neither.
+func (augmenter *XAdESSignatureAugmenter) addTimeStampValidationData(signatureElement *etree.Element, qualifyingPropertiesElement *etree.Element) error {
+	embeddedCertificates, err := keyInfoCertificatesOf(signatureElement)
+	if err != nil {
+		return err
+	}
+	for _, certificateValuesElement := range qualifyingPropertiesElement.FindElements(".//" + certificateValuesElementTag) {
+		certificates, err := parseEncapsulatedX509Certificates(certificateValuesElement)
+		if err != nil {
+			return err
+		}
+		embeddedCertificates = append(embeddedCertificates, certificates...)
+	}
+	timeStampCertificates, err := certificatesOfTimeStampElements(qualifyingPropertiesElement.FindElements(".//" + encapsulatedTimeStampElementTag))
+	if err != nil {
+		return err
+	}
+	missingCertificates := make([]*x509.Certificate, 0)
+	for _, certificate := range timeStampCertificates {
+		if !containsCertificate(embeddedCertificates, certificate) {
+			missingCertificates = append(missingCertificates, certificate)
+		}
+	}
+	archiveTimeStampCertificates := make([]*x509.Certificate, 0)
+	for _, archiveTimeStampElement := range findUnsignedSignaturePropertyElements(qualifyingPropertiesElement, archiveTimeStampElementTag) {
+		certificates, err := certificatesOfTimeStampElements(archiveTimeStampElement.SelectElements(encapsulatedTimeStampElementTag))
+		if err != nil {
+			return err
+		}
+		archiveTimeStampCertificates = append(archiveTimeStampCertificates, certificates...)
+	}
+	crls, ocspResponses, err := augmenter.revocationDataOf(archiveTimeStampCertificates)
+	if err != nil {
+		return err
+	}
+	if len(missingCertificates) == 0 && len(crls) == 0 && len(ocspResponses) == 0 {
+		return nil
+	}
+	timeStampValidationDataElement := unsignedSignaturePropertiesElementOf(qualifyingPropertiesElement).CreateElement(xades141NamespacePrefix + ":" + timeStampValidationDataElementTag)
+	timeStampValidationDataElement.CreateAttr("xmlns:"+xades141NamespacePrefix, xades141NamespaceURI)
+	if len(missingCertificates) > 0 {
+		createCertificateValuesElement(timeStampValidationDataElement, qualifyingPropertiesElement, missingCertificates)
+	}
+	if len(crls) > 0 || len(ocspResponses) > 0 {
+		createRevocationValuesElement(timeStampValidationDataElement, qualifyingPropertiesElement, crls, ocspResponses)
+	}
+	return nil
+}
+
+// collectCertificates returns, without duplicates, keyInfoCertificates, the certificates carried by the time-stamp tokens of qualifyingPropertiesElement
+// and the certificates given by AugmentWithCertificates.
+func (augmenter *XAdESSignatureAugmenter) collectCertificates(keyInfoCertificates []*x509.Certificate, qualifyingPropertiesElement *etree.Element) ([]*x509.Certificate, error) {
+	timeStampCertificates, err := certificatesOfTimeStampElements(qualifyingPropertiesElement.FindElements(".//" + encapsulatedTimeStampElementTag))
+	if err != nil {
+		return nil, err
+	}
+	certificates := make([]*x509.Certificate, 0)
+	for _, certificate := range append(append(keyInfoCertificates, timeStampCertificates...), augmenter.certificates...) {
+		if !containsCertificate(certificates, certificate) {
+			certificates = append(certificates, certificate)
+		}
+	}
+	return certificates, nil
+}
+
+// revocationDataOf returns the revocation data of certificates from RevocationDataProvider, or nothing if it is not given.
+func (augmenter *XAdESSignatureAugmenter) revocationDataOf(certificates []*x509.Certificate) ([][]byte, [][]byte, error) {
+	if augmenter.revocationDataProvider == nil || len(certificates) == 0 {
+		return nil, nil, nil
+	}
+	crls, ocspResponses, err := augmenter.revocationDataProvider.RevocationData(certificates)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot obtain revocation data: %w", err)
+	}
+	return crls, ocspResponses, nil
+}
+
+// canonicalizationAlgorithmOfSignedInfo returns the Algorithm of CanonicalizationMethod element of SignedInfo element of signatureElement.
+func canonicalizationAlgorithmOfSignedInfo(signatureElement *etree.Element) (string, error) {
+	signedInfoElement, err := mustFoundOnlyOneChildElement(signatureElement, signedInfoElementTag)
+	if err != nil {
+		return "", err
+	}
+	canonicalizationMethodElement, err := mustFoundOnlyOneChildElement(signedInfoElement, canonicalizationMethodElementTag)
+	if err != nil {
+		return "", err
+	}
+	algorithmAttribute, err := mustFoundAttribute(canonicalizationMethodElement, algorithmAttributeKey)
+	if err != nil {
+		return "", err
+	}
+	return algorithmAttribute.Value, nil
+}
+
+// keyInfoCertificatesOf returns the certificates of KeyInfo element of signatureElement, if any.
+func keyInfoCertificatesOf(signatureElement *etree.Element) ([]*x509.Certificate, error) {
+	keyInfoElement, err := mustFoundOnlyOneIfFound(signatureElement, keyInfoElementTag)
+	if err != nil {
+		return nil, err
+	}
+	if keyInfoElement == nil {
+		return make([]*x509.Certificate, 0), nil
+	}
+	return parseX509CertificatesOfKeyInfoElement(keyInfoElement)
+}
+
+// certificatesOfTimeStampElements returns the certificates carried by the time-stamp tokens of encapsulatedTimeStampElements.
+func certificatesOfTimeStampElements(encapsulatedTimeStampElements []*etree.Element) ([]*x509.Certificate, error) {
+	certificates := make([]*x509.Certificate, 0)
+	for encapsulatedTimeStampIndex, encapsulatedTimeStampElement := range encapsulatedTimeStampElements {
+		tokenBytes, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(encapsulatedTimeStampElement.Text()), ""))
+		if err != nil {
+			return nil, fmt.Errorf("at EncapsulatedTimeStamp#%d element: cannot base64-decode time-stamp token: %w", encapsulatedTimeStampIndex, err)
+		}
+		token, err := parseTimeStampToken(tokenBytes)
+		if err != nil {
+			return nil, fmt.Errorf("at EncapsulatedTimeStamp#%d element: %w", encapsulatedTimeStampIndex, err)
+		}
+		tokenCertificates, err := x509.ParseCertificates(token.signedData.Certificates.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("at EncapsulatedTimeStamp#%d element: cannot parse certificates of time-stamp token: %w", encapsulatedTimeStampIndex, err)
+		}
+		certificates = append(certificates, tokenCertificates...)
+	}
+	return certificates, nil
+}
+
+// parseEncapsulatedX509Certificates parses EncapsulatedX509Certificate elements of certificateValuesElement.
+func parseEncapsulatedX509Certificates(certificateValuesElement *etree.Element) ([]*x509.Certificate, error) {
+	certificates := make([]*x509.Certificate, 0)
+	for certificateIndex, encapsulatedX509CertificateElement := range certificateValuesElement.SelectElements(encapsulatedX509CertificateElementTag) {
+		asn1Certificate, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(encapsulatedX509CertificateElement.Text()), ""))
+		if err != nil {
+			return nil, fmt.Errorf("at EncapsulatedX509Certificate#%d element: cannot base64-decode certificate: %w", certificateIndex, err)
+		}
+		certificate, err := x509.ParseCertificate(asn1Certificate)
+		if err != nil {
+			return nil, fmt.Errorf("at EncapsulatedX509Certificate#%d element: cannot parse certificate: %w", certificateIndex, err)
+		}
+		certificates = append(certificates, certificate)
+	}
+	return certificates, nil
+}
+
+// parseEncapsulatedRevocationValues returns the DER-encoded CRLs of EncapsulatedCRLValue elements and OCSP responses of EncapsulatedOCSPValue elements of revocationValuesElement.
+func parseEncapsulatedRevocationValues(revocationValuesElement *etree.Element) ([][]byte, [][]byte, error) {
+	decode := func(path string) ([][]byte, error) {
+		values := make([][]byte, 0)
+		for valueIndex, valueElement := range revocationValuesElement.FindElements(path) {
+			value, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(valueElement.Text()), ""))
+			if err != nil {
+				return nil, fmt.Errorf("at %s#%d element: cannot base64-decode value: %w", valueElement.Tag, valueIndex, err)
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	}
+	crls, err := decode("./" + crlValuesElementTag + "/" + encapsulatedCRLValueElementTag)
+	if err != nil {
+		return nil, nil, err
+	}
+	ocspResponses, err := decode("./" + ocspValuesElementTag + "/" + encapsulatedOCSPValueElementTag)
+	if err != nil {
+		return nil, nil, err
+	}
+	return crls, ocspResponses, nil
+}
+
+func containsCertificate(certificates []*x509.Certificate, certificate *x509.Certificate) bool {
+	for _, contained := range certificates {
+		if bytes.Equal(contained.Raw, certificate.Raw) {
+			return true
+		}
+	}
+	return false
+}
+
+// createCertificateValuesElement appends CertificateValues element with certificates to parent.
+func createCertificateValuesElement(parent *etree.Element, qualifyingPropertiesElement *etree.Element, certificates []*x509.Certificate) {
+	certificateValuesElement := createElementInNamespaceOf(parent, qualifyingPropertiesElement, certificateValuesElementTag)
+	for _, certificate := range certificates {
+		createElementInNamespaceOf(certificateValuesElement, qualifyingPropertiesElement, encapsulatedX509CertificateElementTag).SetText(base64.StdEncoding.EncodeToString(certificate.Raw))
+	}
+}
+
+// createRevocationValuesElement appends RevocationValues element with DER-encoded crls and ocspResponses to parent.
+func createRevocationValuesElement(parent *etree.Element, qualifyingPropertiesElement *etree.Element, crls [][]byte, ocspResponses [][]byte) {
+	revocationValuesElement := createElementInNamespaceOf(parent, qualifyingPropertiesElement, revocationValuesElementTag)
+	if len(crls) > 0 {
+		crlValuesElement := createElementInNamespaceOf(revocationValuesElement, qualifyingPropertiesElement, crlValuesElementTag)
+		for _, crl := range crls {
+			createElementInNamespaceOf(crlValuesElement, qualifyingPropertiesElement, encapsulatedCRLValueElementTag).SetText(base64.StdEncoding.EncodeToString(crl))
+		}
+	}
+	if len(ocspResponses) > 0 {
+		ocspValuesElement := createElementInNamespaceOf(revocationValuesElement, qualifyingPropertiesElement, ocspValuesElementTag)
+		for _, ocspResponse := range ocspResponses {
+			createElementInNamespaceOf(ocspValuesElement, qualifyingPropertiesElement, encapsulatedOCSPValueElementTag).SetText(base64.StdEncoding.EncodeToString(ocspResponse))
+		}
+	}
+}
@@ -0,0 +1,364 @@
+package xades4go_test
+
+import (
+	"crypto/x509"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/google/go-cmp/cmp"
+	"github.com/mekpavit/xades4go"
+	"github.com/mekpavit/xades4go/etreeimpl"
+)
+
+type fakeRevocationDataProvider struct {
+	crls          [][]byte
+	ocspResponses [][]byte
+	err           error
+}
+
+func (provider *fakeRevocationDataProvider) RevocationData(certificates []*x509.Certificate) ([][]byte, [][]byte, error) {
+	return provider.crls, provider.ocspResponses, provider.err
+}
+
+func Test_XAdESSignatureAugmenter(t *testing.T) {
+	signedInfoFactory := etreeimpl.NewSignedInfoFactory()
+	signerKey, signerCertificate := mustCreateRSAKeyAndSelfSignedCertificate(t)
+	authority, tsaCertificate := mustCreateTimeStampAuthority(t, time.Now())
+	mustSignWithProfile := func(options ...xades4go.XAdESSignatureGeneratorOption) []byte {
+		generator, err := xades4go.NewXAdESSignatureGenerator(signedInfoFactory, signerKey, []*x509.Certificate{signerCertificate}, xades4go.RSASHA256SignatureAlgorithm, options...)
+		if err != nil {
+			t.Fatalf("NewXAdESSignatureGenerator() error = %v", err)
+		}
+		signedXMLBytes, err := generator.SignXMLBytes([]byte(unsignedInvoice), []xades4go.ReferenceGenerationDetail{
+			{
+				URIOfDataObjectBeingSigned: "",
+				TransformAlgorithms:        []string{xades4go.EnvelopedSignatureTransformAlgorithm},
+				DigestAlgorithm:            xades4go.SHA256MessageDigestAlgorithm,
+			},
+		})
+		if err != nil {
+			t.Fatalf("SignXMLBytes() error = %v", err)
+		}
+		return signedXMLBytes
+	}
+	baselineBSignature := mustSignWithProfile(xades4go.GenerateWithBaselineProfile(xades4go.BaselineProfileB))
+	baselineTSignature := mustSignWithProfile(xades4go.GenerateWithBaselineProfile(xades4go.BaselineProfileT), xades4go.GenerateWithTimeStampProvider(authority))
+	tamperedSignature := []byte(strings.Replace(string(baselineBSignature), "INV01", "INV02", 1))
+	revocationDataProvider := &fakeRevocationDataProvider{crls: [][]byte{[]byte("crl")}, ocspResponses: [][]byte{[]byte("ocsp")}}
+	tests := []struct {
+		name                       string
+		xmlBytes                   []byte
+		profile                    xades4go.BaselineProfile
+		options                    []xades4go.XAdESSignatureAugmenterOption
+		wantSignatureTimeStamps    int
+		wantEncapsulatedCertCount  int
+		wantEncapsulatedValueCount int
+		wantArchiveTimeStamps      int
+		wantErr                    bool
+	}{
+		{
+			name:                    "When B-B signature is augmented to B-T, it should add SignatureTimeStamp",
+			xmlBytes:                baselineBSignature,
+			profile:                 xades4go.BaselineProfileT,
+			options:                 []xades4go.XAdESSignatureAugmenterOption{xades4go.AugmentWithTimeStampProvider(authority)},
+			wantSignatureTimeStamps: 1,
+		},
+		{
+			name:                       "When B-B signature is augmented to B-LT, it should add SignatureTimeStamp, the TSA certificate not in KeyInfo and the revocation data",
+			xmlBytes:                   baselineBSignature,
+			profile:                    xades4go.BaselineProfileLT,
+			options:                    []xades4go.XAdESSignatureAugmenterOption{xades4go.AugmentWithTimeStampProvider(authority), xades4go.AugmentWithRevocationDataProvider(revocationDataProvider)},
+			wantSignatureTimeStamps:    1,
+			wantEncapsulatedCertCount:  1,
+			wantEncapsulatedValueCount: 2,
+		},
+		{
+			name:                       "When B-T signature is augmented to B-LT without time-stamp provider, it should keep its SignatureTimeStamp",
+			xmlBytes:                   baselineTSignature,
+			profile:                    xades4go.BaselineProfileLT,
+			options:                    []xades4go.XAdESSignatureAugmenterOption{xades4go.AugmentWithRevocationDataProvider(revocationDataProvider)},
+			wantSignatureTimeStamps:    1,
+			wantEncapsulatedCertCount:  1,
+			wantEncapsulatedValueCount: 2,
+		},
+		{
+			name:                       "When the signer certificate is also given by AugmentWithCertificates, it should not be added again",
+			xmlBytes:                   baselineTSignature,
+			profile:                    xades4go.BaselineProfileLT,
+			options:                    []xades4go.XAdESSignatureAugmenterOption{xades4go.AugmentWithRevocationDataProvider(revocationDataProvider), xades4go.AugmentWithCertificates(signerCertificate)},
+			wantSignatureTimeStamps:    1,
+			wantEncapsulatedCertCount:  1,
+			wantEncapsulatedValueCount: 2,
+		},
+		{
+			name:     "When B-T signature is augmented to B-LT without revocation data provider, it should return error",
+			xmlBytes: baselineTSignature,
+			profile:  xades4go.BaselineProfileLT,
+			wantErr:  true,
+		},
+		{
+			name:     "When revocation data provider returns nothing, it should return error",
+			xmlBytes: baselineTSignature,
+			profile:  xades4go.BaselineProfileLT,
+			options:  []xades4go.XAdESSignatureAugmenterOption{xades4go.AugmentWithRevocationDataProvider(&fakeRevocationDataProvider{})},
+			wantErr:  true,
+		},
+		{
+			name:                    "When the signed tax invoice of ETDA is augmented to B-T, it should add SignatureTimeStamp",
+			xmlBytes:                []byte(etdaSignedTaxInvoice),
+			profile:                 xades4go.BaselineProfileT,
+			options:                 []xades4go.XAdESSignatureAugmenterOption{xades4go.AugmentWithTimeStampProvider(authority)},
+			wantSignatureTimeStamps: 1,
+		},
+		{
+			name:     "When B-B signature is augmented to B-T without time-stamp provider, it should return error",
+			xmlBytes: baselineBSignature,
+			profile:  xades4go.BaselineProfileT,
+			wantErr:  true,
+		},
+		{
+			name:     "When the signed content is tampered, it should return error",
+			xmlBytes: tamperedSignature,
+			profile:  xades4go.BaselineProfileT,
+			options:  []xades4go.XAdESSignatureAugmenterOption{xades4go.AugmentWithTimeStampProvider(authority)},
+			wantErr:  true,
+		},
+		{
+			name:     "When revocation data provider fails, it should return error",
+			xmlBytes: baselineTSignature,
+			profile:  xades4go.BaselineProfileLT,
+			options:  []xades4go.XAdESSignatureAugmenterOption{xades4go.AugmentWithRevocationDataProvider(&fakeRevocationDataProvider{err: errors.New("unreachable")})},
+			wantErr:  true,
+		},
+		{
+			name:                       "When B-B signature is augmented to B-LTA, it should add ArchiveTimeStamp after the B-LT properties",
+			xmlBytes:                   baselineBSignature,
+			profile:                    xades4go.BaselineProfileLTA,
+			options:                    []xades4go.XAdESSignatureAugmenterOption{xades4go.AugmentWithTimeStampProvider(authority), xades4go.AugmentWithRevocationDataProvider(revocationDataProvider)},
+			wantSignatureTimeStamps:    1,
+			wantEncapsulatedCertCount:  1,
+			wantEncapsulatedValueCount: 2,
+			wantArchiveTimeStamps:      1,
+		},
+		{
+			name:     "When B-T signature is augmented to B-LTA without time-stamp provider, it should return error",
+			xmlBytes: baselineTSignature,
+			profile:  xades4go.BaselineProfileLTA,
+			options:  []xades4go.XAdESSignatureAugmenterOption{xades4go.AugmentWithRevocationDataProvider(revocationDataProvider)},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			augmentedXMLBytes, err := xades4go.NewXAdESSignatureAugmenter(signedInfoFactory, tt.options...).Augment(tt.xmlBytes, tt.profile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Augment() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			doc := etree.NewDocument()
+			if err := doc.ReadFromBytes(augmentedXMLBytes); err != nil {
+				t.Fatalf("cannot parse augmented XML: %v", err)
+			}
+			if got := len(doc.FindElements("//UnsignedSignatureProperties/SignatureTimeStamp")); got != tt.wantSignatureTimeStamps {
+				t.Errorf("Augment() added %d SignatureTimeStamp elements, want %d", got, tt.wantSignatureTimeStamps)
+			}
+			if got := len(doc.FindElements("//CertificateValues/EncapsulatedX509Certificate")); got != tt.wantEncapsulatedCertCount {
+				t.Errorf("Augment() added %d EncapsulatedX509Certificate elements, want %d", got, tt.wantEncapsulatedCertCount)
+			}
+			if got := len(doc.FindElements("//UnsignedSignatureProperties/ArchiveTimeStamp")); got != tt.wantArchiveTimeStamps {
+				t.Errorf("Augment() added %d ArchiveTimeStamp elements, want %d", got, tt.wantArchiveTimeStamps)
+			}
+			if got := len(doc.FindElements("//RevocationValues/CRLValues/EncapsulatedCRLValue")) + len(doc.FindElements("//RevocationValues/OCSPValues/EncapsulatedOCSPValue")); got != tt.wantEncapsulatedValueCount {
+				t.Errorf("Augment() added %d revocation values, want %d", got, tt.wantEncapsulatedValueCount)
+			}
+
+			result, err := xades4go.NewXAdESSignatureValidator(signedInfoFactory, xades4go.ValidateWithBaselineProfile(tt.profile), xades4go.ValidateWithXMLDSigOptions(
+				xades4go.ValidateWithTrustStore(xades4go.NewTrustStore([]*x509.Certificate{tsaCertificate}, nil)),
+			)).Validate(augmentedXMLBytes)
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if !result.IsSignatureValid {
+				t.Errorf("Validate() IsSignatureValid = false after augmentation")
+			}
+			for _, referenceValidationResult := range result.ReferenceValidationResults {
+				if !referenceValidationResult.IsValid {
+					t.Errorf("Validate() Reference to %q is invalid after augmentation", referenceValidationResult.URI)
+				}
+			}
+			for _, timeStampValidationResult := range result.QualifyingPropertiesValidationResult.TimeStampValidationResults {
+				if !timeStampValidationResult.IsValid {
+					t.Errorf("Validate() %s %s is invalid", timeStampValidationResult.Property, timeStampValidationResult.ID)
+				}
+			}
+			for _, violation := range result.QualifyingPropertiesValidationResult.BaselineProfileValidationResult.Violations {
+				if strings.Contains(violation, "is missing (required since") {
+					t.Errorf("Validate() reported %q after augmentation", violation)
+				}
+			}
+		})
+	}
+}
+
+func Test_XAdESSignatureAugmenter_RenewArchiveTimeStamp(t *testing.T) {
+	signedInfoFactory := etreeimpl.NewSignedInfoFactory()
+	signerKey, signerCertificate := mustCreateRSAKeyAndSelfSignedCertificate(t)
+	firstAuthority, firstTSACertificate := mustCreateTimeStampAuthority(t, time.Date(2021, 2, 5, 0, 0, 0, 0, time.UTC))
+	secondAuthority, secondTSACertificate := mustCreateTimeStampAuthority(t, time.Date(2031, 2, 5, 0, 0, 0, 0, time.UTC))
+	generator, err := xades4go.NewXAdESSignatureGenerator(signedInfoFactory, signerKey, []*x509.Certificate{signerCertificate}, xades4go.RSASHA256SignatureAlgorithm)
+	if err != nil {
+		t.Fatalf("NewXAdESSignatureGenerator() error = %v", err)
+	}
+	signedXMLBytes, err := generator.SignXMLBytes([]byte(unsignedInvoice), []xades4go.ReferenceGenerationDetail{
+		{
+			URIOfDataObjectBeingSigned: "",
+			TransformAlgorithms:        []string{xades4go.EnvelopedSignatureTransformAlgorithm},
+			DigestAlgorithm:            xades4go.SHA256MessageDigestAlgorithm,
+		},
+	})
+	if err != nil {
+		t.Fatalf("SignXMLBytes() error = %v", err)
+	}
+	baselineLTASignature, err := xades4go.NewXAdESSignatureAugmenter(signedInfoFactory,
+		xades4go.AugmentWithTimeStampProvider(firstAuthority),
+		xades4go.AugmentWithRevocationDataProvider(&fakeRevocationDataProvider{crls: [][]byte{[]byte("crl of the signer")}}),
+	).Augment(signedXMLBytes, xades4go.BaselineProfileLTA)
+	if err != nil {
+		t.Fatalf("Augment() error = %v", err)
+	}
+
+	renewedXMLBytes, err := xades4go.NewXAdESSignatureAugmenter(signedInfoFactory,
+		xades4go.AugmentWithTimeStampProvider(secondAuthority),
+		xades4go.AugmentWithRevocationDataProvider(&fakeRevocationDataProvider{crls: [][]byte{[]byte("crl of the first TSA")}}),
+	).RenewArchiveTimeStamp(baselineLTASignature)
+	if err != nil {
+		t.Fatalf("RenewArchiveTimeStamp() error = %v", err)
+	}
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(renewedXMLBytes); err != nil {
+		t.Fatalf("cannot parse renewed XML: %v", err)
+	}
+	var gotProperties []string
+	for _, propertyElement := range doc.FindElement("//UnsignedSignatureProperties").ChildElements() {
+		gotProperties = append(gotProperties, propertyElement.Tag)
+	}
+	wantProperties := []string{"SignatureTimeStamp", "CertificateValues", "RevocationValues", "ArchiveTimeStamp", "TimeStampValidationData", "ArchiveTimeStamp"}
+	if diff := cmp.Diff(wantProperties, gotProperties); diff != "" {
+		t.Errorf("RenewArchiveTimeStamp() UnsignedSignatureProperties mismatch (-want+got):\n%s", diff)
+	}
+	if got := len(doc.FindElements("//TimeStampValidationData/RevocationValues/CRLValues/EncapsulatedCRLValue")); got != 1 {
+		t.Errorf("RenewArchiveTimeStamp() added %d EncapsulatedCRLValue to TimeStampValidationData, want 1", got)
+	}
+
+	result, err := xades4go.NewXAdESSignatureValidator(signedInfoFactory, xades4go.ValidateWithBaselineProfile(xades4go.BaselineProfileLTA), xades4go.ValidateWithXMLDSigOptions(
+		xades4go.ValidateWithTrustStore(xades4go.NewTrustStore([]*x509.Certificate{firstTSACertificate, secondTSACertificate}, nil)),
+	)).Validate(renewedXMLBytes)
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	var gotGenTimes []time.Time
+	for _, timeStampValidationResult := range result.QualifyingPropertiesValidationResult.TimeStampValidationResults {
+		if !timeStampValidationResult.IsValid {
+			t.Errorf("Validate() %s %s is invalid", timeStampValidationResult.Property, timeStampValidationResult.ID)
+		}
+		gotGenTimes = append(gotGenTimes, timeStampValidationResult.GenTime)
+	}
+	wantGenTimes := []time.Time{
+		time.Date(2021, 2, 5, 0, 0, 0, 0, time.UTC),
+		time.Date(2021, 2, 5, 0, 0, 0, 0, time.UTC),
+		time.Date(2031, 2, 5, 0, 0, 0, 0, time.UTC),
+	}
+	if diff := cmp.Diff(wantGenTimes, gotGenTimes); diff != "" {
+		t.Errorf("Validate() GenTime of time-stamps mismatch (-want+got):\n%s", diff)
+	}
+	if !result.QualifyingPropertiesValidationResult.IsValid {
+		t.Errorf("Validate() QualifyingPropertiesValidationResult.IsValid = false, violations = %v", result.QualifyingPropertiesValidationResult.BaselineProfileValidationResult.Violations)
+	}
+}
+
+func Test_XAdESSignatureValidator_ArchiveTimeStamp(t *testing.T) {
+	signedInfoFactory := etreeimpl.NewSignedInfoFactory()
+	signerKey, signerCertificate := mustCreateRSAKeyAndSelfSignedCertificate(t)
+	authority, tsaCertificate := mustCreateTimeStampAuthority(t, time.Now())
+	generator, err := xades4go.NewXAdESSignatureGenerator(signedInfoFactory, signerKey, []*x509.Certificate{signerCertificate}, xades4go.RSASHA256SignatureAlgorithm)
+	if err != nil {
+		t.Fatalf("NewXAdESSignatureGenerator() error = %v", err)
+	}
+	signedXMLBytes, err := generator.SignXMLBytes([]byte(unsignedInvoice), []xades4go.ReferenceGenerationDetail{
+		{
+			URIOfDataObjectBeingSigned: "",
+			TransformAlgorithms:        []string{xades4go.EnvelopedSignatureTransformAlgorithm},
+			DigestAlgorithm:            xades4go.SHA256MessageDigestAlgorithm,
+		},
+	})
+	if err != nil {
+		t.Fatalf("SignXMLBytes() error = %v", err)
+	}
+	baselineLTASignature, err := xades4go.NewXAdESSignatureAugmenter(signedInfoFactory,
+		xades4go.AugmentWithTimeStampProvider(authority),
+		xades4go.AugmentWithRevocationDataProvider(&fakeRevocationDataProvider{crls: [][]byte{[]byte("crl of the signer")}}),
+	).Augment(signedXMLBytes, xades4go.BaselineProfileLTA)
+	if err != nil {
+		t.Fatalf("Augment() error = %v", err)
+	}
+	mustModify := func(modify func(doc *etree.Document)) []byte {
+		doc := etree.NewDocument()
+		if err := doc.ReadFromBytes(baselineLTASignature); err != nil {
+			t.Fatalf("cannot parse XML: %v", err)
+		}
+		modify(doc)
+		modifiedXMLBytes, err := doc.WriteToBytes()
+		if err != nil {
+			t.Fatalf("cannot serialize XML: %v", err)
+		}
+		return modifiedXMLBytes
+	}
+	tests := []struct {
+		name     string
+		xmlBytes []byte
+		want     map[string]bool
+	}{
+		{
+			name:     "When nothing is modified after ArchiveTimeStamp was added, every time-stamp should be valid",
+			xmlBytes: baselineLTASignature,
+			want:     map[string]bool{"SignatureTimeStamp": true, "ArchiveTimeStamp": true},
+		},
+		{
+			name: "When a certificate is removed from CertificateValues, ArchiveTimeStamp should be invalid",
+			xmlBytes: mustModify(func(doc *etree.Document) {
+				certificateValuesElement := doc.FindElement("//CertificateValues")
+				certificateValuesElement.RemoveChild(certificateValuesElement.SelectElement("EncapsulatedX509Certificate"))
+			}),
+			want: map[string]bool{"SignatureTimeStamp": true, "ArchiveTimeStamp": false},
+		},
+		{
+			name: "When an unsigned property is appended after ArchiveTimeStamp, every time-stamp should still be valid",
+			xmlBytes: mustModify(func(doc *etree.Document) {
+				doc.FindElement("//UnsignedSignatureProperties").CreateElement("xades:CertificateValues")
+			}),
+			want: map[string]bool{"SignatureTimeStamp": true, "ArchiveTimeStamp": true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := xades4go.NewXAdESSignatureValidator(signedInfoFactory, xades4go.ValidateWithXMLDSigOptions(
+				xades4go.ValidateWithTrustStore(xades4go.NewTrustStore([]*x509.Certificate{tsaCertificate}, nil)),
+			)).Validate(tt.xmlBytes)
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			got := map[string]bool{}
+			for _, timeStampValidationResult := range result.QualifyingPropertiesValidationResult.TimeStampValidationResults {
+				got[timeStampValidationResult.Property] = timeStampValidationResult.IsValid
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Validate() validity of time-stamps mismatch (-want+got):\n%s", diff)
+			}
+		})
+	}
+}
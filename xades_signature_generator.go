@@ -0,0 +1,271 @@
+package xades4go
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/beevik/etree"
+)
+
+const (
+	// SignedPropertiesReferenceType is the Type attribute of the Reference that points to SignedProperties element.
+	SignedPropertiesReferenceType = "http://uri.etsi.org/01903#SignedProperties"
+
+	xadesNamespacePrefix = "xades"
+	xadesNamespaceURI    = "http://uri.etsi.org/01903/v1.3.2#"
+
+	qualifyingPropertiesElementTag       = "QualifyingProperties"
+	signedPropertiesElementTag           = "SignedProperties"
+	signedSignaturePropertiesElementTag  = "SignedSignatureProperties"
+	signedDataObjectPropertiesElementTag = "SignedDataObjectProperties"
+	signingTimeElementTag                = "SigningTime"
+	signingCertificateV2ElementTag       = "SigningCertificateV2"
+	certElementTag                       = "Cert"
+	certDigestElementTag                 = "CertDigest"
+	issuerSerialV2ElementTag             = "IssuerSerialV2"
+	dataObjectFormatElementTag           = "DataObjectFormat"
+	mimeTypeElementTag                   = "MimeType"
+
+	targetAttributeKey          = "Target"
+	objectReferenceAttributeKey = "ObjectReference"
+)
+
+// XAdESSignatureGenerator generates XAdES-BES signature (ETSI EN 319 132-1), XAdES-EPES signature if GenerateWithSignaturePolicy is given
+// and XAdES-T signature if GenerateWithTimeStampProvider is given.
+// On top of what XMLDSigSignatureGenerator generates, the Signature element carries QualifyingProperties with SigningTime, SigningCertificateV2 and SignedDataObjectProperties,
+// and SignedInfo element carries the Reference to SignedProperties element.
+type XAdESSignatureGenerator struct {
+	xmldsigSignatureGenerator *XMLDSigSignatureGenerator
+	signingCertificate        *x509.Certificate
+	digestAlgorithm           string
+	signingTime               func() time.Time
+	signaturePolicy           *SignaturePolicyGenerationDetail
+	timeStampProvider         TimeStampProvider
+	baselineProfile           BaselineProfile
+}
+
+// XAdESSignatureGeneratorOption configures optional behavior of XAdESSignatureGenerator.
+type XAdESSignatureGeneratorOption func(generator *XAdESSignatureGenerator)
+
+// GenerateWithXMLDSigOptions applies XMLDSigSignatureGeneratorOption to the underlying XMLDSig signature generation.
+func GenerateWithXMLDSigOptions(options ...XMLDSigSignatureGeneratorOption) XAdESSignatureGeneratorOption {
+	return func(generator *XAdESSignatureGenerator) {
+		for _, option := range options {
+			option(generator.xmldsigSignatureGenerator)
+		}
+	}
+}
+
+// GenerateWithDigestAlgorithm sets the digest algorithm of CertDigest element and of the Reference to SignedProperties element. The default is SHA-256.
+func GenerateWithDigestAlgorithm(digestAlgorithm string) XAdESSignatureGeneratorOption {
+	return func(generator *XAdESSignatureGenerator) {
+		generator.digestAlgorithm = digestAlgorithm
+	}
+}
+
+// GenerateWithSigningTime sets the function that returns the time put in SigningTime element. The default is time.Now.
+func GenerateWithSigningTime(signingTime func() time.Time) XAdESSignatureGeneratorOption {
+	return func(generator *XAdESSignatureGenerator) {
+		generator.signingTime = signingTime
+	}
+}
+
+// GenerateWithSignaturePolicy adds SignaturePolicyIdentifier element of the explicit signature policy, which makes the generated signature XAdES-EPES.
+func GenerateWithSignaturePolicy(signaturePolicy SignaturePolicyGenerationDetail) XAdESSignatureGeneratorOption {
+	return func(generator *XAdESSignatureGenerator) {
+		generator.signaturePolicy = &signaturePolicy
+	}
+}
+
+// GenerateWithTimeStampProvider adds SignatureTimeStamp obtained from timeStampProvider over the canonicalized SignatureValue element, which makes the generated signature XAdES-T.
+// SignatureValue element is canonicalized with the canonicalization algorithm of SignedInfo element and digested with the algorithm set by GenerateWithDigestAlgorithm.
+func GenerateWithTimeStampProvider(timeStampProvider TimeStampProvider) XAdESSignatureGeneratorOption {
+	return func(generator *XAdESSignatureGenerator) {
+		generator.timeStampProvider = timeStampProvider
+	}
+}
+
+// GenerateWithBaselineProfile makes the generator generate signatures of the baseline profile (ETSI EN 319 132-1).
+// NewXAdESSignatureGenerator returns error if the other options cannot meet the profile, for example BaselineProfileT without GenerateWithTimeStampProvider,
+// and signing returns error if a Reference uses an algorithm the profile does not allow.
+func GenerateWithBaselineProfile(baselineProfile BaselineProfile) XAdESSignatureGeneratorOption {
+	return func(generator *XAdESSignatureGenerator) {
+		generator.baselineProfile = baselineProfile
+	}
+}
+
+// NewXAdESSignatureGenerator creates SignatureGenerator that generates XAdES-BES signature.
+// The first certificate of certificateChain is the signing certificate referenced by SigningCertificateV2 element.
+func NewXAdESSignatureGenerator(signedInfoFactory SignedInfoFactory, signer crypto.Signer, certificateChain []*x509.Certificate, signatureAlgorithm string, options ...XAdESSignatureGeneratorOption) (SignatureGenerator, error) {
+	generator, err := newXAdESSignatureGenerator(signedInfoFactory, signer, certificateChain, signatureAlgorithm, options...)
+	if err != nil {
+		return nil, err
+	}
+	return generator, nil
+}
+
+func newXAdESSignatureGenerator(signedInfoFactory SignedInfoFactory, signer crypto.Signer, certificateChain []*x509.Certificate, signatureAlgorithm string, options ...XAdESSignatureGeneratorOption) (*XAdESSignatureGenerator, error) {
+	if len(certificateChain) == 0 {
+		return nil, errors.New("signing certificate is required to generate XAdES signature")
+	}
+	xmldsigSignatureGenerator, err := newXMLDSigSignatureGenerator(signedInfoFactory, signer, certificateChain, signatureAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	generator := &XAdESSignatureGenerator{
+		xmldsigSignatureGenerator: xmldsigSignatureGenerator,
+		signingCertificate:        certificateChain[0],
+		digestAlgorithm:           SHA256MessageDigestAlgorithm,
+		signingTime:               time.Now,
+	}
+	for _, option := range options {
+		option(generator)
+	}
+	if err := generator.xmldsigSignatureGenerator.preparePSSParameters(); err != nil {
+		return nil, err
+	}
+	if _, err := CreateDigester(generator.digestAlgorithm); err != nil {
+		return nil, err
+	}
+	if generator.baselineProfile != 0 {
+		if err := checkBaselineProfileOfGenerator(generator); err != nil {
+			return nil, err
+		}
+	}
+	return generator, nil
+}
+
+// SignXMLBytes creates an enveloped XAdES signature. The Signature element is appended as the last child of the root element of xmlBytes.
+func (generator *XAdESSignatureGenerator) SignXMLBytes(xmlBytes []byte, dataObjectReferences []ReferenceGenerationDetail) ([]byte, error) {
+	return generator.xmldsigSignatureGenerator.signXMLBytes(xmlBytes, dataObjectReferences, generator)
+}
+
+// SignEnveloping creates an enveloping XAdES signature. The generated Signature element is the root element of the returned XML.
+func (generator *XAdESSignatureGenerator) SignEnveloping(dataObjects []DataObjectGenerationDetail) ([]byte, error) {
+	return generator.xmldsigSignatureGenerator.signEnveloping(dataObjects, generator)
+}
+
+// SignDetached creates a detached XAdES signature. The generated Signature element is the root element of the returned XML.
+func (generator *XAdESSignatureGenerator) SignDetached(dataObjectReferences []ReferenceGenerationDetail) ([]byte, error) {
+	return generator.xmldsigSignatureGenerator.signDetached(dataObjectReferences, generator)
+}
+
+func (generator *XAdESSignatureGenerator) extendSignatureElement(signatureElement *etree.Element, signatureID string, dataObjectReferences []ReferenceGenerationDetail) ([]ReferenceGenerationDetail, error) {
+	if generator.baselineProfile != 0 {
+		for referenceIndex, referenceDetail := range dataObjectReferences {
+			if baselineForbiddenDigestAlgorithms[referenceDetail.DigestAlgorithm] {
+				return nil, fmt.Errorf("at Reference#%d: %s must not be used by %s signature", referenceIndex, referenceDetail.DigestAlgorithm, generator.baselineProfile)
+			}
+		}
+	}
+	qualifyingPropertiesElement := createXMLDSigElement(signatureElement, objectElementTag).CreateElement(xadesNamespacePrefix + ":" + qualifyingPropertiesElementTag)
+	qualifyingPropertiesElement.CreateAttr("xmlns:"+xadesNamespacePrefix, xadesNamespaceURI)
+	qualifyingPropertiesElement.CreateAttr(targetAttributeKey, "#"+signatureID)
+	signedPropertiesID := signatureID + "-signedprops"
+	signedPropertiesElement := createXAdESElement(qualifyingPropertiesElement, signedPropertiesElementTag)
+	signedPropertiesElement.CreateAttr(idAttributeKey, signedPropertiesID)
+
+	signedSignaturePropertiesElement := createXAdESElement(signedPropertiesElement, signedSignaturePropertiesElementTag)
+	createXAdESElement(signedSignaturePropertiesElement, signingTimeElementTag).SetText(generator.signingTime().UTC().Format(time.RFC3339))
+	err := createSigningCertificateV2Element(signedSignaturePropertiesElement, generator.signingCertificate, generator.digestAlgorithm)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create SigningCertificateV2 element: %w", err)
+	}
+	if generator.signaturePolicy != nil {
+		err := createSignaturePolicyIdentifierElement(signedSignaturePropertiesElement, generator.xmldsigSignatureGenerator.signedInfoFactory, generator.xmldsigSignatureGenerator.defaultCanonicalizationAlgorithm, *generator.signaturePolicy)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create SignaturePolicyIdentifier element: %w", err)
+		}
+	}
+
+	signedDataObjectPropertiesElement := createXAdESElement(signedPropertiesElement, signedDataObjectPropertiesElementTag)
+	for referenceIndex, referenceDetail := range dataObjectReferences {
+		if referenceDetail.Type == CountersignedSignatureReferenceType {
+			continue
+		}
+		dataObjectFormatElement := createXAdESElement(signedDataObjectPropertiesElement, dataObjectFormatElementTag)
+		dataObjectFormatElement.CreateAttr(objectReferenceAttributeKey, "#"+referenceIDOf(signatureID, referenceIndex))
+		createXAdESElement(dataObjectFormatElement, mimeTypeElementTag).SetText(mimeTypeOf(referenceDetail))
+	}
+
+	return []ReferenceGenerationDetail{
+		{
+			URIOfDataObjectBeingSigned: "#" + signedPropertiesID,
+			DigestAlgorithm:            generator.digestAlgorithm,
+			Type:                       SignedPropertiesReferenceType,
+		},
+	}, nil
+}
+
+func (generator *XAdESSignatureGenerator) extendSignedSignatureElement(doc *etree.Document, signatureElement *etree.Element) error {
+	if generator.timeStampProvider == nil {
+		return nil
+	}
+	return addSignatureTimeStamp(doc, signatureElement, generator.xmldsigSignatureGenerator.signedInfoFactory, generator.xmldsigSignatureGenerator.canonicalizationAlgorithm, generator.digestAlgorithm, generator.timeStampProvider)
+}
+
+func createSigningCertificateV2Element(parent *etree.Element, signingCertificate *x509.Certificate, digestAlgorithm string) error {
+	digester, err := CreateDigester(digestAlgorithm)
+	if err != nil {
+		return err
+	}
+	certificateDigestValue, err := digester.Digest(signingCertificate.Raw)
+	if err != nil {
+		return err
+	}
+	issuerSerialV2, err := encodeIssuerSerialV2(signingCertificate)
+	if err != nil {
+		return err
+	}
+	certElement := createXAdESElement(createXAdESElement(parent, signingCertificateV2ElementTag), certElementTag)
+	certDigestElement := createXAdESElement(certElement, certDigestElementTag)
+	createXMLDSigElement(certDigestElement, digestMethodElementTag).CreateAttr(algorithmAttributeKey, digestAlgorithm)
+	createXMLDSigElement(certDigestElement, digestValueElementTag).SetText(string(certificateDigestValue))
+	createXAdESElement(certElement, issuerSerialV2ElementTag).SetText(issuerSerialV2)
+	return nil
+}
+
+// issuerSerial is IssuerSerial of RFC 5035 whose DER encoding is the content of IssuerSerialV2 element.
+type issuerSerial struct {
+	Issuer       []asn1.RawValue
+	SerialNumber *big.Int
+}
+
+// encodeIssuerSerialV2 returns base64-encoded DER of IssuerSerial. The issuer is a GeneralNames containing only the directoryName of the certificate issuer.
+func encodeIssuerSerialV2(certificate *x509.Certificate) (string, error) {
+	directoryName := asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 4, IsCompound: true, Bytes: certificate.RawIssuer}
+	encoded, err := asn1.Marshal(issuerSerial{Issuer: []asn1.RawValue{directoryName}, SerialNumber: certificate.SerialNumber})
+	if err != nil {
+		return "", fmt.Errorf("cannot encode IssuerSerial: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(encoded), nil
+}
+
+// mimeTypeOf returns MimeType of the data object. If it is not given, same-document data objects are assumed to be XML.
+func mimeTypeOf(referenceDetail ReferenceGenerationDetail) string {
+	if referenceDetail.MimeType != "" {
+		return referenceDetail.MimeType
+	}
+	if isSameDocumentURI(referenceDetail.URIOfDataObjectBeingSigned) {
+		return "text/xml"
+	}
+	return "application/octet-stream"
+}
+
+func createXAdESElement(parent *etree.Element, tag string) *etree.Element {
+	return parent.CreateElement(xadesNamespacePrefix + ":" + tag)
+}
+
+// createElementInNamespaceOf creates a child element of parent with the namespace prefix of namespaceElement, so that properties added to a signature generated elsewhere use the prefixes it declares.
+func createElementInNamespaceOf(parent *etree.Element, namespaceElement *etree.Element, tag string) *etree.Element {
+	if namespaceElement.Space == "" {
+		return parent.CreateElement(tag)
+	}
+	return parent.CreateElement(namespaceElement.Space + ":" + tag)
+}
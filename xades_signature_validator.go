@@ -0,0 +1,508 @@
+package xades4go
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+)
+
+const (
+	signingCertificateElementTag = "SigningCertificate"
+	issuerSerialElementTag       = "IssuerSerial"
+	x509IssuerNameElementTag     = "X509IssuerName"
+	x509SerialNumberElementTag   = "X509SerialNumber"
+)
+
+// QualifyingPropertiesValidationResult is the result of validating XAdES QualifyingProperties of a signature.
+type QualifyingPropertiesValidationResult struct {
+	// IsValid is true only if every check below passes (including the signature policy hash, time-stamps, counter signatures and baseline profile if present).
+	IsValid bool
+	// IsSignedPropertiesReferenceValid is true if SignedInfo element contains a Reference of type SignedPropertiesReferenceType to SignedProperties element and its digest is valid.
+	IsSignedPropertiesReferenceValid bool
+	// IsSigningCertificateDigestValid is true if a CertDigest of SigningCertificate or SigningCertificateV2 element matches the certificate that verified SignatureValue element.
+	IsSigningCertificateDigestValid bool
+	// IsSigningCertificateIssuerSerialValid is true if the issuer and serial number of that Cert element match the certificate that verified SignatureValue element.
+	IsSigningCertificateIssuerSerialValid bool
+	// SigningTime is the time claimed by SigningTime element. It is zero if SigningTime element is absent.
+	SigningTime time.Time
+	// SignaturePolicyValidationResult is nil if SignaturePolicyIdentifier element is absent (not XAdES-EPES).
+	SignaturePolicyValidationResult *SignaturePolicyValidationResult
+	// TimeStampValidationResults has one result per time-stamp token of AllDataObjectsTimeStamp, SignatureTimeStamp, SigAndRefsTimeStamp(V2), RefsOnlyTimeStamp(V2) and ArchiveTimeStamp elements, in that order.
+	TimeStampValidationResults []TimeStampValidationResult
+	// CompleteReferencesValidationResult is nil if neither CompleteCertificateRefs(V2) nor CompleteRevocationRefs element is present (not XAdES-C or above).
+	CompleteReferencesValidationResult *CompleteReferencesValidationResult
+	// CounterSignatureValidationResults has one result per CounterSignature element, in document order. Each result carries the results of the counter signatures nested in it.
+	CounterSignatureValidationResults []CounterSignatureValidationResult
+	// BaselineProfileValidationResult is nil if ValidateWithBaselineProfile is not given. Counter signatures are not checked against the profile.
+	BaselineProfileValidationResult *BaselineProfileValidationResult
+}
+
+// XAdESSignatureValidator validates XMLDSig signature like XMLDSigSignatureValidator and additionally validates XAdES QualifyingProperties of the signature.
+type XAdESSignatureValidator struct {
+	xmldsigSignatureValidator *XMLDSigSignatureValidator
+	signaturePolicyResolver   URIResolver
+	baselineProfile           BaselineProfile
+}
+
+// XAdESSignatureValidatorOption configures optional behavior of XAdESSignatureValidator.
+type XAdESSignatureValidatorOption func(validator *XAdESSignatureValidator)
+
+// ValidateWithXMLDSigOptions applies XMLDSigSignatureValidatorOption to the underlying XMLDSig signature validation.
+func ValidateWithXMLDSigOptions(options ...XMLDSigSignatureValidatorOption) XAdESSignatureValidatorOption {
+	return func(validator *XAdESSignatureValidator) {
+		for _, option := range options {
+			option(validator.xmldsigSignatureValidator)
+		}
+	}
+}
+
+// ValidateWithSignaturePolicyResolver makes the validator resolve the signature policy document by the policy identifier (Identifier element of SigPolicyId) with signaturePolicyResolver.
+// Without it, SigPolicyHash of an explicit signature policy is not checked (see SignaturePolicyValidationResult.IsPolicyHashChecked).
+func ValidateWithSignaturePolicyResolver(signaturePolicyResolver URIResolver) XAdESSignatureValidatorOption {
+	return func(validator *XAdESSignatureValidator) {
+		validator.signaturePolicyResolver = signaturePolicyResolver
+	}
+}
+
+// ValidateWithBaselineProfile makes the validator check the signature against the requirements of the baseline profile (ETSI EN 319 132-1).
+// A signature of a higher level also meets the requirements of the lower levels.
+func ValidateWithBaselineProfile(baselineProfile BaselineProfile) XAdESSignatureValidatorOption {
+	return func(validator *XAdESSignatureValidator) {
+		validator.baselineProfile = baselineProfile
+	}
+}
+
+func NewXAdESSignatureValidator(signedInfoFactory SignedInfoFactory, options ...XAdESSignatureValidatorOption) MultipleSignatureValidator {
+	validator := &XAdESSignatureValidator{
+		xmldsigSignatureValidator: newXMLDSigSignatureValidator(signedInfoFactory),
+	}
+	for _, option := range options {
+		option(validator)
+	}
+	return validator
+}
+
+func (validator *XAdESSignatureValidator) Validate(xmlBytes []byte) (ValidationResult, error) {
+	rootElement, err := createEtreeElementFromXMLBytes(xmlBytes)
+	if err != nil {
+		return ValidationResult{}, err
+	}
+	signatureElement, err := mustFoundOnlyOneSignatureElement(rootElement)
+	if err != nil {
+		return ValidationResult{}, err
+	}
+	return validator.validateSignatureElement(xmlBytes, signatureElement, validator.baselineProfile)
+}
+
+func (validator *XAdESSignatureValidator) ValidateAll(xmlBytes []byte) ([]ValidationResult, error) {
+	rootElement, err := createEtreeElementFromXMLBytes(xmlBytes)
+	if err != nil {
+		return nil, err
+	}
+	signatureElements, err := mustFoundAtLeastOneSignatureElement(rootElement)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]ValidationResult, 0, len(signatureElements))
+	for signatureIndex, signatureElement := range signatureElements {
+		result, err := validator.validateSignatureElement(xmlBytes, signatureElement, validator.baselineProfile)
+		if err != nil {
+			return nil, fmt.Errorf("at Signature#%d element: %w", signatureIndex, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// ValidateByID validates the signature whose Id attribute is signatureID. When a counter signature is selected, it is checked against the baseline profile like a top-level signature.
+func (validator *XAdESSignatureValidator) ValidateByID(xmlBytes []byte, signatureID string) (ValidationResult, error) {
+	rootElement, err := createEtreeElementFromXMLBytes(xmlBytes)
+	if err != nil {
+		return ValidationResult{}, err
+	}
+	signatureElement, err := mustFoundSignatureElementByID(rootElement, signatureID)
+	if err != nil {
+		return ValidationResult{}, err
+	}
+	return validator.validateSignatureElement(xmlBytes, signatureElement, validator.baselineProfile)
+}
+
+// validateSignatureElement validates signatureElement (parsed from xmlBytes) like XMLDSigSignatureValidator and then its QualifyingProperties.
+// The signature is checked against baselineProfile unless it is zero.
+// The certificates and revocation data embedded in CertificateValues and RevocationValues elements are used to validate the signer certificate offline,
+// at the time of the earliest valid SignatureTimeStamp if there is one (see bestSignatureTimeOf).
+func (validator *XAdESSignatureValidator) validateSignatureElement(xmlBytes []byte, signatureElement *etree.Element, baselineProfile BaselineProfile) (ValidationResult, error) {
+	qualifyingPropertiesElement, err := findQualifyingPropertiesElement(signatureElement)
+	if err != nil {
+		return ValidationResult{}, err
+	}
+	validationData, err := embeddedValidationDataOf(qualifyingPropertiesElement)
+	if err != nil {
+		return ValidationResult{}, err
+	}
+	bestSignatureTime, err := validator.bestSignatureTimeOf(xmlBytes, signatureElement, qualifyingPropertiesElement, validationData)
+	if err != nil {
+		return ValidationResult{}, err
+	}
+	result, err := validator.xmldsigSignatureValidator.validateSignatureElementWithValidationData(xmlBytes, signatureElement, validationData, bestSignatureTime)
+	if err != nil {
+		return ValidationResult{}, err
+	}
+	qualifyingPropertiesValidationResult, err := validator.validateQualifyingProperties(xmlBytes, signatureElement, result, validationData, baselineProfile)
+	if err != nil {
+		return ValidationResult{}, err
+	}
+	result.QualifyingPropertiesValidationResult = &qualifyingPropertiesValidationResult
+	return result, nil
+}
+
+// validateQualifyingProperties validates QualifyingProperties element of signatureElement. The references of CompleteCertificateRefs(V2) and CompleteRevocationRefs elements
+// are matched against validationData embedded in the signature and the validation data in xmldsigResult.
+func (validator *XAdESSignatureValidator) validateQualifyingProperties(xmlBytes []byte, signatureElement *etree.Element, xmldsigResult ValidationResult, validationData embeddedValidationData, baselineProfile BaselineProfile) (QualifyingPropertiesValidationResult, error) {
+	qualifyingPropertiesElement, err := findQualifyingPropertiesElement(signatureElement)
+	if err != nil {
+		return QualifyingPropertiesValidationResult{}, err
+	}
+	signedPropertiesElement, err := mustFoundOnlyOneChildElement(qualifyingPropertiesElement, signedPropertiesElementTag)
+	if err != nil {
+		return QualifyingPropertiesValidationResult{}, err
+	}
+	signedPropertiesID, err := mustFoundAttribute(signedPropertiesElement, idAttributeKey)
+	if err != nil {
+		return QualifyingPropertiesValidationResult{}, err
+	}
+	signedSignaturePropertiesElement, err := mustFoundOnlyOneChildElement(signedPropertiesElement, signedSignaturePropertiesElementTag)
+	if err != nil {
+		return QualifyingPropertiesValidationResult{}, err
+	}
+	isReferenced, err := isOnlyElementWithID(signedPropertiesElement, signedPropertiesID.Value)
+	if err != nil {
+		return QualifyingPropertiesValidationResult{}, err
+	}
+	result := QualifyingPropertiesValidationResult{}
+	for _, referenceValidationResult := range xmldsigResult.ReferenceValidationResults {
+		if referenceValidationResult.URI == "#"+signedPropertiesID.Value && referenceValidationResult.Type == SignedPropertiesReferenceType {
+			result.IsSignedPropertiesReferenceValid = isReferenced && referenceValidationResult.IsValid
+			break
+		}
+	}
+
+	signingTimeElement, err := mustFoundOnlyOneIfFound(signedSignaturePropertiesElement, signingTimeElementTag)
+	if err != nil {
+		return QualifyingPropertiesValidationResult{}, err
+	}
+	if signingTimeElement != nil {
+		result.SigningTime, err = parseXSDDateTime(signingTimeElement.Text())
+		if err != nil {
+			return QualifyingPropertiesValidationResult{}, fmt.Errorf("SigningTime is not a valid xsd:dateTime: %w", err)
+		}
+	}
+
+	if xmldsigResult.SignerCertificate != nil {
+		result.IsSigningCertificateDigestValid, result.IsSigningCertificateIssuerSerialValid, err = validateSigningCertificate(signedSignaturePropertiesElement, xmldsigResult.SignerCertificate)
+		if err != nil {
+			return QualifyingPropertiesValidationResult{}, err
+		}
+	}
+
+	signaturePolicyIdentifierElement, err := mustFoundOnlyOneIfFound(signedSignaturePropertiesElement, signaturePolicyIdentifierElementTag)
+	if err != nil {
+		return QualifyingPropertiesValidationResult{}, err
+	}
+	if signaturePolicyIdentifierElement != nil {
+		signaturePolicyValidationResult, err := validateSignaturePolicyIdentifier(signaturePolicyIdentifierElement, validator.xmldsigSignatureValidator.signedInfoFactory, validator.xmldsigSignatureValidator.defaultCanonicalizationAlgorithm, validator.signaturePolicyResolver)
+		if err != nil {
+			return QualifyingPropertiesValidationResult{}, fmt.Errorf("at SignaturePolicyIdentifier element: %w", err)
+		}
+		result.SignaturePolicyValidationResult = &signaturePolicyValidationResult
+	}
+	result.TimeStampValidationResults, err = validator.validateTimeStamps(xmlBytes, signatureElement, qualifyingPropertiesElement, validationData)
+	if err != nil {
+		return QualifyingPropertiesValidationResult{}, err
+	}
+	referable, err := referableValidationDataOf(signatureElement, qualifyingPropertiesElement, validationData, xmldsigResult.CertificateValidationResult)
+	if err != nil {
+		return QualifyingPropertiesValidationResult{}, err
+	}
+	result.CompleteReferencesValidationResult, err = validateCompleteReferences(qualifyingPropertiesElement, referable)
+	if err != nil {
+		return QualifyingPropertiesValidationResult{}, err
+	}
+	result.IsValid = result.IsSignedPropertiesReferenceValid && result.IsSigningCertificateDigestValid && result.IsSigningCertificateIssuerSerialValid &&
+		(result.SignaturePolicyValidationResult == nil || !result.SignaturePolicyValidationResult.IsPolicyHashChecked || result.SignaturePolicyValidationResult.IsPolicyHashValid) &&
+		(result.CompleteReferencesValidationResult == nil || result.CompleteReferencesValidationResult.IsValid)
+	for _, timeStampValidationResult := range result.TimeStampValidationResults {
+		result.IsValid = result.IsValid && timeStampValidationResult.IsValid
+	}
+	result.CounterSignatureValidationResults, err = validator.validateCounterSignatures(xmlBytes, signatureElement, qualifyingPropertiesElement)
+	if err != nil {
+		return QualifyingPropertiesValidationResult{}, err
+	}
+	for _, counterSignatureValidationResult := range result.CounterSignatureValidationResults {
+		result.IsValid = result.IsValid && counterSignatureValidationResult.IsValid
+	}
+	if baselineProfile != 0 {
+		violations := checkBaselineProfile(baselineProfile, signatureElement, qualifyingPropertiesElement, xmldsigResult.SignerCertificate)
+		result.BaselineProfileValidationResult = &BaselineProfileValidationResult{
+			Profile:      baselineProfile,
+			IsConformant: len(violations) == 0,
+			Violations:   violations,
+		}
+		result.IsValid = result.IsValid && result.BaselineProfileValidationResult.IsConformant
+	}
+	return result, nil
+}
+
+// validateTimeStamps validates AllDataObjectsTimeStamp elements of SignedDataObjectProperties element
+// and SignatureTimeStamp, SigAndRefsTimeStamp(V2), RefsOnlyTimeStamp(V2) and ArchiveTimeStamp elements of UnsignedSignatureProperties element.
+// The TSA certificates are validated against TrustStore of the validator with the help of the certificates of validationData.
+func (validator *XAdESSignatureValidator) validateTimeStamps(xmlBytes []byte, signatureElement *etree.Element, qualifyingPropertiesElement *etree.Element, validationData embeddedValidationData) ([]TimeStampValidationResult, error) {
+	signedInfoFactory := validator.xmldsigSignatureValidator.signedInfoFactory
+	type timeStampProperty struct {
+		element           *etree.Element
+		timeStampedDataOf func(canonicalizationAlgorithm string) ([]byte, error)
+	}
+	timeStampProperties := make([]timeStampProperty, 0)
+	for _, timeStampElement := range qualifyingPropertiesElement.FindElements("./" + signedPropertiesElementTag + "/" + signedDataObjectPropertiesElementTag + "/" + allDataObjectsTimeStampElementTag) {
+		timeStampProperties = append(timeStampProperties, timeStampProperty{element: timeStampElement, timeStampedDataOf: func(canonicalizationAlgorithm string) ([]byte, error) {
+			signedInfoElement, err := mustFoundOnlyOneChildElement(signatureElement, signedInfoElementTag)
+			if err != nil {
+				return nil, err
+			}
+			return allDataObjectsTimeStampInputOf(signedInfoFactory, validator.xmldsigSignatureValidator.uriResolver, xmlBytes, signedInfoElement, canonicalizationAlgorithm)
+		}})
+	}
+	for _, timeStampElement := range qualifyingPropertiesElement.FindElements("./" + unsignedPropertiesElementTag + "/" + unsignedSignaturePropertiesElementTag + "/" + signatureTimeStampElementTag) {
+		timeStampProperties = append(timeStampProperties, timeStampProperty{element: timeStampElement, timeStampedDataOf: func(canonicalizationAlgorithm string) ([]byte, error) {
+			return signatureTimeStampInputOf(signedInfoFactory, xmlBytes, signatureElement, canonicalizationAlgorithm)
+		}})
+	}
+	for _, tag := range []string{sigAndRefsTimeStampElementTag, sigAndRefsTimeStampV2ElementTag} {
+		for _, timeStampElement := range findUnsignedSignaturePropertyElements(qualifyingPropertiesElement, tag) {
+			sigAndRefsTimeStampElement := timeStampElement
+			timeStampProperties = append(timeStampProperties, timeStampProperty{element: sigAndRefsTimeStampElement, timeStampedDataOf: func(canonicalizationAlgorithm string) ([]byte, error) {
+				return sigAndRefsTimeStampInputOf(signedInfoFactory, xmlBytes, signatureElement, qualifyingPropertiesElement, sigAndRefsTimeStampElement, canonicalizationAlgorithm)
+			}})
+		}
+	}
+	for _, tag := range []string{refsOnlyTimeStampElementTag, refsOnlyTimeStampV2ElementTag} {
+		for _, timeStampElement := range findUnsignedSignaturePropertyElements(qualifyingPropertiesElement, tag) {
+			refsOnlyTimeStampElement := timeStampElement
+			timeStampProperties = append(timeStampProperties, timeStampProperty{element: refsOnlyTimeStampElement, timeStampedDataOf: func(canonicalizationAlgorithm string) ([]byte, error) {
+				return refsOnlyTimeStampInputOf(signedInfoFactory, xmlBytes, signatureElement, qualifyingPropertiesElement, refsOnlyTimeStampElement, canonicalizationAlgorithm)
+			}})
+		}
+	}
+	for _, timeStampElement := range findUnsignedSignaturePropertyElements(qualifyingPropertiesElement, archiveTimeStampElementTag) {
+		archiveTimeStampElement := timeStampElement
+		timeStampProperties = append(timeStampProperties, timeStampProperty{element: archiveTimeStampElement, timeStampedDataOf: func(canonicalizationAlgorithm string) ([]byte, error) {
+			return archiveTimeStampInputOf(signedInfoFactory, validator.xmldsigSignatureValidator.uriResolver, xmlBytes, signatureElement, qualifyingPropertiesElement, archiveTimeStampElement, canonicalizationAlgorithm)
+		}})
+	}
+	var results []TimeStampValidationResult
+	for _, property := range timeStampProperties {
+		timeStampResults, err := validateTimeStampElement(property.element, property.timeStampedDataOf, validator.xmldsigSignatureValidator.trustStore, validationData.certificates)
+		if err != nil {
+			return nil, fmt.Errorf("at %s element: %w", property.element.Tag, err)
+		}
+		results = append(results, timeStampResults...)
+	}
+	return results, nil
+}
+
+// bestSignatureTimeOf returns the earliest GenTime among the valid time-stamp tokens of SignatureTimeStamp elements of qualifyingPropertiesElement,
+// which proves that signatureElement existed at that time. It returns zero time if there is no valid one.
+func (validator *XAdESSignatureValidator) bestSignatureTimeOf(xmlBytes []byte, signatureElement *etree.Element, qualifyingPropertiesElement *etree.Element, validationData embeddedValidationData) (time.Time, error) {
+	var bestSignatureTime time.Time
+	for _, timeStampElement := range qualifyingPropertiesElement.FindElements("./" + unsignedPropertiesElementTag + "/" + unsignedSignaturePropertiesElementTag + "/" + signatureTimeStampElementTag) {
+		timeStampResults, err := validateTimeStampElement(timeStampElement, func(canonicalizationAlgorithm string) ([]byte, error) {
+			return signatureTimeStampInputOf(validator.xmldsigSignatureValidator.signedInfoFactory, xmlBytes, signatureElement, canonicalizationAlgorithm)
+		}, validator.xmldsigSignatureValidator.trustStore, validationData.certificates)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("at %s element: %w", timeStampElement.Tag, err)
+		}
+		for _, timeStampResult := range timeStampResults {
+			if timeStampResult.IsValid && (bestSignatureTime.IsZero() || timeStampResult.GenTime.Before(bestSignatureTime)) {
+				bestSignatureTime = timeStampResult.GenTime
+			}
+		}
+	}
+	return bestSignatureTime, nil
+}
+
+// embeddedValidationDataOf parses the certificates and revocation data of CertificateValues and RevocationValues elements in UnsignedSignatureProperties element of qualifyingPropertiesElement,
+// including the ones in TimeStampValidationData elements.
+func embeddedValidationDataOf(qualifyingPropertiesElement *etree.Element) (embeddedValidationData, error) {
+	var validationData embeddedValidationData
+	unsignedSignaturePropertiesPath := "./" + unsignedPropertiesElementTag + "/" + unsignedSignaturePropertiesElementTag + "//"
+	for _, certificateValuesElement := range qualifyingPropertiesElement.FindElements(unsignedSignaturePropertiesPath + certificateValuesElementTag) {
+		certificates, err := parseEncapsulatedX509Certificates(certificateValuesElement)
+		if err != nil {
+			return embeddedValidationData{}, fmt.Errorf("at CertificateValues element: %w", err)
+		}
+		validationData.certificates = append(validationData.certificates, certificates...)
+	}
+	for _, revocationValuesElement := range qualifyingPropertiesElement.FindElements(unsignedSignaturePropertiesPath + revocationValuesElementTag) {
+		crls, ocspResponses, err := parseEncapsulatedRevocationValues(revocationValuesElement)
+		if err != nil {
+			return embeddedValidationData{}, fmt.Errorf("at RevocationValues element: %w", err)
+		}
+		validationData.crls = append(validationData.crls, crls...)
+		validationData.ocspResponses = append(validationData.ocspResponses, ocspResponses...)
+	}
+	return validationData, nil
+}
+
+// findQualifyingPropertiesElement finds QualifyingProperties element (in any Object element of signatureElement) whose Target attribute points to signatureElement.
+func findQualifyingPropertiesElement(signatureElement *etree.Element) (*etree.Element, error) {
+	signatureID := signatureElement.SelectAttrValue(idAttributeKey, "")
+	if signatureID == "" {
+		return nil, errors.New("Signature element must have Id attribute to be targeted by QualifyingProperties element")
+	}
+	var found *etree.Element
+	for _, objectElement := range signatureElement.SelectElements(objectElementTag) {
+		for _, qualifyingPropertiesElement := range objectElement.SelectElements(qualifyingPropertiesElementTag) {
+			if qualifyingPropertiesElement.SelectAttrValue(targetAttributeKey, "") != "#"+signatureID {
+				continue
+			}
+			if found != nil {
+				return nil, fmt.Errorf("found more than one QualifyingProperties element targeting #%s", signatureID)
+			}
+			found = qualifyingPropertiesElement
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("QualifyingProperties element targeting #%s was not found", signatureID)
+	}
+	return found, nil
+}
+
+// isOnlyElementWithID reports whether element is the element that a same-document URI "#"+id dereferences to, that is, the only element of its document whose Id attribute is id.
+// It returns error if more than one element has the Id, as another element could then be digested in place of element (signature wrapping).
+func isOnlyElementWithID(element *etree.Element, id string) (bool, error) {
+	elementsWithID := findElementsByID(element, id)
+	if len(elementsWithID) > 1 {
+		return false, fmt.Errorf("found more than one element with Id %s, the element referenced by #%s is ambiguous", id, id)
+	}
+	return len(elementsWithID) == 1 && elementsWithID[0] == element, nil
+}
+
+// validateSigningCertificate finds the Cert element (of SigningCertificateV2 or SigningCertificate element) whose CertDigest matches signerCertificate and checks its issuer and serial number.
+// IssuerSerialV2 is optional in SigningCertificateV2, so its absence is not considered as a mismatch.
+func validateSigningCertificate(signedSignaturePropertiesElement *etree.Element, signerCertificate *x509.Certificate) (isDigestValid bool, isIssuerSerialValid bool, err error) {
+	signingCertificateElement, err := mustFoundOnlyOneIfFound(signedSignaturePropertiesElement, signingCertificateV2ElementTag)
+	if err != nil {
+		return false, false, err
+	}
+	isV2 := signingCertificateElement != nil
+	if !isV2 {
+		signingCertificateElement, err = mustFoundOnlyOneIfFound(signedSignaturePropertiesElement, signingCertificateElementTag)
+		if err != nil {
+			return false, false, err
+		}
+	}
+	if signingCertificateElement == nil {
+		return false, false, nil
+	}
+	for certIndex, certElement := range signingCertificateElement.SelectElements(certElementTag) {
+		isMatched, err := isCertDigestMatched(certElement, signerCertificate)
+		if err != nil {
+			return false, false, fmt.Errorf("at Cert#%d element: %w", certIndex, err)
+		}
+		if !isMatched {
+			continue
+		}
+		if isV2 {
+			return true, isIssuerSerialV2Matched(certElement, signerCertificate), nil
+		}
+		return true, isIssuerSerialMatched(certElement, signerCertificate), nil
+	}
+	return false, false, nil
+}
+
+func isCertDigestMatched(certElement *etree.Element, certificate *x509.Certificate) (bool, error) {
+	certDigestElement, err := mustFoundOnlyOneChildElement(certElement, certDigestElementTag)
+	if err != nil {
+		return false, err
+	}
+	digestMethodElement, err := mustFoundOnlyOneChildElement(certDigestElement, digestMethodElementTag)
+	if err != nil {
+		return false, err
+	}
+	algorithmAttribute, err := mustFoundAttribute(digestMethodElement, algorithmAttributeKey)
+	if err != nil {
+		return false, err
+	}
+	digestValueElement, err := mustFoundOnlyOneChildElement(certDigestElement, digestValueElementTag)
+	if err != nil {
+		return false, err
+	}
+	digester, err := CreateDigester(algorithmAttribute.Value)
+	if err != nil {
+		return false, err
+	}
+	generatedDigestValue, err := digester.Digest(certificate.Raw)
+	if err != nil {
+		return false, err
+	}
+	return string(generatedDigestValue) == strings.Join(strings.Fields(digestValueElement.Text()), ""), nil
+}
+
+func isIssuerSerialMatched(certElement *etree.Element, certificate *x509.Certificate) bool {
+	issuerSerialElement := certElement.SelectElement(issuerSerialElementTag)
+	if issuerSerialElement == nil {
+		return false
+	}
+	issuerNameElement := issuerSerialElement.SelectElement(x509IssuerNameElementTag)
+	serialNumberElement := issuerSerialElement.SelectElement(x509SerialNumberElementTag)
+	if issuerNameElement == nil || serialNumberElement == nil {
+		return false
+	}
+	serialNumber, ok := new(big.Int).SetString(strings.TrimSpace(serialNumberElement.Text()), 10)
+	if !ok || serialNumber.Cmp(certificate.SerialNumber) != 0 {
+		return false
+	}
+	return isSameDistinguishedName(issuerNameElement.Text(), certificate.RawIssuer)
+}
+
+func isIssuerSerialV2Matched(certElement *etree.Element, certificate *x509.Certificate) bool {
+	issuerSerialV2Element := certElement.SelectElement(issuerSerialV2ElementTag)
+	if issuerSerialV2Element == nil {
+		return true
+	}
+	encoded, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(issuerSerialV2Element.Text()), ""))
+	if err != nil {
+		return false
+	}
+	var decoded issuerSerial
+	rest, err := asn1.Unmarshal(encoded, &decoded)
+	if err != nil || len(rest) > 0 {
+		return false
+	}
+	if decoded.SerialNumber == nil || decoded.SerialNumber.Cmp(certificate.SerialNumber) != 0 {
+		return false
+	}
+	for _, generalName := range decoded.Issuer {
+		if generalName.Class == asn1.ClassContextSpecific && generalName.Tag == 4 && string(generalName.Bytes) == string(certificate.RawIssuer) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseXSDDateTime parses xsd:dateTime. A value without time zone is interpreted as UTC.
+func parseXSDDateTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	parsed, err := time.Parse(time.RFC3339Nano, value)
+	if err == nil {
+		return parsed, nil
+	}
+	return time.Parse("2006-01-02T15:04:05.999999999", value)
+}
@@ -0,0 +1,990 @@
+package xades4go_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/mekpavit/xades4go"
+	"github.com/mekpavit/xades4go/etreeimpl"
+)
+
+func Test_XAdESSignatureGenerator(t *testing.T) {
+	privateKey, certificate := mustCreateRSAKeyAndSelfSignedCertificate(t)
+	signedInfoFactory := etreeimpl.NewSignedInfoFactory()
+	signingTime := time.Date(2021, 2, 5, 10, 30, 0, 0, time.FixedZone("ICT", 7*60*60))
+	generator, err := xades4go.NewXAdESSignatureGenerator(signedInfoFactory, privateKey, []*x509.Certificate{certificate}, xades4go.RSASHA256SignatureAlgorithm,
+		xades4go.GenerateWithSigningTime(func() time.Time { return signingTime }),
+		xades4go.GenerateWithDigestAlgorithm(xades4go.SHA512MessageDigestAlgotithm),
+	)
+	if err != nil {
+		t.Fatalf("NewXAdESSignatureGenerator() error = %v", err)
+	}
+	validator := xades4go.NewXMLDSigSignatureValidator(signedInfoFactory)
+	tests := []struct {
+		name         string
+		sign         func() ([]byte, error)
+		wantMimeType []string
+	}{
+		{
+			name: "When enveloped signature is generated, QualifyingProperties should be signed",
+			sign: func() ([]byte, error) {
+				return generator.SignXMLBytes([]byte(unsignedInvoice), []xades4go.ReferenceGenerationDetail{
+					{
+						URIOfDataObjectBeingSigned: "",
+						TransformAlgorithms:        []string{xades4go.EnvelopedSignatureTransformAlgorithm, xades4go.CanonicalXML10Algorithm},
+						DigestAlgorithm:            xades4go.SHA512MessageDigestAlgotithm,
+					},
+				})
+			},
+			wantMimeType: []string{"text/xml"},
+		},
+		{
+			name: "When enveloping signature is generated, QualifyingProperties should be signed",
+			sign: func() ([]byte, error) {
+				return generator.(xades4go.EnvelopingSigner).SignEnveloping([]xades4go.DataObjectGenerationDetail{
+					{
+						MimeType:        "application/pdf",
+						Content:         []byte("%PDF-1.4 not really a PDF"),
+						IsOctetStream:   true,
+						DigestAlgorithm: xades4go.SHA256MessageDigestAlgorithm,
+					},
+				})
+			},
+			wantMimeType: []string{"application/pdf"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signedXMLBytes, err := tt.sign()
+			if err != nil {
+				t.Fatalf("sign error = %v", err)
+			}
+			got, err := validator.Validate(signedXMLBytes)
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if !got.IsSignatureValid {
+				t.Errorf("Validate() IsSignatureValid = false, want true")
+			}
+			if len(got.ReferenceValidationResults) != len(tt.wantMimeType)+1 {
+				t.Fatalf("Validate() got %d reference results, want %d", len(got.ReferenceValidationResults), len(tt.wantMimeType)+1)
+			}
+			for referenceIndex, referenceValidationResult := range got.ReferenceValidationResults {
+				if !referenceValidationResult.IsValid {
+					t.Errorf("Validate() Reference#%d is invalid: %+v", referenceIndex, referenceValidationResult)
+				}
+			}
+
+			doc := etree.NewDocument()
+			if err := doc.ReadFromBytes(signedXMLBytes); err != nil {
+				t.Fatalf("cannot parse signed XML: %v", err)
+			}
+			signatureElement := doc.FindElement("//Signature")
+			signatureID := signatureElement.SelectAttrValue("Id", "")
+			qualifyingPropertiesElement := signatureElement.FindElement("./Object/QualifyingProperties")
+			if qualifyingPropertiesElement == nil {
+				t.Fatalf("QualifyingProperties element was not found")
+			}
+			if diff := cmp.Diff("#"+signatureID, qualifyingPropertiesElement.SelectAttrValue("Target", "")); diff != "" {
+				t.Errorf("Target mismatch (-want+got):\n%s", diff)
+			}
+			signedPropertiesReference := signatureElement.FindElement("./SignedInfo/Reference[@Type='" + xades4go.SignedPropertiesReferenceType + "']")
+			signedPropertiesElement := qualifyingPropertiesElement.SelectElement("SignedProperties")
+			if signedPropertiesReference == nil || signedPropertiesElement == nil {
+				t.Fatalf("SignedProperties or the Reference to it was not found")
+			}
+			if diff := cmp.Diff("#"+signedPropertiesElement.SelectAttrValue("Id", ""), signedPropertiesReference.SelectAttrValue("URI", "")); diff != "" {
+				t.Errorf("SignedProperties Reference URI mismatch (-want+got):\n%s", diff)
+			}
+			if diff := cmp.Diff("2021-02-05T03:30:00Z", signedPropertiesElement.FindElement("./SignedSignatureProperties/SigningTime").Text()); diff != "" {
+				t.Errorf("SigningTime mismatch (-want+got):\n%s", diff)
+			}
+			certElement := signedPropertiesElement.FindElement("./SignedSignatureProperties/SigningCertificateV2/Cert")
+			if diff := cmp.Diff(xades4go.SHA512MessageDigestAlgotithm, certElement.FindElement("./CertDigest/DigestMethod").SelectAttrValue("Algorithm", "")); diff != "" {
+				t.Errorf("CertDigest DigestMethod mismatch (-want+got):\n%s", diff)
+			}
+			digester, _ := xades4go.CreateDigester(xades4go.SHA512MessageDigestAlgotithm)
+			wantCertDigest, _ := digester.Digest(certificate.Raw)
+			if diff := cmp.Diff(string(wantCertDigest), certElement.FindElement("./CertDigest/DigestValue").Text()); diff != "" {
+				t.Errorf("CertDigest DigestValue mismatch (-want+got):\n%s", diff)
+			}
+			issuerSerialV2, err := base64.StdEncoding.DecodeString(certElement.SelectElement("IssuerSerialV2").Text())
+			if err != nil {
+				t.Fatalf("IssuerSerialV2 is not base64-encoded: %v", err)
+			}
+			var gotIssuerSerial struct {
+				Issuer       []asn1.RawValue
+				SerialNumber *big.Int
+			}
+			if _, err := asn1.Unmarshal(issuerSerialV2, &gotIssuerSerial); err != nil {
+				t.Fatalf("IssuerSerialV2 is not DER-encoded IssuerSerial: %v", err)
+			}
+			if gotIssuerSerial.SerialNumber.Cmp(certificate.SerialNumber) != 0 || len(gotIssuerSerial.Issuer) != 1 || string(gotIssuerSerial.Issuer[0].Bytes) != string(certificate.RawIssuer) {
+				t.Errorf("IssuerSerialV2 does not identify the signing certificate")
+			}
+			gotMimeType := []string{}
+			for _, dataObjectFormatElement := range signedPropertiesElement.FindElements("./SignedDataObjectProperties/DataObjectFormat") {
+				objectReference := dataObjectFormatElement.SelectAttrValue("ObjectReference", "")
+				if signatureElement.FindElement("./SignedInfo/Reference[@Id='"+objectReference[1:]+"']") == nil {
+					t.Errorf("DataObjectFormat refers to unknown Reference %s", objectReference)
+				}
+				gotMimeType = append(gotMimeType, dataObjectFormatElement.SelectElement("MimeType").Text())
+			}
+			if diff := cmp.Diff(tt.wantMimeType, gotMimeType); diff != "" {
+				t.Errorf("MimeType mismatch (-want+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_XAdESSignatureValidator(t *testing.T) {
+	signedInfoFactory := etreeimpl.NewSignedInfoFactory()
+	signingTime := time.Date(2021, 2, 5, 3, 30, 0, 0, time.UTC)
+	signerKey, signerCertificate := mustCreateRSAKeyAndSelfSignedCertificate(t)
+	_, otherCertificate := mustCreateRSAKeyAndSelfSignedCertificate(t)
+	envelopedReference := []xades4go.ReferenceGenerationDetail{
+		{
+			URIOfDataObjectBeingSigned: "",
+			TransformAlgorithms:        []string{xades4go.EnvelopedSignatureTransformAlgorithm},
+			DigestAlgorithm:            xades4go.SHA256MessageDigestAlgorithm,
+		},
+	}
+	mustSign := func(generator xades4go.SignatureGenerator, err error) []byte {
+		if err != nil {
+			t.Fatalf("cannot create generator: %v", err)
+		}
+		signedXMLBytes, err := generator.SignXMLBytes([]byte(unsignedInvoice), envelopedReference)
+		if err != nil {
+			t.Fatalf("SignXMLBytes() error = %v", err)
+		}
+		return signedXMLBytes
+	}
+	tests := []struct {
+		name     string
+		xmlBytes []byte
+		want     *xades4go.QualifyingPropertiesValidationResult
+		wantErr  bool
+	}{
+		{
+			name: "When XAdES-BES signature is generated by XAdESSignatureGenerator, it should pass the validation",
+			xmlBytes: mustSign(xades4go.NewXAdESSignatureGenerator(signedInfoFactory, signerKey, []*x509.Certificate{signerCertificate}, xades4go.RSASHA256SignatureAlgorithm,
+				xades4go.GenerateWithSigningTime(func() time.Time { return signingTime }),
+			)),
+			want: &xades4go.QualifyingPropertiesValidationResult{
+				IsValid:                               true,
+				IsSignedPropertiesReferenceValid:      true,
+				IsSigningCertificateDigestValid:       true,
+				IsSigningCertificateIssuerSerialValid: true,
+				SigningTime:                           signingTime,
+			},
+			wantErr: false,
+		},
+		{
+			name: "When SigningCertificateV2 does not refer to the certificate that verifies SignatureValue, it should fail the validation",
+			xmlBytes: mustSign(xades4go.NewXAdESSignatureGenerator(signedInfoFactory, signerKey, []*x509.Certificate{otherCertificate, signerCertificate}, xades4go.RSASHA256SignatureAlgorithm,
+				xades4go.GenerateWithSigningTime(func() time.Time { return signingTime }),
+			)),
+			want: &xades4go.QualifyingPropertiesValidationResult{
+				IsValid:                               false,
+				IsSignedPropertiesReferenceValid:      true,
+				IsSigningCertificateDigestValid:       false,
+				IsSigningCertificateIssuerSerialValid: false,
+				SigningTime:                           signingTime,
+			},
+			wantErr: false,
+		},
+		{
+			name:     "When X509IssuerName of SigningCertificate does not name the issuer of the signer certificate, it should fail the validation",
+			xmlBytes: []byte(etdaSignedTaxInvoice),
+			want: &xades4go.QualifyingPropertiesValidationResult{
+				IsValid:                               false,
+				IsSignedPropertiesReferenceValid:      true,
+				IsSigningCertificateDigestValid:       true,
+				IsSigningCertificateIssuerSerialValid: false,
+				SigningTime:                           time.Date(2020, 8, 17, 18, 27, 35, 0, time.FixedZone("", 7*60*60)),
+			},
+			wantErr: false,
+		},
+		{
+			name: "When a copy of SignedProperties with the same Id precedes the Signature (signature wrapping), it should return error",
+			xmlBytes: mustWrapSignedProperties(t, mustSign(xades4go.NewXAdESSignatureGenerator(signedInfoFactory, signerKey, []*x509.Certificate{signerCertificate}, xades4go.RSASHA256SignatureAlgorithm,
+				xades4go.GenerateWithSigningTime(func() time.Time { return signingTime }),
+			)), "1999-01-01T00:00:00Z"),
+			wantErr: true,
+		},
+		{
+			name:     "When the signature does not have QualifyingProperties, it should return error",
+			xmlBytes: mustSign(xades4go.NewXMLDSigSignatureGenerator(signedInfoFactory, signerKey, []*x509.Certificate{signerCertificate}, xades4go.RSASHA256SignatureAlgorithm)),
+			wantErr:  true,
+		},
+	}
+	validator := xades4go.NewXAdESSignatureValidator(signedInfoFactory)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := validator.Validate(tt.xmlBytes)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if !got.IsSignatureValid {
+				t.Errorf("Validate() IsSignatureValid = false, want true")
+			}
+			if diff := cmp.Diff(tt.want, got.QualifyingPropertiesValidationResult, cmp.Comparer(func(x, y time.Time) bool { return x.Equal(y) })); diff != "" {
+				t.Errorf("Validate() QualifyingPropertiesValidationResult mismatch (-want+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_XAdESSignatureValidator_IDAttributes(t *testing.T) {
+	signedInfoFactory := etreeimpl.NewSignedInfoFactory()
+	signerKey, signerCertificate := mustCreateRSAKeyAndSelfSignedCertificate(t)
+	generator, err := xades4go.NewXAdESSignatureGenerator(signedInfoFactory, signerKey, []*x509.Certificate{signerCertificate}, xades4go.RSASHA256SignatureAlgorithm)
+	if err != nil {
+		t.Fatalf("NewXAdESSignatureGenerator() error = %v", err)
+	}
+	signedXMLBytes, err := generator.SignXMLBytes([]byte(`<Document><Invoice Id="invoice"><ID>INV01</ID></Invoice></Document>`), []xades4go.ReferenceGenerationDetail{
+		{URIOfDataObjectBeingSigned: "#invoice", DigestAlgorithm: xades4go.SHA256MessageDigestAlgorithm},
+	})
+	if err != nil {
+		t.Fatalf("SignXMLBytes() error = %v", err)
+	}
+	// modify parses signedXMLBytes, lets change modify the document and serializes it back.
+	modify := func(change func(doc *etree.Document)) []byte {
+		doc := etree.NewDocument()
+		if err := doc.ReadFromBytes(signedXMLBytes); err != nil {
+			t.Fatalf("cannot parse signed XML: %v", err)
+		}
+		change(doc)
+		modifiedXMLBytes, err := doc.WriteToBytes()
+		if err != nil {
+			t.Fatalf("cannot serialize XML: %v", err)
+		}
+		return modifiedXMLBytes
+	}
+	tests := []struct {
+		name                 string
+		xmlBytes             []byte
+		wantIsSignatureValid bool
+		wantErr              bool
+	}{
+		{
+			name:                 "When the referenced data object is the only element with its Id, it should be valid",
+			xmlBytes:             signedXMLBytes,
+			wantIsSignatureValid: true,
+		},
+		{
+			name: "When a copy of the referenced data object with the same Id precedes it (signature wrapping), it should return error",
+			xmlBytes: modify(func(doc *etree.Document) {
+				invoiceElement := doc.FindElement("//Invoice")
+				doc.Root().InsertChildAt(invoiceElement.Index(), invoiceElement.Copy())
+				invoiceElement.FindElement("./ID").SetText("INV02")
+			}),
+			wantErr: true,
+		},
+		{
+			name: "When SignedProperties Id has a quote, it should be invalid without panic",
+			xmlBytes: modify(func(doc *etree.Document) {
+				signedPropertiesElement := doc.FindElement("//SignedProperties")
+				signedPropertiesID := signedPropertiesElement.SelectAttrValue("Id", "")
+				signedPropertiesElement.CreateAttr("Id", "a'b")
+				for _, referenceElement := range doc.FindElements("//Reference") {
+					if referenceElement.SelectAttrValue("URI", "") == "#"+signedPropertiesID {
+						referenceElement.CreateAttr("URI", "#a'b")
+					}
+				}
+			}),
+			wantIsSignatureValid: false,
+		},
+	}
+	validator := xades4go.NewXAdESSignatureValidator(signedInfoFactory)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := validator.Validate(tt.xmlBytes)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.IsSignatureValid != tt.wantIsSignatureValid {
+				t.Errorf("Validate() IsSignatureValid = %v, want %v", got.IsSignatureValid, tt.wantIsSignatureValid)
+			}
+		})
+	}
+}
+
+func Test_XAdESSignatureGenerator_SignaturePolicyUserNotice(t *testing.T) {
+	signedInfoFactory := etreeimpl.NewSignedInfoFactory()
+	signerKey, signerCertificate := mustCreateRSAKeyAndSelfSignedCertificate(t)
+	tests := []struct {
+		name       string
+		userNotice *xades4go.SignaturePolicyUserNotice
+		wantErr    bool
+	}{
+		{
+			name:       "When both Organization and NoticeNumbers are given, it should sign",
+			userNotice: &xades4go.SignaturePolicyUserNotice{Organization: "Electronic Transactions Development Agency", NoticeNumbers: []int{1}},
+			wantErr:    false,
+		},
+		{
+			name:       "When neither Organization nor NoticeNumbers is given, it should sign without NoticeRef",
+			userNotice: &xades4go.SignaturePolicyUserNotice{ExplicitText: "Signed under e-Tax Invoice policy"},
+			wantErr:    false,
+		},
+		{
+			name:       "When NoticeNumbers are given without Organization, it should return error",
+			userNotice: &xades4go.SignaturePolicyUserNotice{NoticeNumbers: []int{1}},
+			wantErr:    true,
+		},
+		{
+			name:       "When Organization is given without NoticeNumbers, it should return error",
+			userNotice: &xades4go.SignaturePolicyUserNotice{Organization: "Electronic Transactions Development Agency"},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator, err := xades4go.NewXAdESSignatureGenerator(signedInfoFactory, signerKey, []*x509.Certificate{signerCertificate}, xades4go.RSASHA256SignatureAlgorithm,
+				xades4go.GenerateWithSignaturePolicy(xades4go.SignaturePolicyGenerationDetail{
+					Identifier:      "urn:oid:2.16.764.1.4.100.1",
+					Document:        []byte("policy"),
+					DigestAlgorithm: xades4go.SHA256MessageDigestAlgorithm,
+					SPUserNotice:    tt.userNotice,
+				}),
+			)
+			if err != nil {
+				t.Fatalf("NewXAdESSignatureGenerator() error = %v", err)
+			}
+			_, err = generator.SignXMLBytes([]byte(unsignedInvoice), []xades4go.ReferenceGenerationDetail{
+				{
+					URIOfDataObjectBeingSigned: "",
+					TransformAlgorithms:        []string{xades4go.EnvelopedSignatureTransformAlgorithm},
+					DigestAlgorithm:            xades4go.SHA256MessageDigestAlgorithm,
+				},
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SignXMLBytes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_XAdESSignatureValidator_SignaturePolicy(t *testing.T) {
+	signedInfoFactory := etreeimpl.NewSignedInfoFactory()
+	signerKey, signerCertificate := mustCreateRSAKeyAndSelfSignedCertificate(t)
+	policyIdentifier := "urn:oid:2.16.764.1.4.100.1"
+	policyDocument := []byte(`<SignaturePolicy xmlns="urn:example:policy"><Rule id="1">Invoice must be signed by the seller</Rule></SignaturePolicy>`)
+	userNotice := &xades4go.SignaturePolicyUserNotice{
+		Organization:  "Electronic Transactions Development Agency",
+		NoticeNumbers: []int{1, 2},
+		ExplicitText:  "Signed under e-Tax Invoice policy",
+	}
+	generator, err := xades4go.NewXAdESSignatureGenerator(signedInfoFactory, signerKey, []*x509.Certificate{signerCertificate}, xades4go.RSASHA256SignatureAlgorithm,
+		xades4go.GenerateWithSignaturePolicy(xades4go.SignaturePolicyGenerationDetail{
+			Identifier:          policyIdentifier,
+			IdentifierQualifier: "OIDAsURN",
+			Description:         "e-Tax Invoice signature policy",
+			Document:            policyDocument,
+			TransformAlgorithms: []string{xades4go.CanonicalXML10Algorithm},
+			DigestAlgorithm:     xades4go.SHA256MessageDigestAlgorithm,
+			SPURI:               "https://example.com/policy.xml",
+			SPUserNotice:        userNotice,
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewXAdESSignatureGenerator() error = %v", err)
+	}
+	signedXMLBytes, err := generator.SignXMLBytes([]byte(unsignedInvoice), []xades4go.ReferenceGenerationDetail{
+		{
+			URIOfDataObjectBeingSigned: "",
+			TransformAlgorithms:        []string{xades4go.EnvelopedSignatureTransformAlgorithm},
+			DigestAlgorithm:            xades4go.SHA256MessageDigestAlgorithm,
+		},
+	})
+	if err != nil {
+		t.Fatalf("SignXMLBytes() error = %v", err)
+	}
+	tests := []struct {
+		name    string
+		options []xades4go.XAdESSignatureValidatorOption
+		want    *xades4go.SignaturePolicyValidationResult
+		wantErr bool
+	}{
+		{
+			name:    "When the policy document is the one used while signing, SigPolicyHash should be valid",
+			options: []xades4go.XAdESSignatureValidatorOption{xades4go.ValidateWithSignaturePolicyResolver(xades4go.NewMapURIResolver(map[string][]byte{policyIdentifier: policyDocument}))},
+			want: &xades4go.SignaturePolicyValidationResult{
+				Identifier:          policyIdentifier,
+				SPURI:               "https://example.com/policy.xml",
+				SPUserNotice:        userNotice,
+				IsPolicyHashChecked: true,
+				IsPolicyHashValid:   true,
+			},
+			wantErr: false,
+		},
+		{
+			name:    "When the policy document is different from the one used while signing, SigPolicyHash should be invalid",
+			options: []xades4go.XAdESSignatureValidatorOption{xades4go.ValidateWithSignaturePolicyResolver(xades4go.NewMapURIResolver(map[string][]byte{policyIdentifier: []byte(`<SignaturePolicy xmlns="urn:example:policy"/>`)}))},
+			want: &xades4go.SignaturePolicyValidationResult{
+				Identifier:          policyIdentifier,
+				SPURI:               "https://example.com/policy.xml",
+				SPUserNotice:        userNotice,
+				IsPolicyHashChecked: true,
+				IsPolicyHashValid:   false,
+			},
+			wantErr: false,
+		},
+		{
+			name:    "When the policy document cannot be resolved, it should return error",
+			options: []xades4go.XAdESSignatureValidatorOption{xades4go.ValidateWithSignaturePolicyResolver(xades4go.NewMapURIResolver(map[string][]byte{}))},
+			wantErr: true,
+		},
+		{
+			name: "When the signature policy resolver is not given, SigPolicyHash should be left unchecked without invalidating the signature",
+			want: &xades4go.SignaturePolicyValidationResult{
+				Identifier:          policyIdentifier,
+				SPURI:               "https://example.com/policy.xml",
+				SPUserNotice:        userNotice,
+				IsPolicyHashChecked: false,
+				IsPolicyHashValid:   false,
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := xades4go.NewXAdESSignatureValidator(signedInfoFactory, tt.options...).Validate(signedXMLBytes)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			wantIsValid := !tt.want.IsPolicyHashChecked || tt.want.IsPolicyHashValid
+			if got.QualifyingPropertiesValidationResult.IsValid != wantIsValid {
+				t.Errorf("Validate() QualifyingPropertiesValidationResult.IsValid = %v, want %v", got.QualifyingPropertiesValidationResult.IsValid, wantIsValid)
+			}
+			if diff := cmp.Diff(tt.want, got.QualifyingPropertiesValidationResult.SignaturePolicyValidationResult, cmpopts.IgnoreFields(xades4go.SignaturePolicyValidationResult{}, "GeneratedDigestValue", "DigestValue")); diff != "" {
+				t.Errorf("Validate() SignaturePolicyValidationResult mismatch (-want+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_XAdESSignatureGenerator_SignatureTimeStamp(t *testing.T) {
+	signedInfoFactory := etreeimpl.NewSignedInfoFactory()
+	signerKey, signerCertificate := mustCreateRSAKeyAndSelfSignedCertificate(t)
+	genTime := time.Date(2021, 2, 5, 3, 30, 0, 0, time.UTC)
+	authority, tsaCertificate := mustCreateTimeStampAuthority(t, genTime)
+	generator, err := xades4go.NewXAdESSignatureGenerator(signedInfoFactory, signerKey, []*x509.Certificate{signerCertificate}, xades4go.RSASHA256SignatureAlgorithm,
+		xades4go.GenerateWithTimeStampProvider(authority),
+	)
+	if err != nil {
+		t.Fatalf("NewXAdESSignatureGenerator() error = %v", err)
+	}
+	signedXMLBytes, err := generator.(xades4go.EnvelopingSigner).SignEnveloping([]xades4go.DataObjectGenerationDetail{
+		{Content: []byte("invoice"), IsOctetStream: true, MimeType: "text/plain", DigestAlgorithm: xades4go.SHA256MessageDigestAlgorithm},
+	})
+	if err != nil {
+		t.Fatalf("SignEnveloping() error = %v", err)
+	}
+	result, err := xades4go.NewXAdESSignatureValidator(signedInfoFactory, xades4go.ValidateWithXMLDSigOptions(
+		xades4go.ValidateWithTrustStore(xades4go.NewTrustStore([]*x509.Certificate{tsaCertificate}, nil)),
+	)).Validate(signedXMLBytes)
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if !result.IsSignatureValid || !result.QualifyingPropertiesValidationResult.IsValid {
+		t.Errorf("Validate() = %+v, want valid signature", result)
+	}
+
+	timeStampToken := mustFindEncapsulatedTimeStamp(t, signedXMLBytes)
+	wantHashedMessage := sha256.Sum256(mustCanonicalizeElement(t, signedInfoFactory, signedXMLBytes, "//Signature/SignatureValue"))
+	tstInfo := mustParseTSTInfo(t, timeStampToken)
+	if !bytes.Equal(tstInfo.MessageImprint.HashedMessage, wantHashedMessage[:]) {
+		t.Errorf("SignatureTimeStamp HashedMessage = %x, want %x", tstInfo.MessageImprint.HashedMessage, wantHashedMessage)
+	}
+	if !tstInfo.GenTime.Equal(genTime) {
+		t.Errorf("SignatureTimeStamp GenTime = %v, want %v", tstInfo.GenTime, genTime)
+	}
+}
+
+func Test_XAdESSignatureValidator_SignatureTimeStamp(t *testing.T) {
+	signedInfoFactory := etreeimpl.NewSignedInfoFactory()
+	signerKey, signerCertificate := mustCreateRSAKeyAndSelfSignedCertificate(t)
+	genTime := time.Date(2021, 2, 5, 3, 30, 0, 0, time.UTC)
+	authority, tsaCertificate := mustCreateTimeStampAuthority(t, genTime)
+	generator, err := xades4go.NewXAdESSignatureGenerator(signedInfoFactory, signerKey, []*x509.Certificate{signerCertificate}, xades4go.RSASHA256SignatureAlgorithm,
+		xades4go.GenerateWithTimeStampProvider(authority),
+	)
+	if err != nil {
+		t.Fatalf("NewXAdESSignatureGenerator() error = %v", err)
+	}
+	signedXMLBytes, err := generator.SignXMLBytes([]byte(unsignedInvoice), []xades4go.ReferenceGenerationDetail{
+		{
+			URIOfDataObjectBeingSigned: "",
+			TransformAlgorithms:        []string{xades4go.EnvelopedSignatureTransformAlgorithm},
+			DigestAlgorithm:            xades4go.SHA256MessageDigestAlgorithm,
+		},
+	})
+	if err != nil {
+		t.Fatalf("SignXMLBytes() error = %v", err)
+	}
+	validToken := mustFindEncapsulatedTimeStamp(t, signedXMLBytes)
+	otherDigest := sha256.Sum256([]byte("another SignatureValue"))
+	tokenOverOtherData, err := authority.TimeStamp(crypto.SHA256, otherDigest[:])
+	if err != nil {
+		t.Fatalf("TimeStamp() error = %v", err)
+	}
+	tokenWithBrokenSignature := append([]byte{}, validToken...)
+	tokenWithBrokenSignature[len(tokenWithBrokenSignature)-1] ^= 0xff
+	signatureValueDigest := sha256.Sum256(mustCanonicalizeElement(t, signedInfoFactory, signedXMLBytes, "//Signature/SignatureValue"))
+	forgedGenTime := time.Date(1999, 1, 1, 0, 0, 0, 0, time.UTC)
+	selfIssuedAuthority, _ := mustCreateTimeStampAuthority(t, forgedGenTime)
+	tokenOfSelfIssuedTSA, err := selfIssuedAuthority.TimeStamp(crypto.SHA256, signatureValueDigest[:])
+	if err != nil {
+		t.Fatalf("TimeStamp() error = %v", err)
+	}
+	authorityWithoutTimeStampingUsage, certificateWithoutTimeStampingUsage := mustCreateTimeStampAuthorityWithExtKeyUsage(t, genTime, []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning})
+	tokenWithoutTimeStampingUsage, err := authorityWithoutTimeStampingUsage.TimeStamp(crypto.SHA256, signatureValueDigest[:])
+	if err != nil {
+		t.Fatalf("TimeStamp() error = %v", err)
+	}
+	trustedTSACertificates := []*x509.Certificate{tsaCertificate, certificateWithoutTimeStampingUsage}
+	tests := []struct {
+		name         string
+		token        []byte
+		trustAnchors []*x509.Certificate
+		want         xades4go.TimeStampValidationResult
+		wantErr      bool
+	}{
+		{
+			name:         "When the time-stamp token is issued over the SignatureValue by a trusted TSA, it should be valid",
+			token:        validToken,
+			trustAnchors: trustedTSACertificates,
+			want: xades4go.TimeStampValidationResult{
+				Property:                "SignatureTimeStamp",
+				IsValid:                 true,
+				IsMessageImprintValid:   true,
+				IsSignatureValid:        true,
+				IsTSACertificateTrusted: true,
+				GenTime:                 genTime,
+			},
+			wantErr: false,
+		},
+		{
+			name:         "When the time-stamp token is issued over other data, its message imprint should be invalid",
+			token:        tokenOverOtherData,
+			trustAnchors: trustedTSACertificates,
+			want: xades4go.TimeStampValidationResult{
+				Property:                "SignatureTimeStamp",
+				IsValid:                 false,
+				IsMessageImprintValid:   false,
+				IsSignatureValid:        true,
+				IsTSACertificateTrusted: true,
+				GenTime:                 genTime,
+			},
+			wantErr: false,
+		},
+		{
+			name:         "When the TSA signature of the time-stamp token is broken, its signature should be invalid",
+			token:        tokenWithBrokenSignature,
+			trustAnchors: trustedTSACertificates,
+			want: xades4go.TimeStampValidationResult{
+				Property:                "SignatureTimeStamp",
+				IsValid:                 false,
+				IsMessageImprintValid:   true,
+				IsSignatureValid:        false,
+				IsTSACertificateTrusted: true,
+				GenTime:                 genTime,
+			},
+			wantErr: false,
+		},
+		{
+			name:         "When the time-stamp token is issued by a self-issued TSA that is not trusted, it should be invalid",
+			token:        tokenOfSelfIssuedTSA,
+			trustAnchors: trustedTSACertificates,
+			want: xades4go.TimeStampValidationResult{
+				Property:                "SignatureTimeStamp",
+				IsValid:                 false,
+				IsMessageImprintValid:   true,
+				IsSignatureValid:        true,
+				IsTSACertificateTrusted: false,
+				GenTime:                 forgedGenTime,
+			},
+			wantErr: false,
+		},
+		{
+			name:         "When the TSA certificate does not have id-kp-timeStamping extended key usage, it should be invalid",
+			token:        tokenWithoutTimeStampingUsage,
+			trustAnchors: trustedTSACertificates,
+			want: xades4go.TimeStampValidationResult{
+				Property:                "SignatureTimeStamp",
+				IsValid:                 false,
+				IsMessageImprintValid:   true,
+				IsSignatureValid:        true,
+				IsTSACertificateTrusted: false,
+				GenTime:                 genTime,
+			},
+			wantErr: false,
+		},
+		{
+			name:         "When the validator has no TrustStore, the TSA certificate should not be trusted but the time-stamp token should be valid",
+			token:        validToken,
+			trustAnchors: nil,
+			want: xades4go.TimeStampValidationResult{
+				Property:                "SignatureTimeStamp",
+				IsValid:                 true,
+				IsMessageImprintValid:   true,
+				IsSignatureValid:        true,
+				IsTSACertificateTrusted: false,
+				GenTime:                 genTime,
+			},
+			wantErr: false,
+		},
+		{
+			name:    "When the time-stamp token is not a CMS ContentInfo, it should return error",
+			token:   []byte("not a time-stamp token"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var options []xades4go.XMLDSigSignatureValidatorOption
+			if tt.trustAnchors != nil {
+				options = append(options, xades4go.ValidateWithTrustStore(xades4go.NewTrustStore(tt.trustAnchors, nil)))
+			}
+			validator := xades4go.NewXAdESSignatureValidator(signedInfoFactory, xades4go.ValidateWithXMLDSigOptions(options...))
+			xmlBytes := bytes.Replace(signedXMLBytes, []byte(base64.StdEncoding.EncodeToString(validToken)), []byte(base64.StdEncoding.EncodeToString(tt.token)), 1)
+			got, err := validator.Validate(xmlBytes)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got.QualifyingPropertiesValidationResult.TimeStampValidationResults) != 1 {
+				t.Fatalf("Validate() TimeStampValidationResults = %+v, want 1 result", got.QualifyingPropertiesValidationResult.TimeStampValidationResults)
+			}
+			gotTimeStamp := got.QualifyingPropertiesValidationResult.TimeStampValidationResults[0]
+			if gotTimeStamp.TSACertificate == nil || gotTimeStamp.TSACertificate.Subject.CommonName != "xades4go test TSA" {
+				t.Errorf("Validate() TSACertificate = %v, want the certificate of TSA", gotTimeStamp.TSACertificate)
+			}
+			if (gotTimeStamp.TSACertificateStatus == "") != tt.want.IsTSACertificateTrusted {
+				t.Errorf("Validate() TSACertificateStatus = %q, want it empty only if the TSA certificate is trusted", gotTimeStamp.TSACertificateStatus)
+			}
+			if got.QualifyingPropertiesValidationResult.IsValid != tt.want.IsValid {
+				t.Errorf("Validate() QualifyingPropertiesValidationResult.IsValid = %v, want %v", got.QualifyingPropertiesValidationResult.IsValid, tt.want.IsValid)
+			}
+			if diff := cmp.Diff(tt.want, gotTimeStamp, cmpopts.IgnoreFields(xades4go.TimeStampValidationResult{}, "ID", "TSACertificate", "TSACertificateStatus")); diff != "" {
+				t.Errorf("Validate() TimeStampValidationResult mismatch (-want+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_XAdESSignatureValidator_ParallelSignatures(t *testing.T) {
+	signedInfoFactory := etreeimpl.NewSignedInfoFactory()
+	firstKey, firstCertificate := mustCreateRSAKeyAndSelfSignedCertificate(t)
+	secondKey, secondCertificate := mustCreateRSAKeyAndSelfSignedCertificate(t)
+	envelopedReference := []xades4go.ReferenceGenerationDetail{
+		{
+			URIOfDataObjectBeingSigned: "",
+			TransformAlgorithms:        []string{xades4go.EnvelopedSignatureTransformAlgorithm},
+			DigestAlgorithm:            xades4go.SHA256MessageDigestAlgorithm,
+		},
+	}
+	invoiceReference := []xades4go.ReferenceGenerationDetail{
+		{
+			URIOfDataObjectBeingSigned: "#invoice",
+			DigestAlgorithm:            xades4go.SHA256MessageDigestAlgorithm,
+		},
+	}
+	mustCoSign := func(xmlBytes []byte, references []xades4go.ReferenceGenerationDetail) []byte {
+		for _, signer := range []struct {
+			key         crypto.Signer
+			certificate *x509.Certificate
+		}{{firstKey, firstCertificate}, {secondKey, secondCertificate}} {
+			generator, err := xades4go.NewXAdESSignatureGenerator(signedInfoFactory, signer.key, []*x509.Certificate{signer.certificate}, xades4go.RSASHA256SignatureAlgorithm)
+			if err != nil {
+				t.Fatalf("NewXAdESSignatureGenerator() error = %v", err)
+			}
+			xmlBytes, err = generator.SignXMLBytes(xmlBytes, references)
+			if err != nil {
+				t.Fatalf("SignXMLBytes() error = %v", err)
+			}
+		}
+		return xmlBytes
+	}
+	mustFindSignatureIDs := func(xmlBytes []byte) []string {
+		doc := etree.NewDocument()
+		if err := doc.ReadFromBytes(xmlBytes); err != nil {
+			t.Fatalf("cannot parse XML: %v", err)
+		}
+		signatureIDs := make([]string, 0)
+		for _, signatureElement := range doc.FindElements("//Signature") {
+			signatureIDs = append(signatureIDs, signatureElement.SelectAttrValue("Id", ""))
+		}
+		return signatureIDs
+	}
+
+	type signatureSummary struct {
+		SignatureID       string
+		IsSignatureValid  bool
+		IsReferenceValid  bool
+		SignerCertificate *x509.Certificate
+	}
+	summaryOf := func(result xades4go.ValidationResult) signatureSummary {
+		isReferenceValid := true
+		for _, referenceValidationResult := range result.ReferenceValidationResults {
+			isReferenceValid = isReferenceValid && referenceValidationResult.IsValid
+		}
+		return signatureSummary{
+			SignatureID:       result.SignatureID,
+			IsSignatureValid:  result.IsSignatureValid,
+			IsReferenceValid:  isReferenceValid,
+			SignerCertificate: result.SignerCertificate,
+		}
+	}
+	validators := map[string]xades4go.MultipleSignatureValidator{
+		"XMLDSigSignatureValidator": xades4go.NewXMLDSigSignatureValidator(signedInfoFactory),
+		"XAdESSignatureValidator":   xades4go.NewXAdESSignatureValidator(signedInfoFactory),
+	}
+	tests := []struct {
+		name                  string
+		xmlBytes              []byte
+		wantIsReferencesValid []bool
+	}{
+		{
+			name:                  "When both signatures refer to the invoice element, both should be valid",
+			xmlBytes:              mustCoSign([]byte(`<Document><Invoice Id="invoice"><ID>INV01</ID></Invoice></Document>`), invoiceReference),
+			wantIsReferencesValid: []bool{true, true},
+		},
+		{
+			name:                  "When both signatures are enveloped signatures of the whole document, only the first one should have invalid Reference as the second signature is added to its data object",
+			xmlBytes:              mustCoSign([]byte(unsignedInvoice), envelopedReference),
+			wantIsReferencesValid: []bool{false, true},
+		},
+	}
+	for _, tt := range tests {
+		signatureIDs := mustFindSignatureIDs(tt.xmlBytes)
+		if len(signatureIDs) != 2 {
+			t.Fatalf("co-signed XML has %d Signature elements, want 2", len(signatureIDs))
+		}
+		want := []signatureSummary{
+			{SignatureID: signatureIDs[0], IsSignatureValid: true, IsReferenceValid: tt.wantIsReferencesValid[0], SignerCertificate: firstCertificate},
+			{SignatureID: signatureIDs[1], IsSignatureValid: true, IsReferenceValid: tt.wantIsReferencesValid[1], SignerCertificate: secondCertificate},
+		}
+		for validatorName, validator := range validators {
+			t.Run(validatorName+": "+tt.name, func(t *testing.T) {
+				got, err := validator.ValidateAll(tt.xmlBytes)
+				if err != nil {
+					t.Fatalf("ValidateAll() error = %v", err)
+				}
+				gotSummaries := make([]signatureSummary, 0, len(got))
+				for _, result := range got {
+					gotSummaries = append(gotSummaries, summaryOf(result))
+				}
+				if diff := cmp.Diff(want, gotSummaries, certificateComparer); diff != "" {
+					t.Errorf("ValidateAll() result mismatch (-want+got):\n%s", diff)
+				}
+				for signatureIndex, signatureID := range signatureIDs {
+					got, err := validator.ValidateByID(tt.xmlBytes, signatureID)
+					if err != nil {
+						t.Fatalf("ValidateByID() error = %v", err)
+					}
+					if diff := cmp.Diff(want[signatureIndex], summaryOf(got), certificateComparer); diff != "" {
+						t.Errorf("ValidateByID() result mismatch (-want+got):\n%s", diff)
+					}
+				}
+				if _, err := validator.ValidateByID(tt.xmlBytes, "unknown"); err == nil {
+					t.Errorf("ValidateByID() with unknown Id should return error")
+				}
+				if _, err := validator.ValidateByID(tt.xmlBytes, "a'b"); err == nil {
+					t.Errorf("ValidateByID() with unknown Id having a quote should return error")
+				}
+				if _, err := validator.Validate(tt.xmlBytes); err == nil {
+					t.Errorf("Validate() should return error as the document has more than one signature")
+				}
+			})
+		}
+	}
+}
+
+func Test_XMLDSigSignatureValidator_ParallelSignaturesWithoutID(t *testing.T) {
+	signedInfoFactory := etreeimpl.NewSignedInfoFactory()
+	firstKey, firstCertificate := mustCreateRSAKeyAndSelfSignedCertificate(t)
+	secondKey, secondCertificate := mustCreateRSAKeyAndSelfSignedCertificate(t)
+	mustStripSignatureID := func(xmlBytes []byte) []byte {
+		doc := etree.NewDocument()
+		if err := doc.ReadFromBytes(xmlBytes); err != nil {
+			t.Fatalf("cannot parse XML: %v", err)
+		}
+		for _, signatureElement := range doc.FindElements("//Signature") {
+			signatureElement.RemoveAttr("Id")
+		}
+		xmlBytesWithoutID, err := doc.WriteToBytes()
+		if err != nil {
+			t.Fatalf("cannot serialize XML: %v", err)
+		}
+		return xmlBytesWithoutID
+	}
+	// mustCoSignWithoutID strips Id attribute of each Signature element before the next signer signs, so the second signature covers the first one without Id.
+	mustCoSignWithoutID := func(xmlBytes []byte, references []xades4go.ReferenceGenerationDetail) []byte {
+		for _, signer := range []struct {
+			key         crypto.Signer
+			certificate *x509.Certificate
+		}{{firstKey, firstCertificate}, {secondKey, secondCertificate}} {
+			generator, err := xades4go.NewXMLDSigSignatureGenerator(signedInfoFactory, signer.key, []*x509.Certificate{signer.certificate}, xades4go.RSASHA256SignatureAlgorithm)
+			if err != nil {
+				t.Fatalf("NewXMLDSigSignatureGenerator() error = %v", err)
+			}
+			signedXMLBytes, err := generator.SignXMLBytes(xmlBytes, references)
+			if err != nil {
+				t.Fatalf("SignXMLBytes() error = %v", err)
+			}
+			xmlBytes = mustStripSignatureID(signedXMLBytes)
+		}
+		return xmlBytes
+	}
+	tests := []struct {
+		name                  string
+		xmlBytes              []byte
+		wantIsReferencesValid []bool
+	}{
+		{
+			name: "When both signatures without Id refer to the invoice element, both should be valid",
+			xmlBytes: mustCoSignWithoutID([]byte(`<Document><Invoice Id="invoice"><ID>INV01</ID></Invoice></Document>`), []xades4go.ReferenceGenerationDetail{
+				{URIOfDataObjectBeingSigned: "#invoice", DigestAlgorithm: xades4go.SHA256MessageDigestAlgorithm},
+			}),
+			wantIsReferencesValid: []bool{true, true},
+		},
+		{
+			name: "When both signatures without Id are enveloped signatures of the whole document, the enveloped transform of each should remove only itself",
+			xmlBytes: mustCoSignWithoutID([]byte(unsignedInvoice), []xades4go.ReferenceGenerationDetail{
+				{URIOfDataObjectBeingSigned: "", TransformAlgorithms: []string{xades4go.EnvelopedSignatureTransformAlgorithm}, DigestAlgorithm: xades4go.SHA256MessageDigestAlgorithm},
+			}),
+			wantIsReferencesValid: []bool{false, true},
+		},
+	}
+	validator := xades4go.NewXMLDSigSignatureValidator(signedInfoFactory)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := validator.ValidateAll(tt.xmlBytes)
+			if err != nil {
+				t.Fatalf("ValidateAll() error = %v", err)
+			}
+			if len(got) != 2 {
+				t.Fatalf("ValidateAll() got %d results, want 2", len(got))
+			}
+			for signatureIndex, result := range got {
+				if !result.IsSignatureValid {
+					t.Errorf("ValidateAll() Signature#%d IsSignatureValid = false, want true", signatureIndex)
+				}
+				if result.ReferenceValidationResults[0].IsValid != tt.wantIsReferencesValid[signatureIndex] {
+					t.Errorf("ValidateAll() Signature#%d Reference IsValid = %v, want %v", signatureIndex, result.ReferenceValidationResults[0].IsValid, tt.wantIsReferencesValid[signatureIndex])
+				}
+			}
+		})
+	}
+}
+
+func Test_XMLDSigSignatureValidator_ForeignSignatureElement(t *testing.T) {
+	signedInfoFactory := etreeimpl.NewSignedInfoFactory()
+	signerKey, signerCertificate := mustCreateRSAKeyAndSelfSignedCertificate(t)
+	generator, err := xades4go.NewXMLDSigSignatureGenerator(signedInfoFactory, signerKey, []*x509.Certificate{signerCertificate}, xades4go.RSASHA256SignatureAlgorithm)
+	if err != nil {
+		t.Fatalf("NewXMLDSigSignatureGenerator() error = %v", err)
+	}
+	// The document element is a Signature element of another vocabulary, which must not make the XMLDSig signature in it count as nested.
+	signedXMLBytes, err := generator.SignXMLBytes([]byte(`<x:Signature xmlns:x="urn:example:contract"><Invoice Id="invoice"><ID>INV01</ID></Invoice></x:Signature>`), []xades4go.ReferenceGenerationDetail{
+		{URIOfDataObjectBeingSigned: "#invoice", DigestAlgorithm: xades4go.SHA256MessageDigestAlgorithm},
+	})
+	if err != nil {
+		t.Fatalf("SignXMLBytes() error = %v", err)
+	}
+	got, err := xades4go.NewXMLDSigSignatureValidator(signedInfoFactory).Validate(signedXMLBytes)
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if !got.IsSignatureValid {
+		t.Errorf("Validate() IsSignatureValid = false, want true")
+	}
+}
+
+func mustFindEncapsulatedTimeStamp(t *testing.T, signedXMLBytes []byte) []byte {
+	t.Helper()
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(signedXMLBytes); err != nil {
+		t.Fatalf("cannot parse signed XML: %v", err)
+	}
+	encapsulatedTimeStampElement := doc.FindElement("//SignatureTimeStamp/EncapsulatedTimeStamp")
+	if encapsulatedTimeStampElement == nil {
+		t.Fatalf("EncapsulatedTimeStamp element was not found in %s", signedXMLBytes)
+	}
+	timeStampToken, err := base64.StdEncoding.DecodeString(encapsulatedTimeStampElement.Text())
+	if err != nil {
+		t.Fatalf("EncapsulatedTimeStamp is not base64: %v", err)
+	}
+	return timeStampToken
+}
+
+// mustWrapSignedProperties inserts a copy of SignedProperties element of signedXMLBytes ahead of Signature element, declaring the namespaces it inherits so that it digests the same,
+// and replaces SigningTime of the original SignedProperties element with forgedSigningTime.
+func mustWrapSignedProperties(t *testing.T, signedXMLBytes []byte, forgedSigningTime string) []byte {
+	t.Helper()
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(signedXMLBytes); err != nil {
+		t.Fatalf("cannot parse signed XML: %v", err)
+	}
+	signatureElement := doc.FindElement("//Signature")
+	signedPropertiesElement := doc.FindElement("//SignedProperties")
+	signingTimeElement := doc.FindElement("//SignedProperties/SignedSignatureProperties/SigningTime")
+	if signatureElement == nil || signedPropertiesElement == nil || signingTimeElement == nil {
+		t.Fatalf("Signature, SignedProperties or SigningTime element was not found in %s", signedXMLBytes)
+	}
+	decoyElement := signedPropertiesElement.Copy()
+	decoyElement.CreateAttr("xmlns:"+signatureElement.Space, signatureElement.SelectAttrValue("xmlns:"+signatureElement.Space, ""))
+	decoyElement.CreateAttr("xmlns:"+signedPropertiesElement.Space, signedPropertiesElement.Parent().SelectAttrValue("xmlns:"+signedPropertiesElement.Space, ""))
+	signatureElement.Parent().InsertChildAt(signatureElement.Index(), decoyElement)
+	signingTimeElement.SetText(forgedSigningTime)
+	wrappedXMLBytes, err := doc.WriteToBytes()
+	if err != nil {
+		t.Fatalf("cannot serialize wrapped XML: %v", err)
+	}
+	return wrappedXMLBytes
+}
+
+// mustCanonicalizeElement canonicalizes the element of xmlBytes at path with canonical XML 1.0.
+func mustCanonicalizeElement(t *testing.T, signedInfoFactory xades4go.SignedInfoFactory, xmlBytes []byte, path string) []byte {
+	t.Helper()
+	elementInput, err := signedInfoFactory.CreateDereferencer().DereferenceByPath(xmlBytes, path)
+	if err != nil {
+		t.Fatalf("cannot dereference %s: %v", path, err)
+	}
+	canonicalizer, err := signedInfoFactory.CreateCanonicalizer(xades4go.CanonicalXML10Algorithm)
+	if err != nil {
+		t.Fatalf("cannot create canonicalizer: %v", err)
+	}
+	canonicalizedElement, err := canonicalizer.Canonicalize(elementInput)
+	if err != nil {
+		t.Fatalf("cannot canonicalize %s: %v", path, err)
+	}
+	return canonicalizedElement
+}
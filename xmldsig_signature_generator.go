@@ -1,12 +1,426 @@
 package xades4go
 
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/beevik/etree"
+)
+
 type XMLDSigSignatureGenerator struct {
+	signedInfoFactory                SignedInfoFactory
+	signatureValueSigner             SignatureValueSigner
+	uriResolver                      URIResolver
+	certificateChain                 []*x509.Certificate
+	signatureAlgorithm               string
+	pssParameters                    *PSSParameters
+	keyName                          string
+	canonicalizationAlgorithm        string
+	defaultCanonicalizationAlgorithm string
+}
+
+// XMLDSigSignatureGeneratorOption configures optional behavior of XMLDSigSignatureGenerator.
+type XMLDSigSignatureGeneratorOption func(generator *XMLDSigSignatureGenerator)
+
+// GenerateWithKeyName puts keyName in KeyName element of KeyInfo element, for the validator to look up the key by name (the shared secret of HMAC signature algorithms for example).
+func GenerateWithKeyName(keyName string) XMLDSigSignatureGeneratorOption {
+	return func(generator *XMLDSigSignatureGenerator) {
+		generator.keyName = keyName
+	}
+}
+
+// GenerateWithURIResolver makes the generator resolve data objects outside the signed XML (detached signature) with uriResolver.
+func GenerateWithURIResolver(uriResolver URIResolver) XMLDSigSignatureGeneratorOption {
+	return func(generator *XMLDSigSignatureGenerator) {
+		generator.uriResolver = uriResolver
+	}
+}
+
+// NewXMLDSigSignatureGenerator creates SignatureGenerator that signs with the given signer using the given signature algorithm.
+// The certificateChain (signer certificate first) is attached to KeyInfo element as X509Data.
+// The signedInfoFactory should be the same one used by XMLDSigSignatureValidator so that the digests being signed are computed by the same code that validates them.
+func NewXMLDSigSignatureGenerator(signedInfoFactory SignedInfoFactory, signer crypto.Signer, certificateChain []*x509.Certificate, signatureAlgorithm string, options ...XMLDSigSignatureGeneratorOption) (SignatureGenerator, error) {
+	generator, err := newXMLDSigSignatureGenerator(signedInfoFactory, signer, certificateChain, signatureAlgorithm, options...)
+	if err != nil {
+		return nil, err
+	}
+	return generator, nil
+}
+
+// NewXMLDSigHMACSignatureGenerator creates SignatureGenerator that signs with HMAC signature algorithms using the shared secret.
+// Since the secret cannot be attached, set GenerateWithKeyName for the validator to select it with HMACKeySelector.
+func NewXMLDSigHMACSignatureGenerator(signedInfoFactory SignedInfoFactory, secret []byte, signatureAlgorithm string, options ...XMLDSigSignatureGeneratorOption) (SignatureGenerator, error) {
+	if !isHMACSignatureAlgorithm(signatureAlgorithm) {
+		return nil, fmt.Errorf("%s is not a HMAC signature algorithm", signatureAlgorithm)
+	}
+	if len(secret) == 0 {
+		return nil, errors.New("HMAC secret must not be empty")
+	}
+	generator, err := newXMLDSigSignatureGeneratorWithValueSigner(signedInfoFactory, &hmacSignatureValueSigner{secret: secret}, nil, signatureAlgorithm, options...)
+	if err != nil {
+		return nil, err
+	}
+	return generator, nil
+}
+
+func newXMLDSigSignatureGenerator(signedInfoFactory SignedInfoFactory, signer crypto.Signer, certificateChain []*x509.Certificate, signatureAlgorithm string, options ...XMLDSigSignatureGeneratorOption) (*XMLDSigSignatureGenerator, error) {
+	signatureValueSigner, err := createSignatureValueSigner(signer, nil)
+	if err != nil {
+		return nil, err
+	}
+	return newXMLDSigSignatureGeneratorWithValueSigner(signedInfoFactory, signatureValueSigner, certificateChain, signatureAlgorithm, options...)
+}
+
+func newXMLDSigSignatureGeneratorWithValueSigner(signedInfoFactory SignedInfoFactory, signatureValueSigner SignatureValueSigner, certificateChain []*x509.Certificate, signatureAlgorithm string, options ...XMLDSigSignatureGeneratorOption) (*XMLDSigSignatureGenerator, error) {
+	if err := checkSignatureAlgorithm(signatureAlgorithm); err != nil {
+		return nil, err
+	}
+	generator := &XMLDSigSignatureGenerator{
+		signedInfoFactory:                signedInfoFactory,
+		signatureValueSigner:             signatureValueSigner,
+		certificateChain:                 certificateChain,
+		signatureAlgorithm:               signatureAlgorithm,
+		canonicalizationAlgorithm:        CanonicalXML10Algorithm,
+		defaultCanonicalizationAlgorithm: CanonicalXML10Algorithm,
+	}
+	for _, option := range options {
+		option(generator)
+	}
+	if err := generator.preparePSSParameters(); err != nil {
+		return nil, err
+	}
+	return generator, nil
 }
 
+// preparePSSParameters defaults the PSS parameters of RSASSA-PSS signature algorithms, checks them and hands them to the RSA signer.
+// It must be called again whenever the options of generator are applied after its creation.
+func (generator *XMLDSigSignatureGenerator) preparePSSParameters() error {
+	defaultParameters, err := pssParametersOf(generator.signatureAlgorithm, nil)
+	if err != nil {
+		return err
+	}
+	if generator.pssParameters == nil {
+		generator.pssParameters = defaultParameters
+	} else if generator.signatureAlgorithm != RSASSAPSSSignatureAlgorithm && (defaultParameters == nil || *generator.pssParameters != *defaultParameters) {
+		return fmt.Errorf("PSS parameters can only be set with %s, got %s", RSASSAPSSSignatureAlgorithm, generator.signatureAlgorithm)
+	}
+	if generator.pssParameters == nil {
+		return nil
+	}
+	if _, _, err := rsaPSSOptionsOf(*generator.pssParameters); err != nil {
+		return err
+	}
+	// crypto/rsa reads zero salt length as "maximum length" when signing.
+	if generator.pssParameters.SaltLength == 0 {
+		return errors.New("crypto/rsa cannot sign with SaltLength 0")
+	}
+	if rsaSigner, ok := generator.signatureValueSigner.(*rsaSignatureValueSigner); ok {
+		rsaSigner.pssParameters = generator.pssParameters
+	}
+	return nil
+}
+
+// SignXMLBytes creates an enveloped signature. The Signature element is appended as the last child of the root element of xmlBytes.
 func (generator *XMLDSigSignatureGenerator) SignXMLBytes(xmlBytes []byte, dataObjectReferences []ReferenceGenerationDetail) ([]byte, error) {
-	_, err := createEtreeElementFromXMLBytes(xmlBytes)
+	return generator.signXMLBytes(xmlBytes, dataObjectReferences, nil)
+}
+
+// SignEnveloping creates an enveloping signature. The generated Signature element is the root element of the returned XML.
+func (generator *XMLDSigSignatureGenerator) SignEnveloping(dataObjects []DataObjectGenerationDetail) ([]byte, error) {
+	return generator.signEnveloping(dataObjects, nil)
+}
+
+// SignDetached creates a detached signature over data objects resolved by the URIResolver given with GenerateWithURIResolver.
+// The generated Signature element is the root element of the returned XML.
+func (generator *XMLDSigSignatureGenerator) SignDetached(dataObjectReferences []ReferenceGenerationDetail) ([]byte, error) {
+	return generator.signDetached(dataObjectReferences, nil)
+}
+
+// signatureElementExtender adds elements (such as ds:Object containing QualifyingProperties) to a Signature element being generated.
+// The returned References are appended to SignedInfo element after the References of data objects.
+type signatureElementExtender interface {
+	extendSignatureElement(signatureElement *etree.Element, signatureID string, dataObjectReferences []ReferenceGenerationDetail) ([]ReferenceGenerationDetail, error)
+}
+
+// signedSignatureElementExtender is implemented by a signatureElementExtender that also adds elements depending on SignatureValue (such as SignatureTimeStamp).
+// It is called after SignatureValue element of signatureElement, which is placed in doc, is filled.
+type signedSignatureElementExtender interface {
+	extendSignedSignatureElement(doc *etree.Document, signatureElement *etree.Element) error
+}
+
+func (generator *XMLDSigSignatureGenerator) signXMLBytes(xmlBytes []byte, dataObjectReferences []ReferenceGenerationDetail, extender signatureElementExtender) ([]byte, error) {
+	doc := etree.NewDocument()
+	err := doc.ReadFromBytes(xmlBytes)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse xmlBytes to etree's element: %w", err)
+	}
+	if doc.Root() == nil {
+		return nil, errors.New("xmlBytes does not contain any root element")
+	}
+	signatureElement, references, err := generator.createSignatureElement(dataObjectReferences, nil, extender)
+	if err != nil {
+		return nil, err
+	}
+	doc.Root().AddChild(signatureElement)
+	return generator.completeSignatureElement(doc, signatureElement, references, extender)
+}
+
+func (generator *XMLDSigSignatureGenerator) signEnveloping(dataObjects []DataObjectGenerationDetail, extender signatureElementExtender) ([]byte, error) {
+	objectElements := make([]*etree.Element, 0, len(dataObjects))
+	dataObjectReferences := make([]ReferenceGenerationDetail, 0, len(dataObjects))
+	for dataObjectIndex, dataObject := range dataObjects {
+		objectElement, referenceDetail, err := createObjectElement(dataObject)
+		if err != nil {
+			return nil, fmt.Errorf("at data object#%d: %w", dataObjectIndex, err)
+		}
+		objectElements = append(objectElements, objectElement)
+		dataObjectReferences = append(dataObjectReferences, referenceDetail)
+	}
+	signatureElement, references, err := generator.createSignatureElement(dataObjectReferences, objectElements, extender)
+	if err != nil {
+		return nil, err
+	}
+	return generator.completeStandaloneSignatureElement(signatureElement, references, extender)
+}
+
+func (generator *XMLDSigSignatureGenerator) signDetached(dataObjectReferences []ReferenceGenerationDetail, extender signatureElementExtender) ([]byte, error) {
+	for referenceIndex, referenceDetail := range dataObjectReferences {
+		if isSameDocumentURI(referenceDetail.URIOfDataObjectBeingSigned) {
+			return nil, fmt.Errorf("at Reference#%d: same-document URI cannot be used in detached signature", referenceIndex)
+		}
+	}
+	signatureElement, references, err := generator.createSignatureElement(dataObjectReferences, nil, extender)
 	if err != nil {
 		return nil, err
 	}
-	return nil, nil
+	return generator.completeStandaloneSignatureElement(signatureElement, references, extender)
+}
+
+// completeStandaloneSignatureElement completes signatureElement as the root element of a new document.
+func (generator *XMLDSigSignatureGenerator) completeStandaloneSignatureElement(signatureElement *etree.Element, references []ReferenceGenerationDetail, extender signatureElementExtender) ([]byte, error) {
+	doc := etree.NewDocument()
+	doc.CreateProcInst("xml", `version="1.0" encoding="UTF-8"`)
+	doc.SetRoot(signatureElement)
+	return generator.completeSignatureElement(doc, signatureElement, references, extender)
+}
+
+// createObjectElement wraps the given data object in Object element and returns the Reference that points to it.
+func createObjectElement(dataObject DataObjectGenerationDetail) (*etree.Element, ReferenceGenerationDetail, error) {
+	objectID := dataObject.ID
+	if objectID == "" {
+		var err error
+		objectID, err = generateID("xmldsig-object")
+		if err != nil {
+			return nil, ReferenceGenerationDetail{}, err
+		}
+	}
+	objectElement := etree.NewElement(xmldsigNamespacePrefix + ":" + objectElementTag)
+	objectElement.CreateAttr(idAttributeKey, objectID)
+	if dataObject.MimeType != "" {
+		objectElement.CreateAttr(mimeTypeAttributeKey, dataObject.MimeType)
+	}
+	referenceDetail := ReferenceGenerationDetail{
+		URIOfDataObjectBeingSigned: "#" + objectID,
+		DigestAlgorithm:            dataObject.DigestAlgorithm,
+		MimeType:                   dataObject.MimeType,
+	}
+	if dataObject.IsOctetStream {
+		objectElement.CreateAttr(encodingAttributeKey, Base64Algorithm)
+		objectElement.SetText(base64.StdEncoding.EncodeToString(dataObject.Content))
+		referenceDetail.TransformAlgorithms = append([]string{Base64Algorithm}, dataObject.TransformAlgorithms...)
+		return objectElement, referenceDetail, nil
+	}
+	contentDoc := etree.NewDocument()
+	err := contentDoc.ReadFromBytes(dataObject.Content)
+	if err != nil {
+		return nil, ReferenceGenerationDetail{}, fmt.Errorf("Content is not a well-formed XML: %w", err)
+	}
+	if contentDoc.Root() == nil {
+		return nil, ReferenceGenerationDetail{}, errors.New("Content does not contain any element")
+	}
+	for _, token := range contentDoc.Child {
+		switch node := token.(type) {
+		case *etree.Element:
+			objectElement.AddChild(node.Copy())
+		case *etree.Comment:
+			objectElement.AddChild(etree.NewComment(node.Data))
+		}
+	}
+	referenceDetail.TransformAlgorithms = dataObject.TransformAlgorithms
+	return objectElement, referenceDetail, nil
+}
+
+// createSignatureElement creates Signature element with every children but DigestValue and SignatureValue are left empty.
+// Those values can only be computed after the Signature element is placed in its final document by completeSignatureElement.
+// It returns the References of the data objects followed by the References added by extender (if any).
+func (generator *XMLDSigSignatureGenerator) createSignatureElement(dataObjectReferences []ReferenceGenerationDetail, objectElements []*etree.Element, extender signatureElementExtender) (*etree.Element, []ReferenceGenerationDetail, error) {
+	if len(dataObjectReferences) == 0 {
+		return nil, nil, errors.New("at least one data object reference is required")
+	}
+	signatureID, err := generateID("xmldsig")
+	if err != nil {
+		return nil, nil, err
+	}
+	signatureElement := etree.NewElement(xmldsigNamespacePrefix + ":" + signatureElementTag)
+	signatureElement.CreateAttr("xmlns:"+xmldsigNamespacePrefix, xmldsigNamespaceURI)
+	signatureElement.CreateAttr(idAttributeKey, signatureID)
+
+	signedInfoElement := createXMLDSigElement(signatureElement, signedInfoElementTag)
+	createXMLDSigElement(signedInfoElement, canonicalizationMethodElementTag).CreateAttr(algorithmAttributeKey, generator.canonicalizationAlgorithm)
+	signatureMethodElement := createXMLDSigElement(signedInfoElement, signatureMethodElementTag)
+	signatureMethodElement.CreateAttr(algorithmAttributeKey, generator.signatureAlgorithm)
+	if generator.signatureAlgorithm == RSASSAPSSSignatureAlgorithm {
+		createRSAPSSParamsElement(signatureMethodElement, *generator.pssParameters)
+	}
+	for referenceIndex, referenceDetail := range dataObjectReferences {
+		err := createReferenceElement(signedInfoElement, referenceIDOf(signatureID, referenceIndex), referenceDetail)
+		if err != nil {
+			return nil, nil, fmt.Errorf("at Reference#%d: %w", referenceIndex, err)
+		}
+	}
+
+	createXMLDSigElement(signatureElement, signatureValueElementTag).CreateAttr(idAttributeKey, signatureID+"-sigvalue")
+
+	if generator.keyName != "" || len(generator.certificateChain) > 0 {
+		keyInfoElement := createXMLDSigElement(signatureElement, keyInfoElementTag)
+		if generator.keyName != "" {
+			createXMLDSigElement(keyInfoElement, keyNameElementTag).SetText(generator.keyName)
+		}
+		if len(generator.certificateChain) > 0 {
+			x509DataElement := createXMLDSigElement(keyInfoElement, x509DataElementTag)
+			for _, certificate := range generator.certificateChain {
+				createXMLDSigElement(x509DataElement, x509CertificateElementTag).SetText(base64.StdEncoding.EncodeToString(certificate.Raw))
+			}
+		}
+	}
+	for _, objectElement := range objectElements {
+		signatureElement.AddChild(objectElement)
+	}
+
+	references := dataObjectReferences
+	if extender != nil {
+		extendedReferences, err := extender.extendSignatureElement(signatureElement, signatureID, dataObjectReferences)
+		if err != nil {
+			return nil, nil, err
+		}
+		for extendedReferenceIndex, referenceDetail := range extendedReferences {
+			referenceIndex := len(dataObjectReferences) + extendedReferenceIndex
+			err := createReferenceElement(signedInfoElement, referenceIDOf(signatureID, referenceIndex), referenceDetail)
+			if err != nil {
+				return nil, nil, fmt.Errorf("at Reference#%d: %w", referenceIndex, err)
+			}
+		}
+		references = append(append([]ReferenceGenerationDetail{}, dataObjectReferences...), extendedReferences...)
+	}
+	return signatureElement, references, nil
+}
+
+func createReferenceElement(signedInfoElement *etree.Element, referenceID string, referenceDetail ReferenceGenerationDetail) error {
+	if referenceDetail.DigestAlgorithm == "" {
+		return errors.New("DigestAlgorithm must not be empty")
+	}
+	referenceElement := createXMLDSigElement(signedInfoElement, referenceElementTag)
+	referenceElement.CreateAttr(idAttributeKey, referenceID)
+	if referenceDetail.Type != "" {
+		referenceElement.CreateAttr(typeAttributeKey, referenceDetail.Type)
+	}
+	referenceElement.CreateAttr(uriAttributeKey, referenceDetail.URIOfDataObjectBeingSigned)
+	if len(referenceDetail.TransformAlgorithms) > 0 {
+		transformsElement := createXMLDSigElement(referenceElement, transformsElementTag)
+		for _, transformAlgorithm := range referenceDetail.TransformAlgorithms {
+			createXMLDSigElement(transformsElement, transformElementTag).CreateAttr(algorithmAttributeKey, transformAlgorithm)
+		}
+	}
+	createXMLDSigElement(referenceElement, digestMethodElementTag).CreateAttr(algorithmAttributeKey, referenceDetail.DigestAlgorithm)
+	createXMLDSigElement(referenceElement, digestValueElementTag)
+	return nil
+}
+
+func referenceIDOf(signatureID string, referenceIndex int) string {
+	return fmt.Sprintf("%s-ref%d", signatureID, referenceIndex)
+}
+
+// completeSignatureElement fills DigestValue elements and SignatureValue element of signatureElement which already be placed in doc.
+// The digests and the canonicalized SignedInfo are computed from the serialized doc with the same functions used by XMLDSigSignatureValidator.
+// If extender is a signedSignatureElementExtender, it is called once SignatureValue element is filled.
+func (generator *XMLDSigSignatureGenerator) completeSignatureElement(doc *etree.Document, signatureElement *etree.Element, dataObjectReferences []ReferenceGenerationDetail, extender signatureElementExtender) ([]byte, error) {
+	signedInfoElement, err := mustFoundOnlyOneChildElement(signatureElement, signedInfoElementTag)
+	if err != nil {
+		return nil, err
+	}
+	referenceElements := signedInfoElement.SelectElements(referenceElementTag)
+	xmlBytes, err := doc.WriteToBytes()
+	if err != nil {
+		return nil, fmt.Errorf("cannot serialize document: %w", err)
+	}
+	for referenceIndex, referenceDetail := range dataObjectReferences {
+		generatedDigestValue, err := digestDataObjectFrom(generator.signedInfoFactory, generator.uriResolver, xmlBytes, pathOfSignatureElement(signatureElement), generator.defaultCanonicalizationAlgorithm, referenceDetail)
+		if err != nil {
+			return nil, fmt.Errorf("error while digesting at Reference#%d: %w", referenceIndex, err)
+		}
+		digestValueElement, err := mustFoundOnlyOneChildElement(referenceElements[referenceIndex], digestValueElementTag)
+		if err != nil {
+			return nil, fmt.Errorf("at Reference#%d element: %w", referenceIndex, err)
+		}
+		digestValueElement.SetText(string(generatedDigestValue))
+	}
+
+	xmlBytes, err = doc.WriteToBytes()
+	if err != nil {
+		return nil, fmt.Errorf("cannot serialize document: %w", err)
+	}
+	signatureID := signatureElement.SelectAttrValue(idAttributeKey, "")
+	signedInfoInput, err := generator.signedInfoFactory.CreateDereferencer().DereferenceByPath(xmlBytes, fmt.Sprintf("//%s[@%s='%s']/%s", signatureElementTag, idAttributeKey, signatureID, signedInfoElementTag))
+	if err != nil {
+		return nil, fmt.Errorf("cannot derefernce SignedInfo element: %w", err)
+	}
+	canonicalizer, err := generator.signedInfoFactory.CreateCanonicalizer(generator.canonicalizationAlgorithm)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create canonicalizer for SignedInfo element: %w", err)
+	}
+	canonicalizedSignedInfo, err := canonicalizer.Canonicalize(signedInfoInput)
+	if err != nil {
+		return nil, fmt.Errorf("error while canonicalizing SignedInfo element: %w", err)
+	}
+	signatureValue, err := generator.signatureValueSigner.Sign(generator.signatureAlgorithm, canonicalizedSignedInfo)
+	if err != nil {
+		return nil, err
+	}
+	signatureValueElement, err := mustFoundOnlyOneChildElement(signatureElement, signatureValueElementTag)
+	if err != nil {
+		return nil, err
+	}
+	signatureValueElement.SetText(string(signatureValue))
+	if signedExtender, ok := extender.(signedSignatureElementExtender); ok {
+		err := signedExtender.extendSignedSignatureElement(doc, signatureElement)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	xmlBytes, err = doc.WriteToBytes()
+	if err != nil {
+		return nil, fmt.Errorf("cannot serialize document: %w", err)
+	}
+	return xmlBytes, nil
+}
+
+func createXMLDSigElement(parent *etree.Element, tag string) *etree.Element {
+	return parent.CreateElement(xmldsigNamespacePrefix + ":" + tag)
+}
+
+// generateID generates a random (version 4) UUID prefixed with the given prefix to be used as Id attribute.
+func generateID(prefix string) (string, error) {
+	uuid := make([]byte, 16)
+	_, err := rand.Read(uuid)
+	if err != nil {
+		return "", fmt.Errorf("cannot generate random Id: %w", err)
+	}
+	uuid[6] = (uuid[6] & 0x0f) | 0x40
+	uuid[8] = (uuid[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%s-%x-%x-%x-%x-%x", prefix, uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:16]), nil
 }
@@ -2,25 +2,122 @@ package xades4go
 
 import (
 	"bytes"
+	"crypto"
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"crypto/x509"
-	"encoding/base64"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/beevik/etree"
 )
 
 type XMLDSigSignatureValidator struct {
 	signedInfoFactory                SignedInfoFactory
+	uriResolver                      URIResolver
 	defaultCanonicalizationAlgorithm string
+	allowLegacyAlgorithms            bool
+	hmacKeySelector                  HMACKeySelector
+	keySelector                      KeySelector
+	certificateStore                 CertificateStore
+	trustStore                       TrustStore
+	validationTime                   func() time.Time
+	ocspFetcher                      OCSPFetcher
+	crlFetcher                       CRLFetcher
 }
 
-func NewXMLDSigSignatureValidator(signedInfoFactory SignedInfoFactory) SignatureValidator {
-	return &XMLDSigSignatureValidator{
+// XMLDSigSignatureValidatorOption configures optional behavior of XMLDSigSignatureValidator.
+type XMLDSigSignatureValidatorOption func(validator *XMLDSigSignatureValidator)
+
+// ValidateWithURIResolver makes the validator resolve data objects outside the validated XML (detached signature) with uriResolver.
+func ValidateWithURIResolver(uriResolver URIResolver) XMLDSigSignatureValidatorOption {
+	return func(validator *XMLDSigSignatureValidator) {
+		validator.uriResolver = uriResolver
+	}
+}
+
+// ValidateWithLegacyAlgorithms makes the validator verify signatures made with legacy algorithms (DSASHA1SignatureAlgorithm and DSASHA256SignatureAlgorithm).
+// It is meant for re-verifying archived documents only. Without it, the validation of such signatures returns error.
+func ValidateWithLegacyAlgorithms() XMLDSigSignatureValidatorOption {
+	return func(validator *XMLDSigSignatureValidator) {
+		validator.allowLegacyAlgorithms = true
+	}
+}
+
+// ValidateWithHMACKeySelector makes the validator verify signatures made with HMAC signature algorithms using the shared secret selected by hmacKeySelector.
+// Without it, the validation of such signatures returns error.
+func ValidateWithHMACKeySelector(hmacKeySelector HMACKeySelector) XMLDSigSignatureValidatorOption {
+	return func(validator *XMLDSigSignatureValidator) {
+		validator.hmacKeySelector = hmacKeySelector
+	}
+}
+
+// ValidateWithKeySelector makes the validator verify SignatureValue element with the keys selected by keySelector instead of NewKeyInfoKeySelector.
+// It is required to validate signatures without KeyInfo element, and lets the caller pin the keys of the expected signers.
+func ValidateWithKeySelector(keySelector KeySelector) XMLDSigSignatureValidatorOption {
+	return func(validator *XMLDSigSignatureValidator) {
+		validator.keySelector = keySelector
+	}
+}
+
+// ValidateWithCertificateStore makes the validator look up the certificates that KeyInfo element only identifies (by X509IssuerSerial, X509SKI, X509SubjectName or X509Digest element) in certificateStore.
+func ValidateWithCertificateStore(certificateStore CertificateStore) XMLDSigSignatureValidatorOption {
+	return func(validator *XMLDSigSignatureValidator) {
+		validator.certificateStore = certificateStore
+	}
+}
+
+// ValidateWithTrustStore makes the validator validate the signer certificate against the trust anchors of trustStore, and report it as CertificateValidationResult of ValidationResult.
+// The path is built through the certificates of KeyInfo element and the intermediate certificates of trustStore.
+func ValidateWithTrustStore(trustStore TrustStore) XMLDSigSignatureValidatorOption {
+	return func(validator *XMLDSigSignatureValidator) {
+		validator.trustStore = trustStore
+	}
+}
+
+// ValidateWithValidationTime sets the function that returns the time the signer certificate is validated at. The default is time.Now.
+// It is also the current time that no revocation data may be issued after. XAdESSignatureValidator validates the signer certificate at the time of a valid SignatureTimeStamp instead if it is earlier.
+func ValidateWithValidationTime(validationTime func() time.Time) XMLDSigSignatureValidatorOption {
+	return func(validator *XMLDSigSignatureValidator) {
+		validator.validationTime = validationTime
+	}
+}
+
+// ValidateWithOCSPFetcher makes the validator check the revocation status of every certificate of the path built with ValidateWithTrustStore option, except the trust anchor,
+// with the OCSP responders of the certificates through ocspFetcher. CRLs are used when no OCSP responder gives a usable response and ValidateWithCRLFetcher option is given.
+func ValidateWithOCSPFetcher(ocspFetcher OCSPFetcher) XMLDSigSignatureValidatorOption {
+	return func(validator *XMLDSigSignatureValidator) {
+		validator.ocspFetcher = ocspFetcher
+	}
+}
+
+// ValidateWithCRLFetcher makes the validator check the revocation status of every certificate of the path built with ValidateWithTrustStore option, except the trust anchor,
+// with the CRLs (and delta CRLs) of the certificates downloaded through crlFetcher.
+func ValidateWithCRLFetcher(crlFetcher CRLFetcher) XMLDSigSignatureValidatorOption {
+	return func(validator *XMLDSigSignatureValidator) {
+		validator.crlFetcher = crlFetcher
+	}
+}
+
+func NewXMLDSigSignatureValidator(signedInfoFactory SignedInfoFactory, options ...XMLDSigSignatureValidatorOption) MultipleSignatureValidator {
+	return newXMLDSigSignatureValidator(signedInfoFactory, options...)
+}
+
+func newXMLDSigSignatureValidator(signedInfoFactory SignedInfoFactory, options ...XMLDSigSignatureValidatorOption) *XMLDSigSignatureValidator {
+	validator := &XMLDSigSignatureValidator{
 		signedInfoFactory:                signedInfoFactory,
 		defaultCanonicalizationAlgorithm: CanonicalXML10Algorithm,
+		keySelector:                      NewKeyInfoKeySelector(),
+		validationTime:                   time.Now,
+	}
+	for _, option := range options {
+		option(validator)
 	}
+	return validator
 }
 
 func (validator *XMLDSigSignatureValidator) Validate(xmlBytes []byte) (ValidationResult, error) {
@@ -28,10 +125,61 @@ func (validator *XMLDSigSignatureValidator) Validate(xmlBytes []byte) (Validatio
 	if err != nil {
 		return ValidationResult{}, err
 	}
-	signatureElement, err := mustFoundOnlyOneElement(rootElement, signatureElementTag)
+	signatureElement, err := mustFoundOnlyOneSignatureElement(rootElement)
 	if err != nil {
 		return ValidationResult{}, err
 	}
+	return validator.validateSignatureElement(xmlBytes, signatureElement)
+}
+
+func (validator *XMLDSigSignatureValidator) ValidateAll(xmlBytes []byte) ([]ValidationResult, error) {
+	rootElement, err := createEtreeElementFromXMLBytes(xmlBytes)
+	if err != nil {
+		return nil, err
+	}
+	signatureElements, err := mustFoundAtLeastOneSignatureElement(rootElement)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]ValidationResult, 0, len(signatureElements))
+	for signatureIndex, signatureElement := range signatureElements {
+		result, err := validator.validateSignatureElement(xmlBytes, signatureElement)
+		if err != nil {
+			return nil, fmt.Errorf("at Signature#%d element: %w", signatureIndex, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (validator *XMLDSigSignatureValidator) ValidateByID(xmlBytes []byte, signatureID string) (ValidationResult, error) {
+	rootElement, err := createEtreeElementFromXMLBytes(xmlBytes)
+	if err != nil {
+		return ValidationResult{}, err
+	}
+	signatureElement, err := mustFoundSignatureElementByID(rootElement, signatureID)
+	if err != nil {
+		return ValidationResult{}, err
+	}
+	return validator.validateSignatureElement(xmlBytes, signatureElement)
+}
+
+// validateSignatureElement validates References and SignatureValue of signatureElement which is an element parsed from xmlBytes.
+func (validator *XMLDSigSignatureValidator) validateSignatureElement(xmlBytes []byte, signatureElement *etree.Element) (ValidationResult, error) {
+	return validator.validateSignatureElementWithValidationData(xmlBytes, signatureElement, embeddedValidationData{}, time.Time{})
+}
+
+// embeddedValidationData is the validation material that a signature carries besides KeyInfo element, such as XAdES CertificateValues and RevocationValues elements.
+type embeddedValidationData struct {
+	certificates  []*x509.Certificate
+	crls          [][]byte
+	ocspResponses [][]byte
+}
+
+// validateSignatureElementWithValidationData validates signatureElement like validateSignatureElement, and validates the signer certificate
+// with the certificates and revocation data of validationData before the ones of TrustStore and the fetchers.
+// The signer certificate is validated at bestSignatureTime, the time the signature is proven to exist, if it is not zero and earlier than the validation time.
+func (validator *XMLDSigSignatureValidator) validateSignatureElementWithValidationData(xmlBytes []byte, signatureElement *etree.Element, validationData embeddedValidationData, bestSignatureTime time.Time) (ValidationResult, error) {
 	signedInfoElement, err := mustFoundOnlyOneChildElement(signatureElement, signedInfoElementTag)
 	if err != nil {
 		return ValidationResult{}, err
@@ -40,41 +188,18 @@ func (validator *XMLDSigSignatureValidator) Validate(xmlBytes []byte) (Validatio
 	if err != nil {
 		return ValidationResult{}, err
 	}
-	result := ValidationResult{}
+	result := ValidationResult{SignatureID: signatureElement.SelectAttrValue(idAttributeKey, "")}
 	for referenceIndex, reference := range references {
-		referenceDetail := ReferenceGenerationDetail{}
-		uriAttribute, err := mustFoundAttribute(reference, uriAttributeKey)
-		if err != nil {
-			return ValidationResult{}, errors.New("this validator does not support anonymous referecing (no URI attribute)")
-		}
-		referenceDetail.URIOfDataObjectBeingSigned = uriAttribute.Value
-		transformsElement, err := mustFoundOnlyOneIfFound(reference, transformsElementTag)
+		referenceDetail, err := parseReferenceElement(reference)
 		if err != nil {
 			return ValidationResult{}, fmt.Errorf("at Reference#%d element: %w", referenceIndex, err)
 		}
-		if transformsElement != nil {
-			transformElements, err := mustFoundAtLeastOneChildElement(transformsElement, transformElementTag)
-			if err != nil {
+		if uri := referenceDetail.URIOfDataObjectBeingSigned; strings.HasPrefix(uri, "#") {
+			if _, err := mustFoundOnlyOneElementByID(signatureElement, uri[1:]); err != nil {
 				return ValidationResult{}, fmt.Errorf("at Reference#%d element: %w", referenceIndex, err)
 			}
-			for transformIndex, transformElement := range transformElements {
-				algorithmAttribute, err := mustFoundAttribute(transformElement, algorithmAttributeKey)
-				if err != nil {
-					return ValidationResult{}, fmt.Errorf("at Transform#%d element of Reference#%d element: %w", transformIndex, referenceIndex, err)
-				}
-				referenceDetail.TransformAlgorithms = append(referenceDetail.TransformAlgorithms, algorithmAttribute.Value)
-			}
 		}
-		digestMethodElement, err := mustFoundOnlyOneChildElement(reference, digestMethodElementTag)
-		if err != nil {
-			return ValidationResult{}, fmt.Errorf("at Reference#%d element: %w", referenceIndex, err)
-		}
-		algorithmAttribute, err := mustFoundAttribute(digestMethodElement, algorithmAttributeKey)
-		if err != nil {
-			return ValidationResult{}, fmt.Errorf("at Reference#%d element: %w", referenceIndex, err)
-		}
-		referenceDetail.DigestAlgorithm = algorithmAttribute.Value
-		generatedDigestValue, err := digestDataObjectFrom(validator.signedInfoFactory, xmlBytes, validator.defaultCanonicalizationAlgorithm, referenceDetail)
+		generatedDigestValue, err := digestDataObjectFrom(validator.signedInfoFactory, validator.uriResolver, xmlBytes, pathOfSignatureElement(signatureElement), validator.defaultCanonicalizationAlgorithm, referenceDetail)
 		if err != nil {
 			return ValidationResult{}, fmt.Errorf("error while digesting at Reference#%d: %w", referenceIndex, err)
 		}
@@ -85,6 +210,9 @@ func (validator *XMLDSigSignatureValidator) Validate(xmlBytes []byte) (Validatio
 		digestValue := []byte(digestValueElement.Text())
 		referenceValidationResult := ReferenceValidationResult{
 			IsValid:              false,
+			URI:                  referenceDetail.URIOfDataObjectBeingSigned,
+			Type:                 referenceDetail.Type,
+			TransformAlgorithms:  referenceDetail.TransformAlgorithms,
 			GeneratedDigestValue: string(generatedDigestValue),
 			DigestValue:          string(digestValue),
 		}
@@ -110,7 +238,7 @@ func (validator *XMLDSigSignatureValidator) Validate(xmlBytes []byte) (Validatio
 	if err != nil {
 		return ValidationResult{}, err
 	}
-	signedInfoInput, err := validator.signedInfoFactory.CreateDereferencer().DereferenceByPath(xmlBytes, "//"+signatureElementTag+"/"+signedInfoElementTag)
+	signedInfoInput, err := validator.signedInfoFactory.CreateDereferencer().DereferenceByPath(xmlBytes, pathOfSignatureElement(signatureElement)+"/"+signedInfoElementTag)
 	if err != nil {
 		return ValidationResult{}, fmt.Errorf("cannot derefernce SignedInfo element: %w", err)
 	}
@@ -123,6 +251,9 @@ func (validator *XMLDSigSignatureValidator) Validate(xmlBytes []byte) (Validatio
 		return ValidationResult{}, fmt.Errorf("error while canonicalizing SignedInfo element: %w", err)
 	}
 	signatureMethodAlgorithm := algorithmAttribute.Value
+	if legacySignatureAlgorithms[signatureMethodAlgorithm] && !validator.allowLegacyAlgorithms {
+		return ValidationResult{}, fmt.Errorf("%s is a legacy signature algorithm, it is only verified with ValidateWithLegacyAlgorithms option", signatureMethodAlgorithm)
+	}
 	keyInfoElement, err := mustFoundOnlyOneIfFound(signatureElement, keyInfoElementTag)
 	if err != nil {
 		return ValidationResult{}, err
@@ -132,22 +263,117 @@ func (validator *XMLDSigSignatureValidator) Validate(xmlBytes []byte) (Validatio
 		return ValidationResult{}, err
 	}
 	signatureValue := signatureValueElement.Text()
-	possibleSignatureVerifiers, err := createPossibleSignatureVerifiersFromKeyInfoElement(keyInfoElement, signatureMethodAlgorithm)
+	keyInfo, err := parseKeyInfoElement(keyInfoElement, validator.certificateStore)
 	if err != nil {
-		return ValidationResult{}, err
+		return ValidationResult{}, fmt.Errorf("at KeyInfo element: %w", err)
+	}
+	var possibleSignatureVerifiers []possibleSignatureVerifier
+	if isHMACSignatureAlgorithm(signatureMethodAlgorithm) {
+		possibleSignatureVerifiers, err = validator.createHMACSignatureVerifiers(signatureMethodAlgorithm, signatureMethodElement, keyInfo)
+		if err != nil {
+			return ValidationResult{}, err
+		}
+	} else {
+		pssParameters, err := pssParametersOf(signatureMethodAlgorithm, signatureMethodElement)
+		if err != nil {
+			return ValidationResult{}, fmt.Errorf("at SignatureMethod element: %w", err)
+		}
+		selectedKeys, err := validator.keySelector.SelectKeys(keyInfo, signatureMethodAlgorithm)
+		if err != nil {
+			return ValidationResult{}, fmt.Errorf("cannot select key: %w", err)
+		}
+		possibleSignatureVerifiers = createPossibleSignatureVerifiers(selectedKeys, pssParameters)
 	}
 	isSignatureValid := false
-	for _, signatureVerifier := range possibleSignatureVerifiers {
-		err := signatureVerifier.Verify(signatureMethodAlgorithm, canonicalizedSignedInfo, []byte(signatureValue))
+	for _, possibleSignatureVerifier := range possibleSignatureVerifiers {
+		err := possibleSignatureVerifier.verifier.Verify(signatureMethodAlgorithm, canonicalizedSignedInfo, []byte(signatureValue))
 		if err == nil {
 			isSignatureValid = true
+			result.SignerCertificate = possibleSignatureVerifier.certificate
+			result.SignerPublicKey = possibleSignatureVerifier.publicKey
 			break
 		}
 	}
 	result.IsSignatureValid = isSignatureValid
+	switch {
+	case validator.trustStore != nil && result.SignerCertificate != nil:
+		var attachedCertificates []*x509.Certificate
+		if keyInfo != nil {
+			attachedCertificates = keyInfo.Certificates
+		}
+		attachedCertificates = append(append([]*x509.Certificate{}, attachedCertificates...), validationData.certificates...)
+		var checker *revocationChecker
+		if validator.ocspFetcher != nil || validator.crlFetcher != nil || len(validationData.crls) > 0 || len(validationData.ocspResponses) > 0 {
+			checker = &revocationChecker{
+				ocspFetcher:           validator.ocspFetcher,
+				crlFetcher:            validator.crlFetcher,
+				embeddedCRLs:          validationData.crls,
+				embeddedOCSPResponses: validationData.ocspResponses,
+				clock:                 validator.validationTime,
+			}
+		}
+		validationTime := validator.validationTime()
+		if !bestSignatureTime.IsZero() && bestSignatureTime.Before(validationTime) {
+			validationTime = bestSignatureTime
+		}
+		certificateValidationResult, err := validateSignerCertificate(result.SignerCertificate, attachedCertificates, validator.trustStore, checker, validationTime)
+		if err != nil {
+			return ValidationResult{}, fmt.Errorf("cannot validate signer certificate: %w", err)
+		}
+		result.CertificateValidationResult = &certificateValidationResult
+	case validator.trustStore != nil && result.SignerPublicKey != nil:
+		// A key without certificate, such as the one of KeyValue or DEREncodedKeyValue element, is not bound to any trust anchor of TrustStore.
+		result.CertificateValidationResult = unboundSignerKeyValidationResult(validator.validationTime())
+	}
 	return result, nil
 }
 
+// parseReferenceElement reads URI, Type, Transforms and DigestMethod of referenceElement.
+func parseReferenceElement(referenceElement *etree.Element) (ReferenceGenerationDetail, error) {
+	referenceDetail := ReferenceGenerationDetail{}
+	uriAttribute, err := mustFoundAttribute(referenceElement, uriAttributeKey)
+	if err != nil {
+		return ReferenceGenerationDetail{}, errors.New("this validator does not support anonymous referecing (no URI attribute)")
+	}
+	referenceDetail.URIOfDataObjectBeingSigned = uriAttribute.Value
+	referenceDetail.Type = referenceElement.SelectAttrValue(typeAttributeKey, "")
+	referenceDetail.TransformAlgorithms, err = parseTransformsElement(referenceElement)
+	if err != nil {
+		return ReferenceGenerationDetail{}, err
+	}
+	digestMethodElement, err := mustFoundOnlyOneChildElement(referenceElement, digestMethodElementTag)
+	if err != nil {
+		return ReferenceGenerationDetail{}, err
+	}
+	algorithmAttribute, err := mustFoundAttribute(digestMethodElement, algorithmAttributeKey)
+	if err != nil {
+		return ReferenceGenerationDetail{}, err
+	}
+	referenceDetail.DigestAlgorithm = algorithmAttribute.Value
+	return referenceDetail, nil
+}
+
+// parseTransformsElement returns the algorithms of Transforms element of parent. It returns nil if Transforms element is absent.
+func parseTransformsElement(parent *etree.Element) ([]string, error) {
+	transformsElement, err := mustFoundOnlyOneIfFound(parent, transformsElementTag)
+	if err != nil || transformsElement == nil {
+		return nil, err
+	}
+	transformElements, err := mustFoundAtLeastOneChildElement(transformsElement, transformElementTag)
+	if err != nil {
+		return nil, err
+	}
+	transformAlgorithms := make([]string, 0, len(transformElements))
+	for transformIndex, transformElement := range transformElements {
+		algorithmAttribute, err := mustFoundAttribute(transformElement, algorithmAttributeKey)
+		if err != nil {
+			return nil, fmt.Errorf("at Transform#%d element: %w", transformIndex, err)
+		}
+		transformAlgorithms = append(transformAlgorithms, algorithmAttribute.Value)
+	}
+	return transformAlgorithms, nil
+}
+
 func createEtreeElementFromXMLBytes(xmlBytes []byte) (*etree.Element, error) {
 	doc := etree.NewDocument()
 	err := doc.ReadFromBytes(xmlBytes)
@@ -157,15 +383,86 @@ func createEtreeElementFromXMLBytes(xmlBytes []byte) (*etree.Element, error) {
 	return doc.Root(), nil
 }
 
-func mustFoundOnlyOneElement(root *etree.Element, tag string) (*etree.Element, error) {
-	foundElements := root.FindElements("//" + tag)
-	if len(foundElements) == 0 {
-		return nil, fmt.Errorf("%s element not found", tag)
+// mustFoundOnlyOneSignatureElement finds the only Signature element of root that is not nested in another Signature element (in CounterSignature element, for example).
+func mustFoundOnlyOneSignatureElement(root *etree.Element) (*etree.Element, error) {
+	signatureElements, err := mustFoundAtLeastOneSignatureElement(root)
+	if err != nil {
+		return nil, err
 	}
-	if len(foundElements) > 1 {
-		return nil, fmt.Errorf("found more than one %s element", tag)
+	if len(signatureElements) > 1 {
+		return nil, fmt.Errorf("found more than one %s element, select one by its Id with ValidateByID or validate all of them with ValidateAll", signatureElementTag)
 	}
-	return foundElements[0], nil
+	return signatureElements[0], nil
+}
+
+// mustFoundAtLeastOneSignatureElement finds, in document order, the Signature elements of root that are not nested in another Signature element.
+func mustFoundAtLeastOneSignatureElement(root *etree.Element) ([]*etree.Element, error) {
+	signatureElements := make([]*etree.Element, 0)
+	for _, signatureElement := range root.FindElements("//" + signatureElementTag) {
+		if isXMLDSigSignatureElement(signatureElement) && !isNestedInSignatureElement(signatureElement) {
+			signatureElements = append(signatureElements, signatureElement)
+		}
+	}
+	if len(signatureElements) == 0 {
+		return nil, fmt.Errorf("%s element not found", signatureElementTag)
+	}
+	return signatureElements, nil
+}
+
+// mustFoundSignatureElementByID finds the Signature element of root whose Id attribute is signatureID, at any depth (a counter signature can be selected too).
+func mustFoundSignatureElementByID(root *etree.Element, signatureID string) (*etree.Element, error) {
+	signatureElements := make([]*etree.Element, 0)
+	for _, element := range findElementsByID(root, signatureID) {
+		if isXMLDSigSignatureElement(element) {
+			signatureElements = append(signatureElements, element)
+		}
+	}
+	if len(signatureElements) == 0 {
+		return nil, fmt.Errorf("%s element with Id %s not found", signatureElementTag, signatureID)
+	}
+	if len(signatureElements) > 1 {
+		return nil, fmt.Errorf("found more than one %s element with Id %s", signatureElementTag, signatureID)
+	}
+	return signatureElements[0], nil
+}
+
+// findElementsByID finds, in document order, the elements of the document of element whose Id attribute is id.
+// id comes from the document or the caller, so it is compared with the attribute values instead of being put in a path.
+func findElementsByID(element *etree.Element, id string) []*etree.Element {
+	elementsWithID := make([]*etree.Element, 0)
+	for _, candidateElement := range element.FindElements("//*") {
+		if candidateElement.SelectAttrValue(idAttributeKey, "") == id {
+			elementsWithID = append(elementsWithID, candidateElement)
+		}
+	}
+	return elementsWithID
+}
+
+// mustFoundOnlyOneElementByID finds the element of the document of element that a same-document URI "#"+id dereferences to.
+// It returns error if more than one element has the Id, as another element could then be digested in place of the referenced one (signature wrapping).
+func mustFoundOnlyOneElementByID(element *etree.Element, id string) (*etree.Element, error) {
+	elementsWithID := findElementsByID(element, id)
+	if len(elementsWithID) == 0 {
+		return nil, fmt.Errorf("element with Id %s not found", id)
+	}
+	if len(elementsWithID) > 1 {
+		return nil, fmt.Errorf("found more than one element with Id %s, the element referenced by #%s is ambiguous", id, id)
+	}
+	return elementsWithID[0], nil
+}
+
+func isNestedInSignatureElement(element *etree.Element) bool {
+	for ancestor := element.Parent(); ancestor != nil; ancestor = ancestor.Parent() {
+		if isXMLDSigSignatureElement(ancestor) {
+			return true
+		}
+	}
+	return false
+}
+
+// isXMLDSigSignatureElement reports whether element is a Signature element of XMLDSig namespace, not an element of another vocabulary with the same local name.
+func isXMLDSigSignatureElement(element *etree.Element) bool {
+	return element.Tag == signatureElementTag && element.NamespaceURI() == xmldsigNamespaceURI
 }
 
 func mustFoundOnlyOneChildElement(parent *etree.Element, childTag string) (*etree.Element, error) {
@@ -206,66 +503,137 @@ func mustFoundOnlyOneIfFound(parent *etree.Element, childTag string) (*etree.Ele
 	return nil, nil
 }
 
-func createPossibleSignatureVerifiersFromKeyInfoElement(keyInfoElement *etree.Element, signatureAlgorithm string) ([]SignatureValueVerifier, error) {
-	result := make([]SignatureValueVerifier, 0)
-	x509DataElements := keyInfoElement.SelectElements(x509DataElementTag)
-	if len(x509DataElements) > 0 {
-		for _, x509Element := range x509DataElements {
-			x509CertificateElements := x509Element.SelectElements(x509CertificateElementTag)
-			if len(x509CertificateElements) > 0 {
-				for _, x509CertificateElement := range x509CertificateElements {
-					asn1Certificate, err := base64.StdEncoding.DecodeString(x509CertificateElement.Text())
-					if err != nil {
-						return nil, errors.New("cannot base64-decode attached certificate: " + err.Error())
-					}
-					certificate, err := x509.ParseCertificate(asn1Certificate)
-					if err != nil {
-						return nil, errors.New("cannot parse attached certificate: " + err.Error())
-					}
-					switch pub := certificate.PublicKey.(type) {
-					case *rsa.PublicKey:
-						result = append(result, &rsaSignatureValueVerifier{rsaPublicKey: pub})
-					}
-				}
-			}
+// possibleSignatureVerifier is a SignatureValueVerifier created from a key selected by KeySelector (or HMACKeySelector) together with the certificate that contains the key, if any.
+type possibleSignatureVerifier struct {
+	verifier    SignatureValueVerifier
+	publicKey   crypto.PublicKey
+	certificate *x509.Certificate
+}
+
+// createHMACSignatureVerifiers creates the verifier of HMAC signature algorithms from the shared secret selected by KeyName of keyInfo (which may be nil).
+// The keys in KeyInfo element are never used, as anyone could have put them there.
+func (validator *XMLDSigSignatureValidator) createHMACSignatureVerifiers(signatureMethodAlgorithm string, signatureMethodElement *etree.Element, keyInfo *KeyInfo) ([]possibleSignatureVerifier, error) {
+	if validator.hmacKeySelector == nil {
+		return nil, fmt.Errorf("%s requires ValidateWithHMACKeySelector option to select the shared secret", signatureMethodAlgorithm)
+	}
+	hashAlgorithm, err := mapSignatureAlgorithmToCrytoHash(signatureMethodAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	outputLength, err := hmacOutputLengthOf(signatureMethodElement, hashAlgorithm)
+	if err != nil {
+		return nil, fmt.Errorf("at SignatureMethod element: %w", err)
+	}
+	keyName := ""
+	if keyInfo != nil && len(keyInfo.KeyNames) > 0 {
+		keyName = keyInfo.KeyNames[0]
+	}
+	secret, err := validator.hmacKeySelector.SelectHMACKey(keyName, signatureMethodAlgorithm)
+	if err != nil {
+		return nil, fmt.Errorf("cannot select HMAC key: %w", err)
+	}
+	return []possibleSignatureVerifier{{verifier: &hmacSignatureValueVerifier{secret: secret, outputLength: outputLength}}}, nil
+}
+
+// createPossibleSignatureVerifiers creates a verifier for each of selectedKeys. Keys of unsupported types are skipped. pssParameters are given to RSA verifiers for RSASSA-PSS signature algorithms.
+func createPossibleSignatureVerifiers(selectedKeys []SelectedKey, pssParameters *PSSParameters) []possibleSignatureVerifier {
+	result := make([]possibleSignatureVerifier, 0, len(selectedKeys))
+	for _, selectedKey := range selectedKeys {
+		if verifier := createSignatureValueVerifier(selectedKey.PublicKey, pssParameters); verifier != nil {
+			result = append(result, possibleSignatureVerifier{verifier: verifier, publicKey: selectedKey.PublicKey, certificate: selectedKey.Certificate})
 		}
 	}
-	return result, nil
+	return result
+}
+
+// createSignatureValueVerifier creates SignatureValueVerifier for publicKey. It returns nil if this package cannot verify with the type of publicKey.
+func createSignatureValueVerifier(publicKey crypto.PublicKey, pssParameters *PSSParameters) SignatureValueVerifier {
+	switch pub := publicKey.(type) {
+	case *rsa.PublicKey:
+		return &rsaSignatureValueVerifier{rsaPublicKey: pub, pssParameters: pssParameters}
+	case *ecdsa.PublicKey:
+		return &ecdsaSignatureValueVerifier{ecdsaPublicKey: pub}
+	case ed25519.PublicKey:
+		return &ed25519SignatureValueVerifier{ed25519PublicKey: pub}
+	case *dsa.PublicKey:
+		return &dsaSignatureValueVerifier{dsaPublicKey: pub}
+	}
+	return nil
 }
 
-func digestDataObjectFrom(signedInfoFactory SignedInfoFactory, xmlBytes []byte, defaultCanonicalizationAlgorithm string, referenceDetails ReferenceGenerationDetail) ([]byte, error) {
-	xmlInput, err := signedInfoFactory.CreateDereferencer().DereferenceByURI(xmlBytes, referenceDetails.URIOfDataObjectBeingSigned)
+// digestDataObjectFrom digests the data object referenced by referenceDetails of the Signature element found by signaturePath in xmlBytes.
+func digestDataObjectFrom(signedInfoFactory SignedInfoFactory, uriResolver URIResolver, xmlBytes []byte, signaturePath string, defaultCanonicalizationAlgorithm string, referenceDetails ReferenceGenerationDetail) ([]byte, error) {
+	xmlInput, err := dereferenceDataObject(signedInfoFactory, uriResolver, xmlBytes, referenceDetails.URIOfDataObjectBeingSigned)
 	if err != nil {
-		return nil, fmt.Errorf("cannot dereference the given URI: %w", err)
+		return nil, err
 	}
-	for transformIndex, transformAlgorithm := range referenceDetails.TransformAlgorithms {
+	return digestTransformedDataObject(signedInfoFactory, xmlInput, signaturePath, defaultCanonicalizationAlgorithm, referenceDetails.TransformAlgorithms, referenceDetails.DigestAlgorithm)
+}
+
+// digestTransformedDataObject digests the output of transformDataObject.
+func digestTransformedDataObject(signedInfoFactory SignedInfoFactory, xmlInput XML, signaturePath string, defaultCanonicalizationAlgorithm string, transformAlgorithms []string, digestAlgorithm string) ([]byte, error) {
+	transformedDataObjectToBeDigested, err := transformDataObject(signedInfoFactory, xmlInput, signaturePath, defaultCanonicalizationAlgorithm, transformAlgorithms)
+	if err != nil {
+		return nil, err
+	}
+	digester, err := CreateDigester(digestAlgorithm)
+	if err != nil {
+		return nil, fmt.Errorf("error while creating Digester: %w", err)
+	}
+	generatedDigestValue, err := digester.Digest(transformedDataObjectToBeDigested)
+	if err != nil {
+		return nil, fmt.Errorf("error while digesting: %w", err)
+	}
+	return generatedDigestValue, nil
+}
+
+// transformDataObject applies transformAlgorithms to xmlInput and canonicalizes the result with defaultCanonicalizationAlgorithm if it is still a node set.
+// signaturePath locates the Signature element containing the Transform elements for EnvelopedSignatureTransformer. It is empty when the transforms are not in a Signature element.
+func transformDataObject(signedInfoFactory SignedInfoFactory, xmlInput XML, signaturePath string, defaultCanonicalizationAlgorithm string, transformAlgorithms []string) ([]byte, error) {
+	var err error
+	for transformIndex, transformAlgorithm := range transformAlgorithms {
 		transformer, err := signedInfoFactory.CreateTransformer(transformAlgorithm)
 		if err != nil {
 			return nil, fmt.Errorf("error while creating Transformer at Transform#%d element: %w", transformIndex, err)
 		}
-		xmlInput, err = transformer.Transform(xmlInput)
+		if envelopedSignatureTransformer, ok := transformer.(EnvelopedSignatureTransformer); ok && signaturePath != "" {
+			xmlInput, err = envelopedSignatureTransformer.TransformEnvelopedSignature(xmlInput, signaturePath)
+		} else {
+			xmlInput, err = transformer.Transform(xmlInput)
+		}
 		if err != nil {
 			return nil, fmt.Errorf("error while transforming at Transform#%d element: %w", transformIndex, err)
 		}
 	}
-	transformedDataObjectToBeDigested := xmlInput.OctetStream
-	if !xmlInput.IsOctetStream {
-		canonicalizer, err := signedInfoFactory.CreateCanonicalizer(defaultCanonicalizationAlgorithm)
-		if err != nil {
-			return nil, fmt.Errorf("error while creating canonicalizer: %w", err)
-		}
-		transformedDataObjectToBeDigested, err = canonicalizer.Canonicalize(xmlInput)
+	if xmlInput.IsOctetStream {
+		return xmlInput.OctetStream, nil
+	}
+	canonicalizer, err := signedInfoFactory.CreateCanonicalizer(defaultCanonicalizationAlgorithm)
+	if err != nil {
+		return nil, fmt.Errorf("error while creating canonicalizer: %w", err)
+	}
+	canonicalizedDataObject, err := canonicalizer.Canonicalize(xmlInput)
+	if err != nil {
+		return nil, fmt.Errorf("error while canonicalizing: %w", err)
+	}
+	return canonicalizedDataObject, nil
+}
+
+// dereferenceDataObject dereferences same-document URI with Dereferencer and consults uriResolver for any other URI.
+func dereferenceDataObject(signedInfoFactory SignedInfoFactory, uriResolver URIResolver, xmlBytes []byte, uri string) (XML, error) {
+	if isSameDocumentURI(uri) {
+		xmlInput, err := signedInfoFactory.CreateDereferencer().DereferenceByURI(xmlBytes, uri)
 		if err != nil {
-			return nil, fmt.Errorf("error while canonicalizing: %w", err)
+			return XML{}, fmt.Errorf("cannot dereference the given URI: %w", err)
 		}
+		return xmlInput, nil
 	}
-	digester, err := CreateDigester(referenceDetails.DigestAlgorithm)
-	if err != nil {
-		return nil, fmt.Errorf("error while creating Digester: %w", err)
+	if uriResolver == nil {
+		return XML{}, fmt.Errorf("URIResolver is required to dereference %s", uri)
 	}
-	generatedDigestValue, err := digester.Digest(transformedDataObjectToBeDigested)
+	octetStream, err := uriResolver.Resolve(uri)
 	if err != nil {
-		return nil, fmt.Errorf("error while digesting: %w", err)
+		return XML{}, fmt.Errorf("cannot resolve the given URI: %w", err)
 	}
-	return generatedDigestValue, nil
+	return XML{IsOctetStream: true, OctetStream: octetStream}, nil
 }
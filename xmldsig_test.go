@@ -1,8 +1,22 @@
 package xades4go_test
 
 import (
+	"crypto"
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"math/big"
 	"testing"
+	"time"
 
+	"github.com/beevik/etree"
 	"github.com/google/go-cmp/cmp"
 	"github.com/mekpavit/xades4go"
 	"github.com/mekpavit/xades4go/etreeimpl"
@@ -25,7 +39,1207 @@ func runTestOfXMLDSigSignatureValidator(t *testing.T, name string, xmldsigSignat
 		{
 			name: "When KeyInfo is X509Data, transform algorithms are enveloped signature and canonical XML 1.0, it should pass the valition",
 			args: args{
-				xmlBytes: []byte(`<rsm:TaxInvoice_CrossIndustryInvoice xmlns:rsm="urn:etda:uncefact:data:standard:TaxInvoice_CrossIndustryInvoice:2" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="urn:etda:uncefact:data:standard:TaxInvoice_CrossIndustryInvoice:2">
+				xmlBytes: []byte(etdaSignedTaxInvoice),
+			},
+			want: xades4go.ValidationResult{
+				SignatureID: "xmldsig-5b38fead-4352-464f-b3b3-3f6cd5c9fbf9",
+				ReferenceValidationResults: []xades4go.ReferenceValidationResult{
+					{
+						IsValid:              true,
+						URI:                  "",
+						TransformAlgorithms:  []string{xades4go.EnvelopedSignatureTransformAlgorithm, xades4go.CanonicalXML10Algorithm},
+						GeneratedDigestValue: `y2/Zx52P9Ck3r1/Rb8Xn516CcuT8i4I57hPKWk++6rv8kmk0Azd+intm2yNgtVyKdHaRt/qAL4YWmgHu91Z7tQ==`,
+						DigestValue:          `y2/Zx52P9Ck3r1/Rb8Xn516CcuT8i4I57hPKWk++6rv8kmk0Azd+intm2yNgtVyKdHaRt/qAL4YWmgHu91Z7tQ==`,
+					},
+					{
+						IsValid:              true,
+						URI:                  "#xmldsig-5b38fead-4352-464f-b3b3-3f6cd5c9fbf9-signedprops",
+						Type:                 xades4go.SignedPropertiesReferenceType,
+						GeneratedDigestValue: `u/ejCCgofcQ7jpaZuyc6RAkd4CuEugPVFx31aFJ3iIEoRh4ZxDkryGHmmPvrQXAp/nEMp4GkcedrQLHJT7kZEA==`,
+						DigestValue:          `u/ejCCgofcQ7jpaZuyc6RAkd4CuEugPVFx31aFJ3iIEoRh4ZxDkryGHmmPvrQXAp/nEMp4GkcedrQLHJT7kZEA==`,
+					},
+				},
+				IsSignatureValid:  true,
+				SignerCertificate: mustParseBase64Certificate(t, etdaSignerCertificate),
+				SignerPublicKey:   mustParseBase64Certificate(t, etdaSignerCertificate).PublicKey,
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(name+": "+tt.name, func(t *testing.T) {
+			got, err := xmldsigSignatureValidator.Validate(tt.args.xmlBytes)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if diff := cmp.Diff(tt.want, got, certificateComparer); diff != "" {
+				t.Errorf("Validate() result mismatch (-want+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_XMLDSigSignatureGenerator(t *testing.T) {
+	privateKey, certificate := mustCreateRSAKeyAndSelfSignedCertificate(t)
+	signedInfoFactory := etreeimpl.NewSignedInfoFactory()
+	generator, err := xades4go.NewXMLDSigSignatureGenerator(signedInfoFactory, privateKey, []*x509.Certificate{certificate}, xades4go.RSASHA256SignatureAlgorithm)
+	if err != nil {
+		t.Fatalf("NewXMLDSigSignatureGenerator() error = %v", err)
+	}
+	runTestOfXMLDSigSignatureGenerator(t, "etreeimpl", generator, xades4go.NewXMLDSigSignatureValidator(signedInfoFactory))
+}
+
+func Test_XMLDSigSignatureGenerator_ECDSA(t *testing.T) {
+	signedInfoFactory := etreeimpl.NewSignedInfoFactory()
+	envelopedReference := []xades4go.ReferenceGenerationDetail{
+		{
+			URIOfDataObjectBeingSigned: "",
+			TransformAlgorithms:        []string{xades4go.EnvelopedSignatureTransformAlgorithm},
+			DigestAlgorithm:            xades4go.SHA256MessageDigestAlgorithm,
+		},
+	}
+	tests := []struct {
+		name                    string
+		curve                   elliptic.Curve
+		signatureAlgorithm      string
+		wantSignatureValueBytes int
+		wantErr                 bool
+	}{
+		{
+			name:                    "When P-256 key signs with ECDSA-SHA256, SignatureValue should be 64 octets of r||s and pass the validation",
+			curve:                   elliptic.P256(),
+			signatureAlgorithm:      xades4go.ECDSASHA256SignatureAlgorithm,
+			wantSignatureValueBytes: 64,
+		},
+		{
+			name:                    "When P-384 key signs with ECDSA-SHA384, SignatureValue should be 96 octets of r||s and pass the validation",
+			curve:                   elliptic.P384(),
+			signatureAlgorithm:      xades4go.ECDSASHA384SignatureAlgorithm,
+			wantSignatureValueBytes: 96,
+		},
+		{
+			name:                    "When P-521 key signs with ECDSA-SHA512, SignatureValue should be 132 octets of r||s and pass the validation",
+			curve:                   elliptic.P521(),
+			signatureAlgorithm:      xades4go.ECDSASHA512SignatureAlgorithm,
+			wantSignatureValueBytes: 132,
+		},
+		{
+			name:               "When ECDSA key signs with RSA signature algorithm, it should return error",
+			curve:              elliptic.P256(),
+			signatureAlgorithm: xades4go.RSASHA256SignatureAlgorithm,
+			wantErr:            true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			privateKey, certificate := mustCreateECDSAKeyAndSelfSignedCertificate(t, tt.curve)
+			generator, err := xades4go.NewXMLDSigSignatureGenerator(signedInfoFactory, privateKey, []*x509.Certificate{certificate}, tt.signatureAlgorithm)
+			if err != nil {
+				t.Fatalf("NewXMLDSigSignatureGenerator() error = %v", err)
+			}
+			signedXMLBytes, err := generator.SignXMLBytes([]byte(unsignedInvoice), envelopedReference)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SignXMLBytes() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			doc := etree.NewDocument()
+			if err := doc.ReadFromBytes(signedXMLBytes); err != nil {
+				t.Fatalf("cannot parse signed XML: %v", err)
+			}
+			signatureValue, err := base64.StdEncoding.DecodeString(doc.FindElement("//SignatureValue").Text())
+			if err != nil {
+				t.Fatalf("cannot base64-decode SignatureValue: %v", err)
+			}
+			if len(signatureValue) != tt.wantSignatureValueBytes {
+				t.Errorf("SignatureValue has %d octets, want %d", len(signatureValue), tt.wantSignatureValueBytes)
+			}
+			got, err := xades4go.NewXMLDSigSignatureValidator(signedInfoFactory).Validate(signedXMLBytes)
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if !got.IsSignatureValid {
+				t.Errorf("Validate() IsSignatureValid = false, want true")
+			}
+			if diff := cmp.Diff(certificate, got.SignerCertificate, certificateComparer); diff != "" {
+				t.Errorf("Validate() SignerCertificate mismatch (-want+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_XMLDSigSignatureValidator_ECDSA(t *testing.T) {
+	signedInfoFactory := etreeimpl.NewSignedInfoFactory()
+	privateKey, certificate := mustCreateECDSAKeyAndSelfSignedCertificate(t, elliptic.P256())
+	generator, err := xades4go.NewXMLDSigSignatureGenerator(signedInfoFactory, privateKey, []*x509.Certificate{certificate}, xades4go.ECDSASHA256SignatureAlgorithm)
+	if err != nil {
+		t.Fatalf("NewXMLDSigSignatureGenerator() error = %v", err)
+	}
+	signedXMLBytes, err := generator.SignXMLBytes([]byte(unsignedInvoice), []xades4go.ReferenceGenerationDetail{
+		{
+			URIOfDataObjectBeingSigned: "",
+			TransformAlgorithms:        []string{xades4go.EnvelopedSignatureTransformAlgorithm},
+			DigestAlgorithm:            xades4go.SHA256MessageDigestAlgorithm,
+		},
+	})
+	if err != nil {
+		t.Fatalf("SignXMLBytes() error = %v", err)
+	}
+	replaceSignatureValue := func(replace func(signatureValue []byte) []byte) []byte {
+		doc := etree.NewDocument()
+		if err := doc.ReadFromBytes(signedXMLBytes); err != nil {
+			t.Fatalf("cannot parse signed XML: %v", err)
+		}
+		signatureValueElement := doc.FindElement("//SignatureValue")
+		signatureValue, err := base64.StdEncoding.DecodeString(signatureValueElement.Text())
+		if err != nil {
+			t.Fatalf("cannot base64-decode SignatureValue: %v", err)
+		}
+		signatureValueElement.SetText(base64.StdEncoding.EncodeToString(replace(signatureValue)))
+		xmlBytes, err := doc.WriteToBytes()
+		if err != nil {
+			t.Fatalf("cannot serialize XML: %v", err)
+		}
+		return xmlBytes
+	}
+	tests := []struct {
+		name                 string
+		xmlBytes             []byte
+		wantIsSignatureValid bool
+	}{
+		{
+			name:                 "When SignatureValue is r||s, it should be valid",
+			xmlBytes:             signedXMLBytes,
+			wantIsSignatureValid: true,
+		},
+		{
+			name: "When SignatureValue is ASN.1 DER instead of r||s, it should be invalid",
+			xmlBytes: replaceSignatureValue(func(signatureValue []byte) []byte {
+				derSignatureValue, err := asn1.Marshal(struct{ R, S *big.Int }{new(big.Int).SetBytes(signatureValue[:32]), new(big.Int).SetBytes(signatureValue[32:])})
+				if err != nil {
+					t.Fatalf("cannot marshal ASN.1 signature: %v", err)
+				}
+				return derSignatureValue
+			}),
+			wantIsSignatureValid: false,
+		},
+		{
+			name: "When r and s are swapped, it should be invalid",
+			xmlBytes: replaceSignatureValue(func(signatureValue []byte) []byte {
+				return append(append([]byte{}, signatureValue[32:]...), signatureValue[:32]...)
+			}),
+			wantIsSignatureValid: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := xades4go.NewXMLDSigSignatureValidator(signedInfoFactory).Validate(tt.xmlBytes)
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if got.IsSignatureValid != tt.wantIsSignatureValid {
+				t.Errorf("Validate() IsSignatureValid = %v, want %v", got.IsSignatureValid, tt.wantIsSignatureValid)
+			}
+		})
+	}
+}
+
+func Test_XMLDSigSignatureGenerator_RSAPSS(t *testing.T) {
+	signedInfoFactory := etreeimpl.NewSignedInfoFactory()
+	privateKey, certificate := mustCreateRSAKeyAndSelfSignedCertificate(t)
+	envelopedReference := []xades4go.ReferenceGenerationDetail{
+		{
+			URIOfDataObjectBeingSigned: "",
+			TransformAlgorithms:        []string{xades4go.EnvelopedSignatureTransformAlgorithm},
+			DigestAlgorithm:            xades4go.SHA256MessageDigestAlgorithm,
+		},
+	}
+	tests := []struct {
+		name                string
+		signatureAlgorithm  string
+		options             []xades4go.XMLDSigSignatureGeneratorOption
+		wantRSAPSSParams    bool
+		wantSaltLength      string
+		wantDigestAlgorithm string
+		wantNewGeneratorErr bool
+	}{
+		{
+			name:               "When signing with SHA1-RSA-MGF1, it should pass the validation",
+			signatureAlgorithm: xades4go.RSASHA1MGF1SignatureAlgorithm,
+		},
+		{
+			name:               "When signing with SHA224-RSA-MGF1, it should pass the validation",
+			signatureAlgorithm: xades4go.RSASHA224MGF1SignatureAlgorithm,
+		},
+		{
+			name:               "When signing with SHA256-RSA-MGF1, it should pass the validation",
+			signatureAlgorithm: xades4go.RSASHA256MGF1SignatureAlgorithm,
+		},
+		{
+			name:               "When signing with SHA384-RSA-MGF1, it should pass the validation",
+			signatureAlgorithm: xades4go.RSASHA384MGF1SignatureAlgorithm,
+		},
+		{
+			name:               "When signing with SHA512-RSA-MGF1, it should pass the validation",
+			signatureAlgorithm: xades4go.RSASHA512MGF1SignatureAlgorithm,
+		},
+		{
+			name:                "When signing with RSASSA-PSS without parameters, it should write the default RSAPSSParams and pass the validation",
+			signatureAlgorithm:  xades4go.RSASSAPSSSignatureAlgorithm,
+			wantRSAPSSParams:    true,
+			wantDigestAlgorithm: xades4go.SHA256MessageDigestAlgorithm,
+			wantSaltLength:      "32",
+		},
+		{
+			name:                "When signing with RSASSA-PSS with explicit parameters, it should write them in RSAPSSParams and pass the validation",
+			signatureAlgorithm:  xades4go.RSASSAPSSSignatureAlgorithm,
+			options:             []xades4go.XMLDSigSignatureGeneratorOption{xades4go.GenerateWithPSSParameters(xades4go.PSSParameters{DigestAlgorithm: xades4go.SHA384MessageDigestAlgorithm, SaltLength: 20})},
+			wantRSAPSSParams:    true,
+			wantDigestAlgorithm: xades4go.SHA384MessageDigestAlgorithm,
+			wantSaltLength:      "20",
+		},
+		{
+			name:                "When PSS parameters are given with a non RSASSA-PSS algorithm, it should return error",
+			signatureAlgorithm:  xades4go.RSASHA256SignatureAlgorithm,
+			options:             []xades4go.XMLDSigSignatureGeneratorOption{xades4go.GenerateWithPSSParameters(xades4go.PSSParameters{DigestAlgorithm: xades4go.SHA256MessageDigestAlgorithm, SaltLength: 32})},
+			wantNewGeneratorErr: true,
+		},
+		{
+			name:                "When PSS parameters have no salt, it should return error as crypto/rsa cannot sign without salt",
+			signatureAlgorithm:  xades4go.RSASSAPSSSignatureAlgorithm,
+			options:             []xades4go.XMLDSigSignatureGeneratorOption{xades4go.GenerateWithPSSParameters(xades4go.PSSParameters{DigestAlgorithm: xades4go.SHA256MessageDigestAlgorithm})},
+			wantNewGeneratorErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator, err := xades4go.NewXMLDSigSignatureGenerator(signedInfoFactory, privateKey, []*x509.Certificate{certificate}, tt.signatureAlgorithm, tt.options...)
+			if (err != nil) != tt.wantNewGeneratorErr {
+				t.Fatalf("NewXMLDSigSignatureGenerator() error = %v, wantErr %v", err, tt.wantNewGeneratorErr)
+			}
+			if tt.wantNewGeneratorErr {
+				return
+			}
+			signedXMLBytes, err := generator.SignXMLBytes([]byte(unsignedInvoice), envelopedReference)
+			if err != nil {
+				t.Fatalf("SignXMLBytes() error = %v", err)
+			}
+			doc := etree.NewDocument()
+			if err := doc.ReadFromBytes(signedXMLBytes); err != nil {
+				t.Fatalf("cannot parse signed XML: %v", err)
+			}
+			rsaPSSParamsElement := doc.FindElement("//SignatureMethod/RSAPSSParams")
+			if (rsaPSSParamsElement != nil) != tt.wantRSAPSSParams {
+				t.Fatalf("RSAPSSParams found = %v, want %v", rsaPSSParamsElement != nil, tt.wantRSAPSSParams)
+			}
+			if tt.wantRSAPSSParams {
+				if got := rsaPSSParamsElement.FindElement("DigestMethod").SelectAttrValue("Algorithm", ""); got != tt.wantDigestAlgorithm {
+					t.Errorf("RSAPSSParams DigestMethod = %s, want %s", got, tt.wantDigestAlgorithm)
+				}
+				if got := rsaPSSParamsElement.FindElement("MaskGenerationFunction/DigestMethod").SelectAttrValue("Algorithm", ""); got != tt.wantDigestAlgorithm {
+					t.Errorf("RSAPSSParams MaskGenerationFunction DigestMethod = %s, want %s", got, tt.wantDigestAlgorithm)
+				}
+				if got := rsaPSSParamsElement.FindElement("SaltLength").Text(); got != tt.wantSaltLength {
+					t.Errorf("RSAPSSParams SaltLength = %s, want %s", got, tt.wantSaltLength)
+				}
+			}
+			got, err := xades4go.NewXMLDSigSignatureValidator(signedInfoFactory).Validate(signedXMLBytes)
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if !got.IsSignatureValid {
+				t.Errorf("Validate() IsSignatureValid = false, want true")
+			}
+		})
+	}
+}
+
+func Test_XMLDSigSignatureValidator_RSAPSS(t *testing.T) {
+	signedInfoFactory := etreeimpl.NewSignedInfoFactory()
+	privateKey, certificate := mustCreateRSAKeyAndSelfSignedCertificate(t)
+	mustSign := func(signatureAlgorithm string, options ...xades4go.XMLDSigSignatureGeneratorOption) []byte {
+		generator, err := xades4go.NewXMLDSigSignatureGenerator(signedInfoFactory, privateKey, []*x509.Certificate{certificate}, signatureAlgorithm, options...)
+		if err != nil {
+			t.Fatalf("NewXMLDSigSignatureGenerator() error = %v", err)
+		}
+		signedXMLBytes, err := generator.SignXMLBytes([]byte(unsignedInvoice), []xades4go.ReferenceGenerationDetail{
+			{
+				URIOfDataObjectBeingSigned: "",
+				TransformAlgorithms:        []string{xades4go.EnvelopedSignatureTransformAlgorithm},
+				DigestAlgorithm:            xades4go.SHA256MessageDigestAlgorithm,
+			},
+		})
+		if err != nil {
+			t.Fatalf("SignXMLBytes() error = %v", err)
+		}
+		return signedXMLBytes
+	}
+	editSignatureMethod := func(xmlBytes []byte, edit func(signatureMethodElement *etree.Element)) []byte {
+		doc := etree.NewDocument()
+		if err := doc.ReadFromBytes(xmlBytes); err != nil {
+			t.Fatalf("cannot parse signed XML: %v", err)
+		}
+		edit(doc.FindElement("//SignatureMethod"))
+		editedXMLBytes, err := doc.WriteToBytes()
+		if err != nil {
+			t.Fatalf("cannot serialize XML: %v", err)
+		}
+		return editedXMLBytes
+	}
+	pssSignedXMLBytes := mustSign(xades4go.RSASSAPSSSignatureAlgorithm, xades4go.GenerateWithPSSParameters(xades4go.PSSParameters{DigestAlgorithm: xades4go.SHA384MessageDigestAlgorithm, SaltLength: 20}))
+	setZeroSaltLength := func(signatureMethodElement *etree.Element) {
+		signatureMethodElement.FindElement("RSAPSSParams/SaltLength").SetText("0")
+	}
+	tests := []struct {
+		name                 string
+		xmlBytes             []byte
+		wantIsSignatureValid bool
+		wantErr              bool
+	}{
+		{
+			name:                 "When RSASSA-PSS signature is verified with its RSAPSSParams, it should be valid",
+			xmlBytes:             pssSignedXMLBytes,
+			wantIsSignatureValid: true,
+		},
+		{
+			name: "When SignatureValue is tampered, it should be invalid",
+			xmlBytes: func() []byte {
+				doc := etree.NewDocument()
+				if err := doc.ReadFromBytes(pssSignedXMLBytes); err != nil {
+					t.Fatalf("cannot parse signed XML: %v", err)
+				}
+				signatureValueElement := doc.FindElement("//SignatureValue")
+				signatureValueElement.SetText("AAAA" + signatureValueElement.Text()[4:])
+				xmlBytes, err := doc.WriteToBytes()
+				if err != nil {
+					t.Fatalf("cannot serialize XML: %v", err)
+				}
+				return xmlBytes
+			}(),
+			wantIsSignatureValid: false,
+		},
+		{
+			name: "When the digest of MGF1 differs from DigestMethod of RSAPSSParams, it should be invalid",
+			xmlBytes: editSignatureMethod(pssSignedXMLBytes, func(signatureMethodElement *etree.Element) {
+				signatureMethodElement.FindElement("RSAPSSParams/MaskGenerationFunction/DigestMethod").CreateAttr("Algorithm", xades4go.SHA256MessageDigestAlgorithm)
+			}),
+			wantIsSignatureValid: false,
+		},
+		{
+			name: "When MaskGenerationFunction is not MGF1, it should be invalid",
+			xmlBytes: editSignatureMethod(pssSignedXMLBytes, func(signatureMethodElement *etree.Element) {
+				signatureMethodElement.FindElement("RSAPSSParams/MaskGenerationFunction").CreateAttr("Algorithm", "http://example.com/unknown-mgf")
+			}),
+			wantIsSignatureValid: false,
+		},
+		{
+			name: "When TrailerField is not 1, it should return error",
+			xmlBytes: editSignatureMethod(pssSignedXMLBytes, func(signatureMethodElement *etree.Element) {
+				signatureMethodElement.FindElement("RSAPSSParams/TrailerField").SetText("2")
+			}),
+			wantErr: true,
+		},
+		{
+			name: "When MaskGenerationFunction is omitted with a DigestMethod other than SHA-256, it should be invalid as MGF1 defaults to SHA-256",
+			xmlBytes: editSignatureMethod(pssSignedXMLBytes, func(signatureMethodElement *etree.Element) {
+				rsaPSSParamsElement := signatureMethodElement.FindElement("RSAPSSParams")
+				rsaPSSParamsElement.RemoveChild(rsaPSSParamsElement.SelectElement("MaskGenerationFunction"))
+			}),
+			wantIsSignatureValid: false,
+		},
+		{
+			name:                 "When SaltLength is 0 and the signature has no salt, it should be valid",
+			xmlBytes:             mustSignPSSWithoutSalt(t, signedInfoFactory, privateKey, crypto.SHA384, editSignatureMethod(pssSignedXMLBytes, setZeroSaltLength)),
+			wantIsSignatureValid: true,
+		},
+		{
+			name:                 "When SaltLength is 0 but the signature has salt, it should be invalid",
+			xmlBytes:             editSignatureMethod(pssSignedXMLBytes, setZeroSaltLength),
+			wantIsSignatureValid: false,
+		},
+		{
+			name: "When SaltLength is negative, it should return error",
+			xmlBytes: editSignatureMethod(pssSignedXMLBytes, func(signatureMethodElement *etree.Element) {
+				signatureMethodElement.FindElement("RSAPSSParams/SaltLength").SetText("-1")
+			}),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := xades4go.NewXMLDSigSignatureValidator(signedInfoFactory).Validate(tt.xmlBytes)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.IsSignatureValid != tt.wantIsSignatureValid {
+				t.Errorf("Validate() IsSignatureValid = %v, want %v", got.IsSignatureValid, tt.wantIsSignatureValid)
+			}
+		})
+	}
+}
+
+func Test_XMLDSigSignatureGenerator_Ed25519(t *testing.T) {
+	signedInfoFactory := etreeimpl.NewSignedInfoFactory()
+	ed25519Key, ed25519Certificate := mustCreateEd25519KeyAndSelfSignedCertificate(t)
+	rsaKey, rsaCertificate := mustCreateRSAKeyAndSelfSignedCertificate(t)
+	envelopedReference := []xades4go.ReferenceGenerationDetail{
+		{
+			URIOfDataObjectBeingSigned: "",
+			TransformAlgorithms:        []string{xades4go.EnvelopedSignatureTransformAlgorithm},
+			DigestAlgorithm:            xades4go.SHA256MessageDigestAlgorithm,
+		},
+	}
+	tests := []struct {
+		name               string
+		signer             crypto.Signer
+		certificate        *x509.Certificate
+		signatureAlgorithm string
+		wantErr            bool
+	}{
+		{
+			name:               "When Ed25519 key signs with EdDSA-Ed25519, SignatureValue should be 64 octets and pass the validation",
+			signer:             ed25519Key,
+			certificate:        ed25519Certificate,
+			signatureAlgorithm: xades4go.EdDSAEd25519SignatureAlgorithm,
+		},
+		{
+			name:               "When Ed25519 key signs with RSA signature algorithm, it should return error",
+			signer:             ed25519Key,
+			certificate:        ed25519Certificate,
+			signatureAlgorithm: xades4go.RSASHA256SignatureAlgorithm,
+			wantErr:            true,
+		},
+		{
+			name:               "When RSA key signs with EdDSA-Ed25519, it should return error",
+			signer:             rsaKey,
+			certificate:        rsaCertificate,
+			signatureAlgorithm: xades4go.EdDSAEd25519SignatureAlgorithm,
+			wantErr:            true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator, err := xades4go.NewXMLDSigSignatureGenerator(signedInfoFactory, tt.signer, []*x509.Certificate{tt.certificate}, tt.signatureAlgorithm)
+			if err != nil {
+				t.Fatalf("NewXMLDSigSignatureGenerator() error = %v", err)
+			}
+			signedXMLBytes, err := generator.SignXMLBytes([]byte(unsignedInvoice), envelopedReference)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SignXMLBytes() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			doc := etree.NewDocument()
+			if err := doc.ReadFromBytes(signedXMLBytes); err != nil {
+				t.Fatalf("cannot parse signed XML: %v", err)
+			}
+			signatureValue, err := base64.StdEncoding.DecodeString(doc.FindElement("//SignatureValue").Text())
+			if err != nil {
+				t.Fatalf("cannot base64-decode SignatureValue: %v", err)
+			}
+			if len(signatureValue) != ed25519.SignatureSize {
+				t.Errorf("SignatureValue has %d octets, want %d", len(signatureValue), ed25519.SignatureSize)
+			}
+			got, err := xades4go.NewXMLDSigSignatureValidator(signedInfoFactory).Validate(signedXMLBytes)
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if !got.IsSignatureValid {
+				t.Errorf("Validate() IsSignatureValid = false, want true")
+			}
+			if diff := cmp.Diff(tt.certificate, got.SignerCertificate, certificateComparer); diff != "" {
+				t.Errorf("Validate() SignerCertificate mismatch (-want+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_CreateDigesterForSignatureAlgorithm(t *testing.T) {
+	tests := []struct {
+		name               string
+		signatureAlgorithm string
+		wantErr            bool
+	}{
+		{
+			name:               "When the signature algorithm hashes SignedInfo, it should create the digester",
+			signatureAlgorithm: xades4go.RSASHA256SignatureAlgorithm,
+		},
+		{
+			name:               "When the signature algorithm has no prehash, it should return error",
+			signatureAlgorithm: xades4go.EdDSAEd25519SignatureAlgorithm,
+			wantErr:            true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			digester, err := xades4go.CreateDigesterForSignatureAlgorithm(tt.signatureAlgorithm)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CreateDigesterForSignatureAlgorithm() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if _, err := digester.Digest([]byte("SignedInfo")); err != nil {
+				t.Errorf("Digest() error = %v", err)
+			}
+		})
+	}
+}
+
+func Test_XMLDSigSignatureValidator_Ed25519(t *testing.T) {
+	signedInfoFactory := etreeimpl.NewSignedInfoFactory()
+	privateKey, certificate := mustCreateEd25519KeyAndSelfSignedCertificate(t)
+	otherPublicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("cannot generate Ed25519 key: %v", err)
+	}
+	generator, err := xades4go.NewXMLDSigSignatureGenerator(signedInfoFactory, privateKey, []*x509.Certificate{certificate}, xades4go.EdDSAEd25519SignatureAlgorithm)
+	if err != nil {
+		t.Fatalf("NewXMLDSigSignatureGenerator() error = %v", err)
+	}
+	signedXMLBytes, err := generator.SignXMLBytes([]byte(unsignedInvoice), []xades4go.ReferenceGenerationDetail{
+		{
+			URIOfDataObjectBeingSigned: "",
+			TransformAlgorithms:        []string{xades4go.EnvelopedSignatureTransformAlgorithm},
+			DigestAlgorithm:            xades4go.SHA256MessageDigestAlgorithm,
+		},
+	})
+	if err != nil {
+		t.Fatalf("SignXMLBytes() error = %v", err)
+	}
+	editSignature := func(edit func(signatureElement *etree.Element)) []byte {
+		doc := etree.NewDocument()
+		if err := doc.ReadFromBytes(signedXMLBytes); err != nil {
+			t.Fatalf("cannot parse signed XML: %v", err)
+		}
+		edit(doc.FindElement("//Signature"))
+		xmlBytes, err := doc.WriteToBytes()
+		if err != nil {
+			t.Fatalf("cannot serialize XML: %v", err)
+		}
+		return xmlBytes
+	}
+	// replaceKeyInfoWithKeyValue replaces X509Data element by ECKeyValue element carrying publicKey, since KeyInfo element is not signed.
+	replaceKeyInfoWithKeyValue := func(publicKey ed25519.PublicKey) []byte {
+		return editSignature(func(signatureElement *etree.Element) {
+			keyInfoElement := signatureElement.SelectElement("KeyInfo")
+			keyInfoElement.RemoveChild(keyInfoElement.SelectElement("X509Data"))
+			ecKeyValueElement := keyInfoElement.CreateElement("ds:KeyValue").CreateElement("dsig11:ECKeyValue")
+			ecKeyValueElement.CreateAttr("xmlns:dsig11", "http://www.w3.org/2009/xmldsig11#")
+			ecKeyValueElement.CreateElement("dsig11:NamedCurve").CreateAttr("URI", "urn:oid:1.3.101.112")
+			ecKeyValueElement.CreateElement("dsig11:PublicKey").SetText(base64.StdEncoding.EncodeToString(publicKey))
+		})
+	}
+	tests := []struct {
+		name                  string
+		xmlBytes              []byte
+		wantIsSignatureValid  bool
+		wantSignerCertificate *x509.Certificate
+		wantSignerPublicKey   crypto.PublicKey
+	}{
+		{
+			name:                  "When Ed25519 key is in X509Certificate, it should be valid",
+			xmlBytes:              signedXMLBytes,
+			wantIsSignatureValid:  true,
+			wantSignerCertificate: certificate,
+			wantSignerPublicKey:   privateKey.Public(),
+		},
+		{
+			name:                 "When Ed25519 key is in KeyValue, it should be valid without signer certificate",
+			xmlBytes:             replaceKeyInfoWithKeyValue(privateKey.Public().(ed25519.PublicKey)),
+			wantIsSignatureValid: true,
+			wantSignerPublicKey:  privateKey.Public(),
+		},
+		{
+			name:                 "When KeyValue carries another Ed25519 key, it should be invalid",
+			xmlBytes:             replaceKeyInfoWithKeyValue(otherPublicKey),
+			wantIsSignatureValid: false,
+		},
+		{
+			name: "When SignatureValue is tampered, it should be invalid",
+			xmlBytes: editSignature(func(signatureElement *etree.Element) {
+				signatureValueElement := signatureElement.SelectElement("SignatureValue")
+				signatureValueElement.SetText("AAAA" + signatureValueElement.Text()[4:])
+			}),
+			wantIsSignatureValid: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := xades4go.NewXMLDSigSignatureValidator(signedInfoFactory).Validate(tt.xmlBytes)
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if got.IsSignatureValid != tt.wantIsSignatureValid {
+				t.Errorf("Validate() IsSignatureValid = %v, want %v", got.IsSignatureValid, tt.wantIsSignatureValid)
+			}
+			if diff := cmp.Diff(tt.wantSignerCertificate, got.SignerCertificate, certificateComparer); diff != "" {
+				t.Errorf("Validate() SignerCertificate mismatch (-want+got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantSignerPublicKey, got.SignerPublicKey); diff != "" {
+				t.Errorf("Validate() SignerPublicKey mismatch (-want+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_XMLDSigSignatureValidator_DSA(t *testing.T) {
+	signedInfoFactory := etreeimpl.NewSignedInfoFactory()
+	rsaKey, rsaCertificate := mustCreateRSAKeyAndSelfSignedCertificate(t)
+	generator, err := xades4go.NewXMLDSigSignatureGenerator(signedInfoFactory, rsaKey, []*x509.Certificate{rsaCertificate}, xades4go.RSASHA256SignatureAlgorithm)
+	if err != nil {
+		t.Fatalf("NewXMLDSigSignatureGenerator() error = %v", err)
+	}
+	rsaSignedXMLBytes, err := generator.SignXMLBytes([]byte(unsignedInvoice), []xades4go.ReferenceGenerationDetail{
+		{
+			URIOfDataObjectBeingSigned: "",
+			TransformAlgorithms:        []string{xades4go.EnvelopedSignatureTransformAlgorithm},
+			DigestAlgorithm:            xades4go.SHA256MessageDigestAlgorithm,
+		},
+	})
+	if err != nil {
+		t.Fatalf("SignXMLBytes() error = %v", err)
+	}
+	dsaKey := &dsa.PrivateKey{}
+	if err := dsa.GenerateParameters(&dsaKey.Parameters, rand.Reader, dsa.L1024N160); err != nil {
+		t.Fatalf("cannot generate DSA parameters: %v", err)
+	}
+	if err := dsa.GenerateKey(dsaKey, rand.Reader); err != nil {
+		t.Fatalf("cannot generate DSA key: %v", err)
+	}
+	// mustSignWithDSA turns the RSA signature into a DSA one: it puts dsaKey in DSAKeyValue element, since crypto/x509 cannot create DSA certificate, and re-signs SignedInfo.
+	mustSignWithDSA := func(signatureAlgorithm string, hash crypto.Hash, swapRS bool) []byte {
+		doc := etree.NewDocument()
+		if err := doc.ReadFromBytes(rsaSignedXMLBytes); err != nil {
+			t.Fatalf("cannot parse signed XML: %v", err)
+		}
+		doc.FindElement("//SignatureMethod").CreateAttr("Algorithm", signatureAlgorithm)
+		keyInfoElement := doc.FindElement("//KeyInfo")
+		keyInfoElement.RemoveChild(keyInfoElement.SelectElement("X509Data"))
+		dsaKeyValueElement := keyInfoElement.CreateElement("ds:KeyValue").CreateElement("ds:DSAKeyValue")
+		for _, value := range []struct {
+			tag string
+			n   *big.Int
+		}{{"P", dsaKey.P}, {"Q", dsaKey.Q}, {"G", dsaKey.G}, {"Y", dsaKey.Y}} {
+			dsaKeyValueElement.CreateElement("ds:" + value.tag).SetText(base64.StdEncoding.EncodeToString(value.n.Bytes()))
+		}
+		xmlBytes, err := doc.WriteToBytes()
+		if err != nil {
+			t.Fatalf("cannot serialize XML: %v", err)
+		}
+		h := hash.New()
+		h.Write(mustCanonicalizeSignedInfo(t, signedInfoFactory, xmlBytes))
+		r, s, err := dsa.Sign(rand.Reader, dsaKey, h.Sum(nil)[:20])
+		if err != nil {
+			t.Fatalf("cannot sign with DSA key: %v", err)
+		}
+		if swapRS {
+			r, s = s, r
+		}
+		signatureValue := make([]byte, 40)
+		r.FillBytes(signatureValue[:20])
+		s.FillBytes(signatureValue[20:])
+		doc.FindElement("//SignatureValue").SetText(base64.StdEncoding.EncodeToString(signatureValue))
+		xmlBytes, err = doc.WriteToBytes()
+		if err != nil {
+			t.Fatalf("cannot serialize XML: %v", err)
+		}
+		return xmlBytes
+	}
+	tests := []struct {
+		name                 string
+		xmlBytes             []byte
+		options              []xades4go.XMLDSigSignatureValidatorOption
+		wantIsSignatureValid bool
+		wantErr              bool
+	}{
+		{
+			name:                 "When DSA-SHA1 signature is validated with legacy algorithms, it should be valid",
+			xmlBytes:             mustSignWithDSA(xades4go.DSASHA1SignatureAlgorithm, crypto.SHA1, false),
+			options:              []xades4go.XMLDSigSignatureValidatorOption{xades4go.ValidateWithLegacyAlgorithms()},
+			wantIsSignatureValid: true,
+		},
+		{
+			name:                 "When DSA-SHA256 signature is validated with legacy algorithms, the digest should be truncated to the length of q and it should be valid",
+			xmlBytes:             mustSignWithDSA(xades4go.DSASHA256SignatureAlgorithm, crypto.SHA256, false),
+			options:              []xades4go.XMLDSigSignatureValidatorOption{xades4go.ValidateWithLegacyAlgorithms()},
+			wantIsSignatureValid: true,
+		},
+		{
+			name:                 "When r and s are swapped, it should be invalid",
+			xmlBytes:             mustSignWithDSA(xades4go.DSASHA1SignatureAlgorithm, crypto.SHA1, true),
+			options:              []xades4go.XMLDSigSignatureValidatorOption{xades4go.ValidateWithLegacyAlgorithms()},
+			wantIsSignatureValid: false,
+		},
+		{
+			name:     "When DSA signature is validated without legacy algorithms, it should return error",
+			xmlBytes: mustSignWithDSA(xades4go.DSASHA1SignatureAlgorithm, crypto.SHA1, false),
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := xades4go.NewXMLDSigSignatureValidator(signedInfoFactory, tt.options...).Validate(tt.xmlBytes)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.IsSignatureValid != tt.wantIsSignatureValid {
+				t.Errorf("Validate() IsSignatureValid = %v, want %v", got.IsSignatureValid, tt.wantIsSignatureValid)
+			}
+			if tt.wantIsSignatureValid {
+				if signerPublicKey, ok := got.SignerPublicKey.(*dsa.PublicKey); !ok || signerPublicKey.Y.Cmp(dsaKey.Y) != 0 {
+					t.Errorf("Validate() SignerPublicKey = %v, want the DSA key of DSAKeyValue", got.SignerPublicKey)
+				}
+			}
+		})
+	}
+}
+
+func runTestOfXMLDSigSignatureGenerator(t *testing.T, name string, generator xades4go.SignatureGenerator, validator xades4go.SignatureValidator) {
+	type args struct {
+		xmlBytes             []byte
+		dataObjectReferences []xades4go.ReferenceGenerationDetail
+	}
+	tests := []struct {
+		name               string
+		args               args
+		wantReferenceCount int
+		wantErr            bool
+	}{
+		{
+			name: "When enveloped signature transform and canonical XML 1.0 are used, the signed XML should pass the validation",
+			args: args{
+				xmlBytes: []byte(unsignedInvoice),
+				dataObjectReferences: []xades4go.ReferenceGenerationDetail{
+					{
+						URIOfDataObjectBeingSigned: "",
+						TransformAlgorithms:        []string{xades4go.EnvelopedSignatureTransformAlgorithm, xades4go.CanonicalXML10Algorithm},
+						DigestAlgorithm:            xades4go.SHA256MessageDigestAlgorithm,
+					},
+				},
+			},
+			wantReferenceCount: 1,
+			wantErr:            false,
+		},
+		{
+			name: "When an element is referenced by its Id, the signed XML should pass the validation",
+			args: args{
+				xmlBytes: []byte(unsignedInvoice),
+				dataObjectReferences: []xades4go.ReferenceGenerationDetail{
+					{
+						URIOfDataObjectBeingSigned: "",
+						TransformAlgorithms:        []string{xades4go.EnvelopedSignatureTransformAlgorithm},
+						DigestAlgorithm:            xades4go.SHA512MessageDigestAlgotithm,
+					},
+					{
+						URIOfDataObjectBeingSigned: "#seller",
+						DigestAlgorithm:            xades4go.SHA256MessageDigestAlgorithm,
+					},
+				},
+			},
+			wantReferenceCount: 2,
+			wantErr:            false,
+		},
+		{
+			name: "When no data object reference is given, it should return error",
+			args: args{
+				xmlBytes: []byte(unsignedInvoice),
+			},
+			wantErr: true,
+		},
+		{
+			name: "When the referenced Id does not exist, it should return error",
+			args: args{
+				xmlBytes: []byte(unsignedInvoice),
+				dataObjectReferences: []xades4go.ReferenceGenerationDetail{
+					{
+						URIOfDataObjectBeingSigned: "#not-exist",
+						DigestAlgorithm:            xades4go.SHA256MessageDigestAlgorithm,
+					},
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(name+": "+tt.name, func(t *testing.T) {
+			signedXMLBytes, err := generator.SignXMLBytes(tt.args.xmlBytes, tt.args.dataObjectReferences)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SignXMLBytes() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			got, err := validator.Validate(signedXMLBytes)
+			if err != nil {
+				t.Errorf("Validate() error = %v", err)
+				return
+			}
+			if !got.IsSignatureValid {
+				t.Errorf("Validate() IsSignatureValid = false, want true")
+			}
+			if len(got.ReferenceValidationResults) != tt.wantReferenceCount {
+				t.Errorf("Validate() got %d reference results, want %d", len(got.ReferenceValidationResults), tt.wantReferenceCount)
+			}
+			for referenceIndex, referenceValidationResult := range got.ReferenceValidationResults {
+				if !referenceValidationResult.IsValid {
+					t.Errorf("Validate() Reference#%d is invalid: %+v", referenceIndex, referenceValidationResult)
+				}
+			}
+		})
+	}
+}
+
+func Test_NewXMLDSigSignatureGenerator(t *testing.T) {
+	privateKey, certificate := mustCreateRSAKeyAndSelfSignedCertificate(t)
+	_, err := xades4go.NewXMLDSigSignatureGenerator(etreeimpl.NewSignedInfoFactory(), privateKey, []*x509.Certificate{certificate}, "http://example.com/unknown-signature-algorithm")
+	if err == nil {
+		t.Errorf("NewXMLDSigSignatureGenerator() with unknown signature algorithm should return error")
+	}
+}
+
+func mustCreateRSAKeyAndSelfSignedCertificate(t *testing.T) (*rsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("cannot generate RSA key: %v", err)
+	}
+	return privateKey, mustCreateSelfSignedCertificate(t, privateKey)
+}
+
+func mustCreateECDSAKeyAndSelfSignedCertificate(t *testing.T, curve elliptic.Curve) (*ecdsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+	privateKey, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		t.Fatalf("cannot generate ECDSA key: %v", err)
+	}
+	return privateKey, mustCreateSelfSignedCertificate(t, privateKey)
+}
+
+func mustCreateEd25519KeyAndSelfSignedCertificate(t *testing.T) (ed25519.PrivateKey, *x509.Certificate) {
+	t.Helper()
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("cannot generate Ed25519 key: %v", err)
+	}
+	return privateKey, mustCreateSelfSignedCertificate(t, privateKey)
+}
+
+// mustCanonicalizeSignedInfo canonicalizes SignedInfo element of the only Signature element of xmlBytes with canonical XML 1.0, for tests to compute SignatureValue themselves.
+func mustCanonicalizeSignedInfo(t *testing.T, signedInfoFactory xades4go.SignedInfoFactory, xmlBytes []byte) []byte {
+	t.Helper()
+	signedInfo, err := signedInfoFactory.CreateDereferencer().DereferenceByPath(xmlBytes, "//Signature/SignedInfo")
+	if err != nil {
+		t.Fatalf("cannot dereference SignedInfo: %v", err)
+	}
+	canonicalizer, err := signedInfoFactory.CreateCanonicalizer(xades4go.CanonicalXML10Algorithm)
+	if err != nil {
+		t.Fatalf("cannot create canonicalizer: %v", err)
+	}
+	canonicalizedSignedInfo, err := canonicalizer.Canonicalize(signedInfo)
+	if err != nil {
+		t.Fatalf("cannot canonicalize SignedInfo: %v", err)
+	}
+	return canonicalizedSignedInfo
+}
+
+// mustSignPSSWithoutSalt replaces SignatureValue of xmlBytes by a RSASSA-PSS signature with empty salt (RFC 8017 section 9.1.1 with sLen = 0),
+// which crypto/rsa cannot create since it reads zero salt length as the maximum length.
+func mustSignPSSWithoutSalt(t *testing.T, signedInfoFactory xades4go.SignedInfoFactory, privateKey *rsa.PrivateKey, hashAlgorithm crypto.Hash, xmlBytes []byte) []byte {
+	t.Helper()
+	mgf1 := func(seed []byte, length int) []byte {
+		var mask []byte
+		for counter := uint32(0); len(mask) < length; counter++ {
+			hash := hashAlgorithm.New()
+			hash.Write(seed)
+			hash.Write([]byte{byte(counter >> 24), byte(counter >> 16), byte(counter >> 8), byte(counter)})
+			mask = hash.Sum(mask)
+		}
+		return mask[:length]
+	}
+	hash := hashAlgorithm.New()
+	hash.Write(mustCanonicalizeSignedInfo(t, signedInfoFactory, xmlBytes))
+	messageHash := hash.Sum(nil)
+	hash = hashAlgorithm.New()
+	hash.Write(make([]byte, 8))
+	hash.Write(messageHash)
+	h := hash.Sum(nil)
+	encodedMessageBits := privateKey.N.BitLen() - 1
+	encodedMessageLength := (encodedMessageBits + 7) / 8
+	db := make([]byte, encodedMessageLength-len(h)-1)
+	db[len(db)-1] = 0x01
+	for i, b := range mgf1(h, len(db)) {
+		db[i] ^= b
+	}
+	db[0] &= 0xff >> uint(8*encodedMessageLength-encodedMessageBits)
+	encodedMessage := append(append(db, h...), 0xbc)
+	signatureValue := new(big.Int).Exp(new(big.Int).SetBytes(encodedMessage), privateKey.D, privateKey.N).FillBytes(make([]byte, privateKey.Size()))
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(xmlBytes); err != nil {
+		t.Fatalf("cannot parse signed XML: %v", err)
+	}
+	doc.FindElement("//SignatureValue").SetText(base64.StdEncoding.EncodeToString(signatureValue))
+	signedXMLBytes, err := doc.WriteToBytes()
+	if err != nil {
+		t.Fatalf("cannot serialize XML: %v", err)
+	}
+	return signedXMLBytes
+}
+
+func mustCreateSelfSignedCertificate(t *testing.T, signer crypto.Signer) *x509.Certificate {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "xades4go test signer", Country: []string{"TH"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment,
+	}
+	asn1Certificate, err := x509.CreateCertificate(rand.Reader, template, template, signer.Public(), signer)
+	if err != nil {
+		t.Fatalf("cannot create certificate: %v", err)
+	}
+	certificate, err := x509.ParseCertificate(asn1Certificate)
+	if err != nil {
+		t.Fatalf("cannot parse certificate: %v", err)
+	}
+	return certificate
+}
+
+const unsignedInvoice = `<?xml version="1.0" encoding="UTF-8"?>
+<rsm:TaxInvoice_CrossIndustryInvoice xmlns:rsm="urn:etda:uncefact:data:standard:TaxInvoice_CrossIndustryInvoice:2" xmlns:ram="urn:etda:uncefact:data:standard:TaxInvoice_ReusableAggregateBusinessInformationEntity:2">
+    <rsm:ExchangedDocument>
+        <ram:ID>INV01</ram:ID>
+        <ram:Name>ใบกำกับภาษี</ram:Name>
+        <ram:TypeCode>388</ram:TypeCode>
+        <ram:IssueDateTime>2017-12-19T00:00:00.000</ram:IssueDateTime>
+    </rsm:ExchangedDocument>
+    <rsm:SupplyChainTradeTransaction>
+        <ram:SellerTradeParty Id="seller">
+            <ram:Name>บริษัท ขยันหมั่นเพียร จำกัด</ram:Name>
+            <ram:SpecifiedTaxRegistration>
+                <ram:ID schemeID="TXID">123456789012300000</ram:ID>
+            </ram:SpecifiedTaxRegistration>
+        </ram:SellerTradeParty>
+        <ram:GrandTotalAmount>10698.93</ram:GrandTotalAmount>
+    </rsm:SupplyChainTradeTransaction>
+</rsm:TaxInvoice_CrossIndustryInvoice>`
+
+func Test_XMLDSigSignatureGenerator_SignEnveloping(t *testing.T) {
+	privateKey, certificate := mustCreateRSAKeyAndSelfSignedCertificate(t)
+	signedInfoFactory := etreeimpl.NewSignedInfoFactory()
+	generator, err := xades4go.NewXMLDSigSignatureGenerator(signedInfoFactory, privateKey, []*x509.Certificate{certificate}, xades4go.RSASHA256SignatureAlgorithm)
+	if err != nil {
+		t.Fatalf("NewXMLDSigSignatureGenerator() error = %v", err)
+	}
+	validator := xades4go.NewXMLDSigSignatureValidator(signedInfoFactory)
+	type args struct {
+		dataObjects []xades4go.DataObjectGenerationDetail
+	}
+	tests := []struct {
+		name               string
+		args               args
+		wantReferenceCount int
+		wantErr            bool
+	}{
+		{
+			name: "When XML data object is given, the signed XML should pass the validation",
+			args: args{
+				dataObjects: []xades4go.DataObjectGenerationDetail{
+					{
+						ID:                  "invoice",
+						MimeType:            "text/xml",
+						Content:             []byte(unsignedInvoice),
+						TransformAlgorithms: []string{xades4go.CanonicalXML10Algorithm},
+						DigestAlgorithm:     xades4go.SHA256MessageDigestAlgorithm,
+					},
+				},
+			},
+			wantReferenceCount: 1,
+			wantErr:            false,
+		},
+		{
+			name: "When XML and binary data objects are given, the signed XML should pass the validation",
+			args: args{
+				dataObjects: []xades4go.DataObjectGenerationDetail{
+					{
+						Content:         []byte(unsignedInvoice),
+						DigestAlgorithm: xades4go.SHA256MessageDigestAlgorithm,
+					},
+					{
+						MimeType:        "application/pdf",
+						Content:         []byte("%PDF-1.4 not really a PDF"),
+						IsOctetStream:   true,
+						DigestAlgorithm: xades4go.SHA512MessageDigestAlgotithm,
+					},
+				},
+			},
+			wantReferenceCount: 2,
+			wantErr:            false,
+		},
+		{
+			name: "When XML data object does not contain any element, it should return error",
+			args: args{
+				dataObjects: []xades4go.DataObjectGenerationDetail{
+					{
+						Content:         []byte("not an XML"),
+						DigestAlgorithm: xades4go.SHA256MessageDigestAlgorithm,
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "When no data object is given, it should return error",
+			args:    args{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signedXMLBytes, err := generator.(xades4go.EnvelopingSigner).SignEnveloping(tt.args.dataObjects)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SignEnveloping() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			got, err := validator.Validate(signedXMLBytes)
+			if err != nil {
+				t.Errorf("Validate() error = %v", err)
+				return
+			}
+			if !got.IsSignatureValid {
+				t.Errorf("Validate() IsSignatureValid = false, want true")
+			}
+			if len(got.ReferenceValidationResults) != tt.wantReferenceCount {
+				t.Errorf("Validate() got %d reference results, want %d", len(got.ReferenceValidationResults), tt.wantReferenceCount)
+			}
+			for referenceIndex, referenceValidationResult := range got.ReferenceValidationResults {
+				if !referenceValidationResult.IsValid {
+					t.Errorf("Validate() Reference#%d is invalid: %+v", referenceIndex, referenceValidationResult)
+				}
+			}
+		})
+	}
+}
+
+func Test_XMLDSigSignatureGenerator_SignDetached(t *testing.T) {
+	privateKey, certificate := mustCreateRSAKeyAndSelfSignedCertificate(t)
+	signedInfoFactory := etreeimpl.NewSignedInfoFactory()
+	dataObjects := map[string][]byte{
+		"invoice.pdf":             []byte("%PDF-1.4 not really a PDF"),
+		"cid:invoice@example.com": []byte(unsignedInvoice),
+	}
+	generator, err := xades4go.NewXMLDSigSignatureGenerator(signedInfoFactory, privateKey, []*x509.Certificate{certificate}, xades4go.RSASHA256SignatureAlgorithm, xades4go.GenerateWithURIResolver(xades4go.NewMapURIResolver(dataObjects)))
+	if err != nil {
+		t.Fatalf("NewXMLDSigSignatureGenerator() error = %v", err)
+	}
+	dataObjectReferences := []xades4go.ReferenceGenerationDetail{
+		{
+			URIOfDataObjectBeingSigned: "invoice.pdf",
+			DigestAlgorithm:            xades4go.SHA256MessageDigestAlgorithm,
+		},
+		{
+			URIOfDataObjectBeingSigned: "cid:invoice@example.com",
+			TransformAlgorithms:        []string{xades4go.CanonicalXML10Algorithm},
+			DigestAlgorithm:            xades4go.SHA256MessageDigestAlgorithm,
+		},
+	}
+	signedXMLBytes, err := generator.(xades4go.DetachedSigner).SignDetached(dataObjectReferences)
+	if err != nil {
+		t.Fatalf("SignDetached() error = %v", err)
+	}
+	tamperedDataObjects := map[string][]byte{
+		"invoice.pdf":             []byte("%PDF-1.4 tampered"),
+		"cid:invoice@example.com": []byte(unsignedInvoice),
+	}
+	tests := []struct {
+		name         string
+		validator    xades4go.SignatureValidator
+		wantValidity []bool
+		wantErr      bool
+	}{
+		{
+			name:         "When the same data objects are resolved, every reference should be valid",
+			validator:    xades4go.NewXMLDSigSignatureValidator(signedInfoFactory, xades4go.ValidateWithURIResolver(xades4go.NewMapURIResolver(dataObjects))),
+			wantValidity: []bool{true, true},
+			wantErr:      false,
+		},
+		{
+			name:         "When a data object is modified, its reference should be invalid",
+			validator:    xades4go.NewXMLDSigSignatureValidator(signedInfoFactory, xades4go.ValidateWithURIResolver(xades4go.NewMapURIResolver(tamperedDataObjects))),
+			wantValidity: []bool{false, true},
+			wantErr:      false,
+		},
+		{
+			name:      "When no URIResolver is given, it should return error",
+			validator: xades4go.NewXMLDSigSignatureValidator(signedInfoFactory),
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.validator.Validate(signedXMLBytes)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if !got.IsSignatureValid {
+				t.Errorf("Validate() IsSignatureValid = false, want true")
+			}
+			gotValidity := make([]bool, 0, len(got.ReferenceValidationResults))
+			for _, referenceValidationResult := range got.ReferenceValidationResults {
+				gotValidity = append(gotValidity, referenceValidationResult.IsValid)
+			}
+			if diff := cmp.Diff(tt.wantValidity, gotValidity); diff != "" {
+				t.Errorf("Validate() reference validity mismatch (-want+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+var certificateComparer = cmp.Comparer(func(x, y *x509.Certificate) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return x.Equal(y)
+})
+
+func mustParseBase64Certificate(t *testing.T, base64Certificate string) *x509.Certificate {
+	t.Helper()
+	asn1Certificate, err := base64.StdEncoding.DecodeString(base64Certificate)
+	if err != nil {
+		t.Fatalf("cannot base64-decode certificate: %v", err)
+	}
+	certificate, err := x509.ParseCertificate(asn1Certificate)
+	if err != nil {
+		t.Fatalf("cannot parse certificate: %v", err)
+	}
+	return certificate
+}
+
+// etdaSignerCertificate is the signer certificate embedded in etdaSignedTaxInvoice.
+const etdaSignerCertificate = `MIIFrjCCA5agAwIBAgIIew6XEhSld4EwDQYJKoZIhvcNAQELBQAwgbUxCzAJBgNVBAYTAnRoMT0wOwYDVQQKDDRNaW5pc3RyeSBvZiBJbmZvcm1hdGlvbiBhbmQgQ29tbXVuaWNhdGlvbiBUZWNobm9sb2d5MUkwRwYDVQQLDEBFbGVjdHJvbmljIFRyYW5zYWN0aW9ucyBEZXZlbG9wbWVudCBBZ2VuY3kgKFB1YmxpYyBPcmdhbml6YXRpb24pMRwwGgYDVQQDDBNUZURBIENBIGZvciBUZXN0aW5nMB4XDTE5MDcwNDA1MDY1MFoXDTIyMDcwNDA1MDY1MFowZTELMAkGA1UEBhMCVEgxMzAxBgNVBAoMKkVsZWN0cm9uaWMgVHJhbnNhY3Rpb25zIERldmVsb3BtZW50IEFnZW5jeTEhMB8GA1UEAwwYQ29kZSBTaWduaW5nIENlcnRpZmljYXRlMIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEAoVWH2z88nV0+GuxpyHKZjNWxTv7syQqwL+FiF2KErwdI9rRSKFz5GyOhB5N6Nzjh0AflcfUGrOa+AbjNi+5MGUC3uL2ugo7jMXx2Rwp90aHhGU8jhE/Dx6pdUiQSd6ZLyCTYzIrb4okgDRzsJTe3pFfnM0ScspiU2GMRCMmQgPYWob6BFPgzqIcYK99f82CENg3PFlm4bUWTgvVgF0TTevjLvH8Dx8LvnORW05Hk+jHWbPQuHtarmubUowFv9N1EboBEbFAPxhOp67vctzuoDfOtLaC0unfkXBxzSpnpKg7ZDSEbT4C6hDMqXaAGZxRWtE6ggVQfjzICOs8ZhV+zkwIDAQABo4IBDzCCAQswVQYIKwYBBQUHAQEESTBHMEUGCCsGAQUFBzAChjlodHRwOi8vcmVwby10ZXN0LnRlZGEudGgvY2VydC9UZURBQ0Fmb3JUZXN0aW5nLmNhY2VydC5jcnQwHQYDVR0OBBYEFBnP8q2USQRJ1oTlTds9irSSivl7MAwGA1UdEwEB/wQCMAAwHwYDVR0jBBgwFoAUw7M9c+QxW38JHlzBhP71WNfouZQwQgYDVR0fBDswOTA3oDWgM4YxaHR0cDovL3JlcG8tdGVzdC50ZWRhLnRoL2NybC9UZURBQ0Fmb3JUZXN0aW5nLmNybDALBgNVHQ8EBAMCBPAwEwYDVR0lBAwwCgYIKwYBBQUHAwMwDQYJKoZIhvcNAQELBQADggIBACrFj0Ee4paSEBzmskqyLatVvbnDfUUfDMMkQrSGcD2l2lNaorAtcBZeVJTRMt+doJTNPwpAFbW3rbbAAX+PKAn5M8F2dcj0W/Q6dIw1pQyuRJIBgJ7BwXq7fwbEV3C1AUV3EXTGND4hz7LYRqCIuLi6ODdT3/HBQlEBQtNhKLBBciE81mWKvaQ1g/hAbPZOSDW7WBEw8Kjj1vbPS0lviar8TurRwbwDlYMk6NzpSGPJYUrxjYw54ZJx/1QngKGK6wsZiV0sj5JbbfxjTwWOhEl2LdulQJ8KNZv+ajQMZqtEeAreAHLyGSG6xgOpPV9aHP9LDTR/d5qi3JB5fwMOvEsWWvzoKzvilR6WO3hYL8qQi/Y4C7oYMkjxVBAALXi2PH4cZSA26SkR2gHQ8FMO1o+StqkBBjkrtdyhvr+PxijFSh25T3rLlAPBDCALSUPRdLg848k07CleGBzDDETNsFnUhiZXCzD6TWEKqdMVItzXCuCe+bCX8/wvsVC48chMdxjVHLR3P8csyK+tPS+Te9ipsI3ZgIoDWilNJhKMyaQbmI+zHFzBVVE9cVMkFsGOWh0lKscQA3k1CnhvfIsppyz/ZK6sj7/7Q5+is/4ay5vGhgSPXVhN0kCW5u+esGouVPJLMfqvwhh4V1a+9sQtsRDugqPhzk00/DrI0byUjl65`
+
+// etdaSignedTaxInvoice is a XAdES-BES signed tax invoice from ETDA (Electronic Transactions Development Agency of Thailand).
+const etdaSignedTaxInvoice = `<rsm:TaxInvoice_CrossIndustryInvoice xmlns:rsm="urn:etda:uncefact:data:standard:TaxInvoice_CrossIndustryInvoice:2" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="urn:etda:uncefact:data:standard:TaxInvoice_CrossIndustryInvoice:2">
     <rsm:ExchangedDocumentContext xmlns:ram="urn:etda:uncefact:data:standard:TaxInvoice_ReusableAggregateBusinessInformationEntity:2">
         <ram:GuidelineSpecifiedDocumentContextParameter>
             <ram:ID schemeAgencyID="ETDA" schemeVersionID="v2.0">ER3-2560</ram:ID>
@@ -157,36 +1371,4 @@ func runTestOfXMLDSigSignatureValidator(t *testing.T, name string, xmldsigSignat
             </ram:SpecifiedLineTradeSettlement>
         </ram:IncludedSupplyChainTradeLineItem>
     </rsm:SupplyChainTradeTransaction>
-<ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#" Id="xmldsig-5b38fead-4352-464f-b3b3-3f6cd5c9fbf9"><ds:SignedInfo><ds:CanonicalizationMethod Algorithm="http://www.w3.org/TR/2001/REC-xml-c14n-20010315"/><ds:SignatureMethod Algorithm="http://www.w3.org/2001/04/xmldsig-more#rsa-sha512"/><ds:Reference Id="xmldsig-5b38fead-4352-464f-b3b3-3f6cd5c9fbf9-ref0" URI=""><ds:Transforms><ds:Transform Algorithm="http://www.w3.org/2000/09/xmldsig#enveloped-signature"/><ds:Transform Algorithm="http://www.w3.org/TR/2001/REC-xml-c14n-20010315"/></ds:Transforms><ds:DigestMethod Algorithm="http://www.w3.org/2001/04/xmlenc#sha512"/><ds:DigestValue>y2/Zx52P9Ck3r1/Rb8Xn516CcuT8i4I57hPKWk++6rv8kmk0Azd+intm2yNgtVyKdHaRt/qAL4YWmgHu91Z7tQ==</ds:DigestValue></ds:Reference><ds:Reference Type="http://uri.etsi.org/01903#SignedProperties" URI="#xmldsig-5b38fead-4352-464f-b3b3-3f6cd5c9fbf9-signedprops"><ds:DigestMethod Algorithm="http://www.w3.org/2001/04/xmlenc#sha512"/><ds:DigestValue>u/ejCCgofcQ7jpaZuyc6RAkd4CuEugPVFx31aFJ3iIEoRh4ZxDkryGHmmPvrQXAp/nEMp4GkcedrQLHJT7kZEA==</ds:DigestValue></ds:Reference></ds:SignedInfo><ds:SignatureValue Id="xmldsig-5b38fead-4352-464f-b3b3-3f6cd5c9fbf9-sigvalue">BOxF2QGxUzpNcP5YcJ6IIMLLWWmrqEscAPE7a+yr/x3kgJnwMPWm4D3ae0F5zifA+OeZGhQjPZ9ctIZYssVUZtJNIBrHuTpFndZvL9H07//HWjJUOi7Gv8qeCRw1FsuSdlTew9TrucNH6zfCm5KQIGCkH2nplV3oNhvqewA6cjYW1nJmnAyfaaDcD0x7xl6dmgXQ9xv573eCFKP72GRzhwxr36llqLvaoJMHUGkq59wCYc7oMgj5d+vG5fSA6BXsfXPWxmp1gyeoa7UIPT62Pvy79RjH9WtmiQcUcm4/iA+fX0jCOXhKeJEOz15fhTCHQnWp4CYNjVBOzoh2xOhlEg==</ds:SignatureValue><ds:KeyInfo><ds:X509Data><ds:X509Certificate>MIIFrjCCA5agAwIBAgIIew6XEhSld4EwDQYJKoZIhvcNAQELBQAwgbUxCzAJBgNVBAYTAnRoMT0wOwYDVQQKDDRNaW5pc3RyeSBvZiBJbmZvcm1hdGlvbiBhbmQgQ29tbXVuaWNhdGlvbiBUZWNobm9sb2d5MUkwRwYDVQQLDEBFbGVjdHJvbmljIFRyYW5zYWN0aW9ucyBEZXZlbG9wbWVudCBBZ2VuY3kgKFB1YmxpYyBPcmdhbml6YXRpb24pMRwwGgYDVQQDDBNUZURBIENBIGZvciBUZXN0aW5nMB4XDTE5MDcwNDA1MDY1MFoXDTIyMDcwNDA1MDY1MFowZTELMAkGA1UEBhMCVEgxMzAxBgNVBAoMKkVsZWN0cm9uaWMgVHJhbnNhY3Rpb25zIERldmVsb3BtZW50IEFnZW5jeTEhMB8GA1UEAwwYQ29kZSBTaWduaW5nIENlcnRpZmljYXRlMIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEAoVWH2z88nV0+GuxpyHKZjNWxTv7syQqwL+FiF2KErwdI9rRSKFz5GyOhB5N6Nzjh0AflcfUGrOa+AbjNi+5MGUC3uL2ugo7jMXx2Rwp90aHhGU8jhE/Dx6pdUiQSd6ZLyCTYzIrb4okgDRzsJTe3pFfnM0ScspiU2GMRCMmQgPYWob6BFPgzqIcYK99f82CENg3PFlm4bUWTgvVgF0TTevjLvH8Dx8LvnORW05Hk+jHWbPQuHtarmubUowFv9N1EboBEbFAPxhOp67vctzuoDfOtLaC0unfkXBxzSpnpKg7ZDSEbT4C6hDMqXaAGZxRWtE6ggVQfjzICOs8ZhV+zkwIDAQABo4IBDzCCAQswVQYIKwYBBQUHAQEESTBHMEUGCCsGAQUFBzAChjlodHRwOi8vcmVwby10ZXN0LnRlZGEudGgvY2VydC9UZURBQ0Fmb3JUZXN0aW5nLmNhY2VydC5jcnQwHQYDVR0OBBYEFBnP8q2USQRJ1oTlTds9irSSivl7MAwGA1UdEwEB/wQCMAAwHwYDVR0jBBgwFoAUw7M9c+QxW38JHlzBhP71WNfouZQwQgYDVR0fBDswOTA3oDWgM4YxaHR0cDovL3JlcG8tdGVzdC50ZWRhLnRoL2NybC9UZURBQ0Fmb3JUZXN0aW5nLmNybDALBgNVHQ8EBAMCBPAwEwYDVR0lBAwwCgYIKwYBBQUHAwMwDQYJKoZIhvcNAQELBQADggIBACrFj0Ee4paSEBzmskqyLatVvbnDfUUfDMMkQrSGcD2l2lNaorAtcBZeVJTRMt+doJTNPwpAFbW3rbbAAX+PKAn5M8F2dcj0W/Q6dIw1pQyuRJIBgJ7BwXq7fwbEV3C1AUV3EXTGND4hz7LYRqCIuLi6ODdT3/HBQlEBQtNhKLBBciE81mWKvaQ1g/hAbPZOSDW7WBEw8Kjj1vbPS0lviar8TurRwbwDlYMk6NzpSGPJYUrxjYw54ZJx/1QngKGK6wsZiV0sj5JbbfxjTwWOhEl2LdulQJ8KNZv+ajQMZqtEeAreAHLyGSG6xgOpPV9aHP9LDTR/d5qi3JB5fwMOvEsWWvzoKzvilR6WO3hYL8qQi/Y4C7oYMkjxVBAALXi2PH4cZSA26SkR2gHQ8FMO1o+StqkBBjkrtdyhvr+PxijFSh25T3rLlAPBDCALSUPRdLg848k07CleGBzDDETNsFnUhiZXCzD6TWEKqdMVItzXCuCe+bCX8/wvsVC48chMdxjVHLR3P8csyK+tPS+Te9ipsI3ZgIoDWilNJhKMyaQbmI+zHFzBVVE9cVMkFsGOWh0lKscQA3k1CnhvfIsppyz/ZK6sj7/7Q5+is/4ay5vGhgSPXVhN0kCW5u+esGouVPJLMfqvwhh4V1a+9sQtsRDugqPhzk00/DrI0byUjl65</ds:X509Certificate><ds:X509Certificate>MIIGnjCCBIagAwIBAgIJAJb3gAGTprceMA0GCSqGSIb3DQEBDQUAMIG1MQswCQYDVQQGEwJ0aDE9MDsGA1UECgw0TWluaXN0cnkgb2YgSW5mb3JtYXRpb24gYW5kIENvbW11bmljYXRpb24gVGVjaG5vbG9neTFJMEcGA1UECwxARWxlY3Ryb25pYyBUcmFuc2FjdGlvbnMgRGV2ZWxvcG1lbnQgQWdlbmN5IChQdWJsaWMgT3JnYW5pemF0aW9uKTEcMBoGA1UEAwwTVGVEQSBDQSBmb3IgVGVzdGluZzAeFw0xNDAzMTgwNTQ0MTJaFw0zNDAzMTMwNTQ0MTJaMIG1MQswCQYDVQQGEwJ0aDE9MDsGA1UECgw0TWluaXN0cnkgb2YgSW5mb3JtYXRpb24gYW5kIENvbW11bmljYXRpb24gVGVjaG5vbG9neTFJMEcGA1UECwxARWxlY3Ryb25pYyBUcmFuc2FjdGlvbnMgRGV2ZWxvcG1lbnQgQWdlbmN5IChQdWJsaWMgT3JnYW5pemF0aW9uKTEcMBoGA1UEAwwTVGVEQSBDQSBmb3IgVGVzdGluZzCCAiIwDQYJKoZIhvcNAQEBBQADggIPADCCAgoCggIBAMQJXv8fjahmK4hXC6mVSexeDNXa0XYnjeOueZmEpGydRh+b/dIMxcEUPdZm6zs3Y+IkDVma8OovRigLMk8XapcKcEsTwdliy5wTgiLtfJEDjUMxuC9RbvIoIcOHlz+Vv4iHlqOL4fab5dXWFQ5E8j2EfZO3HMm55KTIFSMSRJSPUysw3p65EddckQ5SrWB0JoQoRaj57oguXZXxZVLcvLRtHbpggF12Jx+B2kOdcrxoK+NPVowmD2CZmOlTAC9suB3gB6f7JiHYBSuh2O75K+Or5At5q4tjVcbgAvMAkWjjor+DB9QZJxtAGC9Xa+lMJko9DBWXjSkXTwAmTP/ubVaD9szexAMDCROZGbFv7qfnxX3qFfCvIYkFmCRi+gmgInb7SOIJfTr5hta5JEHHFK/6dL6RFHM3EgZEEQcOZzyYVpe1WckKJjfiOmGgh9HyaT0Ey8hRXHo1DxuCrwEL0or9Hedle6j17WB6iWh1Uc0o9Qof8XCyV3y+NUf0KmHC9bze6sG3C5v+cwo8hBjSWK5J8452d6XQ+/tHJQpFPlaCNrss1voJgaenn3u6ZpGDn5VANBnObgxB8RucQpvEaOd1UP+F0scQSMomtg3WE5tGzOX/EnGfv3cd2qubPkAX+IwFXsEgUoCvgUAXkj/VwfcxuNzq3DbTKGFoqot6zI43AgMBAAGjga4wgaswHQYDVR0OBBYEFMOzPXPkMVt/CR5cwYT+9VjX6LmUMB8GA1UdIwQYMBaAFMOzPXPkMVt/CR5cwYT+9VjX6LmUMAwGA1UdEwQFMAMBAf8wFQYDVR0gBA4wDDAKBghghXwBBAQBZTALBgNVHQ8EBAMCAQYwNwYDVR0fBDAwLjAsoCqgKIYmaHR0cDovL2xhYnRvcmVhbC5jb20vdGVkYWNhL3RlZGFDQS5jcmwwDQYJKoZIhvcNAQENBQADggIBAIdQlz1S09lH4YBqmCDcCS4O4XGK0+L8fIzum0k71C9bTY+JD1Ck2EZ0Ozy34hQrfjrfO4qAwkzxs9r3KVMrYFBsVGRkfYk2jXSwJMDT63L+NoEwZQ3+8Z+pxOF3vxPWfklRg9nJ0KeOWxjm4tqWUpaFrTLF7r/K0DRgq4xHaZm3d+iAAwsmWX0XHWgurmkqXYgiXUB9qGyaXP8JeKhYXi8OEIAgE/TiqXbG3caTZn9ESAx26WDDzX863mowIsRIjUuvZzoM66DVJ+6CuiE5m2GyWrJu+TCiyGtvsvgWPdoowBwTwu816OcIcWEL3RUEVy5vuuPYlMZm/udA0dHaBEgYiLZJ/t5dfX3JezVdoqSFFXrGfT4X1VyKd3Lf8hYs16zwtY5CxCrY6GMHdCjhDXKlf6E8/azXv/T7PC0WyTsifDz4SN/CJvBd1eApoVHF389Rf4uih8LFhSiUinkKhWgauomxIy8GIFx0alD6/Qjh3V6Mm/Es8ItutcG4ej/BCN+gedexe135zOBpKFW1SYT2Hw6n1/rrswHGdF1JrvHSQoU5qSwOMQS5w3WwHigs0hUuvoGiwJhtq/NnidMgrOfupE1BIjSnh/KnAeeqb7Dyi9n+WIvPDf8yTjDWiVna3Jk4ooQYzz36HcM3qGExRDppId5GnctPw/AiFbxYqknW</ds:X509Certificate></ds:X509Data></ds:KeyInfo><ds:Object><xades:QualifyingProperties xmlns:xades="http://uri.etsi.org/01903/v1.3.2#" Target="#xmldsig-5b38fead-4352-464f-b3b3-3f6cd5c9fbf9"><xades:SignedProperties Id="xmldsig-5b38fead-4352-464f-b3b3-3f6cd5c9fbf9-signedprops"><xades:SignedSignatureProperties><xades:SigningTime>2020-08-17T18:27:35+07:00</xades:SigningTime><xades:SigningCertificate><xades:Cert><xades:CertDigest><ds:DigestMethod Algorithm="http://www.w3.org/2001/04/xmlenc#sha512"/><ds:DigestValue>1r8x/T+ReH+ehYxWyrRkILA2U0whmsLYLrewFjuhiTGSLQX7RchTPF0eZJvhPihlaIGa7xBMzS58iILPkh71MA==</ds:DigestValue></xades:CertDigest><xades:IssuerSerial><ds:X509IssuerName>C=TH,O=Ministry of Information and Communication Technology,CN=TeDA CA for Testing</ds:X509IssuerName><ds:X509SerialNumber>8867190820250679169</ds:X509SerialNumber></xades:IssuerSerial></xades:Cert></xades:SigningCertificate></xades:SignedSignatureProperties></xades:SignedProperties></xades:QualifyingProperties></ds:Object></ds:Signature></rsm:TaxInvoice_CrossIndustryInvoice>`),
-			},
-			want: xades4go.ValidationResult{
-				ReferenceValidationResults: []xades4go.ReferenceValidationResult{
-					{
-						IsValid:              true,
-						GeneratedDigestValue: `y2/Zx52P9Ck3r1/Rb8Xn516CcuT8i4I57hPKWk++6rv8kmk0Azd+intm2yNgtVyKdHaRt/qAL4YWmgHu91Z7tQ==`,
-						DigestValue:          `y2/Zx52P9Ck3r1/Rb8Xn516CcuT8i4I57hPKWk++6rv8kmk0Azd+intm2yNgtVyKdHaRt/qAL4YWmgHu91Z7tQ==`,
-					},
-					{
-						IsValid:              true,
-						GeneratedDigestValue: `u/ejCCgofcQ7jpaZuyc6RAkd4CuEugPVFx31aFJ3iIEoRh4ZxDkryGHmmPvrQXAp/nEMp4GkcedrQLHJT7kZEA==`,
-						DigestValue:          `u/ejCCgofcQ7jpaZuyc6RAkd4CuEugPVFx31aFJ3iIEoRh4ZxDkryGHmmPvrQXAp/nEMp4GkcedrQLHJT7kZEA==`,
-					},
-				},
-				IsSignatureValid: true,
-			},
-			wantErr: false,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(name+": "+tt.name, func(t *testing.T) {
-			got, err := xmldsigSignatureValidator.Validate(tt.args.xmlBytes)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if diff := cmp.Diff(tt.want, got); diff != "" {
-				t.Errorf("Validate() result mismatch (-want+got):\n%s", diff)
-			}
-		})
-	}
-}
+<ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#" Id="xmldsig-5b38fead-4352-464f-b3b3-3f6cd5c9fbf9"><ds:SignedInfo><ds:CanonicalizationMethod Algorithm="http://www.w3.org/TR/2001/REC-xml-c14n-20010315"/><ds:SignatureMethod Algorithm="http://www.w3.org/2001/04/xmldsig-more#rsa-sha512"/><ds:Reference Id="xmldsig-5b38fead-4352-464f-b3b3-3f6cd5c9fbf9-ref0" URI=""><ds:Transforms><ds:Transform Algorithm="http://www.w3.org/2000/09/xmldsig#enveloped-signature"/><ds:Transform Algorithm="http://www.w3.org/TR/2001/REC-xml-c14n-20010315"/></ds:Transforms><ds:DigestMethod Algorithm="http://www.w3.org/2001/04/xmlenc#sha512"/><ds:DigestValue>y2/Zx52P9Ck3r1/Rb8Xn516CcuT8i4I57hPKWk++6rv8kmk0Azd+intm2yNgtVyKdHaRt/qAL4YWmgHu91Z7tQ==</ds:DigestValue></ds:Reference><ds:Reference Type="http://uri.etsi.org/01903#SignedProperties" URI="#xmldsig-5b38fead-4352-464f-b3b3-3f6cd5c9fbf9-signedprops"><ds:DigestMethod Algorithm="http://www.w3.org/2001/04/xmlenc#sha512"/><ds:DigestValue>u/ejCCgofcQ7jpaZuyc6RAkd4CuEugPVFx31aFJ3iIEoRh4ZxDkryGHmmPvrQXAp/nEMp4GkcedrQLHJT7kZEA==</ds:DigestValue></ds:Reference></ds:SignedInfo><ds:SignatureValue Id="xmldsig-5b38fead-4352-464f-b3b3-3f6cd5c9fbf9-sigvalue">BOxF2QGxUzpNcP5YcJ6IIMLLWWmrqEscAPE7a+yr/x3kgJnwMPWm4D3ae0F5zifA+OeZGhQjPZ9ctIZYssVUZtJNIBrHuTpFndZvL9H07//HWjJUOi7Gv8qeCRw1FsuSdlTew9TrucNH6zfCm5KQIGCkH2nplV3oNhvqewA6cjYW1nJmnAyfaaDcD0x7xl6dmgXQ9xv573eCFKP72GRzhwxr36llqLvaoJMHUGkq59wCYc7oMgj5d+vG5fSA6BXsfXPWxmp1gyeoa7UIPT62Pvy79RjH9WtmiQcUcm4/iA+fX0jCOXhKeJEOz15fhTCHQnWp4CYNjVBOzoh2xOhlEg==</ds:SignatureValue><ds:KeyInfo><ds:X509Data><ds:X509Certificate>MIIFrjCCA5agAwIBAgIIew6XEhSld4EwDQYJKoZIhvcNAQELBQAwgbUxCzAJBgNVBAYTAnRoMT0wOwYDVQQKDDRNaW5pc3RyeSBvZiBJbmZvcm1hdGlvbiBhbmQgQ29tbXVuaWNhdGlvbiBUZWNobm9sb2d5MUkwRwYDVQQLDEBFbGVjdHJvbmljIFRyYW5zYWN0aW9ucyBEZXZlbG9wbWVudCBBZ2VuY3kgKFB1YmxpYyBPcmdhbml6YXRpb24pMRwwGgYDVQQDDBNUZURBIENBIGZvciBUZXN0aW5nMB4XDTE5MDcwNDA1MDY1MFoXDTIyMDcwNDA1MDY1MFowZTELMAkGA1UEBhMCVEgxMzAxBgNVBAoMKkVsZWN0cm9uaWMgVHJhbnNhY3Rpb25zIERldmVsb3BtZW50IEFnZW5jeTEhMB8GA1UEAwwYQ29kZSBTaWduaW5nIENlcnRpZmljYXRlMIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEAoVWH2z88nV0+GuxpyHKZjNWxTv7syQqwL+FiF2KErwdI9rRSKFz5GyOhB5N6Nzjh0AflcfUGrOa+AbjNi+5MGUC3uL2ugo7jMXx2Rwp90aHhGU8jhE/Dx6pdUiQSd6ZLyCTYzIrb4okgDRzsJTe3pFfnM0ScspiU2GMRCMmQgPYWob6BFPgzqIcYK99f82CENg3PFlm4bUWTgvVgF0TTevjLvH8Dx8LvnORW05Hk+jHWbPQuHtarmubUowFv9N1EboBEbFAPxhOp67vctzuoDfOtLaC0unfkXBxzSpnpKg7ZDSEbT4C6hDMqXaAGZxRWtE6ggVQfjzICOs8ZhV+zkwIDAQABo4IBDzCCAQswVQYIKwYBBQUHAQEESTBHMEUGCCsGAQUFBzAChjlodHRwOi8vcmVwby10ZXN0LnRlZGEudGgvY2VydC9UZURBQ0Fmb3JUZXN0aW5nLmNhY2VydC5jcnQwHQYDVR0OBBYEFBnP8q2USQRJ1oTlTds9irSSivl7MAwGA1UdEwEB/wQCMAAwHwYDVR0jBBgwFoAUw7M9c+QxW38JHlzBhP71WNfouZQwQgYDVR0fBDswOTA3oDWgM4YxaHR0cDovL3JlcG8tdGVzdC50ZWRhLnRoL2NybC9UZURBQ0Fmb3JUZXN0aW5nLmNybDALBgNVHQ8EBAMCBPAwEwYDVR0lBAwwCgYIKwYBBQUHAwMwDQYJKoZIhvcNAQELBQADggIBACrFj0Ee4paSEBzmskqyLatVvbnDfUUfDMMkQrSGcD2l2lNaorAtcBZeVJTRMt+doJTNPwpAFbW3rbbAAX+PKAn5M8F2dcj0W/Q6dIw1pQyuRJIBgJ7BwXq7fwbEV3C1AUV3EXTGND4hz7LYRqCIuLi6ODdT3/HBQlEBQtNhKLBBciE81mWKvaQ1g/hAbPZOSDW7WBEw8Kjj1vbPS0lviar8TurRwbwDlYMk6NzpSGPJYUrxjYw54ZJx/1QngKGK6wsZiV0sj5JbbfxjTwWOhEl2LdulQJ8KNZv+ajQMZqtEeAreAHLyGSG6xgOpPV9aHP9LDTR/d5qi3JB5fwMOvEsWWvzoKzvilR6WO3hYL8qQi/Y4C7oYMkjxVBAALXi2PH4cZSA26SkR2gHQ8FMO1o+StqkBBjkrtdyhvr+PxijFSh25T3rLlAPBDCALSUPRdLg848k07CleGBzDDETNsFnUhiZXCzD6TWEKqdMVItzXCuCe+bCX8/wvsVC48chMdxjVHLR3P8csyK+tPS+Te9ipsI3ZgIoDWilNJhKMyaQbmI+zHFzBVVE9cVMkFsGOWh0lKscQA3k1CnhvfIsppyz/ZK6sj7/7Q5+is/4ay5vGhgSPXVhN0kCW5u+esGouVPJLMfqvwhh4V1a+9sQtsRDugqPhzk00/DrI0byUjl65</ds:X509Certificate><ds:X509Certificate>MIIGnjCCBIagAwIBAgIJAJb3gAGTprceMA0GCSqGSIb3DQEBDQUAMIG1MQswCQYDVQQGEwJ0aDE9MDsGA1UECgw0TWluaXN0cnkgb2YgSW5mb3JtYXRpb24gYW5kIENvbW11bmljYXRpb24gVGVjaG5vbG9neTFJMEcGA1UECwxARWxlY3Ryb25pYyBUcmFuc2FjdGlvbnMgRGV2ZWxvcG1lbnQgQWdlbmN5IChQdWJsaWMgT3JnYW5pemF0aW9uKTEcMBoGA1UEAwwTVGVEQSBDQSBmb3IgVGVzdGluZzAeFw0xNDAzMTgwNTQ0MTJaFw0zNDAzMTMwNTQ0MTJaMIG1MQswCQYDVQQGEwJ0aDE9MDsGA1UECgw0TWluaXN0cnkgb2YgSW5mb3JtYXRpb24gYW5kIENvbW11bmljYXRpb24gVGVjaG5vbG9neTFJMEcGA1UECwxARWxlY3Ryb25pYyBUcmFuc2FjdGlvbnMgRGV2ZWxvcG1lbnQgQWdlbmN5IChQdWJsaWMgT3JnYW5pemF0aW9uKTEcMBoGA1UEAwwTVGVEQSBDQSBmb3IgVGVzdGluZzCCAiIwDQYJKoZIhvcNAQEBBQADggIPADCCAgoCggIBAMQJXv8fjahmK4hXC6mVSexeDNXa0XYnjeOueZmEpGydRh+b/dIMxcEUPdZm6zs3Y+IkDVma8OovRigLMk8XapcKcEsTwdliy5wTgiLtfJEDjUMxuC9RbvIoIcOHlz+Vv4iHlqOL4fab5dXWFQ5E8j2EfZO3HMm55KTIFSMSRJSPUysw3p65EddckQ5SrWB0JoQoRaj57oguXZXxZVLcvLRtHbpggF12Jx+B2kOdcrxoK+NPVowmD2CZmOlTAC9suB3gB6f7JiHYBSuh2O75K+Or5At5q4tjVcbgAvMAkWjjor+DB9QZJxtAGC9Xa+lMJko9DBWXjSkXTwAmTP/ubVaD9szexAMDCROZGbFv7qfnxX3qFfCvIYkFmCRi+gmgInb7SOIJfTr5hta5JEHHFK/6dL6RFHM3EgZEEQcOZzyYVpe1WckKJjfiOmGgh9HyaT0Ey8hRXHo1DxuCrwEL0or9Hedle6j17WB6iWh1Uc0o9Qof8XCyV3y+NUf0KmHC9bze6sG3C5v+cwo8hBjSWK5J8452d6XQ+/tHJQpFPlaCNrss1voJgaenn3u6ZpGDn5VANBnObgxB8RucQpvEaOd1UP+F0scQSMomtg3WE5tGzOX/EnGfv3cd2qubPkAX+IwFXsEgUoCvgUAXkj/VwfcxuNzq3DbTKGFoqot6zI43AgMBAAGjga4wgaswHQYDVR0OBBYEFMOzPXPkMVt/CR5cwYT+9VjX6LmUMB8GA1UdIwQYMBaAFMOzPXPkMVt/CR5cwYT+9VjX6LmUMAwGA1UdEwQFMAMBAf8wFQYDVR0gBA4wDDAKBghghXwBBAQBZTALBgNVHQ8EBAMCAQYwNwYDVR0fBDAwLjAsoCqgKIYmaHR0cDovL2xhYnRvcmVhbC5jb20vdGVkYWNhL3RlZGFDQS5jcmwwDQYJKoZIhvcNAQENBQADggIBAIdQlz1S09lH4YBqmCDcCS4O4XGK0+L8fIzum0k71C9bTY+JD1Ck2EZ0Ozy34hQrfjrfO4qAwkzxs9r3KVMrYFBsVGRkfYk2jXSwJMDT63L+NoEwZQ3+8Z+pxOF3vxPWfklRg9nJ0KeOWxjm4tqWUpaFrTLF7r/K0DRgq4xHaZm3d+iAAwsmWX0XHWgurmkqXYgiXUB9qGyaXP8JeKhYXi8OEIAgE/TiqXbG3caTZn9ESAx26WDDzX863mowIsRIjUuvZzoM66DVJ+6CuiE5m2GyWrJu+TCiyGtvsvgWPdoowBwTwu816OcIcWEL3RUEVy5vuuPYlMZm/udA0dHaBEgYiLZJ/t5dfX3JezVdoqSFFXrGfT4X1VyKd3Lf8hYs16zwtY5CxCrY6GMHdCjhDXKlf6E8/azXv/T7PC0WyTsifDz4SN/CJvBd1eApoVHF389Rf4uih8LFhSiUinkKhWgauomxIy8GIFx0alD6/Qjh3V6Mm/Es8ItutcG4ej/BCN+gedexe135zOBpKFW1SYT2Hw6n1/rrswHGdF1JrvHSQoU5qSwOMQS5w3WwHigs0hUuvoGiwJhtq/NnidMgrOfupE1BIjSnh/KnAeeqb7Dyi9n+WIvPDf8yTjDWiVna3Jk4ooQYzz36HcM3qGExRDppId5GnctPw/AiFbxYqknW</ds:X509Certificate></ds:X509Data></ds:KeyInfo><ds:Object><xades:QualifyingProperties xmlns:xades="http://uri.etsi.org/01903/v1.3.2#" Target="#xmldsig-5b38fead-4352-464f-b3b3-3f6cd5c9fbf9"><xades:SignedProperties Id="xmldsig-5b38fead-4352-464f-b3b3-3f6cd5c9fbf9-signedprops"><xades:SignedSignatureProperties><xades:SigningTime>2020-08-17T18:27:35+07:00</xades:SigningTime><xades:SigningCertificate><xades:Cert><xades:CertDigest><ds:DigestMethod Algorithm="http://www.w3.org/2001/04/xmlenc#sha512"/><ds:DigestValue>1r8x/T+ReH+ehYxWyrRkILA2U0whmsLYLrewFjuhiTGSLQX7RchTPF0eZJvhPihlaIGa7xBMzS58iILPkh71MA==</ds:DigestValue></xades:CertDigest><xades:IssuerSerial><ds:X509IssuerName>C=TH,O=Ministry of Information and Communication Technology,CN=TeDA CA for Testing</ds:X509IssuerName><ds:X509SerialNumber>8867190820250679169</ds:X509SerialNumber></xades:IssuerSerial></xades:Cert></xades:SigningCertificate></xades:SignedSignatureProperties></xades:SignedProperties></xades:QualifyingProperties></ds:Object></ds:Signature></rsm:TaxInvoice_CrossIndustryInvoice>`